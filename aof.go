@@ -0,0 +1,627 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const CONFIG_APPENDFSYNC = "appendfsync"
+const CONFIG_AUTO_AOF_REWRITE_PERCENTAGE = "auto-aof-rewrite-percentage"
+const CONFIG_AUTO_AOF_REWRITE_MIN_SIZE = "auto-aof-rewrite-min-size"
+
+// Policies telling when the append only file is flushed to disk, as in
+// Redis: after every write, once per second, or whenever the OS decides
+const APPENDFSYNC_ALWAYS = "always"
+const APPENDFSYNC_EVERYSEC = "everysec"
+const APPENDFSYNC_NO = "no"
+
+const APPENDFSYNC_INTERVAL = time.Second
+
+const AOF_FILE = "xredis_appendonly.aof"
+
+const AOF_ERROR_CORRUPTED = "corrupted append only file"
+
+// As in Redis, the append only file is rewritten once it doubled in size
+// since the last rewrite, provided that it holds at least 64MB
+const AUTO_AOF_REWRITE_DEFAULT_PERCENTAGE = 100
+const AUTO_AOF_REWRITE_DEFAULT_MIN_SIZE = 64 << 20
+
+const AOF_REWRITE_TEMP_FILE_PATTERN = "temp-rewriteaof-*.aof"
+
+// Requests written by a rewrite are compressed and encrypted in frames of
+// about this many bytes
+const AOF_REWRITE_FRAME_SIZE = 1 << 20
+
+// AppendOnlyFile logs every write request applied to the databases, in the
+// order they were applied, so that they can be replayed on startup. It is
+// owned by the commands goroutine, only enabled and writeFailed being read
+// by the others.
+//
+// As in Redis, the write requests are refused once a write to the file
+// failed, until the requests left pending are written by a later attempt.
+type AppendOnlyFile struct {
+	file            *os.File
+	path            string
+	db              int    // Database selected by the last request logged, -1 before any
+	pending         []byte // Requests logged but not written to the file yet
+	unsynced        bool   // Set when requests were written since the last fsync
+	size            int64  // Size of the file in bytes
+	rewriteBaseSize int64  // Size of the file after the last rewrite, or when it was opened
+	rewrite         *AppendOnlyRewrite
+	transforms      FileTransforms // Transforms of the file, which are applied to every write
+	frames          uint64         // Number of frames of the file with transforms, numbering the next one
+	stopFsync       chan struct{}
+	enabled         atomic.Bool
+	writeFailed     atomic.Bool
+}
+
+// AppendOnlyLogEntry is a write request logged along with the database it
+// was applied to.
+type AppendOnlyLogEntry struct {
+	db      int
+	request RespArray
+}
+
+// AppendOnlyRewrite is a rewrite of the append only file in progress. The
+// requests logged while the snapshot is being written are buffered, to be
+// appended to the new file before it replaces the current one.
+type AppendOnlyRewrite struct {
+	buffer     []byte
+	db         int            // Database selected by the last request buffered, -1 before any
+	transforms FileTransforms // Transforms of the new file
+}
+
+func NewAppendOnlyFile() *AppendOnlyFile {
+	return &AppendOnlyFile{db: -1}
+}
+
+// OpenAppendOnlyFile starts logging the write requests at the end of the
+// file at path, creating it if needed. The new files are compressed and
+// encrypted as configured, while the existing ones keep their transforms
+// until they are rewritten.
+func (xredis *XRedis) OpenAppendOnlyFile(path string) error {
+	errorChan := make(chan error)
+	xredis.commands <- OpenAppendOnlyFileCommand{path, errorChan}
+	return <-errorChan
+}
+
+// CloseAppendOnlyFile flushes the append only file to disk and stops
+// logging the write requests.
+func (xredis *XRedis) CloseAppendOnlyFile() error {
+	errorChan := make(chan error)
+	xredis.commands <- CloseAppendOnlyFileCommand{errorChan}
+	return <-errorChan
+}
+
+// AppendOnlyEnabled tells whether the write requests are being logged
+func (xredis *XRedis) AppendOnlyEnabled() bool {
+	return xredis.aof.enabled.Load()
+}
+
+// AppendOnlyWriteFailed tells whether the last write to the append only file
+// failed, in which case the write requests must be refused.
+func (xredis *XRedis) AppendOnlyWriteFailed() bool {
+	return xredis.aof.enabled.Load() && xredis.aof.writeFailed.Load()
+}
+
+// RewriteAppendOnlyFile starts rewriting, in the background, the append
+// only file with the fewest requests that rebuild the current databases.
+func (xredis *XRedis) RewriteAppendOnlyFile() error {
+	errorChan := make(chan error)
+	xredis.commands <- RewriteAppendOnlyFileCommand{errorChan}
+	return <-errorChan
+}
+
+// AppendOnlyRewriteInProgress tells whether the append only file is being
+// rewritten.
+func (xredis *XRedis) AppendOnlyRewriteInProgress() bool {
+	rspChan := make(chan bool)
+	xredis.commands <- AppendOnlyRewriteInProgressCommand{rspChan}
+	return <-rspChan
+}
+
+// AppendToLog logs a write request to the selected database. It must be
+// called in the same transaction the request was applied in, so that the
+// log holds the requests in the order they were applied. It fails if the
+// request couldn't be written to the file.
+func (xredis *XRedis) AppendToLog(request RespArray) error {
+	errorChan := make(chan error)
+	xredis.commands <- AppendToLogCommand{[]AppendOnlyLogEntry{{xredis.db, request}}, false, errorChan}
+	return <-errorChan
+}
+
+// AppendTransactionToLog logs the write requests applied by EXEC between
+// MULTI and EXEC, so that replaying the file applies all of them or none.
+func (xredis *XRedis) AppendTransactionToLog(entries []AppendOnlyLogEntry) error {
+	errorChan := make(chan error)
+	xredis.commands <- AppendToLogCommand{entries, len(entries) > 1, errorChan}
+	return <-errorChan
+}
+
+func (xredis *XRedis) handleOpenAppendOnlyFileCommand(cmd OpenAppendOnlyFileCommand) {
+	defer close(cmd.errorChannel)
+
+	if xredis.aof.enabled.Load() {
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_AOF_ALREADY_ENABLED)
+		return
+	}
+	file, err := os.OpenFile(cmd.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		cmd.errorChannel <- err
+		return
+	}
+	transforms := xredis.config.fileTransforms()
+	var size int64
+	var frames uint64
+	info, err := file.Stat()
+	if err == nil {
+		size = info.Size()
+	}
+	if err == nil && size > 0 {
+		transforms, frames, err = readFileTransforms(cmd.path, xredis.config.fileDecryption())
+	} else if err == nil && transforms.enabled() {
+		var written int
+		written, err = file.Write(transforms.header())
+		size = int64(written)
+	}
+	if err != nil {
+		file.Close()
+		cmd.errorChannel <- err
+		return
+	}
+	xredis.aof.file = file
+	xredis.aof.path = cmd.path
+	xredis.aof.transforms = transforms
+	xredis.aof.frames = frames
+	xredis.aof.db = -1
+	xredis.aof.pending = nil
+	xredis.aof.writeFailed.Store(false)
+	xredis.aof.size = size
+	xredis.aof.rewriteBaseSize = size
+	xredis.aof.stopFsync = make(chan struct{})
+	xredis.aof.enabled.Store(true)
+	go xredis.fsyncEverySecond(xredis.aof.stopFsync)
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleCloseAppendOnlyFileCommand(cmd CloseAppendOnlyFileCommand) {
+	defer close(cmd.errorChannel)
+	cmd.errorChannel <- xredis.closeAppendOnlyFile()
+}
+
+func (xredis *XRedis) closeAppendOnlyFile() error {
+	if !xredis.aof.enabled.Load() {
+		return nil
+	}
+	close(xredis.aof.stopFsync)
+	xredis.aof.enabled.Store(false)
+	// A rewrite still in progress is discarded once it completes
+	xredis.aof.rewrite = nil
+	err := xredis.writeAppendOnlyFile()
+	if syncErr := xredis.aof.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := xredis.aof.file.Close(); err == nil {
+		err = closeErr
+	}
+	xredis.aof.file = nil
+	return err
+}
+
+// logExpiredKey logs a DEL of the key that just expired, as Redis does. Keys
+// don't expire while the file is replayed, so the requests applied after
+// the expiration would otherwise be replayed on the expired value. The DEL
+// is written along with the next requests, or by the next fsync.
+func (xredis *XRedis) logExpiredKey(db int, key string) {
+	if !xredis.aof.enabled.Load() {
+		return
+	}
+	entries := []AppendOnlyLogEntry{{db, stringsToRespArray([]string{REQUEST_DELETE, key})}}
+	xredis.aof.pending, xredis.aof.db = appendOnlyEntries(xredis.aof.pending, xredis.aof.db, entries, false)
+	if rewrite := xredis.aof.rewrite; rewrite != nil {
+		rewrite.buffer, rewrite.db = appendOnlyEntries(rewrite.buffer, rewrite.db, entries, false)
+	}
+}
+
+// handleAppendToLogCommand queues the requests after those still pending
+// and writes them all to the file, or lets the next fsync do it for the
+// files with transforms. The requests are queued even when the write
+// fails, since they were applied already, to be written by the next
+// attempt.
+func (xredis *XRedis) handleAppendToLogCommand(cmd AppendToLogCommand) {
+	defer close(cmd.errorChannel)
+
+	if !xredis.aof.enabled.Load() {
+		cmd.errorChannel <- nil
+		return
+	}
+	xredis.aof.pending, xredis.aof.db = appendOnlyEntries(xredis.aof.pending, xredis.aof.db, cmd.entries, cmd.transaction)
+	// The files with transforms get a frame per second, written along with
+	// the fsync, rather than a frame per write, unless every write must be
+	// flushed to disk
+	var err error
+	if !xredis.aof.transforms.enabled() || xredis.config.appendFsync == APPENDFSYNC_ALWAYS {
+		err = xredis.writeAppendOnlyFile()
+	}
+	if err == nil && xredis.config.appendFsync == APPENDFSYNC_ALWAYS {
+		xredis.fsyncAppendOnlyFile()
+	}
+
+	if rewrite := xredis.aof.rewrite; rewrite != nil {
+		rewrite.buffer, rewrite.db = appendOnlyEntries(rewrite.buffer, rewrite.db, cmd.entries, cmd.transaction)
+	} else if err == nil && xredis.appendOnlyFileGrown() {
+		log.Printf("Starting automatic rewrite of the append only file, which grew to %d bytes", xredis.aof.size)
+		xredis.startAppendOnlyRewrite()
+	}
+	if err != nil {
+		err = errors.New(REQUEST_ERROR_AOF_WRITE_FAILED)
+	}
+	cmd.errorChannel <- err
+}
+
+// writeAppendOnlyFile writes the pending requests to the file, in a single
+// frame for the files with transforms. A failed write is undone, so that
+// the file doesn't end with part of a request which would hide the ones
+// written after it, and the requests are kept pending.
+func (xredis *XRedis) writeAppendOnlyFile() error {
+	if len(xredis.aof.pending) == 0 {
+		return nil
+	}
+	data := xredis.aof.pending
+	var err error
+	if xredis.aof.transforms.enabled() {
+		data, err = xredis.aof.transforms.appendFrame(nil, data, xredis.aof.frames, false)
+	}
+	if err == nil {
+		_, err = xredis.aof.file.Write(data)
+	}
+	if err != nil {
+		if truncateErr := xredis.aof.file.Truncate(xredis.aof.size); truncateErr != nil {
+			log.Println("Failed truncating the append only file after a failed write: ", truncateErr)
+		}
+		if !xredis.aof.writeFailed.Swap(true) {
+			log.Println("Failed writing to the append only file, refusing writes until it succeeds: ", err)
+		}
+		return err
+	}
+	xredis.aof.size += int64(len(data))
+	if xredis.aof.transforms.enabled() {
+		xredis.aof.frames++
+	}
+	xredis.aof.pending = xredis.aof.pending[:0]
+	xredis.aof.unsynced = true
+	if xredis.aof.writeFailed.Swap(false) {
+		log.Println("Wrote to the append only file again, accepting writes")
+	}
+	return nil
+}
+
+func (xredis *XRedis) handleRewriteAppendOnlyFileCommand(cmd RewriteAppendOnlyFileCommand) {
+	defer close(cmd.errorChannel)
+
+	if !xredis.aof.enabled.Load() {
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_AOF_DISABLED)
+		return
+	}
+	if xredis.aof.rewrite != nil {
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_AOF_REWRITE_IN_PROGRESS)
+		return
+	}
+	xredis.startAppendOnlyRewrite()
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleAppendOnlyRewriteInProgressCommand(cmd AppendOnlyRewriteInProgressCommand) {
+	defer close(cmd.rspChannel)
+	cmd.rspChannel <- xredis.aof.rewrite != nil
+}
+
+// handleAppendOnlyRewriteDoneCommand appends the requests buffered during
+// the rewrite to the new file, which then atomically replaces the current
+// one. The new file is dropped if the rewrite failed or was discarded.
+func (xredis *XRedis) handleAppendOnlyRewriteDoneCommand(cmd AppendOnlyRewriteDoneCommand) {
+	if cmd.rewrite != xredis.aof.rewrite {
+		log.Println("Discarding the rewrite of the append only file, which was closed")
+		os.Remove(cmd.path)
+		return
+	}
+	xredis.aof.rewrite = nil
+	if cmd.err != nil {
+		log.Println("Failed rewriting the append only file: ", cmd.err)
+		os.Remove(cmd.path)
+		return
+	}
+
+	buffer := cmd.rewrite.buffer
+	frames := cmd.frames
+	var err error
+	if cmd.rewrite.transforms.enabled() && len(buffer) > 0 {
+		buffer, err = cmd.rewrite.transforms.appendFrame(nil, buffer, frames, false)
+		frames++
+	}
+	var file *os.File
+	if err == nil {
+		file, err = os.OpenFile(cmd.path, os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	if err == nil {
+		_, err = file.Write(buffer)
+		if err == nil {
+			err = file.Sync()
+		}
+		if err == nil {
+			err = os.Rename(cmd.path, xredis.aof.path)
+		}
+		if err != nil {
+			file.Close()
+		}
+	}
+	if err != nil {
+		log.Println("Failed replacing the append only file: ", err)
+		os.Remove(cmd.path)
+		return
+	}
+
+	if err := syncDir(filepath.Dir(xredis.aof.path)); err != nil {
+		log.Println("Failed syncing the directory of the append only file: ", err)
+	}
+	xredis.aof.file.Close()
+	xredis.aof.file = file
+	xredis.aof.transforms = cmd.rewrite.transforms
+	xredis.aof.frames = frames
+	xredis.aof.db = cmd.rewrite.db
+	// The requests left pending were applied before the rewrite ended, so
+	// they are in its snapshot or buffer already
+	xredis.aof.pending = nil
+	xredis.aof.writeFailed.Store(false)
+	xredis.aof.size = cmd.size + int64(len(buffer))
+	xredis.aof.rewriteBaseSize = xredis.aof.size
+	xredis.aof.unsynced = false
+	log.Printf("Rewrote the append only file, down to %d bytes", xredis.aof.size)
+}
+
+// appendOnlyFileGrown tells whether the append only file grew enough since
+// the last rewrite to be rewritten automatically.
+func (xredis *XRedis) appendOnlyFileGrown() bool {
+	percentage := xredis.config.autoAofRewritePercentage
+	if percentage == 0 || xredis.aof.size < xredis.config.autoAofRewriteMinSize {
+		return false
+	}
+	baseSize := max(xredis.aof.rewriteBaseSize, 1)
+	return (xredis.aof.size-baseSize)*100/baseSize >= int64(percentage)
+}
+
+// startAppendOnlyRewrite captures the databases, which are then written
+// to a temporary file by another goroutine so that requests keep being
+// processed meanwhile.
+func (xredis *XRedis) startAppendOnlyRewrite() {
+	rewrite := &AppendOnlyRewrite{db: -1, transforms: xredis.config.fileTransforms()}
+	xredis.aof.rewrite = rewrite
+	dir := filepath.Dir(xredis.aof.path)
+	xredis.startCapture(func(databases []map[string]XRedisValue) {
+		go func() {
+			path, size, frames, err := writeAppendOnlySnapshot(dir, rewrite.transforms, databases)
+			xredis.commands <- AppendOnlyRewriteDoneCommand{rewrite, path, size, frames, err}
+		}()
+	})
+}
+
+// handleAppendOnlyFsyncCommand writes the requests left pending, by a failed
+// write or gathered into a frame for the files with transforms, and flushes
+// the file to disk with the everysec policy.
+func (xredis *XRedis) handleAppendOnlyFsyncCommand(cmd AppendOnlyFsyncCommand) {
+	if !xredis.aof.enabled.Load() || xredis.writeAppendOnlyFile() != nil {
+		return
+	}
+	if xredis.config.appendFsync == APPENDFSYNC_EVERYSEC {
+		xredis.fsyncAppendOnlyFile()
+	}
+}
+
+func (xredis *XRedis) fsyncAppendOnlyFile() {
+	if !xredis.aof.unsynced {
+		return
+	}
+	if err := xredis.aof.file.Sync(); err != nil {
+		log.Println("Failed syncing the append only file: ", err)
+		return
+	}
+	xredis.aof.unsynced = false
+}
+
+// writeAppendOnlySnapshot writes the requests rebuilding the databases to
+// a new temporary file in dir, with the transforms applied, returning its
+// path, its size and its number of frames.
+func writeAppendOnlySnapshot(dir string, transforms FileTransforms, databases []map[string]XRedisValue) (string, int64, uint64, error) {
+	file, err := os.CreateTemp(dir, AOF_REWRITE_TEMP_FILE_PATTERN)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	size := 0
+	frames := uint64(0)
+	write := func(data []byte) error {
+		if transforms.enabled() {
+			var err error
+			if data, err = transforms.appendFrame(nil, data, frames, false); err != nil {
+				return err
+			}
+			frames++
+		}
+		written, err := writer.Write(data)
+		size += written
+		return err
+	}
+	if transforms.enabled() {
+		written, err := writer.Write(transforms.header())
+		if err != nil {
+			return file.Name(), 0, 0, err
+		}
+		size += written
+	}
+	var pending []byte
+	for db, database := range databases {
+		lastDB := -1
+		for key, value := range database {
+			request, err := appendOnlyRestoreRequest(key, value)
+			if err != nil {
+				return file.Name(), 0, 0, err
+			}
+			pending = append(pending, appendOnlyEntry(db, lastDB, request)...)
+			lastDB = db
+			if len(pending) >= AOF_REWRITE_FRAME_SIZE {
+				if err := write(pending); err != nil {
+					return file.Name(), 0, 0, err
+				}
+				pending = pending[:0]
+			}
+		}
+	}
+	if len(pending) > 0 {
+		if err := write(pending); err != nil {
+			return file.Name(), 0, 0, err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return file.Name(), 0, 0, err
+	}
+	return file.Name(), int64(size), frames, file.Sync()
+}
+
+// readFileTransforms returns the transforms listed in the header of the
+// existing file at path, along with its number of complete frames.
+func readFileTransforms(path string, decryption FileDecryption) (FileTransforms, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileTransforms{}, 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	header, err := reader.Peek(FILE_HEADER_MAX_SIZE)
+	if err != nil && err != io.EOF {
+		return FileTransforms{}, 0, err
+	}
+	transforms, headerSize, err := parseFileHeader(header, decryption)
+	if err != nil || headerSize == 0 {
+		return transforms, 0, err
+	}
+	reader.Discard(headerSize)
+	frames, err := countFileFrames(reader)
+	return transforms, frames, err
+}
+
+// appendOnlyRestoreRequest returns the request that rebuilds the value:
+// strings are SET, as in Redis, while the other types, which lack a request
+// rebuilding them in one go, are serialized for RESTORE.
+func appendOnlyRestoreRequest(key string, value XRedisValue) (RespArray, error) {
+	expires := value.ExpirationTimestampMillis != NON_EXPIRATION_TIME
+	expirationTime := strconv.FormatInt(value.ExpirationTimestampMillis, 10)
+	switch element := value.Element.(type) {
+	case RespString, RespInt:
+		request := []string{REQUEST_SET, key, decodeStringValue(element).(RespString).Str}
+		if expires {
+			request = append(request, EXPIRATION_MODE_TIMESTAMP_MILLISECONDS, expirationTime)
+		}
+		return stringsToRespArray(request), nil
+	}
+
+	payload, err := dumpValue(value.Element)
+	if err != nil {
+		return RespArray{}, err
+	}
+	request := []string{REQUEST_RESTORE, key, "0", string(payload)}
+	if expires {
+		request = []string{REQUEST_RESTORE, key, expirationTime, string(payload), RESTORE_OPTION_ABSTTL}
+	}
+	return stringsToRespArray(request), nil
+}
+
+// appendOnlyEntries appends the entries to data, after a request targeting
+// lastDB, returning the database targeted by the last one. The requests of
+// a transaction are wrapped in MULTI and EXEC, as Redis does.
+func appendOnlyEntries(data []byte, lastDB int, entries []AppendOnlyLogEntry, transaction bool) ([]byte, int) {
+	if transaction {
+		data = append(data, stringsToRespArray([]string{REQUEST_MULTI}).serialize()...)
+	}
+	for _, entry := range entries {
+		data = append(data, appendOnlyEntry(entry.db, lastDB, entry.request)...)
+		lastDB = entry.db
+	}
+	if transaction {
+		data = append(data, stringsToRespArray([]string{REQUEST_EXEC}).serialize()...)
+	}
+	return data, lastDB
+}
+
+// appendOnlyEntry serializes the request to be logged after another one
+// targeting lastDB. A SELECT is logged first whenever the request targets
+// another database, as Redis does.
+func appendOnlyEntry(db int, lastDB int, request RespArray) []byte {
+	var data []byte
+	if db != lastDB {
+		data = append(data, stringsToRespArray([]string{REQUEST_SELECT, strconv.Itoa(db)}).serialize()...)
+	}
+	return append(data, request.serialize()...)
+}
+
+// fsyncEverySecond asks the commands goroutine to flush the append only
+// file every second, which it only does with the everysec policy, until
+// stop is closed.
+func (xredis *XRedis) fsyncEverySecond(stop chan struct{}) {
+	ticker := time.NewTicker(APPENDFSYNC_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case xredis.commands <- AppendOnlyFsyncCommand{}:
+			case <-stop:
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+type OpenAppendOnlyFileCommand struct {
+	path         string
+	errorChannel chan error
+}
+
+type CloseAppendOnlyFileCommand struct {
+	errorChannel chan error
+}
+
+type AppendToLogCommand struct {
+	entries      []AppendOnlyLogEntry
+	transaction  bool // Set to wrap the entries in MULTI and EXEC
+	errorChannel chan error
+}
+
+type AppendOnlyFsyncCommand struct {
+}
+
+type RewriteAppendOnlyFileCommand struct {
+	errorChannel chan error
+}
+
+type AppendOnlyRewriteInProgressCommand struct {
+	rspChannel chan bool
+}
+
+type AppendOnlyRewriteDoneCommand struct {
+	rewrite *AppendOnlyRewrite
+	path    string // Temporary file holding the snapshot
+	size    int64
+	frames  uint64
+	err     error
+}
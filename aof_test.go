@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendToLogSelectsTheDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	assert.Nil(t, xredis.OpenAppendOnlyFile(path))
+	assert.True(t, xredis.AppendOnlyEnabled())
+
+	xredis.AppendToLog(stringsToRespArray([]string{"SET", "a", "1"}))
+	xredis.AppendToLog(stringsToRespArray([]string{"SET", "b", "2"}))
+	db1, _ := xredis.Select(1)
+	db1.AppendToLog(stringsToRespArray([]string{"DEL", "a"}))
+	assert.Nil(t, xredis.CloseAppendOnlyFile())
+	assert.False(t, xredis.AppendOnlyEnabled())
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "*2\r\n$6\r\nSELECT\r\n$1\r\n0\r\n"+
+		"*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n"+
+		"*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n"+
+		"*2\r\n$6\r\nSELECT\r\n$1\r\n1\r\n"+
+		"*2\r\n$3\r\nDEL\r\n$1\r\na\r\n", string(data))
+}
+
+func TestAppendToLogWithoutAppendOnlyFile(t *testing.T) {
+	xredis := NewXRedis()
+	assert.False(t, xredis.AppendOnlyEnabled())
+	xredis.AppendToLog(stringsToRespArray([]string{"SET", "a", "1"}))
+	assert.Nil(t, xredis.CloseAppendOnlyFile())
+}
+
+func TestOpenAppendOnlyFileTwice(t *testing.T) {
+	xredis := NewXRedis()
+	assert.Nil(t, xredis.OpenAppendOnlyFile(filepath.Join(t.TempDir(), AOF_FILE)))
+	defer xredis.CloseAppendOnlyFile()
+
+	err := xredis.OpenAppendOnlyFile(filepath.Join(t.TempDir(), AOF_FILE))
+	assert.Equal(t, REQUEST_ERROR_AOF_ALREADY_ENABLED, err.Error())
+}
+
+func TestAppendFsyncConfig(t *testing.T) {
+	xredis := NewXRedis()
+	assert.Equal(t, []string{CONFIG_APPENDFSYNC, APPENDFSYNC_EVERYSEC}, xredis.ConfigGet(CONFIG_APPENDFSYNC))
+
+	assert.Nil(t, xredis.ConfigSet(map[string]string{CONFIG_APPENDFSYNC: "ALWAYS"}))
+	assert.Equal(t, []string{CONFIG_APPENDFSYNC, APPENDFSYNC_ALWAYS}, xredis.ConfigGet(CONFIG_APPENDFSYNC))
+	err := xredis.ConfigSet(map[string]string{CONFIG_APPENDFSYNC: "sometimes"})
+	assert.Equal(t, REQUEST_ERROR_INVALID_CONFIG_VALUE, err.Error())
+}
+
+func TestRewriteAppendOnlyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	assert.Nil(t, xredis.OpenAppendOnlyFile(path))
+	client := NewClient(xredis)
+	for range 100 {
+		_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	}
+	_ = handleRequest(client, []byte("*5\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n$2\r\nEX\r\n$3\r\n100\r\n"))
+	_ = handleRequest(client, []byte("*4\r\n$5\r\nXADD\r\n$6\r\nstream\r\n$1\r\n*\r\n$1\r\nf\r\n"))
+	_ = handleRequest(client, []byte("*5\r\n$4\r\nXADD\r\n$6\r\nstream\r\n$1\r\n*\r\n$1\r\nf\r\n$1\r\nv\r\n"))
+	sizeBefore := fileSize(path)
+
+	// Writes applied while the snapshot is being written are buffered
+	// and appended to the rewritten file
+	xredis.Atomically(func(tx *XRedis) {
+		assert.Nil(t, tx.RewriteAppendOnlyFile())
+		assert.Equal(t, REQUEST_ERROR_AOF_REWRITE_IN_PROGRESS, tx.RewriteAppendOnlyFile().Error())
+		db1, _ := tx.Select(1)
+		db1.Set("buffered", RespString{"1"})
+		db1.AppendToLog(stringsToRespArray([]string{REQUEST_SET, "buffered", "1"}))
+	})
+	assert.Eventually(t, func() bool { return !xredis.AppendOnlyRewriteInProgress() }, time.Second, time.Millisecond)
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	assert.Nil(t, xredis.CloseAppendOnlyFile())
+	assert.Less(t, fileSize(path), sizeBefore)
+
+	replayed := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.Equal(t, RespString{"101"}, replayed.Get("counter"))
+	assert.Equal(t, RespString{"value"}, replayed.Get("key"))
+	assert.Equal(t, xredis.databases[DEFAULT_DATABASE]["key"], replayed.databases[DEFAULT_DATABASE]["key"])
+	assert.Equal(t, xredis.Get("stream"), replayed.Get("stream"))
+	replayedDB1, _ := replayed.Select(1)
+	assert.Equal(t, RespString{"1"}, replayedDB1.Get("buffered"))
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(t, entries, 1)
+}
+
+func TestRewriteAppendOnlyFileAutomatically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	assert.Nil(t, xredis.ConfigSet(map[string]string{CONFIG_AUTO_AOF_REWRITE_MIN_SIZE: "1kb"}))
+	assert.Nil(t, xredis.OpenAppendOnlyFile(path))
+	defer xredis.CloseAppendOnlyFile()
+	client := NewClient(xredis)
+
+	increment := "*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"
+	for range 100 {
+		_ = handleRequest(client, []byte(increment))
+	}
+	assert.Eventually(t, func() bool { return !xredis.AppendOnlyRewriteInProgress() }, time.Second, time.Millisecond)
+	assert.Less(t, fileSize(path), int64(100*len(increment)))
+	replayed := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.Equal(t, RespString{"100"}, replayed.Get("counter"))
+}
+
+func TestRewriteWithoutAppendOnlyFile(t *testing.T) {
+	err := NewXRedis().RewriteAppendOnlyFile()
+	assert.Equal(t, REQUEST_ERROR_AOF_DISABLED, err.Error())
+}
+
+func TestAutoAofRewriteConfig(t *testing.T) {
+	xredis := NewXRedis()
+
+	assert.Nil(t, xredis.ConfigSet(map[string]string{CONFIG_AUTO_AOF_REWRITE_MIN_SIZE: "2MB"}))
+	assert.Equal(t, []string{CONFIG_AUTO_AOF_REWRITE_MIN_SIZE, "2097152"}, xredis.ConfigGet(CONFIG_AUTO_AOF_REWRITE_MIN_SIZE))
+	assert.Nil(t, xredis.ConfigSet(map[string]string{CONFIG_AUTO_AOF_REWRITE_MIN_SIZE: "3k"}))
+	assert.Equal(t, []string{CONFIG_AUTO_AOF_REWRITE_MIN_SIZE, "3000"}, xredis.ConfigGet(CONFIG_AUTO_AOF_REWRITE_MIN_SIZE))
+	assert.Nil(t, xredis.ConfigSet(map[string]string{CONFIG_AUTO_AOF_REWRITE_PERCENTAGE: "0"}))
+	assert.Equal(t, []string{CONFIG_AUTO_AOF_REWRITE_PERCENTAGE, "0"}, xredis.ConfigGet(CONFIG_AUTO_AOF_REWRITE_PERCENTAGE))
+
+	for _, invalid := range []string{"", "mb", "-1", "1tb", "99999999999gb"} {
+		err := xredis.ConfigSet(map[string]string{CONFIG_AUTO_AOF_REWRITE_MIN_SIZE: invalid})
+		assert.Equal(t, REQUEST_ERROR_INVALID_CONFIG_VALUE, err.Error(), invalid)
+	}
+	err := xredis.ConfigSet(map[string]string{CONFIG_AUTO_AOF_REWRITE_PERCENTAGE: "-1"})
+	assert.Equal(t, REQUEST_ERROR_INVALID_CONFIG_VALUE, err.Error())
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,165 @@
+package main
+
+import "math/big"
+
+const BITFIELD_GET = "GET"
+const BITFIELD_SET = "SET"
+const BITFIELD_INCRBY = "INCRBY"
+const BITFIELD_OVERFLOW = "OVERFLOW"
+
+const BITFIELD_OVERFLOW_WRAP = "WRAP"
+const BITFIELD_OVERFLOW_SAT = "SAT"
+const BITFIELD_OVERFLOW_FAIL = "FAIL"
+
+// Widest integers allowed, u64 being left out as in Redis since replies
+// are signed 64 bit integers
+const BITFIELD_MAX_SIGNED_WIDTH = 64
+const BITFIELD_MAX_UNSIGNED_WIDTH = 63
+
+// BitFieldOperation is a GET, SET or INCRBY of BITFIELD over the integer
+// of width bits starting at the bit offset.
+type BitFieldOperation struct {
+	kind     string
+	signed   bool
+	width    uint
+	offset   uint64
+	value    int64  // Value set, or increment
+	overflow string // Behavior of SET and INCRBY on overflow
+}
+
+type BitFieldResult struct {
+	value  int64
+	failed bool // Set when the FAIL overflow behavior prevented the operation
+}
+
+// BitField performs the operations in order over the string stored at
+// key, zero-extending it as needed by writes.
+func (xredis *XRedis) BitField(key string, operations []BitFieldOperation) ([]BitFieldResult, error) {
+	rspChan := make(chan []BitFieldResult)
+	errorChan := make(chan error)
+	xredis.commands <- BitFieldCommand{xredis.db, key, operations, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) handleBitFieldCommand(cmd BitFieldCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	data, _, err := xredis.getStringBytes(cmd.db, cmd.key)
+	if err != nil {
+		cmd.rspChannel <- nil
+		cmd.errorChannel <- err
+		return
+	}
+
+	results := make([]BitFieldResult, 0, len(cmd.operations))
+	written := false
+	for _, operation := range cmd.operations {
+		current := operation.get(data)
+		if operation.kind == BITFIELD_GET {
+			results = append(results, BitFieldResult{current, false})
+			continue
+		}
+
+		base, increment := int64(0), operation.value
+		if operation.kind == BITFIELD_INCRBY {
+			base = current
+		}
+		value, ok := operation.applyOverflow(base, increment)
+		if !ok {
+			results = append(results, BitFieldResult{0, true})
+			continue
+		}
+		if lastByte := int((operation.offset + uint64(operation.width) - 1) / 8); lastByte >= len(data) {
+			data = append(data, make([]byte, lastByte-len(data)+1)...)
+		}
+		operation.set(data, value)
+		written = true
+
+		if operation.kind == BITFIELD_SET {
+			results = append(results, BitFieldResult{current, false})
+		} else {
+			results = append(results, BitFieldResult{value, false})
+		}
+	}
+
+	if written {
+		xredis.setStringBytes(cmd.db, cmd.key, data)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STRING, KEYSPACE_EVENT_SETBIT, cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- results
+	cmd.errorChannel <- nil
+}
+
+// get reads the integer, bits past the end of the string being zeros
+func (operation BitFieldOperation) get(data []byte) int64 {
+	var value uint64
+	for i := uint64(0); i < uint64(operation.width); i++ {
+		position := operation.offset + i
+		bit := uint64(0)
+		if position/8 < uint64(len(data)) {
+			bit = uint64(data[position/8]>>(7-position%8)) & 1
+		}
+		value = value<<1 | bit
+	}
+	if operation.signed && operation.width < 64 && value&(1<<(operation.width-1)) != 0 {
+		value |= ^uint64(0) << operation.width
+	}
+	return int64(value)
+}
+
+// set writes the lowest width bits of value into data, which must be long
+// enough to hold them.
+func (operation BitFieldOperation) set(data []byte, value int64) {
+	for i := uint64(0); i < uint64(operation.width); i++ {
+		position := operation.offset + i
+		mask := byte(0x80) >> (position % 8)
+		if uint64(value)>>(uint64(operation.width)-1-i)&1 == 1 {
+			data[position/8] |= mask
+		} else {
+			data[position/8] &^= mask
+		}
+	}
+}
+
+// applyOverflow adds the increment to base, handling a result that doesn't
+// fit in the integer type as the overflow behavior tells. It returns false
+// if the operation must fail.
+func (operation BitFieldOperation) applyOverflow(base int64, increment int64) (int64, bool) {
+	result := new(big.Int).Add(big.NewInt(base), big.NewInt(increment))
+	minValue, maxValue := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), operation.width)
+	if operation.signed {
+		maxValue.Rsh(maxValue, 1)
+		minValue.Neg(maxValue)
+	}
+	maxValue.Sub(maxValue, big.NewInt(1))
+	if result.Cmp(minValue) >= 0 && result.Cmp(maxValue) <= 0 {
+		return result.Int64(), true
+	}
+
+	switch operation.overflow {
+	case BITFIELD_OVERFLOW_SAT:
+		if result.Sign() < 0 {
+			return minValue.Int64(), true
+		}
+		return maxValue.Int64(), true
+	case BITFIELD_OVERFLOW_FAIL:
+		return 0, false
+	default:
+		// Only the lowest width bits are kept, as in two's complement
+		modulus := new(big.Int).Lsh(big.NewInt(1), operation.width)
+		result.Mod(result, modulus)
+		if result.Cmp(maxValue) > 0 {
+			result.Sub(result, modulus)
+		}
+		return result.Int64(), true
+	}
+}
+
+type BitFieldCommand struct {
+	db           int
+	key          string
+	operations   []BitFieldOperation
+	rspChannel   chan []BitFieldResult
+	errorChannel chan error
+}
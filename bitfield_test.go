@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitFieldSetAndGet(t *testing.T) {
+	xredis := NewXRedis()
+
+	results, err := xredis.BitField("counters", []BitFieldOperation{
+		{BITFIELD_SET, true, 8, 0, -1, BITFIELD_OVERFLOW_WRAP},
+		{BITFIELD_GET, false, 8, 0, 0, BITFIELD_OVERFLOW_WRAP},
+		{BITFIELD_GET, true, 8, 0, 0, BITFIELD_OVERFLOW_WRAP},
+		{BITFIELD_SET, false, 8, 8, 65, BITFIELD_OVERFLOW_WRAP},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []BitFieldResult{{0, false}, {255, false}, {-1, false}, {0, false}}, results)
+	assert.Equal(t, RespString{"\xffA"}, xredis.Get("counters"))
+
+	results, _ = xredis.BitField("missing", []BitFieldOperation{{BITFIELD_GET, false, 4, 0, 0, BITFIELD_OVERFLOW_WRAP}})
+	assert.Equal(t, []BitFieldResult{{0, false}}, results)
+	assert.False(t, xredis.Exists("missing"))
+}
+
+func TestBitFieldIncrByAtUnalignedOffset(t *testing.T) {
+	xredis := NewXRedis()
+
+	results, _ := xredis.BitField("counters", []BitFieldOperation{
+		{BITFIELD_INCRBY, true, 5, 100, 1, BITFIELD_OVERFLOW_WRAP},
+		{BITFIELD_GET, false, 4, 0, 0, BITFIELD_OVERFLOW_WRAP},
+	})
+	assert.Equal(t, []BitFieldResult{{1, false}, {0, false}}, results)
+
+	results, _ = xredis.BitField("counters", []BitFieldOperation{{BITFIELD_GET, true, 5, 100, 0, BITFIELD_OVERFLOW_WRAP}})
+	assert.Equal(t, []BitFieldResult{{1, false}}, results)
+}
+
+func TestBitFieldOverflow(t *testing.T) {
+	xredis := NewXRedis()
+	incrBy := func(overflow string) BitFieldOperation {
+		return BitFieldOperation{BITFIELD_INCRBY, false, 2, 100, 1, overflow}
+	}
+
+	for range 3 {
+		xredis.BitField("counters", []BitFieldOperation{incrBy(BITFIELD_OVERFLOW_WRAP)})
+	}
+	results, _ := xredis.BitField("counters", []BitFieldOperation{
+		incrBy(BITFIELD_OVERFLOW_SAT),
+		incrBy(BITFIELD_OVERFLOW_FAIL),
+		incrBy(BITFIELD_OVERFLOW_WRAP),
+	})
+	assert.Equal(t, []BitFieldResult{{3, false}, {0, true}, {0, false}}, results)
+
+	results, _ = xredis.BitField("signed", []BitFieldOperation{
+		{BITFIELD_SET, true, 64, 0, math.MaxInt64, BITFIELD_OVERFLOW_WRAP},
+		{BITFIELD_INCRBY, true, 64, 0, 1, BITFIELD_OVERFLOW_WRAP},
+		{BITFIELD_INCRBY, true, 64, 0, -1, BITFIELD_OVERFLOW_SAT},
+		{BITFIELD_SET, true, 4, 64, -100, BITFIELD_OVERFLOW_SAT},
+		{BITFIELD_GET, true, 4, 64, 0, BITFIELD_OVERFLOW_SAT},
+	})
+	assert.Equal(t, []BitFieldResult{{0, false}, {math.MinInt64, false}, {math.MinInt64, false}, {0, false}, {-8, false}}, results)
+}
+
+func TestBitFieldOnWrongType(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.LPush("list", RespString{"a"})
+
+	_, err := xredis.BitField("list", []BitFieldOperation{{BITFIELD_GET, false, 8, 0, 0, BITFIELD_OVERFLOW_WRAP}})
+	assert.Equal(t, REQUEST_ERROR_WRONG_TYPE, err.Error())
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// Greatest bit offset allowed, as strings are limited to 512MB in Redis
+const BITMAP_MAX_OFFSET = 1<<32 - 1
+
+const BITOP_AND = "AND"
+const BITOP_OR = "OR"
+const BITOP_XOR = "XOR"
+const BITOP_NOT = "NOT"
+
+// BitRange restricts BITCOUNT and BITPOS to the bytes, or the bits with
+// bitUnit, between start and end. Negative positions count from the end
+// of the string.
+type BitRange struct {
+	start   int64
+	end     int64
+	hasEnd  bool // BITPOS can leave the end out, which is then the last byte
+	bitUnit bool
+}
+
+// SetBit sets or clears the bit at offset, zero-extending the string as
+// needed, and returns the bit's previous value.
+func (xredis *XRedis) SetBit(key string, offset uint64, bit int) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- SetBitCommand{xredis.db, key, offset, bit, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) GetBit(key string, offset uint64) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- GetBitCommand{xredis.db, key, offset, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// BitCount counts the set bits of the string, only those in bitRange if
+// it isn't nil.
+func (xredis *XRedis) BitCount(key string, bitRange *BitRange) (int64, error) {
+	rspChan := make(chan int64)
+	errorChan := make(chan error)
+	xredis.commands <- BitCountCommand{xredis.db, key, bitRange, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// BitPos returns the position of the first bit set to bit, or -1 if there
+// is none. Strings are considered padded with zeros on the right unless an
+// end was given.
+func (xredis *XRedis) BitPos(key string, bit int, bitRange *BitRange) (int64, error) {
+	rspChan := make(chan int64)
+	errorChan := make(chan error)
+	xredis.commands <- BitPosCommand{xredis.db, key, bit, bitRange, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// BitOp stores at destKey the bitwise operation between the strings,
+// shorter strings being zero-extended, and returns the result's length.
+func (xredis *XRedis) BitOp(operation string, destKey string, keys []string) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- BitOpCommand{xredis.db, operation, destKey, keys, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) handleSetBitCommand(cmd SetBitCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	data, _, err := xredis.getStringBytes(cmd.db, cmd.key)
+	if err != nil {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+
+	byteIndex := int(cmd.offset / 8)
+	if byteIndex >= len(data) {
+		data = append(data, make([]byte, byteIndex-len(data)+1)...)
+	}
+	mask := byte(0x80) >> (cmd.offset % 8)
+	previous := 0
+	if data[byteIndex]&mask != 0 {
+		previous = 1
+	}
+	if cmd.bit == 1 {
+		data[byteIndex] |= mask
+	} else {
+		data[byteIndex] &^= mask
+	}
+
+	xredis.setStringBytes(cmd.db, cmd.key, data)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STRING, KEYSPACE_EVENT_SETBIT, cmd.db, cmd.key)
+	cmd.rspChannel <- previous
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleGetBitCommand(cmd GetBitCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	data, _, err := xredis.getStringBytes(cmd.db, cmd.key)
+	if err != nil || cmd.offset/8 >= uint64(len(data)) {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+	cmd.rspChannel <- int(data[cmd.offset/8]>>(7-cmd.offset%8)) & 1
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleBitCountCommand(cmd BitCountCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	data, _, err := xredis.getStringBytes(cmd.db, cmd.key)
+	if err != nil {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+
+	first, last := bitRangeBounds(len(data), cmd.bitRange)
+	count := int64(0)
+	for position := first; position <= last; {
+		// Whole bytes are counted at once
+		if position%8 == 0 && position+7 <= last {
+			count += int64(bits.OnesCount8(data[position/8]))
+			position += 8
+			continue
+		}
+		count += int64(data[position/8]>>(7-position%8)) & 1
+		position++
+	}
+	cmd.rspChannel <- count
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleBitPosCommand(cmd BitPosCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	data, exists, err := xredis.getStringBytes(cmd.db, cmd.key)
+	if err != nil || !exists {
+		// Missing keys are empty strings, padded with zeros
+		position := int64(-1)
+		if err == nil && cmd.bit == 0 {
+			position = 0
+		}
+		cmd.rspChannel <- position
+		cmd.errorChannel <- err
+		return
+	}
+
+	first, last := bitRangeBounds(len(data), cmd.bitRange)
+	// Bytes without the bit looked for are skipped at once
+	skipped := byte(0x00)
+	if cmd.bit == 0 {
+		skipped = 0xff
+	}
+	for position := first; position <= last; {
+		if position%8 == 0 && position+7 <= last && data[position/8] == skipped {
+			position += 8
+			continue
+		}
+		if int(data[position/8]>>(7-position%8))&1 == cmd.bit {
+			cmd.rspChannel <- position
+			cmd.errorChannel <- nil
+			return
+		}
+		position++
+	}
+
+	position := int64(-1)
+	if cmd.bit == 0 && first <= last && (cmd.bitRange == nil || !cmd.bitRange.hasEnd) {
+		position = last + 1
+	}
+	cmd.rspChannel <- position
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleBitOpCommand(cmd BitOpCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	sources := make([][]byte, 0, len(cmd.keys))
+	length := 0
+	for _, key := range cmd.keys {
+		data, _, err := xredis.getStringBytes(cmd.db, key)
+		if err != nil {
+			cmd.rspChannel <- 0
+			cmd.errorChannel <- err
+			return
+		}
+		sources = append(sources, data)
+		length = max(length, len(data))
+	}
+
+	result := make([]byte, length)
+	for i := range result {
+		result[i] = bitOpByte(cmd.operation, sources, i)
+	}
+
+	if length == 0 {
+		_, existed := xredis.getAndInvalidateIfExpired(cmd.db, cmd.destKey)
+		if existed {
+			xredis.deleteValue(cmd.db, cmd.destKey)
+			xredis.touchKey(cmd.db, cmd.destKey)
+			xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_DEL, cmd.db, cmd.destKey)
+		}
+	} else {
+		xredis.setValue(cmd.db, cmd.destKey, XRedisValue{encodeStringValue(RespString{string(result)}), NON_EXPIRATION_TIME})
+		xredis.touchKey(cmd.db, cmd.destKey)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STRING, KEYSPACE_EVENT_SET, cmd.db, cmd.destKey)
+	}
+	cmd.rspChannel <- length
+	cmd.errorChannel <- nil
+}
+
+// getStringBytes returns the bytes of the string stored at key, which is
+// empty if the key doesn't exist.
+func (xredis *XRedis) getStringBytes(db int, key string) ([]byte, bool, error) {
+	value, exists := xredis.getAndInvalidateIfExpired(db, key)
+	if !exists {
+		return []byte{}, false, nil
+	}
+	respStr, ok := decodeStringValue(value.Element).(RespString)
+	if !ok {
+		return nil, false, errors.New(REQUEST_ERROR_WRONG_TYPE)
+	}
+	return []byte(respStr.Str), true, nil
+}
+
+// setStringBytes stores the bytes at key, keeping its expiration time
+func (xredis *XRedis) setStringBytes(db int, key string, data []byte) {
+	expirationTime := int64(NON_EXPIRATION_TIME)
+	if value, exists := xredis.databases[db][key]; exists {
+		expirationTime = value.ExpirationTimestampMillis
+	}
+	xredis.setValue(db, key, XRedisValue{encodeStringValue(RespString{string(data)}), expirationTime})
+	xredis.touchKey(db, key)
+}
+
+// bitRangeBounds returns the positions of the first and last bits of a
+// string of length bytes covered by the range, first being greater than
+// last when the range is empty.
+func bitRangeBounds(length int, bitRange *BitRange) (int64, int64) {
+	totalBits := int64(length) * 8
+	if bitRange == nil {
+		return 0, totalBits - 1
+	}
+
+	total := int64(length)
+	if bitRange.bitUnit {
+		total = totalBits
+	}
+	start, end := bitRange.start, total-1
+	if bitRange.hasEnd {
+		end = bitRange.end
+	}
+	if start < 0 {
+		start = max(start+total, 0)
+	}
+	if end < 0 {
+		end += total
+	}
+	end = min(end, total-1)
+	if start > end {
+		return 0, -1
+	}
+	if bitRange.bitUnit {
+		return start, end
+	}
+	return start * 8, end*8 + 7
+}
+
+func bitOpByte(operation string, sources [][]byte, i int) byte {
+	sourceByte := func(source []byte) byte {
+		if i < len(source) {
+			return source[i]
+		}
+		return 0
+	}
+
+	result := sourceByte(sources[0])
+	if operation == BITOP_NOT {
+		return ^result
+	}
+	for _, source := range sources[1:] {
+		switch operation {
+		case BITOP_AND:
+			result &= sourceByte(source)
+		case BITOP_OR:
+			result |= sourceByte(source)
+		case BITOP_XOR:
+			result ^= sourceByte(source)
+		}
+	}
+	return result
+}
+
+type SetBitCommand struct {
+	db           int
+	key          string
+	offset       uint64
+	bit          int
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type GetBitCommand struct {
+	db           int
+	key          string
+	offset       uint64
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type BitCountCommand struct {
+	db           int
+	key          string
+	bitRange     *BitRange
+	rspChannel   chan int64
+	errorChannel chan error
+}
+
+type BitPosCommand struct {
+	db           int
+	key          string
+	bit          int
+	bitRange     *BitRange
+	rspChannel   chan int64
+	errorChannel chan error
+}
+
+type BitOpCommand struct {
+	db           int
+	operation    string
+	destKey      string
+	keys         []string
+	rspChannel   chan int
+	errorChannel chan error
+}
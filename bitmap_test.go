@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetBitAndGetBit(t *testing.T) {
+	xredis := NewXRedis()
+
+	previous, err := xredis.SetBit("bitmap", 7, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, previous)
+	assert.Equal(t, RespString{"\x01"}, xredis.Get("bitmap"))
+
+	previous, _ = xredis.SetBit("bitmap", 7, 0)
+	assert.Equal(t, 1, previous)
+
+	xredis.SetBit("bitmap", 20, 1)
+	assert.Equal(t, RespString{"\x00\x00\x08"}, xredis.Get("bitmap"))
+	bit, _ := xredis.GetBit("bitmap", 20)
+	assert.Equal(t, 1, bit)
+	bit, _ = xredis.GetBit("bitmap", 1000)
+	assert.Equal(t, 0, bit)
+}
+
+func TestSetBitKeepsIntegerEncodingAndExpiration(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.SetWithExpiration("number", RespString{"1"}, time.Now().Add(time.Hour))
+
+	// "1" is 0x31 and "3" is 0x33
+	xredis.SetBit("number", 6, 1)
+	assert.Equal(t, RespString{"3"}, xredis.Get("number"))
+	encoding, _ := xredis.Encoding("number")
+	assert.Equal(t, ENCODING_INT, encoding)
+	assert.NotEqual(t, int64(NON_EXPIRATION_TIME), xredis.databases[DEFAULT_DATABASE]["number"].ExpirationTimestampMillis)
+}
+
+func TestSetBitOnWrongType(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.LPush("list", RespString{"a"})
+
+	_, err := xredis.SetBit("list", 0, 1)
+	assert.Equal(t, REQUEST_ERROR_WRONG_TYPE, err.Error())
+}
+
+func TestBitCount(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("key", RespString{"foobar"})
+
+	count, _ := xredis.BitCount("key", nil)
+	assert.Equal(t, int64(26), count)
+	count, _ = xredis.BitCount("key", &BitRange{0, 0, true, false})
+	assert.Equal(t, int64(4), count)
+	count, _ = xredis.BitCount("key", &BitRange{1, 1, true, false})
+	assert.Equal(t, int64(6), count)
+	count, _ = xredis.BitCount("key", &BitRange{5, 30, true, true})
+	assert.Equal(t, int64(17), count)
+	count, _ = xredis.BitCount("key", &BitRange{-2, -1, true, false})
+	assert.Equal(t, int64(7), count)
+	count, _ = xredis.BitCount("missing", nil)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestBitPos(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("key", RespString{"\xff\xf0\x00"})
+	position, _ := xredis.BitPos("key", 0, nil)
+	assert.Equal(t, int64(12), position)
+
+	xredis.Set("key", RespString{"\x00\xff\xf0"})
+	position, _ = xredis.BitPos("key", 1, &BitRange{0, 0, false, false})
+	assert.Equal(t, int64(8), position)
+	position, _ = xredis.BitPos("key", 1, &BitRange{2, 0, false, false})
+	assert.Equal(t, int64(16), position)
+	position, _ = xredis.BitPos("key", 1, &BitRange{7, 15, true, true})
+	assert.Equal(t, int64(8), position)
+
+	xredis.Set("key", RespString{"\xff\xff\xff"})
+	position, _ = xredis.BitPos("key", 0, nil)
+	assert.Equal(t, int64(24), position)
+	position, _ = xredis.BitPos("key", 0, &BitRange{0, -1, true, false})
+	assert.Equal(t, int64(-1), position)
+
+	position, _ = xredis.BitPos("missing", 1, nil)
+	assert.Equal(t, int64(-1), position)
+	position, _ = xredis.BitPos("missing", 0, nil)
+	assert.Equal(t, int64(0), position)
+}
+
+func TestBitOp(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("key1", RespString{"foobar"})
+	xredis.Set("key2", RespString{"abcdef"})
+
+	length, _ := xredis.BitOp(BITOP_AND, "dest", []string{"key1", "key2"})
+	assert.Equal(t, 6, length)
+	assert.Equal(t, RespString{"`bc`ab"}, xredis.Get("dest"))
+
+	xredis.Set("short", RespString{"\x0f"})
+	xredis.BitOp(BITOP_OR, "dest", []string{"short", "key2"})
+	assert.Equal(t, RespString{"obcdef"}, xredis.Get("dest"))
+
+	xredis.BitOp(BITOP_NOT, "dest", []string{"short"})
+	assert.Equal(t, RespString{"\xf0"}, xredis.Get("dest"))
+
+	length, _ = xredis.BitOp(BITOP_XOR, "dest", []string{"missing"})
+	assert.Equal(t, 0, length)
+	assert.False(t, xredis.Exists("dest"))
+}
@@ -0,0 +1,49 @@
+package main
+
+const CLIENT_OUTBOX_SIZE = 1024
+
+// Client holds the state of a single connection to xredis.
+type Client struct {
+	xredis         *XRedis               // Handle targeting the database selected by the client
+	transaction    *Transaction          // Set between MULTI and EXEC/DISCARD
+	executing      bool                  // Set while EXEC runs the queued requests
+	loggedRequests *[]AppendOnlyLogEntry // Write requests applied by EXEC, to be logged once it's done
+	loading        bool                  // Set on the client replaying the append only file, served while loading
+	watchedKeys    []WatchedKey          // Keys that abort the transaction when modified
+	outbox         chan []byte           // Replies and messages to be written to the connection, in order
+	subscriber     *Subscriber
+	subscriptions  int // Number of channels, patterns and sharded channels the client is subscribed to
+}
+
+// Transaction holds the requests queued after MULTI
+type Transaction struct {
+	requests []RespArray
+	aborted  bool // Set when a request failed to be queued
+	writes   bool // Set when a write request was queued
+}
+
+func NewClient(xredis *XRedis) *Client {
+	outbox := make(chan []byte, CLIENT_OUTBOX_SIZE)
+	return &Client{xredis, nil, false, nil, false, nil, outbox, NewSubscriber(outbox), 0}
+}
+
+// Close releases the server resources held by the client and closes its
+// outbox. It must be called once the connection is over.
+func (client *Client) Close() {
+	client.unwatchAll()
+	if client.subscriptions > 0 {
+		client.xredis.Unsubscribe(client.subscriber, nil, false)
+		client.xredis.Unsubscribe(client.subscriber, nil, true)
+		client.xredis.ShardUnsubscribe(client.subscriber, nil)
+		client.subscriptions = 0
+	}
+	close(client.outbox)
+}
+
+func (client *Client) unwatchAll() {
+	if len(client.watchedKeys) == 0 {
+		return
+	}
+	client.xredis.Unwatch(client.watchedKeys)
+	client.watchedKeys = nil
+}
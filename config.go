@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/cipher"
+	"errors"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const CONFIG_NOTIFY_KEYSPACE_EVENTS = "notify-keyspace-events"
+
+// Config holds the server settings that can be read and changed at runtime
+// with CONFIG GET and CONFIG SET. It is owned by the commands goroutine.
+type Config struct {
+	notifyKeyspaceEvents     int         // Classes of keyspace events to be published
+	appendFsync              string      // When the append only file is flushed to disk
+	autoAofRewritePercentage int         // Growth of the append only file triggering a rewrite, 0 to disable
+	autoAofRewriteMinSize    int64       // Size below which the append only file isn't rewritten
+	dir                      string      // Directory of the dump file, only set on startup
+	dbFilename               string      // Name of the file the databases are saved to, in dir
+	dbFilenameBackups        int         // Number of previous dumps kept
+	dbFormat                 string      // Format the databases are saved in
+	persistenceCompression   string      // Codec compressing the dump and append only files
+	encryption               cipher.AEAD // AES-256-GCM of the key encrypting the dump and append only files, nil if not encrypted
+	allowUnencryptedLoad     bool        // Whether the files that aren't encrypted are loaded despite the key
+	savePoints               []SavePoint
+}
+
+// configParameter converts a setting of the Config from and to the string
+// exchanged with clients. The parameters without set can only be given on
+// startup.
+type configParameter struct {
+	get func(config *Config) string
+	set func(config *Config, value string) error
+}
+
+var CONFIG_PARAMETERS = map[string]configParameter{
+	CONFIG_NOTIFY_KEYSPACE_EVENTS: {
+		func(config *Config) string { return formatKeyspaceEvents(config.notifyKeyspaceEvents) },
+		func(config *Config, value string) error {
+			flags, err := parseKeyspaceEvents(value)
+			if err != nil {
+				return err
+			}
+			config.notifyKeyspaceEvents = flags
+			return nil
+		},
+	},
+	CONFIG_APPENDFSYNC: {
+		func(config *Config) string { return config.appendFsync },
+		func(config *Config, value string) error {
+			value = strings.ToLower(value)
+			if value != APPENDFSYNC_ALWAYS && value != APPENDFSYNC_EVERYSEC && value != APPENDFSYNC_NO {
+				return errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+			}
+			config.appendFsync = value
+			return nil
+		},
+	},
+	CONFIG_DIR: {
+		func(config *Config) string { return config.dir },
+		nil,
+	},
+	CONFIG_DBFILENAME: {
+		func(config *Config) string { return config.dbFilename },
+		func(config *Config, value string) error {
+			// A name rather than a path, so that clients can't have the
+			// dump written anywhere else than in dir
+			if value == "" || value == "." || value == ".." || value != filepath.Base(value) || filepath.IsAbs(value) {
+				return errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+			}
+			config.dbFilename = value
+			return nil
+		},
+	},
+	CONFIG_DBFILENAME_BACKUPS: {
+		func(config *Config) string { return strconv.Itoa(config.dbFilenameBackups) },
+		func(config *Config, value string) error {
+			backups, err := strconv.Atoi(value)
+			if err != nil || backups < 0 {
+				return errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+			}
+			config.dbFilenameBackups = backups
+			return nil
+		},
+	},
+	CONFIG_DBFORMAT: {
+		func(config *Config) string { return config.dbFormat },
+		func(config *Config, value string) error {
+			value = strings.ToLower(value)
+			if value != DUMP_FORMAT_XREDIS && value != DUMP_FORMAT_RDB {
+				return errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+			}
+			config.dbFormat = value
+			return nil
+		},
+	},
+	CONFIG_PERSISTENCE_COMPRESSION: {
+		func(config *Config) string { return config.persistenceCompression },
+		func(config *Config, value string) error {
+			value = strings.ToLower(value)
+			if value != COMPRESSION_NO && value != COMPRESSION_GZIP && value != COMPRESSION_FLATE {
+				return errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+			}
+			config.persistenceCompression = value
+			return nil
+		},
+	},
+	CONFIG_SAVE: {
+		func(config *Config) string { return formatSavePoints(config.savePoints) },
+		func(config *Config, value string) error {
+			savePoints, err := parseSavePoints(value)
+			if err != nil {
+				return err
+			}
+			config.savePoints = savePoints
+			return nil
+		},
+	},
+	CONFIG_AUTO_AOF_REWRITE_PERCENTAGE: {
+		func(config *Config) string { return strconv.Itoa(config.autoAofRewritePercentage) },
+		func(config *Config, value string) error {
+			percentage, err := strconv.Atoi(value)
+			if err != nil || percentage < 0 {
+				return errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+			}
+			config.autoAofRewritePercentage = percentage
+			return nil
+		},
+	},
+	CONFIG_AUTO_AOF_REWRITE_MIN_SIZE: {
+		func(config *Config) string { return strconv.FormatInt(config.autoAofRewriteMinSize, 10) },
+		func(config *Config, value string) error {
+			size, err := parseMemorySize(value)
+			if err != nil {
+				return err
+			}
+			config.autoAofRewriteMinSize = size
+			return nil
+		},
+	},
+}
+
+// Units accepted by the memory sizes, as in the Redis configuration
+var MEMORY_UNITS = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1000,
+	"kb": 1 << 10,
+	"m":  1000 * 1000,
+	"mb": 1 << 20,
+	"g":  1000 * 1000 * 1000,
+	"gb": 1 << 30,
+}
+
+// parseMemorySize parses a number of bytes optionally followed by a unit,
+// such as 64mb.
+func parseMemorySize(value string) (int64, error) {
+	value = strings.ToLower(value)
+	digits := strings.TrimRightFunc(value, unicode.IsLetter)
+	unit, ok := MEMORY_UNITS[value[len(digits):]]
+	size, err := strconv.ParseInt(digits, 10, 64)
+	if !ok || err != nil || size < 0 || size > math.MaxInt64/unit {
+		return 0, errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+	}
+	return size * unit, nil
+}
+
+func NewConfig() *Config {
+	return &Config{
+		appendFsync:              APPENDFSYNC_EVERYSEC,
+		autoAofRewritePercentage: AUTO_AOF_REWRITE_DEFAULT_PERCENTAGE,
+		autoAofRewriteMinSize:    AUTO_AOF_REWRITE_DEFAULT_MIN_SIZE,
+		dir:                      ".",
+		dbFilename:               DB_DUMP_FILE,
+		dbFilenameBackups:        DUMP_DEFAULT_BACKUPS,
+		dbFormat:                 DUMP_FORMAT_XREDIS,
+		persistenceCompression:   COMPRESSION_NO,
+	}
+}
+
+// ConfigGet returns the name and value of every parameter matching the
+// pattern, flattened and sorted by name.
+func (xredis *XRedis) ConfigGet(pattern string) []string {
+	rspChan := make(chan []string)
+	xredis.commands <- ConfigGetCommand{pattern, rspChan}
+	return <-rspChan
+}
+
+// ConfigSet changes the values of the parameters. Either every parameter
+// is changed or, on error, none of them.
+func (xredis *XRedis) ConfigSet(parameters map[string]string) error {
+	errorChan := make(chan error)
+	xredis.commands <- ConfigSetCommand{parameters, errorChan}
+	return <-errorChan
+}
+
+// SetDir sets the directory the dump file is saved to, which clients can't
+// change.
+func (xredis *XRedis) SetDir(dir string) {
+	doneChan := make(chan struct{})
+	xredis.commands <- SetDirCommand{dir, doneChan}
+	<-doneChan // Wait for completion
+}
+
+func (xredis *XRedis) handleSetDirCommand(cmd SetDirCommand) {
+	defer close(cmd.done)
+	xredis.config.dir = cmd.dir
+}
+
+// dumpPath returns the path of the dump file
+func (config *Config) dumpPath() string {
+	return filepath.Join(config.dir, config.dbFilename)
+}
+
+func (xredis *XRedis) handleConfigGetCommand(cmd ConfigGetCommand) {
+	defer close(cmd.rspChannel)
+
+	names := make([]string, 0)
+	for name := range CONFIG_PARAMETERS {
+		if globMatch(strings.ToLower(cmd.pattern), name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	parameters := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		parameters = append(parameters, name, CONFIG_PARAMETERS[name].get(xredis.config))
+	}
+	cmd.rspChannel <- parameters
+}
+
+func (xredis *XRedis) handleConfigSetCommand(cmd ConfigSetCommand) {
+	defer close(cmd.errorChannel)
+
+	// Changes are applied to a copy so that a failure leaves the
+	// configuration untouched
+	config := *xredis.config
+	for name, value := range cmd.parameters {
+		parameter, ok := CONFIG_PARAMETERS[strings.ToLower(name)]
+		if !ok {
+			cmd.errorChannel <- errors.New(REQUEST_ERROR_UNKNOWN_CONFIG_PARAMETER)
+			return
+		}
+		if parameter.set == nil {
+			cmd.errorChannel <- errors.New(REQUEST_ERROR_IMMUTABLE_CONFIG_PARAMETER)
+			return
+		}
+		if err := parameter.set(&config, value); err != nil {
+			cmd.errorChannel <- err
+			return
+		}
+	}
+	*xredis.config = config
+	cmd.errorChannel <- nil
+}
+
+type ConfigGetCommand struct {
+	pattern    string
+	rspChannel chan []string
+}
+
+type SetDirCommand struct {
+	dir  string
+	done chan struct{}
+}
+
+type ConfigSetCommand struct {
+	parameters   map[string]string
+	errorChannel chan error
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigSetIsAtomic(t *testing.T) {
+	xredis := NewXRedis()
+
+	err := xredis.ConfigSet(map[string]string{CONFIG_NOTIFY_KEYSPACE_EVENTS: "KEA", "unknown": "1"})
+	assert.Equal(t, REQUEST_ERROR_UNKNOWN_CONFIG_PARAMETER, err.Error())
+	assert.Equal(t, []string{
+		CONFIG_APPENDFSYNC, APPENDFSYNC_EVERYSEC,
+		CONFIG_AUTO_AOF_REWRITE_MIN_SIZE, "67108864",
+		CONFIG_AUTO_AOF_REWRITE_PERCENTAGE, "100",
+		CONFIG_DBFILENAME, DB_DUMP_FILE,
+		CONFIG_DBFILENAME_BACKUPS, "1",
+		CONFIG_DBFORMAT, DUMP_FORMAT_XREDIS,
+		CONFIG_DIR, ".",
+		CONFIG_NOTIFY_KEYSPACE_EVENTS, "",
+		CONFIG_PERSISTENCE_COMPRESSION, COMPRESSION_NO,
+		CONFIG_SAVE, "",
+	}, xredis.ConfigGet("*"))
+}
+
+func TestDumpFileStaysInDir(t *testing.T) {
+	xredis := NewXRedis()
+	for _, name := range []string{"", ".", "..", "../dump.db", "dir/dump.db", "/tmp/dump.db"} {
+		err := xredis.ConfigSet(map[string]string{CONFIG_DBFILENAME: name})
+		assert.Equal(t, REQUEST_ERROR_INVALID_CONFIG_VALUE, err.Error(), name)
+	}
+	assert.Nil(t, xredis.ConfigSet(map[string]string{CONFIG_DBFILENAME: "dump.rdb"}))
+
+	// The directory can only be given on startup
+	err := xredis.ConfigSet(map[string]string{CONFIG_DIR: "/tmp"})
+	assert.Equal(t, REQUEST_ERROR_IMMUTABLE_CONFIG_PARAMETER, err.Error())
+	xredis.SetDir("/var/lib/xredis")
+	assert.Equal(t, []string{CONFIG_DIR, "/var/lib/xredis"}, xredis.ConfigGet(CONFIG_DIR))
+	assert.Equal(t, []string{CONFIG_DBFILENAME, "dump.rdb"}, xredis.ConfigGet(CONFIG_DBFILENAME))
+}
+
+func TestConfigRequest(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*4\r\n$6\r\nCONFIG\r\n$3\r\nSET\r\n$22\r\nNOTIFY-KEYSPACE-EVENTS\r\n$3\r\nKEA\r\n"
+	setRsp := handleRequest(client, []byte(setCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(setRsp))
+
+	getCommand := "*3\r\n$6\r\nCONFIG\r\n$3\r\nGET\r\n$8\r\nnotify-*\r\n"
+	getRsp := handleRequest(client, []byte(getCommand))
+	assert.Equal(t, "*2\r\n$22\r\nnotify-keyspace-events\r\n$3\r\nAKE\r\n", string(getRsp))
+
+	invalidCommand := "*4\r\n$6\r\nCONFIG\r\n$3\r\nSET\r\n$22\r\nnotify-keyspace-events\r\n$1\r\nZ\r\n"
+	invalidRsp := handleRequest(client, []byte(invalidCommand))
+	assert.Equal(t, "-ERR INVALID-CONFIG-VALUE\r\n", string(invalidRsp))
+}
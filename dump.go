@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"hash/crc64"
+	"log"
+	"time"
+)
+
+// Version of the values serialized by DUMP, which RESTORE refuses to load
+// when it is unknown. The values are encoded as in the snapshots, except
+// for the first version which gob encoded them.
+const DUMP_PAYLOAD_VERSION = 2
+const DUMP_PAYLOAD_GOB_VERSION = 1
+
+// As in Redis, a DUMP payload ends with the version and a CRC64 checksum
+// of everything before it
+const DUMP_PAYLOAD_VERSION_SIZE = 2
+const DUMP_PAYLOAD_CHECKSUM_SIZE = 8
+
+var DUMP_PAYLOAD_CRC64_TABLE = crc64.MakeTable(crc64.ECMA)
+
+// Dump serializes the value stored at key, whatever its type, in a format
+// that only RESTORE understands.
+func (xredis *XRedis) Dump(key string) ([]byte, bool) {
+	rspChan := make(chan []byte)
+	existsChan := make(chan bool)
+	xredis.commands <- DumpCommand{xredis.db, key, rspChan, existsChan}
+	return <-rspChan, <-existsChan
+}
+
+// Restore stores at key the value serialized by DUMP, expiring it at the
+// Unix time in milliseconds given, if any. Unless replace is set, it fails
+// when the key already exists.
+func (xredis *XRedis) Restore(key string, payload []byte, expirationTimestampMillis int64, replace bool) error {
+	errorChan := make(chan error)
+	xredis.commands <- RestoreCommand{xredis.db, key, payload, expirationTimestampMillis, replace, errorChan}
+	return <-errorChan
+}
+
+func (xredis *XRedis) handleDumpCommand(cmd DumpCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.existsChannel)
+
+	value, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
+	if !exists {
+		cmd.rspChannel <- nil
+		cmd.existsChannel <- false
+		return
+	}
+	payload, err := dumpValue(value.Element)
+	if err != nil {
+		log.Println("Failed serializing value: ", err)
+		cmd.rspChannel <- nil
+		cmd.existsChannel <- false
+		return
+	}
+	cmd.rspChannel <- payload
+	cmd.existsChannel <- true
+}
+
+func (xredis *XRedis) handleRestoreCommand(cmd RestoreCommand) {
+	defer close(cmd.errorChannel)
+
+	element, err := restoreValue(cmd.payload)
+	if err != nil {
+		cmd.errorChannel <- err
+		return
+	}
+	_, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
+	if exists && !cmd.replace {
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_BUSY_KEY)
+		return
+	}
+
+	// A value restored already expired only replaces the existing one
+	if cmd.expirationTimestampMillis != NON_EXPIRATION_TIME && cmd.expirationTimestampMillis < time.Now().UnixMilli() {
+		if exists {
+			xredis.deleteValue(cmd.db, cmd.key)
+			xredis.touchKey(cmd.db, cmd.key)
+			xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_DEL, cmd.db, cmd.key)
+		}
+		cmd.errorChannel <- nil
+		return
+	}
+
+	xredis.setValue(cmd.db, cmd.key, XRedisValue{element, cmd.expirationTimestampMillis})
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_RESTORE, cmd.db, cmd.key)
+	xredis.signalStreamWaiters(cmd.db, cmd.key)
+	cmd.errorChannel <- nil
+}
+
+// dumpValue serializes the type and encoding of the element followed by
+// the payload version and checksum.
+func dumpValue(element RespDataType) ([]byte, error) {
+	payload, valueType, err := appendSnapshotElement([]byte{0}, element)
+	if err != nil {
+		return nil, err
+	}
+	payload[0] = valueType
+	payload = binary.LittleEndian.AppendUint16(payload, DUMP_PAYLOAD_VERSION)
+	return binary.LittleEndian.AppendUint64(payload, crc64.Checksum(payload, DUMP_PAYLOAD_CRC64_TABLE)), nil
+}
+
+// restoreValue deserializes the element of a payload written by dumpValue,
+// failing if it was written by another version or got corrupted.
+func restoreValue(payload []byte) (RespDataType, error) {
+	if len(payload) < DUMP_PAYLOAD_VERSION_SIZE+DUMP_PAYLOAD_CHECKSUM_SIZE {
+		return nil, errors.New(REQUEST_ERROR_INVALID_DUMP_PAYLOAD)
+	}
+	checksumOffset := len(payload) - DUMP_PAYLOAD_CHECKSUM_SIZE
+	versionOffset := checksumOffset - DUMP_PAYLOAD_VERSION_SIZE
+	if binary.LittleEndian.Uint64(payload[checksumOffset:]) != crc64.Checksum(payload[:checksumOffset], DUMP_PAYLOAD_CRC64_TABLE) {
+		return nil, errors.New(REQUEST_ERROR_INVALID_DUMP_PAYLOAD)
+	}
+
+	switch binary.LittleEndian.Uint16(payload[versionOffset:checksumOffset]) {
+	case DUMP_PAYLOAD_VERSION:
+		reader := &snapshotReader{data: payload[:versionOffset]}
+		element := reader.element(reader.byte())
+		if reader.err != nil || len(reader.data) > 0 {
+			return nil, errors.New(REQUEST_ERROR_INVALID_DUMP_PAYLOAD)
+		}
+		return encodeStringValue(element), nil
+	case DUMP_PAYLOAD_GOB_VERSION:
+		// Payloads of the first version may still be found in the append
+		// only files rewritten back then
+		var value XRedisValue
+		if err := gob.NewDecoder(bytes.NewReader(payload[:versionOffset])).Decode(&value); err != nil || value.Element == nil {
+			return nil, errors.New(REQUEST_ERROR_INVALID_DUMP_PAYLOAD)
+		}
+		return encodeStringValue(value.Element), nil
+	default:
+		return nil, errors.New(REQUEST_ERROR_INVALID_DUMP_PAYLOAD)
+	}
+}
+
+type DumpCommand struct {
+	db            int
+	key           string
+	rspChannel    chan []byte
+	existsChannel chan bool
+}
+
+type RestoreCommand struct {
+	db                        int
+	key                       string
+	payload                   []byte
+	expirationTimestampMillis int64
+	replace                   bool
+	errorChannel              chan error
+}
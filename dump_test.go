@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc64"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpAndRestore(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("counter", RespString{"42"})
+	xredis.RPush("list", RespString{"a"})
+	xredis.RPush("list", RespString{"b"})
+	xredis.XAdd("stream", "1-1", []string{"field", "value"}, false, nil)
+	xredis.XGroupCreate("stream", "group", StreamID{}, false, false)
+	xredis.PFAdd("hll", []string{"a", "b", "c"})
+	xredis.GeoAdd("geo", []GeoMember{{"Palermo", GeoPoint{13.361389, 38.115556}}}, GeoAddOptions{})
+
+	restored := NewXRedis()
+	for _, key := range []string{"counter", "list", "stream", "hll", "geo"} {
+		payload, exists := xredis.Dump(key)
+		assert.True(t, exists)
+		assert.Nil(t, restored.Restore(key, payload, NON_EXPIRATION_TIME, false))
+	}
+
+	assert.Equal(t, RespString{"42"}, restored.Get("counter"))
+	encoding, _ := restored.Encoding("counter")
+	assert.Equal(t, ENCODING_INT, encoding)
+	assert.Equal(t, RespArray{[]RespDataType{RespString{"a"}, RespString{"b"}}}, restored.Get("list"))
+	assert.Equal(t, xredis.Get("stream"), restored.Get("stream"))
+	count, _ := restored.PFCount([]string{"hll"})
+	assert.Equal(t, uint64(3), count)
+	points, _ := restored.GeoPos("geo", []string{"Palermo"})
+	assert.InDelta(t, 13.361389, points[0].longitude, 1e-5)
+
+	_, exists := xredis.Dump("missing")
+	assert.False(t, exists)
+}
+
+func TestRestoreExistingKey(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("key", RespString{"old"})
+	payload, _ := xredis.Dump("key")
+
+	err := xredis.Restore("key", payload, NON_EXPIRATION_TIME, false)
+	assert.Equal(t, REQUEST_ERROR_BUSY_KEY, err.Error())
+
+	expirationTime := time.Now().Add(time.Hour).UnixMilli()
+	assert.Nil(t, xredis.Restore("key", payload, expirationTime, true))
+	assert.Equal(t, expirationTime, xredis.databases[DEFAULT_DATABASE]["key"].ExpirationTimestampMillis)
+
+	// Restoring an already expired value just removes the existing one
+	assert.Nil(t, xredis.Restore("key", payload, time.Now().Add(-time.Hour).UnixMilli(), true))
+	assert.False(t, xredis.Exists("key"))
+}
+
+func TestRestoreInvalidPayload(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("key", RespString{"value"})
+	payload, _ := xredis.Dump("key")
+
+	for _, invalid := range [][]byte{nil, []byte("garbage"), payload[:len(payload)-1]} {
+		err := xredis.Restore("other", invalid, NON_EXPIRATION_TIME, false)
+		assert.Equal(t, REQUEST_ERROR_INVALID_DUMP_PAYLOAD, err.Error())
+	}
+
+	// Payloads of other versions are refused even with a valid checksum
+	encoded := payload[:len(payload)-DUMP_PAYLOAD_VERSION_SIZE-DUMP_PAYLOAD_CHECKSUM_SIZE]
+	otherVersion := binary.LittleEndian.AppendUint16(slices.Clone(encoded), DUMP_PAYLOAD_VERSION+1)
+	otherVersion = binary.LittleEndian.AppendUint64(otherVersion, crc64.Checksum(otherVersion, DUMP_PAYLOAD_CRC64_TABLE))
+	err := xredis.Restore("other", otherVersion, NON_EXPIRATION_TIME, false)
+	assert.Equal(t, REQUEST_ERROR_INVALID_DUMP_PAYLOAD, err.Error())
+	assert.False(t, xredis.Exists("other"))
+}
+
+func TestRestoreGobPayload(t *testing.T) {
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(XRedisValue{RespString{"10"}, NON_EXPIRATION_TIME})
+	payload := binary.LittleEndian.AppendUint16(buf.Bytes(), DUMP_PAYLOAD_GOB_VERSION)
+	payload = binary.LittleEndian.AppendUint64(payload, crc64.Checksum(payload, DUMP_PAYLOAD_CRC64_TABLE))
+
+	xredis := NewXRedis()
+	assert.Nil(t, xredis.Restore("key", payload, NON_EXPIRATION_TIME, false))
+	assert.Equal(t, RespString{"10"}, xredis.Get("key"))
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const CONFIG_PERSISTENCE_COMPRESSION = "persistence-compression"
+
+// Codecs compressing the dump and append only files
+const COMPRESSION_NO = "no"
+const COMPRESSION_GZIP = "gzip"
+const COMPRESSION_FLATE = "flate"
+
+// Environment variable holding the encryption key, unless it is given in
+// a file. The key is made of 32 bytes, for AES-256, written as 64 hex
+// digits or in base64, or as is in a file.
+const ENCRYPTION_KEY_ENV = "XREDIS_ENCRYPTION_KEY"
+const ENCRYPTION_KEY_SIZE = 32
+
+// Compressed or encrypted files start with a header listing the transforms
+// applied, in order, followed by frames of transformed data, each prefixed
+// by its length:
+//
+//	XRFILE <version:byte> <transforms:byte> <transform:byte>...
+//	<length:uvarint> <frame>
+//	...
+//
+// Dumps are made of a single frame, while the writes to the append only
+// file are gathered into a frame per second, or per write with appendfsync
+// always. Encrypted frames start with their random nonce and authenticate
+// the header, so that it can't be altered either, along with their index
+// in the file and whether they end a dump, so that they can't be reordered,
+// replayed, dropped from the middle of the file or from the end of a dump.
+// Only the frames at the end of an append only file can be dropped, as a
+// crash before writing them would.
+const FILE_HEADER_MAGIC = "XRFILE"
+const FILE_HEADER_VERSION = 1
+const FILE_HEADER_MAX_SIZE = len(FILE_HEADER_MAGIC) + 2 + 255
+
+// Transforms listed in the header, which must never be renumbered
+const FILE_TRANSFORM_GZIP = 1
+const FILE_TRANSFORM_FLATE = 2
+const FILE_TRANSFORM_AES_GCM = 3
+
+var FILE_TRANSFORM_COMPRESSIONS = map[byte]string{
+	FILE_TRANSFORM_GZIP:  COMPRESSION_GZIP,
+	FILE_TRANSFORM_FLATE: COMPRESSION_FLATE,
+}
+
+var ERROR_ENCRYPTION_KEY_MISSING = errors.New("the file is encrypted, but no encryption key was given")
+var ERROR_FILE_NOT_ENCRYPTED = errors.New("the file isn't encrypted, although an encryption key was given, and unencrypted files aren't allowed")
+var ERROR_DECRYPTION_FAILED = errors.New("decryption failed, the encryption key is wrong or the file is corrupted")
+var ERROR_INVALID_ENCRYPTION_KEY = fmt.Errorf("the encryption key must be %d bytes, written as hex digits or in base64", ENCRYPTION_KEY_SIZE)
+
+// FileTransforms tells how the data written to the dump and append only
+// files is compressed and encrypted.
+type FileTransforms struct {
+	compression string
+	aead        cipher.AEAD // AES-256-GCM of the key, built once, nil if not encrypted
+}
+
+// FileDecryption tells how the files loaded are decrypted. Once a key is
+// given, the files that aren't encrypted could have been put in place of
+// the encrypted ones, so they are only loaded if explicitly allowed.
+type FileDecryption struct {
+	aead             cipher.AEAD // nil if no key was given
+	allowUnencrypted bool
+}
+
+// SetEncryptionKey sets the key encrypting the files written from now on,
+// and decrypting those loaded.
+func (xredis *XRedis) SetEncryptionKey(key []byte) error {
+	if len(key) != ENCRYPTION_KEY_SIZE {
+		return ERROR_INVALID_ENCRYPTION_KEY
+	}
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return err
+	}
+	errorChan := make(chan error)
+	xredis.commands <- SetEncryptionKeyCommand{aead, errorChan}
+	return <-errorChan
+}
+
+// AllowUnencryptedLoad allows loading the files that aren't encrypted
+// despite the encryption key, to encrypt the data of an existing server.
+func (xredis *XRedis) AllowUnencryptedLoad() {
+	doneChan := make(chan struct{})
+	xredis.commands <- AllowUnencryptedLoadCommand{doneChan}
+	<-doneChan // Wait for completion
+}
+
+// fileDecryption returns how the files loaded are decrypted
+func (xredis *XRedis) fileDecryption() FileDecryption {
+	rspChan := make(chan FileDecryption)
+	xredis.commands <- FileDecryptionCommand{rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) handleSetEncryptionKeyCommand(cmd SetEncryptionKeyCommand) {
+	defer close(cmd.errorChannel)
+	xredis.config.encryption = cmd.aead
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleAllowUnencryptedLoadCommand(cmd AllowUnencryptedLoadCommand) {
+	defer close(cmd.done)
+	xredis.config.allowUnencryptedLoad = true
+}
+
+func (xredis *XRedis) handleFileDecryptionCommand(cmd FileDecryptionCommand) {
+	defer close(cmd.rspChannel)
+	cmd.rspChannel <- xredis.config.fileDecryption()
+}
+
+func (config *Config) fileTransforms() FileTransforms {
+	return FileTransforms{config.persistenceCompression, config.encryption}
+}
+
+func (config *Config) fileDecryption() FileDecryption {
+	return FileDecryption{config.encryption, config.allowUnencryptedLoad}
+}
+
+// readEncryptionKey reads the key from the file at path or, without path,
+// from the environment. It returns nil if none is given.
+func readEncryptionKey(path string) ([]byte, error) {
+	material := []byte(os.Getenv(ENCRYPTION_KEY_ENV))
+	if path != "" {
+		var err error
+		if material, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if len(material) == 0 {
+		return nil, nil
+	}
+	return parseEncryptionKey(material)
+}
+
+func parseEncryptionKey(material []byte) ([]byte, error) {
+	trimmed := string(bytes.TrimSpace(material))
+	if key, err := hex.DecodeString(trimmed); err == nil && len(key) == ENCRYPTION_KEY_SIZE {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(trimmed); err == nil && len(key) == ENCRYPTION_KEY_SIZE {
+		return key, nil
+	}
+	if len(material) == ENCRYPTION_KEY_SIZE {
+		return material, nil
+	}
+	return nil, ERROR_INVALID_ENCRYPTION_KEY
+}
+
+func (transforms FileTransforms) enabled() bool {
+	return transforms.compression != COMPRESSION_NO || transforms.aead != nil
+}
+
+func (transforms FileTransforms) header() []byte {
+	var applied []byte
+	switch transforms.compression {
+	case COMPRESSION_GZIP:
+		applied = append(applied, FILE_TRANSFORM_GZIP)
+	case COMPRESSION_FLATE:
+		applied = append(applied, FILE_TRANSFORM_FLATE)
+	}
+	if transforms.aead != nil {
+		applied = append(applied, FILE_TRANSFORM_AES_GCM)
+	}
+	header := append([]byte(FILE_HEADER_MAGIC), FILE_HEADER_VERSION, byte(len(applied)))
+	return append(header, applied...)
+}
+
+// parseFileHeader returns the transforms listed in the header of the file
+// and the size of the header. The files without header have no transforms.
+// The files that aren't encrypted are refused once a key is given, unless
+// allowed, except for the empty ones which hold nothing to trust.
+func parseFileHeader(data []byte, decryption FileDecryption) (FileTransforms, int, error) {
+	transforms := FileTransforms{compression: COMPRESSION_NO}
+	if !bytes.HasPrefix(data, []byte(FILE_HEADER_MAGIC)) {
+		if len(data) > 0 && decryption.aead != nil && !decryption.allowUnencrypted {
+			return transforms, 0, ERROR_FILE_NOT_ENCRYPTED
+		}
+		return transforms, 0, nil
+	}
+	reader := &snapshotReader{data: data[len(FILE_HEADER_MAGIC):]}
+	version := reader.byte()
+	applied := reader.bytes(int(reader.byte()))
+	if reader.err != nil {
+		return transforms, 0, reader.err
+	}
+	if version > FILE_HEADER_VERSION {
+		return transforms, 0, fmt.Errorf("%w file header version %d", ERROR_UNSUPPORTED_DUMP, version)
+	}
+	// Compressing before encrypting is the only order that makes sense
+	for i, transform := range applied {
+		compression, isCompression := FILE_TRANSFORM_COMPRESSIONS[transform]
+		switch {
+		case isCompression && i == 0:
+			transforms.compression = compression
+		case transform == FILE_TRANSFORM_AES_GCM && i == len(applied)-1:
+			if decryption.aead == nil {
+				return transforms, 0, ERROR_ENCRYPTION_KEY_MISSING
+			}
+			transforms.aead = decryption.aead
+		default:
+			return transforms, 0, fmt.Errorf("%w file transforms %v", ERROR_UNSUPPORTED_DUMP, applied)
+		}
+	}
+	if transforms.aead == nil && decryption.aead != nil && !decryption.allowUnencrypted {
+		return transforms, 0, ERROR_FILE_NOT_ENCRYPTED
+	}
+	return transforms, len(data) - len(reader.data), nil
+}
+
+// sealFile applies the transforms to the data of a dump, returned as is
+// without transforms.
+func sealFile(data []byte, transforms FileTransforms) ([]byte, error) {
+	if !transforms.enabled() {
+		return data, nil
+	}
+	return transforms.appendFrame(transforms.header(), data, 0, true)
+}
+
+// unsealFile reverses the transforms of an append only file, returning its
+// frames concatenated and the size of the file up to its last complete
+// frame, which is smaller than the file if the last one was cut short.
+func unsealFile(data []byte, decryption FileDecryption) ([]byte, int, error) {
+	return unsealFrames(data, decryption, false)
+}
+
+// unsealDump reverses the transforms of a dump, whose only frame can't be
+// cut short and must be the final one.
+func unsealDump(data []byte, decryption FileDecryption) ([]byte, error) {
+	unsealed, size, err := unsealFrames(data, decryption, true)
+	if err == nil && size != len(data) {
+		err = fmt.Errorf("%w: truncated frame", ERROR_CORRUPTED_SNAPSHOT)
+	}
+	return unsealed, err
+}
+
+// unsealFrames reverses the transforms of the frames of the file, the one
+// ending a dump being expected to be final.
+func unsealFrames(data []byte, decryption FileDecryption, dump bool) ([]byte, int, error) {
+	transforms, headerSize, err := parseFileHeader(data, decryption)
+	if err != nil || headerSize == 0 {
+		return data, len(data), err
+	}
+	var unsealed []byte
+	offset := headerSize
+	index := uint64(0)
+	for offset < len(data) {
+		length, n := binary.Uvarint(data[offset:])
+		if n <= 0 || length > uint64(len(data)-offset-n) {
+			break
+		}
+		end := offset + n + int(length)
+		frame, err := transforms.unsealFrame(data[offset+n:end], index, dump && end == len(data))
+		if err != nil {
+			return nil, 0, err
+		}
+		unsealed = append(unsealed, frame...)
+		offset = end
+		index++
+	}
+	if dump && index == 0 {
+		return nil, 0, fmt.Errorf("%w: missing frame", ERROR_CORRUPTED_SNAPSHOT)
+	}
+	return unsealed, offset, nil
+}
+
+// countFileFrames returns the number of complete frames of the file read
+// by reader, past its header, so that the next one appended is numbered
+// after them.
+func countFileFrames(reader *bufio.Reader) (uint64, error) {
+	frames := uint64(0)
+	for {
+		length, err := binary.ReadUvarint(reader)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return frames, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if length > math.MaxInt {
+			return frames, nil
+		}
+		if _, err := reader.Discard(int(length)); err == io.EOF {
+			return frames, nil
+		} else if err != nil {
+			return 0, err
+		}
+		frames++
+	}
+}
+
+// appendFrame appends the data, once compressed then encrypted, prefixed
+// by its length. The frame is authenticated along with its index in the
+// file and whether it is the final frame of a dump.
+func (transforms FileTransforms) appendFrame(dst []byte, data []byte, index uint64, final bool) ([]byte, error) {
+	frame, err := compress(data, transforms.compression)
+	if err != nil {
+		return nil, err
+	}
+	if transforms.aead != nil {
+		nonce := make([]byte, transforms.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		frame = transforms.aead.Seal(nonce, nonce, frame, transforms.frameData(index, final))
+	}
+	return append(binary.AppendUvarint(dst, uint64(len(frame))), frame...), nil
+}
+
+func (transforms FileTransforms) unsealFrame(frame []byte, index uint64, final bool) ([]byte, error) {
+	if transforms.aead != nil {
+		nonceSize := transforms.aead.NonceSize()
+		if len(frame) < nonceSize {
+			return nil, ERROR_DECRYPTION_FAILED
+		}
+		var err error
+		if frame, err = transforms.aead.Open(nil, frame[:nonceSize], frame[nonceSize:], transforms.frameData(index, final)); err != nil {
+			return nil, ERROR_DECRYPTION_FAILED
+		}
+	}
+	data, err := decompress(frame, transforms.compression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ERROR_CORRUPTED_SNAPSHOT, err)
+	}
+	return data, nil
+}
+
+// frameData returns the data authenticated along with a frame: the header
+// of the file, the index of the frame and whether it ends a dump
+func (transforms FileTransforms) frameData(index uint64, final bool) []byte {
+	data := binary.BigEndian.AppendUint64(transforms.header(), index)
+	if final {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func compress(data []byte, compression string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	switch compression {
+	case COMPRESSION_GZIP:
+		writer = gzip.NewWriter(&buffer)
+	case COMPRESSION_FLATE:
+		writer, _ = flate.NewWriter(&buffer, flate.DefaultCompression)
+	default:
+		return data, nil
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func decompress(data []byte, compression string) ([]byte, error) {
+	var reader io.ReadCloser
+	switch compression {
+	case COMPRESSION_GZIP:
+		var err error
+		if reader, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	case COMPRESSION_FLATE:
+		reader = flate.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+type SetEncryptionKeyCommand struct {
+	aead         cipher.AEAD
+	errorChannel chan error
+}
+
+type AllowUnencryptedLoadCommand struct {
+	done chan struct{}
+}
+
+type FileDecryptionCommand struct {
+	rspChannel chan FileDecryption
+}
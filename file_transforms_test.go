@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var TEST_ENCRYPTION_KEY = bytes.Repeat([]byte{0x2A}, ENCRYPTION_KEY_SIZE)
+
+func testFileTransforms(compression string, key []byte) FileTransforms {
+	return FileTransforms{compression, testFileDecryption(key).aead}
+}
+
+func testFileDecryption(key []byte) FileDecryption {
+	if key == nil {
+		return FileDecryption{}
+	}
+	aead, _ := newAESGCM(key)
+	return FileDecryption{aead: aead}
+}
+
+func TestSealFileRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("personal data ", 100))
+	for _, compression := range []string{COMPRESSION_NO, COMPRESSION_GZIP, COMPRESSION_FLATE} {
+		for _, key := range [][]byte{nil, TEST_ENCRYPTION_KEY} {
+			transforms := testFileTransforms(compression, key)
+			sealed, err := sealFile(data, transforms)
+			assert.Nil(t, err)
+			unsealed, err := unsealDump(sealed, testFileDecryption(key))
+			assert.Nil(t, err)
+			assert.Equal(t, data, unsealed)
+
+			if transforms.enabled() {
+				assert.True(t, bytes.HasPrefix(sealed, transforms.header()))
+				assert.NotContains(t, string(sealed), "personal data")
+			}
+			if compression != COMPRESSION_NO {
+				assert.Less(t, len(sealed), len(data)/10)
+			}
+		}
+	}
+
+	assert.Equal(t, []byte("XRFILE\x01\x02\x01\x03"), testFileTransforms(COMPRESSION_GZIP, TEST_ENCRYPTION_KEY).header())
+	assert.Equal(t, []byte("XRFILE\x01\x01\x02"), testFileTransforms(COMPRESSION_FLATE, nil).header())
+}
+
+func TestUnsealEncryptedFile(t *testing.T) {
+	decryption := testFileDecryption(TEST_ENCRYPTION_KEY)
+	sealed, _ := sealFile([]byte("secret"), testFileTransforms(COMPRESSION_GZIP, TEST_ENCRYPTION_KEY))
+
+	_, err := unsealDump(sealed, FileDecryption{})
+	assert.ErrorIs(t, err, ERROR_ENCRYPTION_KEY_MISSING)
+	_, err = unsealDump(sealed, testFileDecryption(bytes.Repeat([]byte{1}, ENCRYPTION_KEY_SIZE)))
+	assert.ErrorIs(t, err, ERROR_DECRYPTION_FAILED)
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1]++
+	_, err = unsealDump(tampered, decryption)
+	assert.ErrorIs(t, err, ERROR_DECRYPTION_FAILED)
+
+	// The header is authenticated as well
+	tampered = bytes.Clone(sealed)
+	tampered[len(FILE_HEADER_MAGIC)+2] = FILE_TRANSFORM_FLATE
+	_, err = unsealDump(tampered, decryption)
+	assert.ErrorIs(t, err, ERROR_DECRYPTION_FAILED)
+
+	_, _, err = unsealFile([]byte("XRFILE\x01\x02\x03\x01"), decryption)
+	assert.ErrorIs(t, err, ERROR_UNSUPPORTED_DUMP)
+	_, _, err = unsealFile([]byte("XRFILE\x02\x00"), FileDecryption{})
+	assert.ErrorIs(t, err, ERROR_UNSUPPORTED_DUMP)
+}
+
+func TestUnsealTruncatedFile(t *testing.T) {
+	decryption := testFileDecryption(TEST_ENCRYPTION_KEY)
+	transforms := testFileTransforms(COMPRESSION_FLATE, TEST_ENCRYPTION_KEY)
+	sealed, _ := transforms.appendFrame(transforms.header(), []byte("first"), 0, false)
+	complete := len(sealed)
+	sealed, _ = transforms.appendFrame(sealed, []byte("second"), 1, false)
+
+	unsealed, size, err := unsealFile(sealed[:len(sealed)-1], decryption)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("first"), unsealed)
+	assert.Equal(t, complete, size)
+
+	// A dump cut short, even right after its header, is corrupted
+	dump, _ := sealFile([]byte("first"), transforms)
+	_, err = unsealDump(dump[:len(dump)-1], decryption)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+	_, err = unsealDump(dump[:len(transforms.header())], decryption)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+}
+
+func TestFramesCantBeMovedAround(t *testing.T) {
+	decryption := testFileDecryption(TEST_ENCRYPTION_KEY)
+	transforms := testFileTransforms(COMPRESSION_NO, TEST_ENCRYPTION_KEY)
+	header := transforms.header()
+	frames := make([][]byte, 3)
+	for i := range frames {
+		frames[i], _ = transforms.appendFrame(nil, []byte(strconv.Itoa(i)), uint64(i), false)
+	}
+	file := func(frames ...[]byte) []byte {
+		return bytes.Join(append([][]byte{header}, frames...), nil)
+	}
+
+	unsealed, _, err := unsealFile(file(frames...), decryption)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("012"), unsealed)
+	for _, moved := range [][]byte{
+		file(frames[1], frames[0], frames[2]), // Reordered
+		file(frames[0], frames[1], frames[1]), // Replayed
+		file(frames[0], frames[2]),            // Dropped from the middle
+	} {
+		_, _, err = unsealFile(moved, decryption)
+		assert.ErrorIs(t, err, ERROR_DECRYPTION_FAILED)
+	}
+
+	// The frames of an append only file don't make a dump, and the other
+	// way around
+	_, err = unsealDump(file(frames[0]), decryption)
+	assert.ErrorIs(t, err, ERROR_DECRYPTION_FAILED)
+	dump, _ := sealFile([]byte("0"), transforms)
+	_, _, err = unsealFile(dump, decryption)
+	assert.ErrorIs(t, err, ERROR_DECRYPTION_FAILED)
+}
+
+func TestUnencryptedFilesRefusedWithKey(t *testing.T) {
+	decryption := testFileDecryption(TEST_ENCRYPTION_KEY)
+	plain, _ := encodeSnapshot([]map[string]XRedisValue{{"key": {RespString{"value"}, NON_EXPIRATION_TIME}}})
+	compressed, _ := sealFile(plain, testFileTransforms(COMPRESSION_GZIP, nil))
+	for _, data := range [][]byte{plain, compressed} {
+		_, err := unsealDump(data, decryption)
+		assert.ErrorIs(t, err, ERROR_FILE_NOT_ENCRYPTED)
+
+		decryption.allowUnencrypted = true
+		unsealed, err := unsealDump(data, decryption)
+		assert.Nil(t, err)
+		assert.Equal(t, plain, unsealed)
+		decryption.allowUnencrypted = false
+	}
+	_, _, err := unsealFile(nil, decryption)
+	assert.Nil(t, err)
+
+	// Refusing the file is a matter of configuration, so the dump is never
+	// moved aside
+	path := filepath.Join(t.TempDir(), DB_DUMP_FILE)
+	os.WriteFile(path, plain, 0644)
+	xredis := NewXRedis()
+	xredis.SetEncryptionKey(TEST_ENCRYPTION_KEY)
+	err = xredis.LoadDumpFile(path)
+	assert.ErrorIs(t, err, ERROR_FILE_NOT_ENCRYPTED)
+	assert.ErrorIs(t, recoverFromLoadError(err, LOAD_ERROR_EMPTY), ERROR_FILE_NOT_ENCRYPTED)
+	xredis.AllowUnencryptedLoad()
+	assert.Nil(t, xredis.LoadDumpFile(path))
+	assert.Equal(t, RespString{"value"}, xredis.Get("key"))
+}
+
+func TestReadEncryptionKey(t *testing.T) {
+	for _, material := range []string{
+		hex.EncodeToString(TEST_ENCRYPTION_KEY) + "\n",
+		base64.StdEncoding.EncodeToString(TEST_ENCRYPTION_KEY),
+		string(TEST_ENCRYPTION_KEY),
+	} {
+		key, err := parseEncryptionKey([]byte(material))
+		assert.Nil(t, err)
+		assert.Equal(t, TEST_ENCRYPTION_KEY, key)
+	}
+	_, err := parseEncryptionKey([]byte("too short"))
+	assert.ErrorIs(t, err, ERROR_INVALID_ENCRYPTION_KEY)
+
+	path := filepath.Join(t.TempDir(), "key")
+	os.WriteFile(path, []byte(hex.EncodeToString(TEST_ENCRYPTION_KEY)), 0600)
+	t.Setenv(ENCRYPTION_KEY_ENV, "")
+	key, err := readEncryptionKey("")
+	assert.Nil(t, err)
+	assert.Nil(t, key)
+	key, err = readEncryptionKey(path)
+	assert.Nil(t, err)
+	assert.Equal(t, TEST_ENCRYPTION_KEY, key)
+
+	t.Setenv(ENCRYPTION_KEY_ENV, base64.StdEncoding.EncodeToString(TEST_ENCRYPTION_KEY))
+	key, err = readEncryptionKey("")
+	assert.Nil(t, err)
+	assert.Equal(t, TEST_ENCRYPTION_KEY, key)
+
+	assert.ErrorIs(t, NewXRedis().SetEncryptionKey([]byte("short")), ERROR_INVALID_ENCRYPTION_KEY)
+}
+
+func TestSaveWithFileTransforms(t *testing.T) {
+	for _, format := range []string{DUMP_FORMAT_XREDIS, DUMP_FORMAT_RDB} {
+		path := filepath.Join(t.TempDir(), DB_DUMP_FILE)
+		saved := NewXRedis()
+		saved.SetDir(filepath.Dir(path))
+		saved.ConfigSet(map[string]string{CONFIG_DBFORMAT: format, CONFIG_PERSISTENCE_COMPRESSION: "GZIP"})
+		assert.Nil(t, saved.SetEncryptionKey(TEST_ENCRYPTION_KEY))
+		saved.Set("key", RespString{"personal data"})
+		assert.Nil(t, saved.Save())
+		data, _ := os.ReadFile(path)
+		assert.True(t, bytes.HasPrefix(data, []byte(FILE_HEADER_MAGIC)))
+		assert.NotContains(t, string(data), "personal data")
+
+		xredis := NewXRedis()
+		assert.Nil(t, xredis.SetEncryptionKey(TEST_ENCRYPTION_KEY))
+		assert.Nil(t, xredis.LoadDumpFile(path))
+		assert.Equal(t, RespString{"personal data"}, xredis.Get("key"))
+		assert.Nil(t, xredis.Load(data))
+
+		// A missing key is a configuration mistake, for which the dump is
+		// never moved aside
+		err := NewXRedis().LoadDumpFile(path)
+		assert.ErrorIs(t, err, ERROR_ENCRYPTION_KEY_MISSING)
+		assert.ErrorIs(t, recoverFromLoadError(err, LOAD_ERROR_EMPTY), ERROR_ENCRYPTION_KEY_MISSING)
+		_, statErr := os.Stat(path)
+		assert.Nil(t, statErr)
+	}
+
+	err := NewXRedis().ConfigSet(map[string]string{CONFIG_PERSISTENCE_COMPRESSION: "zip"})
+	assert.Equal(t, REQUEST_ERROR_INVALID_CONFIG_VALUE, err.Error())
+}
+
+func TestAppendOnlyFileWithTransforms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	xredis.ConfigSet(map[string]string{CONFIG_PERSISTENCE_COMPRESSION: COMPRESSION_FLATE})
+	xredis.SetEncryptionKey(TEST_ENCRYPTION_KEY)
+	assert.Nil(t, xredis.OpenAppendOnlyFile(path))
+	client := NewClient(xredis)
+	_ = handleRequest(client, []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$13\r\npersonal data\r\n"))
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	// The writes are gathered into a frame, written along with the fsync
+	headerSize := len(testFileTransforms(COMPRESSION_FLATE, TEST_ENCRYPTION_KEY).header())
+	assert.Equal(t, int64(headerSize), fileSize(path))
+	assert.Nil(t, xredis.CloseAppendOnlyFile())
+	data, _ := os.ReadFile(path)
+	frames, _ := countFileFrames(bufio.NewReader(bytes.NewReader(data[headerSize:])))
+	assert.Equal(t, uint64(1), frames)
+	assert.True(t, bytes.HasPrefix(data, testFileTransforms(COMPRESSION_FLATE, TEST_ENCRYPTION_KEY).header()))
+	assert.NotContains(t, string(data), "personal data")
+
+	// A frame cut short is dropped, as a request would be
+	os.WriteFile(path, append(data, 0x40, 0x01), 0644)
+	replayed := NewXRedis()
+	replayed.SetEncryptionKey(TEST_ENCRYPTION_KEY)
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.Equal(t, RespString{"personal data"}, replayed.Get("key"))
+	assert.Equal(t, RespString{"1"}, replayed.Get("counter"))
+	assert.Equal(t, int64(len(data)), fileSize(path))
+	assert.ErrorIs(t, replayAppendOnlyFile(NewXRedis(), path), ERROR_ENCRYPTION_KEY_MISSING)
+
+	// The frames written once the file is opened again follow its own
+	assert.Nil(t, xredis.OpenAppendOnlyFile(path))
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	assert.Nil(t, xredis.CloseAppendOnlyFile())
+	replayed = NewXRedis()
+	replayed.SetEncryptionKey(TEST_ENCRYPTION_KEY)
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.Equal(t, RespString{"2"}, replayed.Get("counter"))
+
+	// The existing file keeps its transforms until the rewrite applies the
+	// configured ones
+	xredis.ConfigSet(map[string]string{CONFIG_PERSISTENCE_COMPRESSION: COMPRESSION_GZIP})
+	assert.Nil(t, xredis.OpenAppendOnlyFile(path))
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	xredis.Atomically(func(tx *XRedis) {
+		assert.Nil(t, tx.RewriteAppendOnlyFile())
+		tx.Set("buffered", RespString{"1"})
+		tx.AppendToLog(stringsToRespArray([]string{REQUEST_SET, "buffered", "1"}))
+	})
+	assert.Eventually(t, func() bool { return !xredis.AppendOnlyRewriteInProgress() }, time.Second, time.Millisecond)
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	assert.Nil(t, xredis.CloseAppendOnlyFile())
+	data, _ = os.ReadFile(path)
+	assert.True(t, bytes.HasPrefix(data, testFileTransforms(COMPRESSION_GZIP, TEST_ENCRYPTION_KEY).header()))
+
+	replayed = NewXRedis()
+	replayed.SetEncryptionKey(TEST_ENCRYPTION_KEY)
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.Equal(t, RespString{"4"}, replayed.Get("counter"))
+	assert.Equal(t, RespString{"1"}, replayed.Get("buffered"))
+}
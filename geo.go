@@ -0,0 +1,529 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Bounds of the coordinates that can be indexed, those of the EPSG:3857
+// projection as in Redis
+const GEO_LONGITUDE_MIN = -180
+const GEO_LONGITUDE_MAX = 180
+const GEO_LATITUDE_MIN = -85.05112878
+const GEO_LATITUDE_MAX = 85.05112878
+
+// Number of times each coordinate range is halved by the geohashes, whose
+// 52 bits fit exactly in the float64 scores of the sorted sets
+const GEO_STEP_MAX = 26
+
+const GEO_EARTH_RADIUS_IN_METERS = 6372797.560856
+
+// Standard geohash strings, as replied by GEOHASH, span the whole latitude
+// range
+const GEOHASH_STRING_LATITUDE_MIN = -90
+const GEOHASH_STRING_LATITUDE_MAX = 90
+const GEOHASH_STRING_ALPHABET = "0123456789bcdefghjkmnpqrstuvwxyz"
+const GEOHASH_STRING_LENGTH = 11
+
+const (
+	GEO_SORT_NONE = iota
+	GEO_SORT_ASC
+	GEO_SORT_DESC
+)
+
+type GeoPoint struct {
+	longitude float64
+	latitude  float64
+}
+
+// GeoMember is a member added by GEOADD at the given coordinates
+type GeoMember struct {
+	member string
+	point  GeoPoint
+}
+
+// GeoAddOptions are the NX, XX and CH options of GEOADD, which behave as
+// those of ZADD.
+type GeoAddOptions struct {
+	onlyNew      bool // NX: existing members are not updated
+	onlyExisting bool // XX: new members are not added
+	countChanged bool // CH: updated members are counted along with the new ones
+}
+
+// GeoShape is the area searched around the center, either a circle of the
+// given radius or a box of the given width and height. The dimensions, as
+// the distances of the results, are expressed in units of unit meters.
+type GeoShape struct {
+	byBox  bool
+	radius float64
+	width  float64
+	height float64
+	unit   float64
+}
+
+// GeoQuery searches the members within the shape around either the given
+// point or, when it's nil, the position of fromMember.
+type GeoQuery struct {
+	from       *GeoPoint
+	fromMember string
+	shape      GeoShape
+	sort       int
+	count      int  // Maximum number of results, if positive
+	any        bool // Stop as soon as count results are found instead of returning the closest ones
+}
+
+type GeoSearchResult struct {
+	member   string
+	point    GeoPoint
+	distance float64 // Distance to the center of the search, in the unit of the shape
+	hash     uint64
+}
+
+// GeoAdd sets the position of the members in the sorted set, creating it
+// if needed, and returns the number of members added, plus those updated
+// with CH.
+func (xredis *XRedis) GeoAdd(key string, members []GeoMember, options GeoAddOptions) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- GeoAddCommand{xredis.db, key, members, options, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// GeoPos returns the position of each member, nil for the missing ones.
+// Positions are those of the center of the geohash cell of the members,
+// which differ slightly from the ones they were added with.
+func (xredis *XRedis) GeoPos(key string, members []string) ([]*GeoPoint, error) {
+	rspChan := make(chan []*GeoPoint)
+	errorChan := make(chan error)
+	xredis.commands <- GeoPosCommand{xredis.db, key, members, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// GeoDist returns the distance in meters between two members, and whether
+// both of them exist.
+func (xredis *XRedis) GeoDist(key string, member1 string, member2 string) (float64, bool, error) {
+	rspChan := make(chan float64)
+	existsChan := make(chan bool)
+	errorChan := make(chan error)
+	xredis.commands <- GeoDistCommand{xredis.db, key, member1, member2, rspChan, existsChan, errorChan}
+	return <-rspChan, <-existsChan, <-errorChan
+}
+
+// GeoSearch returns the members within the shape of the query.
+func (xredis *XRedis) GeoSearch(key string, query GeoQuery) ([]GeoSearchResult, error) {
+	rspChan := make(chan []GeoSearchResult)
+	errorChan := make(chan error)
+	xredis.commands <- GeoSearchCommand{xredis.db, key, query, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// GeoSearchStore stores at destKey the members of srcKey within the shape
+// of the query, scored by their geohash or, with storeDist, by their
+// distance to the center. It returns the number of members stored.
+func (xredis *XRedis) GeoSearchStore(destKey string, srcKey string, query GeoQuery, storeDist bool) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- GeoSearchStoreCommand{xredis.db, destKey, srcKey, query, storeDist, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) handleGeoAddCommand(cmd GeoAddCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	sortedSet, exists, err := xredis.getSortedSet(cmd.db, cmd.key)
+	if err != nil {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+	if !exists {
+		sortedSet = NewSortedSet()
+	}
+
+	added, updated := 0, 0
+	for _, geoMember := range cmd.members {
+		_, memberExists := sortedSet.score(geoMember.member)
+		if (memberExists && cmd.options.onlyNew) || (!memberExists && cmd.options.onlyExisting) {
+			continue
+		}
+		isNew, changed := sortedSet.add(geoMember.member, float64(geohashEncode(geoMember.point)))
+		added += bool2Int(isNew)
+		updated += bool2Int(changed)
+	}
+
+	if added+updated > 0 {
+		if !exists {
+			xredis.setValue(cmd.db, cmd.key, XRedisValue{sortedSet, NON_EXPIRATION_TIME})
+		}
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_ZSET, KEYSPACE_EVENT_ZADD, cmd.db, cmd.key)
+	}
+	if cmd.options.countChanged {
+		added += updated
+	}
+	cmd.rspChannel <- added
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleGeoPosCommand(cmd GeoPosCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	sortedSet, exists, err := xredis.getSortedSet(cmd.db, cmd.key)
+	if err != nil {
+		cmd.rspChannel <- nil
+		cmd.errorChannel <- err
+		return
+	}
+	positions := make([]*GeoPoint, len(cmd.members))
+	for i, member := range cmd.members {
+		if !exists {
+			continue
+		}
+		if score, memberExists := sortedSet.score(member); memberExists {
+			point := geohashDecode(uint64(score))
+			positions[i] = &point
+		}
+	}
+	cmd.rspChannel <- positions
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleGeoDistCommand(cmd GeoDistCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.existsChannel)
+	defer close(cmd.errorChannel)
+
+	sortedSet, exists, err := xredis.getSortedSet(cmd.db, cmd.key)
+	if err != nil || !exists {
+		cmd.rspChannel <- 0
+		cmd.existsChannel <- false
+		cmd.errorChannel <- err
+		return
+	}
+	score1, exists1 := sortedSet.score(cmd.member1)
+	score2, exists2 := sortedSet.score(cmd.member2)
+	if !exists1 || !exists2 {
+		cmd.rspChannel <- 0
+		cmd.existsChannel <- false
+		cmd.errorChannel <- nil
+		return
+	}
+	cmd.rspChannel <- geoDistance(geohashDecode(uint64(score1)), geohashDecode(uint64(score2)))
+	cmd.existsChannel <- true
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleGeoSearchCommand(cmd GeoSearchCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	results, err := xredis.geoSearch(cmd.db, cmd.key, cmd.query)
+	cmd.rspChannel <- results
+	cmd.errorChannel <- err
+}
+
+func (xredis *XRedis) handleGeoSearchStoreCommand(cmd GeoSearchStoreCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	results, err := xredis.geoSearch(cmd.db, cmd.srcKey, cmd.query)
+	if err != nil {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+
+	if len(results) == 0 {
+		if _, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.destKey); exists {
+			xredis.deleteValue(cmd.db, cmd.destKey)
+			xredis.touchKey(cmd.db, cmd.destKey)
+			xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_DEL, cmd.db, cmd.destKey)
+		}
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- nil
+		return
+	}
+
+	sortedSet := NewSortedSet()
+	for _, result := range results {
+		score := float64(result.hash)
+		if cmd.storeDist {
+			score = result.distance
+		}
+		sortedSet.add(result.member, score)
+	}
+	xredis.setValue(cmd.db, cmd.destKey, XRedisValue{sortedSet, NON_EXPIRATION_TIME})
+	xredis.touchKey(cmd.db, cmd.destKey)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_ZSET, KEYSPACE_EVENT_GEOSEARCHSTORE, cmd.db, cmd.destKey)
+	cmd.rspChannel <- len(results)
+	cmd.errorChannel <- nil
+}
+
+// geoSearch looks up the members within the shape of the query in the
+// cells of the geohash grid covering it, then sorts and limits them as
+// the query tells.
+func (xredis *XRedis) geoSearch(db int, key string, query GeoQuery) ([]GeoSearchResult, error) {
+	sortedSet, exists, err := xredis.getSortedSet(db, key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []GeoSearchResult{}, nil
+	}
+
+	var center GeoPoint
+	if query.from != nil {
+		center = *query.from
+	} else {
+		score, memberExists := sortedSet.score(query.fromMember)
+		if !memberExists {
+			return nil, errors.New(REQUEST_ERROR_GEO_MEMBER_NOT_FOUND)
+		}
+		center = geohashDecode(uint64(score))
+	}
+
+	results := make([]GeoSearchResult, 0)
+	for _, scoreRange := range geoSearchRanges(center, query.shape) {
+		for _, member := range sortedSet.rangeByScore(scoreRange[0], scoreRange[1]) {
+			hash := uint64(member.Score)
+			point := geohashDecode(hash)
+			distance, ok := query.shape.contains(center, point)
+			if !ok {
+				continue
+			}
+			results = append(results, GeoSearchResult{member.Member, point, distance / query.shape.unit, hash})
+			if query.any && len(results) == query.count {
+				break
+			}
+		}
+		if query.any && len(results) == query.count {
+			break
+		}
+	}
+
+	sort := query.sort
+	// The closest members are returned when there are too many of them
+	if sort == GEO_SORT_NONE && query.count > 0 && !query.any {
+		sort = GEO_SORT_ASC
+	}
+	switch sort {
+	case GEO_SORT_ASC:
+		slices.SortStableFunc(results, func(a, b GeoSearchResult) int {
+			return cmp.Compare(a.distance, b.distance)
+		})
+	case GEO_SORT_DESC:
+		slices.SortStableFunc(results, func(a, b GeoSearchResult) int {
+			return cmp.Compare(b.distance, a.distance)
+		})
+	}
+	if query.count > 0 && len(results) > query.count {
+		results = results[:query.count]
+	}
+	return results, nil
+}
+
+// geoSearchRanges returns the score ranges of the geohash cells that may
+// hold members within the shape: the cell of the center and its eight
+// neighbors, at the finest step whose cells are at least as wide and as
+// high as the shape so that they cover it whole.
+func geoSearchRanges(center GeoPoint, shape GeoShape) [][2]float64 {
+	halfWidth, halfHeight := shape.radius*shape.unit, shape.radius*shape.unit
+	if shape.byBox {
+		halfWidth, halfHeight = shape.width*shape.unit/2, shape.height*shape.unit/2
+	}
+	latitudeDelta := radiansToDegrees(halfHeight / GEO_EARTH_RADIUS_IN_METERS)
+	// Degrees of longitude shrink towards the poles, so the widest delta is
+	// the one at the latitude of the shape the closest to a pole
+	longitudeDelta := float64(GEO_LONGITUDE_MAX - GEO_LONGITUDE_MIN)
+	if farthestLatitude := math.Abs(center.latitude) + latitudeDelta; farthestLatitude < 90 {
+		longitudeDelta = radiansToDegrees(halfWidth / (GEO_EARTH_RADIUS_IN_METERS * math.Cos(degreesToRadians(farthestLatitude))))
+	}
+
+	step := uint(GEO_STEP_MAX)
+	for step > 0 {
+		cells := float64(uint64(1) << step)
+		if (GEO_LONGITUDE_MAX-GEO_LONGITUDE_MIN)/cells >= longitudeDelta && (GEO_LATITUDE_MAX-GEO_LATITUDE_MIN)/cells >= latitudeDelta {
+			break
+		}
+		step--
+	}
+	if step == 0 {
+		return [][2]float64{{0, float64(uint64(1)<<(2*GEO_STEP_MAX) - 1)}}
+	}
+
+	cells := int64(1) << step
+	latitudeIndex, longitudeIndex := geohashDeinterleave(geohashEncodeStep(center, GEO_LATITUDE_MIN, GEO_LATITUDE_MAX, step), step)
+	shift := 2 * (GEO_STEP_MAX - step)
+	var ranges [][2]float64
+	seen := make(map[uint64]bool)
+	for latitudeOffset := int64(-1); latitudeOffset <= 1; latitudeOffset++ {
+		neighborLatitude := int64(latitudeIndex) + latitudeOffset
+		if neighborLatitude < 0 || neighborLatitude >= cells {
+			continue
+		}
+		for longitudeOffset := int64(-1); longitudeOffset <= 1; longitudeOffset++ {
+			// Longitudes wrap around the antimeridian
+			neighborLongitude := (int64(longitudeIndex) + longitudeOffset + cells) % cells
+			cell := geohashInterleave(uint32(neighborLatitude), uint32(neighborLongitude), step)
+			if seen[cell] {
+				continue
+			}
+			seen[cell] = true
+			ranges = append(ranges, [2]float64{float64(cell << shift), float64((cell+1)<<shift - 1)})
+		}
+	}
+	return ranges
+}
+
+// contains returns the distance in meters between the center and the
+// point, and whether the point lies within the shape around the center.
+func (shape GeoShape) contains(center GeoPoint, point GeoPoint) (float64, bool) {
+	distance := geoDistance(center, point)
+	if !shape.byBox {
+		return distance, distance <= shape.radius*shape.unit
+	}
+	latitudeDistance := GEO_EARTH_RADIUS_IN_METERS * math.Abs(degreesToRadians(point.latitude-center.latitude))
+	if latitudeDistance > shape.height*shape.unit/2 {
+		return 0, false
+	}
+	longitudeDistance := geoDistance(GeoPoint{center.longitude, point.latitude}, point)
+	if longitudeDistance > shape.width*shape.unit/2 {
+		return 0, false
+	}
+	return distance, true
+}
+
+func (point GeoPoint) valid() bool {
+	return point.longitude >= GEO_LONGITUDE_MIN && point.longitude <= GEO_LONGITUDE_MAX &&
+		point.latitude >= GEO_LATITUDE_MIN && point.latitude <= GEO_LATITUDE_MAX
+}
+
+// geohash returns the standard 11 characters geohash string of the point
+func (point GeoPoint) geohash() string {
+	hash := geohashEncodeStep(point, GEOHASH_STRING_LATITUDE_MIN, GEOHASH_STRING_LATITUDE_MAX, GEO_STEP_MAX)
+	var geohash strings.Builder
+	for i := 0; i < GEOHASH_STRING_LENGTH; i++ {
+		// The 52 bits only fill 10 characters and a half, the last one is 0
+		index := 0
+		if i < GEOHASH_STRING_LENGTH-1 {
+			index = int(hash>>(2*GEO_STEP_MAX-(i+1)*5)) & 0x1f
+		}
+		geohash.WriteByte(GEOHASH_STRING_ALPHABET[index])
+	}
+	return geohash.String()
+}
+
+// geohashEncode returns the 52 bits geohash stored as the score of a point
+func geohashEncode(point GeoPoint) uint64 {
+	return geohashEncodeStep(point, GEO_LATITUDE_MIN, GEO_LATITUDE_MAX, GEO_STEP_MAX)
+}
+
+// geohashEncodeStep returns the index of the cell holding the point when
+// both coordinate ranges are halved step times, interleaving the bits of
+// the longitude and latitude indexes.
+func geohashEncodeStep(point GeoPoint, latitudeMin float64, latitudeMax float64, step uint) uint64 {
+	cells := float64(uint64(1) << step)
+	latitudeIndex := min((point.latitude-latitudeMin)/(latitudeMax-latitudeMin)*cells, cells-1)
+	longitudeIndex := min((point.longitude-GEO_LONGITUDE_MIN)/(GEO_LONGITUDE_MAX-GEO_LONGITUDE_MIN)*cells, cells-1)
+	return geohashInterleave(uint32(latitudeIndex), uint32(longitudeIndex), step)
+}
+
+// geohashDecode returns the center of the cell of the 52 bits geohash
+func geohashDecode(hash uint64) GeoPoint {
+	latitudeIndex, longitudeIndex := geohashDeinterleave(hash, GEO_STEP_MAX)
+	cells := float64(uint64(1) << GEO_STEP_MAX)
+	latitudeMin := GEO_LATITUDE_MIN + float64(latitudeIndex)/cells*(GEO_LATITUDE_MAX-GEO_LATITUDE_MIN)
+	latitudeMax := GEO_LATITUDE_MIN + float64(latitudeIndex+1)/cells*(GEO_LATITUDE_MAX-GEO_LATITUDE_MIN)
+	longitudeMin := GEO_LONGITUDE_MIN + float64(longitudeIndex)/cells*(GEO_LONGITUDE_MAX-GEO_LONGITUDE_MIN)
+	longitudeMax := GEO_LONGITUDE_MIN + float64(longitudeIndex+1)/cells*(GEO_LONGITUDE_MAX-GEO_LONGITUDE_MIN)
+	return GeoPoint{
+		min(max((longitudeMin+longitudeMax)/2, GEO_LONGITUDE_MIN), GEO_LONGITUDE_MAX),
+		min(max((latitudeMin+latitudeMax)/2, GEO_LATITUDE_MIN), GEO_LATITUDE_MAX),
+	}
+}
+
+// geohashInterleave merges the indexes bit by bit, the longitude taking
+// the most significant bit of each pair.
+func geohashInterleave(latitudeIndex uint32, longitudeIndex uint32, step uint) uint64 {
+	var hash uint64
+	for i := int(step) - 1; i >= 0; i-- {
+		hash = hash<<2 | uint64(longitudeIndex>>i&1)<<1 | uint64(latitudeIndex>>i&1)
+	}
+	return hash
+}
+
+func geohashDeinterleave(hash uint64, step uint) (uint32, uint32) {
+	var latitudeIndex, longitudeIndex uint32
+	for i := int(step) - 1; i >= 0; i-- {
+		longitudeIndex = longitudeIndex<<1 | uint32(hash>>(2*i+1)&1)
+		latitudeIndex = latitudeIndex<<1 | uint32(hash>>(2*i)&1)
+	}
+	return latitudeIndex, longitudeIndex
+}
+
+// geoDistance returns the great-circle distance in meters between the
+// points, computed with the haversine formula.
+func geoDistance(a GeoPoint, b GeoPoint) float64 {
+	latitudeA, latitudeB := degreesToRadians(a.latitude), degreesToRadians(b.latitude)
+	u := math.Sin((latitudeB - latitudeA) / 2)
+	v := math.Sin(degreesToRadians(b.longitude-a.longitude) / 2)
+	return 2 * GEO_EARTH_RADIUS_IN_METERS * math.Asin(math.Sqrt(u*u+math.Cos(latitudeA)*math.Cos(latitudeB)*v*v))
+}
+
+func degreesToRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func radiansToDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+type GeoAddCommand struct {
+	db           int
+	key          string
+	members      []GeoMember
+	options      GeoAddOptions
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type GeoPosCommand struct {
+	db           int
+	key          string
+	members      []string
+	rspChannel   chan []*GeoPoint
+	errorChannel chan error
+}
+
+type GeoDistCommand struct {
+	db            int
+	key           string
+	member1       string
+	member2       string
+	rspChannel    chan float64
+	existsChannel chan bool
+	errorChannel  chan error
+}
+
+type GeoSearchCommand struct {
+	db           int
+	key          string
+	query        GeoQuery
+	rspChannel   chan []GeoSearchResult
+	errorChannel chan error
+}
+
+type GeoSearchStoreCommand struct {
+	db           int
+	destKey      string
+	srcKey       string
+	query        GeoQuery
+	storeDist    bool
+	rspChannel   chan int
+	errorChannel chan error
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var PALERMO = GeoMember{"Palermo", GeoPoint{13.361389, 38.115556}}
+var CATANIA = GeoMember{"Catania", GeoPoint{15.087269, 37.502669}}
+
+func TestGeoAdd(t *testing.T) {
+	xredis := NewXRedis()
+
+	count, err := xredis.GeoAdd("Sicily", []GeoMember{PALERMO, CATANIA}, GeoAddOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, TYPE_ZSET, xredis.Type("Sicily"))
+
+	moved := GeoMember{"Palermo", GeoPoint{13.5, 38}}
+	count, _ = xredis.GeoAdd("Sicily", []GeoMember{moved}, GeoAddOptions{onlyNew: true})
+	assert.Equal(t, 0, count)
+	count, _ = xredis.GeoAdd("Sicily", []GeoMember{moved}, GeoAddOptions{})
+	assert.Equal(t, 0, count)
+	count, _ = xredis.GeoAdd("Sicily", []GeoMember{PALERMO}, GeoAddOptions{countChanged: true})
+	assert.Equal(t, 1, count)
+
+	count, _ = xredis.GeoAdd("Missing", []GeoMember{PALERMO}, GeoAddOptions{onlyExisting: true})
+	assert.Equal(t, 0, count)
+	assert.False(t, xredis.Exists("Missing"))
+}
+
+func TestGeoAddOnWrongType(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("key", RespString{"value"})
+
+	_, err := xredis.GeoAdd("key", []GeoMember{PALERMO}, GeoAddOptions{})
+	assert.Equal(t, REQUEST_ERROR_WRONG_TYPE, err.Error())
+}
+
+func TestGeoPosAndGeoDist(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.GeoAdd("Sicily", []GeoMember{PALERMO, CATANIA}, GeoAddOptions{})
+
+	positions, err := xredis.GeoPos("Sicily", []string{"Palermo", "Missing"})
+	assert.Nil(t, err)
+	assert.InDelta(t, PALERMO.point.longitude, positions[0].longitude, 1e-5)
+	assert.InDelta(t, PALERMO.point.latitude, positions[0].latitude, 1e-5)
+	assert.Nil(t, positions[1])
+
+	distance, exists, _ := xredis.GeoDist("Sicily", "Palermo", "Catania")
+	assert.True(t, exists)
+	assert.InDelta(t, 166274.1516, distance, 1e-4)
+	_, exists, _ = xredis.GeoDist("Sicily", "Palermo", "Missing")
+	assert.False(t, exists)
+}
+
+func TestGeohash(t *testing.T) {
+	assert.Equal(t, uint64(3479099956230698), geohashEncode(PALERMO.point))
+	assert.Equal(t, "sqc8b49rny0", geohashDecode(geohashEncode(PALERMO.point)).geohash())
+	assert.Equal(t, "sqdtr74hyu0", geohashDecode(geohashEncode(CATANIA.point)).geohash())
+}
+
+func TestGeoSearch(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.GeoAdd("Sicily", []GeoMember{PALERMO, CATANIA}, GeoAddOptions{})
+	center := GeoPoint{15, 37}
+	kilometers := GEO_UNITS[GEO_UNIT_KILOMETERS]
+
+	query := GeoQuery{from: &center, shape: GeoShape{radius: 200, unit: kilometers}, sort: GEO_SORT_DESC}
+	results, err := xredis.GeoSearch("Sicily", query)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, "Palermo", results[0].member)
+	assert.InDelta(t, 190.4424, results[0].distance, 1e-4)
+	assert.Equal(t, "Catania", results[1].member)
+
+	query = GeoQuery{from: &center, shape: GeoShape{radius: 100, unit: kilometers}}
+	results, _ = xredis.GeoSearch("Sicily", query)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "Catania", results[0].member)
+
+	// Palermo lies 151km west of Catania and 68km north of it
+	query = GeoQuery{fromMember: "Catania", shape: GeoShape{byBox: true, width: 400, height: 150, unit: kilometers}}
+	results, _ = xredis.GeoSearch("Sicily", query)
+	assert.Equal(t, 2, len(results))
+	query.shape.width = 250
+	results, _ = xredis.GeoSearch("Sicily", query)
+	assert.Equal(t, 1, len(results))
+
+	query = GeoQuery{from: &center, shape: GeoShape{radius: 200, unit: kilometers}, count: 1}
+	results, _ = xredis.GeoSearch("Sicily", query)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "Catania", results[0].member)
+
+	_, err = xredis.GeoSearch("Sicily", GeoQuery{fromMember: "Missing", shape: GeoShape{radius: 1, unit: 1}})
+	assert.Equal(t, REQUEST_ERROR_GEO_MEMBER_NOT_FOUND, err.Error())
+	results, _ = xredis.GeoSearch("Missing", GeoQuery{fromMember: "Missing", shape: GeoShape{radius: 1, unit: 1}})
+	assert.Empty(t, results)
+}
+
+func TestGeoSearchAcrossTheAntimeridian(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.GeoAdd("Pacific", []GeoMember{
+		{"east", GeoPoint{179.9, 0}},
+		{"west", GeoPoint{-179.9, 0}},
+		{"far", GeoPoint{170, 0}},
+	}, GeoAddOptions{})
+
+	center := GeoPoint{179.95, 0}
+	query := GeoQuery{from: &center, shape: GeoShape{radius: 50, unit: GEO_UNITS[GEO_UNIT_KILOMETERS]}, sort: GEO_SORT_ASC}
+	results, _ := xredis.GeoSearch("Pacific", query)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, "east", results[0].member)
+	assert.Equal(t, "west", results[1].member)
+}
+
+func TestGeoSearchStore(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.GeoAdd("Sicily", []GeoMember{PALERMO, CATANIA}, GeoAddOptions{})
+	center := GeoPoint{15, 37}
+	query := GeoQuery{from: &center, shape: GeoShape{radius: 100, unit: GEO_UNITS[GEO_UNIT_KILOMETERS]}}
+
+	count, err := xredis.GeoSearchStore("dest", "Sicily", query, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	positions, _ := xredis.GeoPos("dest", []string{"Catania"})
+	assert.NotNil(t, positions[0])
+
+	xredis.GeoSearchStore("dest", "Sicily", query, true)
+	sortedSet, _, _ := xredis.getSortedSet(DEFAULT_DATABASE, "dest")
+	score, _ := sortedSet.score("Catania")
+	assert.InDelta(t, 56.4413, score, 1e-4)
+
+	query.shape.radius = 1
+	count, _ = xredis.GeoSearchStore("dest", "Sicily", query, false)
+	assert.Equal(t, 0, count)
+	assert.False(t, xredis.Exists("dest"))
+}
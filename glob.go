@@ -0,0 +1,96 @@
+package main
+
+// globMatch reports whether str matches the Redis style glob pattern.
+// Supported syntax:
+//   - '*' matches any sequence of characters, including the empty one
+//   - '?' matches any single character
+//   - '[abc]', '[^abc]' and '[a-z]' match character classes and ranges
+//   - '\' escapes the following character
+//
+// Only the last '*' is backtracked to, by making it match one more byte:
+// the earlier ones can't do better, since whatever follows the last '*' can
+// be matched by it too. This keeps the matching in O(len(pattern) *
+// len(str)) whatever the number of stars.
+func globMatch(pattern string, str string) bool {
+	p, s := 0, 0
+	star, starMatch := -1, 0
+	for s < len(str) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				star, starMatch = p, s
+				p++
+				continue
+			}
+			if matched, width := globMatchChar(pattern[p:], str[s]); matched {
+				p += width
+				s++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		starMatch++
+		p, s = star+1, starMatch
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// globMatchChar matches char against the pattern element at pattern[0],
+// which isn't a '*'. It returns whether the char matches and the number of
+// pattern bytes taken by the element.
+func globMatchChar(pattern string, char byte) (bool, int) {
+	switch pattern[0] {
+	case '?':
+		return true, 1
+	case '[':
+		return globMatchClass(pattern, char)
+	case '\\':
+		if len(pattern) >= 2 {
+			return pattern[1] == char, 2
+		}
+	}
+	return pattern[0] == char, 1
+}
+
+// globMatchClass matches char against the character class that starts at
+// pattern[0] (a '['). It returns whether the char belongs to the class and
+// the number of pattern bytes taken by the class, including both brackets.
+// An unterminated class extends until the end of the pattern.
+func globMatchClass(pattern string, char byte) (bool, int) {
+	i := 1
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+	matched := false
+	for ; i < len(pattern) && pattern[i] != ']'; i++ {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			i++
+			if pattern[i] == char {
+				matched = true
+			}
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			start, end := pattern[i], pattern[i+2]
+			if start > end {
+				start, end = end, start
+			}
+			if char >= start && char <= end {
+				matched = true
+			}
+			i += 2
+		default:
+			if pattern[i] == char {
+				matched = true
+			}
+		}
+	}
+	if i == len(pattern) {
+		return matched != negate, i
+	}
+	return matched != negate, i + 1
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobMatchLiterals(t *testing.T) {
+	assert.True(t, globMatch("hello", "hello"))
+	assert.False(t, globMatch("hello", "hell"))
+	assert.False(t, globMatch("hell", "hello"))
+}
+
+func TestGlobMatchWildcards(t *testing.T) {
+	assert.True(t, globMatch("*", ""))
+	assert.True(t, globMatch("h*o", "hello"))
+	assert.True(t, globMatch("h**o", "ho"))
+	assert.True(t, globMatch("h?llo", "hallo"))
+	assert.False(t, globMatch("h?llo", "hllo"))
+	assert.True(t, globMatch("user:*:name", "user:10:name"))
+	assert.False(t, globMatch("user:*:name", "user:10:age"))
+}
+
+func TestGlobMatchClasses(t *testing.T) {
+	assert.True(t, globMatch("h[ae]llo", "hello"))
+	assert.False(t, globMatch("h[ae]llo", "hillo"))
+	assert.True(t, globMatch("h[^e]llo", "hallo"))
+	assert.False(t, globMatch("h[^e]llo", "hello"))
+	assert.True(t, globMatch("h[a-b]llo", "hbllo"))
+	assert.True(t, globMatch("h[b-a]llo", "hallo"))
+	assert.False(t, globMatch("h[a-b]llo", "hcllo"))
+	assert.True(t, globMatch("h[a", "ha"))
+}
+
+func TestGlobMatchEscapes(t *testing.T) {
+	assert.True(t, globMatch("h\\*llo", "h*llo"))
+	assert.False(t, globMatch("h\\*llo", "hello"))
+	assert.True(t, globMatch("h[\\]]llo", "h]llo"))
+}
+
+func TestGlobMatchBacktracksInLinearTime(t *testing.T) {
+	str := strings.Repeat("a", 100)
+	assert.False(t, globMatch(strings.Repeat("*a", 30)+"b", str))
+	assert.True(t, globMatch(strings.Repeat("*a", 30)+"*", str))
+	assert.True(t, globMatch("*a*b*c", "xaxxbxxxc"))
+	assert.False(t, globMatch("*a*b*c", "xaxxbxxx"))
+}
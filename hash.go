@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"slices"
+)
+
+const ENCODING_HASHTABLE = "hashtable"
+
+// Hash maps fields to their values. Hashes left without fields are
+// deleted, as in Redis.
+type Hash struct {
+	fields map[string]string
+	index  *scanIndex // Built by the first HSCAN
+}
+
+func NewHash() *Hash {
+	return &Hash{fields: make(map[string]string)}
+}
+
+// HSet sets the fields of the hash to the given values, creating the hash
+// if needed, and returns the number of fields added.
+func (xredis *XRedis) HSet(key string, fields map[string]string) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- HSetCommand{xredis.db, key, fields, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// HDel removes the fields from the hash and returns how many were there.
+func (xredis *XRedis) HDel(key string, fields []string) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- HDelCommand{xredis.db, key, fields, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) handleHSetCommand(cmd HSetCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	hash, exists, err := xredis.getHash(cmd.db, cmd.key)
+	if err != nil {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+	if !exists {
+		hash = NewHash()
+	}
+	added := 0
+	for field, value := range cmd.fields {
+		if hash.set(field, value) {
+			added++
+		}
+	}
+
+	if len(cmd.fields) > 0 {
+		if !exists {
+			xredis.setValue(cmd.db, cmd.key, XRedisValue{hash, NON_EXPIRATION_TIME})
+		}
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_HASH, KEYSPACE_EVENT_HSET, cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- added
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleHDelCommand(cmd HDelCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	hash, exists, err := xredis.getHash(cmd.db, cmd.key)
+	if !exists {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+	removed := 0
+	for _, field := range cmd.fields {
+		if hash.remove(field) {
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		if hash.len() == 0 {
+			xredis.deleteValue(cmd.db, cmd.key)
+		}
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_HASH, KEYSPACE_EVENT_HDEL, cmd.db, cmd.key)
+		if hash.len() == 0 {
+			xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_DEL, cmd.db, cmd.key)
+		}
+	}
+	cmd.rspChannel <- removed
+	cmd.errorChannel <- nil
+}
+
+// getHash returns the hash stored at key, failing if the key holds a value
+// of another type.
+func (xredis *XRedis) getHash(db int, key string) (*Hash, bool, error) {
+	value, exists := xredis.getAndInvalidateIfExpired(db, key)
+	if !exists {
+		return nil, false, nil
+	}
+	hash, ok := value.Element.(*Hash)
+	if !ok {
+		return nil, false, errors.New(REQUEST_ERROR_WRONG_TYPE)
+	}
+	return hash, true, nil
+}
+
+// set sets the value of the field, returning whether it was added.
+func (hash *Hash) set(field string, value string) bool {
+	_, exists := hash.fields[field]
+	hash.fields[field] = value
+	if !exists && hash.index != nil {
+		hash.index.add(field)
+	}
+	return !exists
+}
+
+func (hash *Hash) remove(field string) bool {
+	if _, exists := hash.fields[field]; !exists {
+		return false
+	}
+	delete(hash.fields, field)
+	if hash.index != nil {
+		hash.index.remove(field)
+	}
+	return true
+}
+
+func (hash *Hash) len() int {
+	return len(hash.fields)
+}
+
+func (hash *Hash) encoding() string {
+	if hash.len() <= LISTPACK_MAX_ENTRIES {
+		return ENCODING_LISTPACK
+	}
+	return ENCODING_HASHTABLE
+}
+
+func (hash *Hash) clone() *Hash {
+	return &Hash{fields: maps.Clone(hash.fields)}
+}
+
+func (hash *Hash) collectionType() string {
+	return TYPE_HASH
+}
+
+func (hash *Hash) scan(cursor uint64, count int) ([]string, uint64) {
+	if hash.index == nil {
+		hash.index = newScanIndex(slices.Collect(maps.Keys(hash.fields)))
+	}
+	return hash.index.scan(cursor, count)
+}
+
+func (hash *Hash) scanEntry(field string) []RespDataType {
+	return []RespDataType{RespString{field}, RespString{hash.fields[field]}}
+}
+
+// serialize replies the fields along with their values, as HGETALL would,
+// ordered by field so that the reply is stable
+func (hash *Hash) serialize() string {
+	elements := make([]RespDataType, 0, 2*hash.len())
+	for _, field := range slices.Sorted(maps.Keys(hash.fields)) {
+		elements = append(elements, RespString{field}, RespString{hash.fields[field]})
+	}
+	return RespArray{elements}.serialize()
+}
+
+type HSetCommand struct {
+	db           int
+	key          string
+	fields       map[string]string
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type HDelCommand struct {
+	db           int
+	key          string
+	fields       []string
+	rspChannel   chan int
+	errorChannel chan error
+}
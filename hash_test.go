@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHSetAndHDel(t *testing.T) {
+	xredis := NewXRedis()
+
+	added, err := xredis.HSet("hash", map[string]string{"a": "1", "b": "2"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, added)
+	added, _ = xredis.HSet("hash", map[string]string{"a": "3", "c": "4"})
+	assert.Equal(t, 1, added)
+	assert.Equal(t, TYPE_HASH, xredis.Type("hash"))
+
+	removed, err := xredis.HDel("hash", []string{"a", "b", "missing"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, removed)
+	entries, _, _ := xredis.ScanCollection("hash", TYPE_HASH, 0, "", 10)
+	assert.Equal(t, []RespDataType{RespString{"c"}, RespString{"4"}}, entries)
+
+	xredis.HDel("hash", []string{"c"})
+	assert.False(t, xredis.Exists("hash"))
+
+	xredis.Set("string", RespString{"a"})
+	_, err = xredis.HSet("string", map[string]string{"a": "1"})
+	assert.NotNil(t, err)
+}
+
+func TestHashScanSeesFieldsChangedDuringTheScan(t *testing.T) {
+	xredis := NewXRedis()
+
+	fields := make(map[string]string)
+	for i := range 100 {
+		fields["stable-"+strconv.Itoa(i)] = strconv.Itoa(i)
+	}
+	xredis.HSet("hash", fields)
+
+	scanned := make(map[string]string)
+	cursor := uint64(0)
+	for iteration := 0; ; iteration++ {
+		entries, nextCursor, err := xredis.ScanCollection("hash", TYPE_HASH, cursor, "", 10)
+		assert.Nil(t, err)
+		for i := 0; i < len(entries); i += 2 {
+			scanned[entries[i].(RespString).Str] = entries[i+1].(RespString).Str
+		}
+		xredis.HSet("hash", map[string]string{"added-" + strconv.Itoa(iteration): "new"})
+		xredis.HDel("hash", []string{"added-" + strconv.Itoa(iteration-1)})
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	for field, value := range fields {
+		assert.Equal(t, value, scanned[field])
+	}
+}
+
+func TestHashEncoding(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.HSet("hash", map[string]string{"a": "1"})
+	encoding, _ := xredis.Encoding("hash")
+	assert.Equal(t, ENCODING_LISTPACK, encoding)
+
+	fields := make(map[string]string)
+	for i := range LISTPACK_MAX_ENTRIES + 1 {
+		fields[strconv.Itoa(i)] = "value"
+	}
+	xredis.HSet("hash", fields)
+	encoding, _ = xredis.Encoding("hash")
+	assert.Equal(t, ENCODING_HASHTABLE, encoding)
+}
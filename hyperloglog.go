@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"maps"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const ENCODING_HYPERLOGLOG_SPARSE = "hll-sparse"
+const ENCODING_HYPERLOGLOG_DENSE = "hll-dense"
+
+// Precision of the HyperLogLogs, whose 2^14 registers give the standard
+// error of 1.04/sqrt(16384) = 0.81% as in Redis
+const HYPERLOGLOG_PRECISION = 14
+const HYPERLOGLOG_REGISTERS = 1 << HYPERLOGLOG_PRECISION
+
+// Bits of the hash left to count the run of zeros once the register index
+// is taken out
+const HYPERLOGLOG_Q = 64 - HYPERLOGLOG_PRECISION
+
+// Number of non-zero registers past which a sparse HyperLogLog turns dense
+const HYPERLOGLOG_SPARSE_MAX_REGISTERS = 1000
+
+const HYPERLOGLOG_HASH_SEED = 0xadc83b19
+
+// HyperLogLog estimates the number of distinct elements added to it. While
+// few registers are set it is sparse and only keeps those, once dense it
+// holds every register. Its fields are exported so that it can be
+// persisted in the dump.
+type HyperLogLog struct {
+	Sparse    map[uint16]uint8 // Non-zero registers, while sparse
+	Registers []uint8          // Every register, once dense
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{Sparse: make(map[uint16]uint8)}
+}
+
+// PFAdd adds the elements to the HyperLogLog, creating it if needed, and
+// returns whether its estimation may have changed.
+func (xredis *XRedis) PFAdd(key string, elements []string) (bool, error) {
+	rspChan := make(chan bool)
+	errorChan := make(chan error)
+	xredis.commands <- PFAddCommand{xredis.db, key, elements, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// PFCount estimates the number of distinct elements added to any of the
+// HyperLogLogs.
+func (xredis *XRedis) PFCount(keys []string) (uint64, error) {
+	rspChan := make(chan uint64)
+	errorChan := make(chan error)
+	xredis.commands <- PFCountCommand{xredis.db, keys, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// PFMerge stores at destKey the union of the HyperLogLogs, including the
+// one already stored there if any.
+func (xredis *XRedis) PFMerge(destKey string, keys []string) error {
+	errorChan := make(chan error)
+	xredis.commands <- PFMergeCommand{xredis.db, destKey, keys, errorChan}
+	return <-errorChan
+}
+
+func (xredis *XRedis) handlePFAddCommand(cmd PFAddCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	hyperLogLog, exists, err := xredis.getHyperLogLog(cmd.db, cmd.key)
+	if err != nil {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- err
+		return
+	}
+	changed := !exists
+	if !exists {
+		hyperLogLog = NewHyperLogLog()
+		xredis.setValue(cmd.db, cmd.key, XRedisValue{hyperLogLog, NON_EXPIRATION_TIME})
+	}
+	for _, element := range cmd.elements {
+		if hyperLogLog.add(element) {
+			changed = true
+		}
+	}
+
+	if changed {
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STRING, KEYSPACE_EVENT_PFADD, cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- changed
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handlePFCountCommand(cmd PFCountCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	union := NewHyperLogLog()
+	for _, key := range cmd.keys {
+		hyperLogLog, exists, err := xredis.getHyperLogLog(cmd.db, key)
+		if err != nil {
+			cmd.rspChannel <- 0
+			cmd.errorChannel <- err
+			return
+		}
+		if exists {
+			union.merge(hyperLogLog)
+		}
+	}
+	cmd.rspChannel <- union.count()
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handlePFMergeCommand(cmd PFMergeCommand) {
+	defer close(cmd.errorChannel)
+
+	union := NewHyperLogLog()
+	for _, key := range append([]string{cmd.destKey}, cmd.keys...) {
+		hyperLogLog, exists, err := xredis.getHyperLogLog(cmd.db, key)
+		if err != nil {
+			cmd.errorChannel <- err
+			return
+		}
+		if exists {
+			union.merge(hyperLogLog)
+		}
+	}
+
+	expirationTime := int64(NON_EXPIRATION_TIME)
+	if value, exists := xredis.databases[cmd.db][cmd.destKey]; exists {
+		expirationTime = value.ExpirationTimestampMillis
+	}
+	xredis.setValue(cmd.db, cmd.destKey, XRedisValue{union, expirationTime})
+	xredis.touchKey(cmd.db, cmd.destKey)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STRING, KEYSPACE_EVENT_PFADD, cmd.db, cmd.destKey)
+	cmd.errorChannel <- nil
+}
+
+// getHyperLogLog returns the HyperLogLog stored at key, failing if the key
+// holds a value of another type.
+func (xredis *XRedis) getHyperLogLog(db int, key string) (*HyperLogLog, bool, error) {
+	value, exists := xredis.getAndInvalidateIfExpired(db, key)
+	if !exists {
+		return nil, false, nil
+	}
+	hyperLogLog, ok := value.Element.(*HyperLogLog)
+	if !ok {
+		return nil, false, errors.New(REQUEST_ERROR_WRONG_TYPE)
+	}
+	return hyperLogLog, true, nil
+}
+
+// add hashes the element into a register, which keeps the longest run of
+// trailing zeros seen in the remaining bits of the hashes. It returns
+// whether the register changed.
+func (hyperLogLog *HyperLogLog) add(element string) bool {
+	hash := murmurHash64A([]byte(element), HYPERLOGLOG_HASH_SEED)
+	index := uint16(hash & (HYPERLOGLOG_REGISTERS - 1))
+	// The sentinel bit bounds the run when the remaining bits are all zeros
+	remaining := hash>>HYPERLOGLOG_PRECISION | 1<<HYPERLOGLOG_Q
+	return hyperLogLog.raiseRegister(index, uint8(bits.TrailingZeros64(remaining)+1))
+}
+
+func (hyperLogLog *HyperLogLog) register(index uint16) uint8 {
+	if hyperLogLog.isDense() {
+		return hyperLogLog.Registers[index]
+	}
+	return hyperLogLog.Sparse[index]
+}
+
+// raiseRegister sets the register to value if it is lower, turning the
+// HyperLogLog dense when it gets too many registers to stay sparse.
+func (hyperLogLog *HyperLogLog) raiseRegister(index uint16, value uint8) bool {
+	if hyperLogLog.register(index) >= value {
+		return false
+	}
+	if hyperLogLog.isDense() {
+		hyperLogLog.Registers[index] = value
+		return true
+	}
+
+	if hyperLogLog.Sparse == nil {
+		hyperLogLog.Sparse = make(map[uint16]uint8)
+	}
+	hyperLogLog.Sparse[index] = value
+	if len(hyperLogLog.Sparse) > HYPERLOGLOG_SPARSE_MAX_REGISTERS {
+		hyperLogLog.Registers = make([]uint8, HYPERLOGLOG_REGISTERS)
+		for sparseIndex, sparseValue := range hyperLogLog.Sparse {
+			hyperLogLog.Registers[sparseIndex] = sparseValue
+		}
+		hyperLogLog.Sparse = nil
+	}
+	return true
+}
+
+func (hyperLogLog *HyperLogLog) merge(other *HyperLogLog) {
+	if !other.isDense() {
+		for index, value := range other.Sparse {
+			hyperLogLog.raiseRegister(index, value)
+		}
+		return
+	}
+	for index, value := range other.Registers {
+		if value > 0 {
+			hyperLogLog.raiseRegister(uint16(index), value)
+		}
+	}
+}
+
+// count estimates the cardinality with the improved estimator by Otmar
+// Ertl used by Redis, which needs no bias correction for small or large
+// cardinalities.
+func (hyperLogLog *HyperLogLog) count() uint64 {
+	var histogram [HYPERLOGLOG_Q + 2]int
+	if hyperLogLog.isDense() {
+		for _, value := range hyperLogLog.Registers {
+			histogram[value]++
+		}
+	} else {
+		histogram[0] = HYPERLOGLOG_REGISTERS - len(hyperLogLog.Sparse)
+		for _, value := range hyperLogLog.Sparse {
+			histogram[value]++
+		}
+	}
+
+	registers := float64(HYPERLOGLOG_REGISTERS)
+	z := registers * hyperLogLogTau((registers-float64(histogram[HYPERLOGLOG_Q+1]))/registers)
+	for k := HYPERLOGLOG_Q; k >= 1; k-- {
+		z += float64(histogram[k])
+		z *= 0.5
+	}
+	z += registers * hyperLogLogSigma(float64(histogram[0])/registers)
+	alpha := 0.5 / math.Ln2
+	return uint64(math.Round(alpha * registers * registers / z))
+}
+
+func (hyperLogLog *HyperLogLog) isDense() bool {
+	return hyperLogLog.Registers != nil
+}
+
+func (hyperLogLog *HyperLogLog) encoding() string {
+	if hyperLogLog.isDense() {
+		return ENCODING_HYPERLOGLOG_DENSE
+	}
+	return ENCODING_HYPERLOGLOG_SPARSE
+}
+
+func (hyperLogLog *HyperLogLog) clone() *HyperLogLog {
+	return &HyperLogLog{maps.Clone(hyperLogLog.Sparse), slices.Clone(hyperLogLog.Registers)}
+}
+
+// serialize replies every register as a string, one byte each
+func (hyperLogLog *HyperLogLog) serialize() string {
+	registers := make([]byte, HYPERLOGLOG_REGISTERS)
+	for index := range registers {
+		registers[index] = hyperLogLog.register(uint16(index))
+	}
+	return RespString{string(registers)}.serialize()
+}
+
+func hyperLogLogSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		previous := z
+		z += x * y
+		y += y
+		if previous == z {
+			return z
+		}
+	}
+}
+
+func hyperLogLogTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		previous := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if previous == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A is the 64 bit MurmurHash2 variant hashing the elements of
+// the HyperLogLogs in Redis.
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	hash := seed ^ uint64(len(data))*m
+	for ; len(data) >= 8; data = data[8:] {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		hash ^= k
+		hash *= m
+	}
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			hash ^= uint64(data[i]) << (8 * i)
+		}
+		hash *= m
+	}
+	hash ^= hash >> r
+	hash *= m
+	hash ^= hash >> r
+	return hash
+}
+
+type PFAddCommand struct {
+	db           int
+	key          string
+	elements     []string
+	rspChannel   chan bool
+	errorChannel chan error
+}
+
+type PFCountCommand struct {
+	db           int
+	keys         []string
+	rspChannel   chan uint64
+	errorChannel chan error
+}
+
+type PFMergeCommand struct {
+	db           int
+	destKey      string
+	keys         []string
+	errorChannel chan error
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPFAdd(t *testing.T) {
+	xredis := NewXRedis()
+
+	changed, err := xredis.PFAdd("visitors", []string{"alice", "bob"})
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	changed, _ = xredis.PFAdd("visitors", []string{"alice"})
+	assert.False(t, changed)
+
+	changed, _ = xredis.PFAdd("empty", []string{})
+	assert.True(t, changed)
+	assert.True(t, xredis.Exists("empty"))
+	assert.Equal(t, TYPE_STRING, xredis.Type("empty"))
+
+	count, _ := xredis.PFCount([]string{"visitors"})
+	assert.Equal(t, uint64(2), count)
+	count, _ = xredis.PFCount([]string{"missing"})
+	assert.Equal(t, uint64(0), count)
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	xredis := NewXRedis()
+
+	for _, cardinality := range []int{100, 5000, 200000} {
+		key := "visitors" + strconv.Itoa(cardinality)
+		elements := make([]string, 0, cardinality)
+		for i := range cardinality {
+			elements = append(elements, "user:"+strconv.Itoa(i))
+		}
+		xredis.PFAdd(key, elements)
+
+		count, _ := xredis.PFCount([]string{key})
+		// Within 3 standard errors
+		assert.InDelta(t, cardinality, count, math.Max(1, 0.0243*float64(cardinality)))
+	}
+}
+
+func TestHyperLogLogTurnsDense(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.PFAdd("visitors", []string{"alice"})
+	encoding, _ := xredis.Encoding("visitors")
+	assert.Equal(t, ENCODING_HYPERLOGLOG_SPARSE, encoding)
+
+	elements := make([]string, 0, 10000)
+	for i := range 10000 {
+		elements = append(elements, strconv.Itoa(i))
+	}
+	xredis.PFAdd("visitors", elements)
+	encoding, _ = xredis.Encoding("visitors")
+	assert.Equal(t, ENCODING_HYPERLOGLOG_DENSE, encoding)
+}
+
+func TestPFMergeAndMultiKeyPFCount(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.PFAdd("monday", []string{"alice", "bob", "carol"})
+	xredis.PFAdd("tuesday", []string{"carol", "dave"})
+
+	count, _ := xredis.PFCount([]string{"monday", "tuesday", "missing"})
+	assert.Equal(t, uint64(4), count)
+
+	xredis.PFAdd("week", []string{"erin"})
+	assert.Nil(t, xredis.PFMerge("week", []string{"monday", "tuesday"}))
+	count, _ = xredis.PFCount([]string{"week"})
+	assert.Equal(t, uint64(5), count)
+
+	// The sources are left untouched
+	count, _ = xredis.PFCount([]string{"tuesday"})
+	assert.Equal(t, uint64(2), count)
+}
+
+func TestHyperLogLogOnWrongType(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("bla", RespString{"1"})
+
+	_, err := xredis.PFAdd("bla", []string{"a"})
+	assert.Equal(t, REQUEST_ERROR_WRONG_TYPE, err.Error())
+	_, err = xredis.PFCount([]string{"bla"})
+	assert.Equal(t, REQUEST_ERROR_WRONG_TYPE, err.Error())
+	err = xredis.PFMerge("dest", []string{"bla"})
+	assert.Equal(t, REQUEST_ERROR_WRONG_TYPE, err.Error())
+}
+
+func TestSaveAndLoadHyperLogLog(t *testing.T) {
+	xredis1 := NewXRedis()
+	xredis1.PFAdd("sparse", []string{"alice", "bob"})
+	elements := make([]string, 0, 5000)
+	for i := range 5000 {
+		elements = append(elements, strconv.Itoa(i))
+	}
+	xredis1.PFAdd("dense", elements)
+	xredis1.Copy("sparse", "copy", DEFAULT_DATABASE, false)
+	xredis1.PFAdd("sparse", []string{"carol"})
+	data := xredis1.Serialize()
+
+	xredis2 := NewXRedis()
+	assert.Nil(t, xredis2.Load(data))
+	for _, key := range []string{"sparse", "dense", "copy"} {
+		expected, _ := xredis1.PFCount([]string{key})
+		count, _ := xredis2.PFCount([]string{key})
+		assert.Equal(t, expected, count)
+	}
+	count, _ := xredis2.PFCount([]string{"copy"})
+	assert.Equal(t, uint64(2), count)
+}
@@ -0,0 +1,60 @@
+package main
+
+const INFO_PERSISTENCE_HEADER = "# Persistence"
+const INFO_STATUS_OK = "ok"
+const INFO_STATUS_ERR = "err"
+
+// Sections of INFO including the persistence one
+var INFO_PERSISTENCE_SECTIONS = map[string]bool{
+	"persistence": true,
+	"default":     true,
+	"all":         true,
+	"everything":  true,
+}
+
+// PersistenceInfo is the state of the loading, of the dump file and of the
+// append only file, as reported by INFO persistence.
+type PersistenceInfo struct {
+	loading              bool
+	loadingStartTime     int64
+	loadingTotalBytes    int64
+	loadingLoadedBytes   int64
+	lastLoadKeys         int64
+	lastLoadFailed       bool
+	changesSinceLastSave int64
+	bgsaveInProgress     bool
+	lastSaveTime         int64
+	lastBgsaveFailed     bool
+	aofEnabled           bool
+	aofRewriteInProgress bool
+	aofLastWriteFailed   bool
+}
+
+func (xredis *XRedis) PersistenceInfo() PersistenceInfo {
+	rspChan := make(chan PersistenceInfo)
+	xredis.commands <- PersistenceInfoCommand{rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) handlePersistenceInfoCommand(cmd PersistenceInfoCommand) {
+	defer close(cmd.rspChannel)
+	cmd.rspChannel <- PersistenceInfo{
+		loading:              xredis.snapshots.loading.Load(),
+		loadingStartTime:     xredis.snapshots.loadingStartTime,
+		loadingTotalBytes:    xredis.snapshots.loadingTotalBytes,
+		loadingLoadedBytes:   xredis.snapshots.loadingLoadedBytes,
+		lastLoadKeys:         xredis.snapshots.lastLoadKeys,
+		lastLoadFailed:       xredis.snapshots.lastLoadFailed,
+		changesSinceLastSave: xredis.snapshots.dirty,
+		bgsaveInProgress:     xredis.snapshots.bgsaveInProgress,
+		lastSaveTime:         xredis.snapshots.lastSaveTime,
+		lastBgsaveFailed:     xredis.snapshots.lastBgsaveFailed,
+		aofEnabled:           xredis.aof.enabled.Load(),
+		aofRewriteInProgress: xredis.aof.rewrite != nil,
+		aofLastWriteFailed:   xredis.aof.writeFailed.Load(),
+	}
+}
+
+type PersistenceInfoCommand struct {
+	rspChannel chan PersistenceInfo
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// What the server does on startup when the dump file can't be loaded:
+// refuse to start, leaving the file untouched, or move the file aside and
+// start with empty databases
+const LOAD_ERROR_REFUSE = "refuse"
+const LOAD_ERROR_EMPTY = "empty"
+
+// Suffix of the dump files moved aside, followed by the Unix time in
+// seconds at which they were
+const DUMP_QUARANTINE_SUFFIX = ".corrupt-"
+
+// Bytes read from the dump file between two reports of the progress
+const LOADING_PROGRESS_CHUNK_SIZE = 1 << 20
+
+// LoadError tells which dump file couldn't be loaded and why. It wraps
+// the error of the read, ERROR_CORRUPTED_SNAPSHOT, ERROR_UNSUPPORTED_DUMP,
+// ERROR_ENCRYPTION_KEY_MISSING, ERROR_FILE_NOT_ENCRYPTED or
+// ERROR_DECRYPTION_FAILED.
+type LoadError struct {
+	Path string
+	Size int64 // Size of the file in bytes, -1 if unknown
+	Err  error
+}
+
+func (err *LoadError) Error() string {
+	return fmt.Sprintf("loading %s (%d bytes): %v", err.Path, err.Size, err.Err)
+}
+
+func (err *LoadError) Unwrap() error {
+	return err.Err
+}
+
+// LoadDumpFile loads the databases of the dump file at path. Meanwhile the
+// progress is reported by INFO persistence and the requests are refused.
+// On error the databases are left untouched and a *LoadError is returned.
+func (xredis *XRedis) LoadDumpFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return &LoadError{path, -1, err}
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return &LoadError{path, -1, err}
+	}
+
+	decryption := xredis.fileDecryption()
+	xredis.commands <- LoadingStartCommand{stat.Size()}
+	data, err := xredis.readDumpFile(file, stat.Size())
+	if err == nil {
+		data, err = unsealDump(data, decryption)
+	}
+	var databases []map[string]XRedisValue
+	if err == nil {
+		databases, err = decodeSnapshot(data)
+	}
+	xredis.finishLoading(databases, err)
+	if err != nil {
+		return &LoadError{path, stat.Size(), err}
+	}
+	return nil
+}
+
+// Loading tells whether the databases are being loaded, in which case the
+// requests are refused.
+func (xredis *XRedis) Loading() bool {
+	return xredis.snapshots.loading.Load()
+}
+
+// readDumpFile reads the file of the given size, reporting the progress
+// every LOADING_PROGRESS_CHUNK_SIZE bytes.
+func (xredis *XRedis) readDumpFile(file io.Reader, size int64) ([]byte, error) {
+	data := make([]byte, 0, size)
+	chunk := make([]byte, LOADING_PROGRESS_CHUNK_SIZE)
+	for {
+		n, err := file.Read(chunk)
+		data = append(data, chunk[:n]...)
+		if n > 0 {
+			xredis.commands <- LoadingProgressCommand{int64(len(data))}
+		}
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// finishLoading installs the databases loaded, unless the load failed or
+// they were loaded in place, and records the outcome for INFO persistence.
+func (xredis *XRedis) finishLoading(databases []map[string]XRedisValue, err error) {
+	errorChan := make(chan error)
+	xredis.commands <- LoadingDoneCommand{databases, err, errorChan}
+	<-errorChan
+}
+
+func (xredis *XRedis) handleLoadingStartCommand(cmd LoadingStartCommand) {
+	xredis.snapshots.loading.Store(true)
+	xredis.snapshots.loadingStartTime = time.Now().Unix()
+	xredis.snapshots.loadingTotalBytes = cmd.totalBytes
+	xredis.snapshots.loadingLoadedBytes = 0
+}
+
+func (xredis *XRedis) handleLoadingProgressCommand(cmd LoadingProgressCommand) {
+	xredis.snapshots.loadingLoadedBytes = cmd.loadedBytes
+}
+
+func (xredis *XRedis) handleLoadingDoneCommand(cmd LoadingDoneCommand) {
+	defer close(cmd.errorChannel)
+
+	xredis.snapshots.lastLoadFailed = cmd.err != nil
+	if cmd.err == nil {
+		if cmd.databases != nil {
+			xredis.loadDatabases(cmd.databases)
+		}
+		xredis.snapshots.lastLoadKeys = 0
+		for _, database := range xredis.databases {
+			xredis.snapshots.lastLoadKeys += int64(len(database))
+		}
+		// The keys loaded are saved already, whether in the dump or in
+		// the append only file
+		xredis.snapshots.dirty = 0
+	}
+	xredis.snapshots.loading.Store(false)
+	cmd.errorChannel <- nil
+}
+
+// quarantineDumpFile moves the dump file aside, so that it is neither
+// loaded again nor replaced by the next save, returning its new path.
+func quarantineDumpFile(path string) (string, error) {
+	quarantinePath := fmt.Sprintf("%s%s%d", path, DUMP_QUARANTINE_SUFFIX, time.Now().Unix())
+	if err := os.Rename(path, quarantinePath); err != nil {
+		return "", err
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		log.Println("Failed syncing the directory of the quarantined dump file: ", err)
+	}
+	return quarantinePath, nil
+}
+
+// recoverFromLoadError applies the policy to a dump file that couldn't be
+// loaded. Starting empty is only allowed once the file was moved aside,
+// otherwise the error is returned so that the server refuses to start. A
+// missing encryption key, or an unencrypted file not allowed, being a
+// matter of the configuration rather than of the file, the server always
+// refuses to start then.
+func recoverFromLoadError(err error, policy string) error {
+	var loadError *LoadError
+	if policy != LOAD_ERROR_EMPTY || !errors.As(err, &loadError) || errors.Is(err, ERROR_ENCRYPTION_KEY_MISSING) || errors.Is(err, ERROR_FILE_NOT_ENCRYPTED) {
+		return err
+	}
+	quarantinePath, quarantineErr := quarantineDumpFile(loadError.Path)
+	if quarantineErr != nil {
+		return fmt.Errorf("%w, and it couldn't be moved aside: %w", err, quarantineErr)
+	}
+	log.Printf("Starting with empty databases: %v. The dump file was moved to %s", err, quarantinePath)
+	return nil
+}
+
+type LoadingStartCommand struct {
+	totalBytes int64
+}
+
+type LoadingProgressCommand struct {
+	loadedBytes int64
+}
+
+type LoadingDoneCommand struct {
+	databases    []map[string]XRedisValue // Nil if loaded in place
+	err          error
+	errorChannel chan error
+}
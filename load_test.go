@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDumpFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DB_DUMP_FILE)
+	saved := NewXRedis()
+	saved.SetDir(filepath.Dir(path))
+	saved.Set("key", RespString{"value"})
+	saved.RPush("list", RespString{"a"})
+	saved.Save()
+
+	xredis := NewXRedis()
+	assert.Nil(t, xredis.LoadDumpFile(path))
+	assert.Equal(t, RespString{"value"}, xredis.Get("key"))
+	info := xredis.PersistenceInfo()
+	assert.False(t, info.loading)
+	assert.False(t, info.lastLoadFailed)
+	assert.Equal(t, int64(2), info.lastLoadKeys)
+	assert.False(t, xredis.Loading())
+}
+
+func TestLoadCorruptedDumpFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DB_DUMP_FILE)
+	saved := NewXRedis()
+	saved.Set("key", RespString{"value"})
+	data := saved.Serialize()
+	data[len(data)/2]++
+	os.WriteFile(path, data, 0644)
+
+	xredis := NewXRedis()
+	xredis.Set("existing", RespString{"value"})
+	err := xredis.LoadDumpFile(path)
+	var loadError *LoadError
+	assert.True(t, errors.As(err, &loadError))
+	assert.Equal(t, path, loadError.Path)
+	assert.Equal(t, int64(len(data)), loadError.Size)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+	assert.Equal(t, RespString{"value"}, xredis.Get("existing"))
+	assert.False(t, xredis.Exists("key"))
+	assert.True(t, xredis.PersistenceInfo().lastLoadFailed)
+	assert.False(t, xredis.Loading())
+
+	// Loading the data directly fails the same way
+	assert.ErrorIs(t, xredis.Load(data), ERROR_CORRUPTED_SNAPSHOT)
+
+	assert.ErrorIs(t, xredis.LoadDumpFile(filepath.Join(t.TempDir(), DB_DUMP_FILE)), os.ErrNotExist)
+	os.WriteFile(path, []byte("REDIS0099\xFF"), 0644)
+	assert.ErrorIs(t, xredis.LoadDumpFile(path), ERROR_UNSUPPORTED_DUMP)
+}
+
+func TestRecoverFromLoadError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DB_DUMP_FILE)
+	os.WriteFile(path, []byte(SNAPSHOT_MAGIC+"corrupted"), 0644)
+	xredis := NewXRedis()
+
+	// Refusing to start leaves the file in place
+	err := xredis.LoadDumpFile(path)
+	assert.Equal(t, err, recoverFromLoadError(err, LOAD_ERROR_REFUSE))
+	_, statErr := os.Stat(path)
+	assert.Nil(t, statErr)
+
+	// Starting empty moves it aside first
+	assert.Nil(t, recoverFromLoadError(err, LOAD_ERROR_EMPTY))
+	_, statErr = os.Stat(path)
+	assert.ErrorIs(t, statErr, os.ErrNotExist)
+	quarantined, _ := filepath.Glob(path + DUMP_QUARANTINE_SUFFIX + "*")
+	assert.Len(t, quarantined, 1)
+	data, _ := os.ReadFile(quarantined[0])
+	assert.Equal(t, SNAPSHOT_MAGIC+"corrupted", string(data))
+
+	// Unless it can't be moved aside
+	err = recoverFromLoadError(err, LOAD_ERROR_EMPTY)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRequestsRefusedWhileLoading(t *testing.T) {
+	xredis := NewXRedis()
+	client := NewClient(xredis)
+	xredis.commands <- LoadingStartCommand{200}
+	xredis.commands <- LoadingProgressCommand{50}
+
+	getRsp := handleRequest(client, []byte("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"))
+	assert.Equal(t, RespError{REQUEST_ERROR_LOADING}.serialize(), string(getRsp))
+	infoRsp := string(handleRequest(client, []byte("*1\r\n$4\r\nINFO\r\n")))
+	assert.Contains(t, infoRsp, "loading:1\r\n")
+	assert.Contains(t, infoRsp, "loading_total_bytes:200\r\n")
+	assert.Contains(t, infoRsp, "loading_loaded_bytes:50\r\n")
+	assert.Contains(t, infoRsp, "loading_loaded_perc:25.00\r\n")
+
+	xredis.finishLoading(nil, nil)
+	getRsp = handleRequest(client, []byte("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"))
+	assert.Equal(t, "$-1\r\n", string(getRsp))
+	infoRsp = string(handleRequest(client, []byte("*1\r\n$4\r\nINFO\r\n")))
+	assert.Contains(t, infoRsp, "loading:0\r\n")
+	assert.False(t, strings.Contains(infoRsp, "loading_total_bytes"))
+}
+
+func TestSavePointsWaitForLoading(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.SetDir(t.TempDir())
+	xredis.ConfigSet(map[string]string{CONFIG_SAVE: "1 1"})
+	xredis.Atomically(func(tx *XRedis) { tx.snapshots.lastSaveTime -= 10 })
+
+	xredis.commands <- LoadingStartCommand{200}
+	xredis.Set("key", RespString{"value"})
+	xredis.commands <- SavePointsCheckCommand{}
+	assert.False(t, xredis.PersistenceInfo().bgsaveInProgress)
+
+	// Nothing is left to save once loaded
+	xredis.finishLoading(nil, nil)
+	assert.Equal(t, int64(0), xredis.PersistenceInfo().changesSinceLastSave)
+	xredis.commands <- SavePointsCheckCommand{}
+	assert.False(t, xredis.PersistenceInfo().bgsaveInProgress)
+}
+
+func TestReplayAppendOnlyFileReportsLoading(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	os.WriteFile(path, []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n"), 0644)
+	xredis := NewXRedis()
+
+	assert.Nil(t, replayAppendOnlyFile(xredis, path))
+	assert.Equal(t, RespString{"value"}, xredis.Get("key"))
+	info := xredis.PersistenceInfo()
+	assert.False(t, info.loading)
+	assert.Equal(t, int64(1), info.lastLoadKeys)
+
+	os.WriteFile(path, []byte("garbage"), 0644)
+	assert.NotNil(t, replayAppendOnlyFile(xredis, path))
+	assert.True(t, xredis.PersistenceInfo().lastLoadFailed)
+	assert.False(t, xredis.Loading())
+}
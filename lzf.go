@@ -0,0 +1,120 @@
+package main
+
+import "errors"
+
+// LZF compresses data as a sequence of literal runs and back references,
+// each introduced by a control byte:
+//
+//	000LLLLL <L+1 literal bytes>
+//	LLLOOOOO OOOOOOOO           a reference of L+2 bytes, L < 7
+//	111OOOOO LLLLLLLL OOOOOOOO  a reference of L+9 bytes
+//
+// The offset O of a reference counts back from the byte before the output
+// written so far, as in the liblzf used by Redis.
+const LZF_MAX_LITERAL = 1 << 5
+const LZF_MAX_OFFSET = 1 << 13
+const LZF_MAX_REFERENCE = 7 + 255 + 2
+const LZF_MIN_REFERENCE = 3
+const LZF_HASH_BITS = 14
+
+var ERROR_CORRUPTED_LZF = errors.New("corrupted LZF data")
+
+// lzfCompress compresses the data, finding the back references by hashing
+// every 3 bytes sequence to its last position.
+func lzfCompress(data []byte) []byte {
+	compressed := make([]byte, 0, len(data))
+	var positions [1 << LZF_HASH_BITS]int // Last position plus one, 0 if none
+	hash := func(i int) int {
+		sequence := uint32(data[i])<<16 | uint32(data[i+1])<<8 | uint32(data[i+2])
+		return int((sequence * 2654435761) >> (32 - LZF_HASH_BITS))
+	}
+
+	literalStart := 0
+	flushLiterals := func(end int) {
+		for literalStart < end {
+			run := min(end-literalStart, LZF_MAX_LITERAL)
+			compressed = append(compressed, byte(run-1))
+			compressed = append(compressed, data[literalStart:literalStart+run]...)
+			literalStart += run
+		}
+	}
+
+	i := 0
+	for i+LZF_MIN_REFERENCE <= len(data) {
+		h := hash(i)
+		reference := positions[h] - 1
+		positions[h] = i + 1
+		if reference < 0 || i-reference > LZF_MAX_OFFSET ||
+			data[reference] != data[i] || data[reference+1] != data[i+1] || data[reference+2] != data[i+2] {
+			i++
+			continue
+		}
+
+		length := LZF_MIN_REFERENCE
+		for i+length < len(data) && length < LZF_MAX_REFERENCE && data[reference+length] == data[i+length] {
+			length++
+		}
+		flushLiterals(i)
+		offset := i - reference - 1
+		if length-2 < 7 {
+			compressed = append(compressed, byte((length-2)<<5|offset>>8))
+		} else {
+			compressed = append(compressed, byte(7<<5|offset>>8), byte(length-2-7))
+		}
+		compressed = append(compressed, byte(offset))
+
+		for j := i + 1; j < i+length && j+LZF_MIN_REFERENCE <= len(data); j++ {
+			positions[hash(j)] = j + 1
+		}
+		i += length
+		literalStart = i
+	}
+	flushLiterals(len(data))
+	return compressed
+}
+
+// lzfDecompress decompresses data known to hold length bytes once
+// decompressed.
+func lzfDecompress(data []byte, length int) ([]byte, error) {
+	decompressed := make([]byte, 0, min(length, LZF_MAX_REFERENCE*len(data)))
+	for i := 0; i < len(data); {
+		control := int(data[i])
+		i++
+		if control < LZF_MAX_LITERAL {
+			run := control + 1
+			if i+run > len(data) || len(decompressed)+run > length {
+				return nil, ERROR_CORRUPTED_LZF
+			}
+			decompressed = append(decompressed, data[i:i+run]...)
+			i += run
+			continue
+		}
+
+		referenceLength := control >> 5
+		if referenceLength == 7 {
+			if i >= len(data) {
+				return nil, ERROR_CORRUPTED_LZF
+			}
+			referenceLength += int(data[i])
+			i++
+		}
+		referenceLength += 2
+		if i >= len(data) {
+			return nil, ERROR_CORRUPTED_LZF
+		}
+		reference := len(decompressed) - (control&0x1F)<<8 - int(data[i]) - 1
+		i++
+		if reference < 0 || len(decompressed)+referenceLength > length {
+			return nil, ERROR_CORRUPTED_LZF
+		}
+		// The reference may overlap the bytes it produces, so they are
+		// copied one at a time
+		for j := range referenceLength {
+			decompressed = append(decompressed, decompressed[reference+j])
+		}
+	}
+	if len(decompressed) != length {
+		return nil, ERROR_CORRUPTED_LZF
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLzfRoundTrip(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte(strings.Repeat("a", 1000)),
+		[]byte(strings.Repeat("xredis is a redis clone ", 100)),
+		bytes.Repeat([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 2000),
+	} {
+		compressed := lzfCompress(data)
+		decompressed, err := lzfDecompress(compressed, len(data))
+		assert.Nil(t, err)
+		assert.Equal(t, len(data), len(decompressed))
+		assert.True(t, bytes.Equal(data, decompressed))
+	}
+
+	compressed := lzfCompress([]byte(strings.Repeat("a", 1000)))
+	assert.Less(t, len(compressed), 20)
+}
+
+func TestLzfDecompress(t *testing.T) {
+	// A literal "a" repeated by a reference of 39 bytes to the previous one
+	decompressed, err := lzfDecompress([]byte{0x00, 'a', 0xE0, 30, 0x00}, 40)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Repeat("a", 40), string(decompressed))
+
+	for _, corrupted := range [][]byte{{0x01, 'a'}, {0x00, 'a', 0x20, 0x01}, {0x00, 'a', 0xE0}} {
+		_, err = lzfDecompress(corrupted, 40)
+		assert.ErrorIs(t, err, ERROR_CORRUPTED_LZF)
+	}
+	_, err = lzfDecompress([]byte{0x00, 'a', 0xE0, 30, 0x00}, 39)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_LZF)
+}
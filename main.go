@@ -1,13 +1,16 @@
 package main
 
 import (
-	"bytes"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 )
 
 const SERVER_NETWORK_PROTOCOL = "tcp"
@@ -22,18 +25,77 @@ const BANNER = `
 const DB_DUMP_FILE = "xredis_dump.db"
 
 func main() {
+	databasesNumber := flag.Int("databases", DEFAULT_DATABASES_NUMBER, "Number of logical databases")
+	notifyKeyspaceEvents := flag.String(CONFIG_NOTIFY_KEYSPACE_EVENTS, "", "Classes of keyspace events to publish, as in Redis (e.g. KEA)")
+	appendOnly := flag.Bool("appendonly", false, "Log every write to the append only file, which is replayed on startup instead of loading the dump")
+	appendFsync := flag.String(CONFIG_APPENDFSYNC, APPENDFSYNC_EVERYSEC, "When the append only file is flushed to disk: always, everysec or no")
+	dir := flag.String(CONFIG_DIR, ".", "Directory of the dump and append only files")
+	dbFilename := flag.String(CONFIG_DBFILENAME, DB_DUMP_FILE, "Name of the file, in dir, the databases are saved to and loaded from on startup")
+	dbFormat := flag.String(CONFIG_DBFORMAT, DUMP_FORMAT_XREDIS, "Format the databases are saved in: xredis or rdb (the dumps of Redis)")
+	persistenceCompression := flag.String(CONFIG_PERSISTENCE_COMPRESSION, COMPRESSION_NO, "Codec compressing the dump and append only files: no, gzip or flate")
+	encryptionKeyFile := flag.String("encryption-key-file", "", "File holding the key encrypting the dump and append only files, read from $"+ENCRYPTION_KEY_ENV+" otherwise")
+	allowUnencryptedLoad := flag.Bool("allow-unencrypted-load", false, "Load the dump and append only files that aren't encrypted despite the encryption key, to encrypt the data of an existing server")
+	loadError := flag.String("load-error", LOAD_ERROR_REFUSE, "What to do on startup when the dump file can't be loaded: refuse to start, or move it aside and start empty")
+	savePoints := flag.String(CONFIG_SAVE, SAVE_POINTS_REDIS_DEFAULT, "Save points, as pairs of seconds and changes after which the dump is saved (empty to disable)")
+	flag.Parse()
+
 	fmt.Print(BANNER)
 	log.Println("Starting xRedis on port ", SERVER_PORT)
 
-	xredis := NewXRedis()
-	loadStoredState(xredis)
+	xredis := NewXRedisWithDatabases(*databasesNumber)
+	if err := xredis.ConfigSet(map[string]string{CONFIG_NOTIFY_KEYSPACE_EVENTS: *notifyKeyspaceEvents}); err != nil {
+		log.Fatalf("Invalid %s configuration: %v", CONFIG_NOTIFY_KEYSPACE_EVENTS, err)
+	}
+	if err := xredis.ConfigSet(map[string]string{CONFIG_APPENDFSYNC: *appendFsync}); err != nil {
+		log.Fatalf("Invalid %s configuration: %v", CONFIG_APPENDFSYNC, err)
+	}
+	if err := xredis.ConfigSet(map[string]string{CONFIG_SAVE: *savePoints}); err != nil {
+		log.Fatalf("Invalid %s configuration: %v", CONFIG_SAVE, err)
+	}
+	xredis.SetDir(*dir)
+	if err := xredis.ConfigSet(map[string]string{CONFIG_DBFILENAME: *dbFilename}); err != nil {
+		log.Fatalf("Invalid %s configuration: %v", CONFIG_DBFILENAME, err)
+	}
+	if err := xredis.ConfigSet(map[string]string{CONFIG_DBFORMAT: *dbFormat}); err != nil {
+		log.Fatalf("Invalid %s configuration: %v", CONFIG_DBFORMAT, err)
+	}
+	if err := xredis.ConfigSet(map[string]string{CONFIG_PERSISTENCE_COMPRESSION: *persistenceCompression}); err != nil {
+		log.Fatalf("Invalid %s configuration: %v", CONFIG_PERSISTENCE_COMPRESSION, err)
+	}
+	if key, err := readEncryptionKey(*encryptionKeyFile); err != nil {
+		log.Fatalf("Invalid encryption key: %v", err)
+	} else if key != nil {
+		if err := xredis.SetEncryptionKey(key); err != nil {
+			log.Fatalf("Invalid encryption key: %v", err)
+		}
+		log.Println("Encrypting the dump and append only files")
+		if *allowUnencryptedLoad {
+			xredis.AllowUnencryptedLoad()
+		}
+	}
+	if *loadError != LOAD_ERROR_REFUSE && *loadError != LOAD_ERROR_EMPTY {
+		log.Fatalf("Invalid load-error configuration: %s", *loadError)
+	}
 
+	// As in Redis, connections are accepted while loading, only to be told
+	// that the dataset is being loaded
 	listener, err := net.Listen(SERVER_NETWORK_PROTOCOL, ":"+SERVER_PORT)
 	if err != nil {
 		log.Panic("Could not start xredis on port " + SERVER_PORT)
 	}
+	go acceptConnections(xredis, listener)
+
+	if *appendOnly {
+		loadAppendOnlyFile(xredis, filepath.Join(*dir, AOF_FILE))
+	} else {
+		loadStoredState(xredis, filepath.Join(*dir, *dbFilename), *loadError)
+	}
 
 	log.Println("Ready to receive connections")
+	shutdownOnSignal(xredis)
+}
+
+func acceptConnections(xredis *XRedis, listener net.Listener) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -45,32 +107,62 @@ func main() {
 	}
 }
 
-func loadStoredState(xredis *XRedis) {
+// loadStoredState loads the dump file, whether it was saved by xredis or is
+// an RDB file of Redis. When it can't be loaded, the server either refuses
+// to start or starts empty, according to the policy.
+func loadStoredState(xredis *XRedis, path string, loadErrorPolicy string) {
 	log.Println("Loading dump file")
-	_, err := os.Stat(DB_DUMP_FILE)
+	err := xredis.LoadDumpFile(path)
 	if errors.Is(err, os.ErrNotExist) {
 		log.Println("No dump file to load")
 		return
 	}
-	file, err := os.Open(DB_DUMP_FILE)
 	if err != nil {
-		log.Println("Failed opening dump file")
+		if err := recoverFromLoadError(err, loadErrorPolicy); err != nil {
+			log.Fatalf("Refusing to start: %v", err)
+		}
 		return
 	}
-	defer file.Close()
+	log.Println("Dump file loaded succesfully")
+}
 
-	var buf bytes.Buffer
-	_, err = io.Copy(&buf, file)
-	if err != nil {
-		log.Println("Failed copying dump file to byte buffer")
-		return
+// loadAppendOnlyFile replays the append only file, if any, then keeps
+// logging the writes to it. As in Redis, the dump isn't loaded.
+func loadAppendOnlyFile(xredis *XRedis, path string) {
+	log.Println("Loading append only file")
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Println("No append only file to load")
+	} else if err := replayAppendOnlyFile(xredis, path); err != nil {
+		log.Fatalf("Failed loading append only file: %v", err)
+	}
+	if err := xredis.OpenAppendOnlyFile(path); err != nil {
+		log.Fatalf("Failed opening append only file: %v", err)
 	}
+}
 
-	log.Println("Dump file loaded succesfully")
-	xredis.Load(buf.Bytes())
+// shutdownOnSignal saves the databases and closes the append only file
+// before exiting once the process is asked to terminate. Until it's called,
+// the signals terminate the process without saving, so that the dump isn't
+// replaced while it is being loaded.
+func shutdownOnSignal(xredis *XRedis) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	received := <-signals
+
+	log.Printf("Received %v, shutting down", received)
+	if err := xredis.Shutdown(); err != nil {
+		log.Fatalf("Failed shutting down: %v", err)
+	}
+	log.Println("xRedis is now ready to exit, bye bye...")
+	os.Exit(0)
 }
 
 func handleConnection(xredis *XRedis, conn net.Conn) {
+	client := NewClient(xredis)
+	defer client.Close()
+	go writeOutbox(conn, client.outbox, client.subscriber.disconnect)
+
 	data := make([]byte, 1024)
 	for {
 		_, err := conn.Read(data)
@@ -78,10 +170,37 @@ func handleConnection(xredis *XRedis, conn net.Conn) {
 			if err != io.EOF {
 				log.Println("An error occurred reading from connection: " + conn.RemoteAddr().String())
 			}
-			conn.Close()
 			return
 		}
 
-		conn.Write([]byte(handleRequest(xredis, data)))
+		// Replies go through the outbox as well, so that they are never
+		// reordered with the messages published to a subscribed client
+		if rsp := handleRequest(client, data); rsp != nil {
+			client.outbox <- rsp
+		}
+	}
+}
+
+// writeOutbox writes everything sent to the client outbox to the
+// connection, closing it once the outbox is closed or once the client is
+// disconnected for not keeping up with its messages.
+func writeOutbox(conn net.Conn, outbox chan []byte, disconnect chan struct{}) {
+	defer conn.Close()
+	for {
+		select {
+		case data, ok := <-outbox:
+			if !ok {
+				return
+			}
+			// Keep draining on errors so that the outbox never blocks
+			conn.Write(data)
+		case <-disconnect:
+			// Closing the connection stops the reads, after which the
+			// outbox is closed
+			conn.Close()
+			for range outbox {
+			}
+			return
+		}
 	}
 }
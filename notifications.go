@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Classes of keyspace events, enabled by the characters of the
+// notify-keyspace-events configuration as in Redis
+const (
+	KEYSPACE_EVENTS_KEYSPACE = 1 << iota // K: published to __keyspace@<db>__:<key>
+	KEYSPACE_EVENTS_KEYEVENT             // E: published to __keyevent@<db>__:<event>
+	KEYSPACE_EVENTS_GENERIC              // g: del, expire, rename, ...
+	KEYSPACE_EVENTS_STRING               // $
+	KEYSPACE_EVENTS_LIST                 // l
+	KEYSPACE_EVENTS_SET                  // s
+	KEYSPACE_EVENTS_HASH                 // h
+	KEYSPACE_EVENTS_ZSET                 // z
+	KEYSPACE_EVENTS_EXPIRED              // x
+	KEYSPACE_EVENTS_EVICTED              // e
+	KEYSPACE_EVENTS_STREAM               // t
+	KEYSPACE_EVENTS_KEY_MISS             // m
+	KEYSPACE_EVENTS_NEW_KEY              // n
+)
+
+// KEYSPACE_EVENTS_ALL is the A alias, which leaves out key miss and new
+// key events because of how noisy they are
+const KEYSPACE_EVENTS_ALL = KEYSPACE_EVENTS_GENERIC | KEYSPACE_EVENTS_STRING | KEYSPACE_EVENTS_LIST |
+	KEYSPACE_EVENTS_SET | KEYSPACE_EVENTS_HASH | KEYSPACE_EVENTS_ZSET | KEYSPACE_EVENTS_EXPIRED |
+	KEYSPACE_EVENTS_EVICTED | KEYSPACE_EVENTS_STREAM
+
+const KEYSPACE_EVENTS_ALL_ALIAS = 'A'
+
+// Characters of each class, in the order they are formatted by CONFIG GET
+var KEYSPACE_EVENTS_CHARACTERS = []struct {
+	character rune
+	class     int
+}{
+	{'g', KEYSPACE_EVENTS_GENERIC},
+	{'$', KEYSPACE_EVENTS_STRING},
+	{'l', KEYSPACE_EVENTS_LIST},
+	{'s', KEYSPACE_EVENTS_SET},
+	{'h', KEYSPACE_EVENTS_HASH},
+	{'z', KEYSPACE_EVENTS_ZSET},
+	{'x', KEYSPACE_EVENTS_EXPIRED},
+	{'e', KEYSPACE_EVENTS_EVICTED},
+	{'t', KEYSPACE_EVENTS_STREAM},
+	{'K', KEYSPACE_EVENTS_KEYSPACE},
+	{'E', KEYSPACE_EVENTS_KEYEVENT},
+	{'m', KEYSPACE_EVENTS_KEY_MISS},
+	{'n', KEYSPACE_EVENTS_NEW_KEY},
+}
+
+const KEYSPACE_CHANNEL_FORMAT = "__keyspace@%d__:%s"
+const KEYEVENT_CHANNEL_FORMAT = "__keyevent@%d__:%s"
+
+const KEYSPACE_EVENT_SET = "set"
+const KEYSPACE_EVENT_DEL = "del"
+const KEYSPACE_EVENT_EXPIRE = "expire"
+const KEYSPACE_EVENT_EXPIRED = "expired"
+const KEYSPACE_EVENT_INCRBY = "incrby"
+const KEYSPACE_EVENT_DECRBY = "decrby"
+const KEYSPACE_EVENT_LPUSH = "lpush"
+const KEYSPACE_EVENT_RPUSH = "rpush"
+const KEYSPACE_EVENT_SETBIT = "setbit"
+const KEYSPACE_EVENT_PFADD = "pfadd"
+const KEYSPACE_EVENT_ZADD = "zadd"
+const KEYSPACE_EVENT_HSET = "hset"
+const KEYSPACE_EVENT_HDEL = "hdel"
+const KEYSPACE_EVENT_SADD = "sadd"
+const KEYSPACE_EVENT_SREM = "srem"
+const KEYSPACE_EVENT_GEOSEARCHSTORE = "geosearchstore"
+const KEYSPACE_EVENT_RENAME_FROM = "rename_from"
+const KEYSPACE_EVENT_RENAME_TO = "rename_to"
+const KEYSPACE_EVENT_COPY_TO = "copy_to"
+const KEYSPACE_EVENT_MOVE_FROM = "move_from"
+const KEYSPACE_EVENT_MOVE_TO = "move_to"
+const KEYSPACE_EVENT_RESTORE = "restore"
+const KEYSPACE_EVENT_XADD = "xadd"
+const KEYSPACE_EVENT_XDEL = "xdel"
+const KEYSPACE_EVENT_XTRIM = "xtrim"
+const KEYSPACE_EVENT_XGROUP_CREATE = "xgroup-create"
+const KEYSPACE_EVENT_XGROUP_SETID = "xgroup-setid"
+const KEYSPACE_EVENT_XGROUP_DESTROY = "xgroup-destroy"
+const KEYSPACE_EVENT_XGROUP_CREATECONSUMER = "xgroup-createconsumer"
+const KEYSPACE_EVENT_XGROUP_DELCONSUMER = "xgroup-delconsumer"
+
+// notifyKeyspaceEvent publishes the event to the keyspace channel of the
+// key and the key to the keyevent channel of the event, provided that the
+// class of the event is enabled.
+func (xredis *XRedis) notifyKeyspaceEvent(class int, event string, db int, key string) {
+	flags := xredis.config.notifyKeyspaceEvents
+	if flags&class == 0 {
+		return
+	}
+	if flags&KEYSPACE_EVENTS_KEYSPACE != 0 {
+		xredis.publish(fmt.Sprintf(KEYSPACE_CHANNEL_FORMAT, db, key), event)
+	}
+	if flags&KEYSPACE_EVENTS_KEYEVENT != 0 {
+		xredis.publish(fmt.Sprintf(KEYEVENT_CHANNEL_FORMAT, db, event), key)
+	}
+}
+
+func parseKeyspaceEvents(value string) (int, error) {
+	flags := 0
+	for _, character := range value {
+		if character == KEYSPACE_EVENTS_ALL_ALIAS {
+			flags |= KEYSPACE_EVENTS_ALL
+			continue
+		}
+		class, ok := keyspaceEventsClass(character)
+		if !ok {
+			return 0, errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+		}
+		flags |= class
+	}
+	return flags, nil
+}
+
+func formatKeyspaceEvents(flags int) string {
+	var builder strings.Builder
+	if flags&KEYSPACE_EVENTS_ALL == KEYSPACE_EVENTS_ALL {
+		builder.WriteRune(KEYSPACE_EVENTS_ALL_ALIAS)
+		flags &^= KEYSPACE_EVENTS_ALL
+	}
+	for _, eventsCharacter := range KEYSPACE_EVENTS_CHARACTERS {
+		if flags&eventsCharacter.class != 0 {
+			builder.WriteRune(eventsCharacter.character)
+		}
+	}
+	return builder.String()
+}
+
+func keyspaceEventsClass(character rune) (int, bool) {
+	for _, eventsCharacter := range KEYSPACE_EVENTS_CHARACTERS {
+		if eventsCharacter.character == character {
+			return eventsCharacter.class, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAndFormatKeyspaceEvents(t *testing.T) {
+	flags, err := parseKeyspaceEvents("KEA")
+	assert.Nil(t, err)
+	assert.Equal(t, "AKE", formatKeyspaceEvents(flags))
+
+	flags, err = parseKeyspaceEvents("Egx$")
+	assert.Nil(t, err)
+	assert.Equal(t, "g$xE", formatKeyspaceEvents(flags))
+
+	flags, err = parseKeyspaceEvents("")
+	assert.Nil(t, err)
+	assert.Equal(t, "", formatKeyspaceEvents(flags))
+
+	_, err = parseKeyspaceEvents("KZ")
+	assert.NotNil(t, err)
+}
+
+func TestKeyspaceAndKeyeventNotifications(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber := NewSubscriber(make(chan []byte, 10))
+	xredis.Subscribe(subscriber, []string{"__keyspace@0__:bla", "__keyevent@0__:set"}, false)
+	<-subscriber.outbox
+	<-subscriber.outbox
+
+	xredis.Set("bla", RespString{"1"})
+	assert.Empty(t, subscriber.outbox) // Notifications are disabled by default
+
+	err := xredis.ConfigSet(map[string]string{CONFIG_NOTIFY_KEYSPACE_EVENTS: "KE$"})
+	assert.Nil(t, err)
+	xredis.Set("bla", RespString{"1"})
+	assert.Equal(t, "*3\r\n$7\r\nmessage\r\n$18\r\n__keyspace@0__:bla\r\n$3\r\nset\r\n", string(<-subscriber.outbox))
+	assert.Equal(t, "*3\r\n$7\r\nmessage\r\n$18\r\n__keyevent@0__:set\r\n$3\r\nbla\r\n", string(<-subscriber.outbox))
+
+	xredis.Delete("bla") // Generic events are not enabled
+	assert.Empty(t, subscriber.outbox)
+}
+
+func TestExpiredKeyNotification(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.ConfigSet(map[string]string{CONFIG_NOTIFY_KEYSPACE_EVENTS: "Ex"})
+	subscriber := NewSubscriber(make(chan []byte, 10))
+	xredis.Subscribe(subscriber, []string{"__keyevent@*__:*"}, true)
+	<-subscriber.outbox
+
+	db3, _ := xredis.Select(3)
+	db3.SetWithExpiration("bla", RespString{"1"}, time.Now().Add(-time.Second))
+	assert.False(t, db3.Exists("bla"))
+	assert.Equal(t, "*4\r\n$8\r\npmessage\r\n$16\r\n__keyevent@*__:*\r\n$22\r\n__keyevent@3__:expired\r\n$3\r\nbla\r\n", string(<-subscriber.outbox))
+}
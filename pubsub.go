@@ -0,0 +1,448 @@
+package main
+
+import (
+	"log"
+	"slices"
+)
+
+const PUBSUB_MESSAGE = "message"
+const PUBSUB_PATTERN_MESSAGE = "pmessage"
+const PUBSUB_SUBSCRIBE = "subscribe"
+const PUBSUB_UNSUBSCRIBE = "unsubscribe"
+const PUBSUB_PATTERN_SUBSCRIBE = "psubscribe"
+const PUBSUB_PATTERN_UNSUBSCRIBE = "punsubscribe"
+const PUBSUB_SHARD_MESSAGE = "smessage"
+const PUBSUB_SHARD_SUBSCRIBE = "ssubscribe"
+const PUBSUB_SHARD_UNSUBSCRIBE = "sunsubscribe"
+
+// PubSub holds the channel and pattern subscriptions. It is owned by the
+// commands goroutine, so it must only be accessed from command handlers.
+// Sharded channels are kept apart, grouped by the slot owning each of
+// them, so that their messages never reach the global subscribers.
+type PubSub struct {
+	channels subscriptionRegistry
+	patterns subscriptionRegistry
+	shards   map[int]subscriptionRegistry
+}
+
+// subscriptionRegistry maps each channel (or pattern) to its subscribers
+type subscriptionRegistry map[string]map[*Subscriber]struct{}
+
+// Subscriber is the receiving end of the messages sent to a client. Its
+// subscription sets are owned by the commands goroutine as well.
+type Subscriber struct {
+	outbox        chan []byte
+	channels      map[string]struct{}
+	patterns      map[string]struct{}
+	shardChannels map[string]struct{}
+	disconnected  bool          // Set once the outbox overflowed
+	disconnect    chan struct{} // Closed once the outbox overflowed, for the connection to be closed
+}
+
+func NewPubSub() *PubSub {
+	return &PubSub{make(subscriptionRegistry), make(subscriptionRegistry), make(map[int]subscriptionRegistry)}
+}
+
+func NewSubscriber(outbox chan []byte) *Subscriber {
+	return &Subscriber{outbox, make(map[string]struct{}), make(map[string]struct{}), make(map[string]struct{}), false, make(chan struct{})}
+}
+
+// Subscribe adds the subscriptions and returns the total number of
+// subscriptions of the subscriber, sharded ones included.
+func (xredis *XRedis) Subscribe(subscriber *Subscriber, channels []string, pattern bool) int {
+	rspChan := make(chan int)
+	xredis.commands <- SubscribeCommand{subscriber, channels, pattern, rspChan}
+	return <-rspChan
+}
+
+// Unsubscribe removes the given subscriptions, or all the subscriptions
+// of the kind when no channel is given, and returns the total number of
+// subscriptions left.
+func (xredis *XRedis) Unsubscribe(subscriber *Subscriber, channels []string, pattern bool) int {
+	rspChan := make(chan int)
+	xredis.commands <- UnsubscribeCommand{subscriber, channels, pattern, rspChan}
+	return <-rspChan
+}
+
+// Publish sends the message to the subscribers of the channel and returns
+// how many clients received it, which leaves out those disconnected for
+// not keeping up.
+func (xredis *XRedis) Publish(channel string, message string) int {
+	rspChan := make(chan int)
+	xredis.commands <- PublishCommand{channel, message, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) PubSubChannels(pattern string) []string {
+	rspChan := make(chan []string)
+	xredis.commands <- PubSubChannelsCommand{pattern, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) PubSubNumSub(channels []string) []int {
+	rspChan := make(chan []int)
+	xredis.commands <- PubSubNumSubCommand{channels, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) PubSubNumPat() int {
+	rspChan := make(chan int)
+	xredis.commands <- PubSubNumPatCommand{rspChan}
+	return <-rspChan
+}
+
+// ShardSubscribe adds sharded channel subscriptions, which all must hash
+// to the same slot, and returns the total number of subscriptions.
+func (xredis *XRedis) ShardSubscribe(subscriber *Subscriber, channels []string) int {
+	rspChan := make(chan int)
+	xredis.commands <- ShardSubscribeCommand{subscriber, channels, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) ShardUnsubscribe(subscriber *Subscriber, channels []string) int {
+	rspChan := make(chan int)
+	xredis.commands <- ShardUnsubscribeCommand{subscriber, channels, rspChan}
+	return <-rspChan
+}
+
+// ShardPublish sends the message to the subscribers of the sharded channel
+// and returns how many clients received it, as Publish does.
+func (xredis *XRedis) ShardPublish(channel string, message string) int {
+	rspChan := make(chan int)
+	xredis.commands <- ShardPublishCommand{channel, message, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) PubSubShardChannels(pattern string) []string {
+	rspChan := make(chan []string)
+	xredis.commands <- PubSubShardChannelsCommand{pattern, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) PubSubShardNumSub(channels []string) []int {
+	rspChan := make(chan []int)
+	xredis.commands <- PubSubShardNumSubCommand{channels, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) handleSubscribeCommand(cmd SubscribeCommand) {
+	defer close(cmd.rspChannel)
+
+	registry, subscriptions, kind := xredis.pubsub.channels, cmd.subscriber.channels, PUBSUB_SUBSCRIBE
+	if cmd.pattern {
+		registry, subscriptions, kind = xredis.pubsub.patterns, cmd.subscriber.patterns, PUBSUB_PATTERN_SUBSCRIBE
+	}
+	for _, channel := range cmd.channels {
+		if cmd.subscriber.disconnected {
+			break
+		}
+		registry.add(channel, cmd.subscriber)
+		subscriptions[channel] = struct{}{}
+		xredis.deliver(cmd.subscriber, subscriptionReply(kind, RespString{channel}, cmd.subscriber.count()))
+	}
+	cmd.rspChannel <- cmd.subscriber.subscriptions()
+}
+
+func (xredis *XRedis) handleUnsubscribeCommand(cmd UnsubscribeCommand) {
+	defer close(cmd.rspChannel)
+
+	registry, subscriptions, kind := xredis.pubsub.channels, cmd.subscriber.channels, PUBSUB_UNSUBSCRIBE
+	if cmd.pattern {
+		registry, subscriptions, kind = xredis.pubsub.patterns, cmd.subscriber.patterns, PUBSUB_PATTERN_UNSUBSCRIBE
+	}
+	channels := cmd.channels
+	if len(channels) == 0 {
+		channels = sortedChannels(subscriptions)
+		if len(channels) == 0 {
+			xredis.deliver(cmd.subscriber, subscriptionReply(kind, RespNil{}, cmd.subscriber.count()))
+		}
+	}
+	for _, channel := range channels {
+		delete(subscriptions, channel)
+		registry.remove(channel, cmd.subscriber)
+		xredis.deliver(cmd.subscriber, subscriptionReply(kind, RespString{channel}, cmd.subscriber.count()))
+	}
+	cmd.rspChannel <- cmd.subscriber.subscriptions()
+}
+
+func (xredis *XRedis) handlePublishCommand(cmd PublishCommand) {
+	defer close(cmd.rspChannel)
+	cmd.rspChannel <- xredis.publish(cmd.channel, cmd.message)
+}
+
+func (xredis *XRedis) handlePubSubChannelsCommand(cmd PubSubChannelsCommand) {
+	defer close(cmd.rspChannel)
+
+	channels := make([]string, 0)
+	for channel := range xredis.pubsub.channels {
+		if cmd.pattern == "" || globMatch(cmd.pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	slices.Sort(channels)
+	cmd.rspChannel <- channels
+}
+
+func (xredis *XRedis) handlePubSubNumSubCommand(cmd PubSubNumSubCommand) {
+	defer close(cmd.rspChannel)
+
+	counts := make([]int, 0, len(cmd.channels))
+	for _, channel := range cmd.channels {
+		counts = append(counts, len(xredis.pubsub.channels[channel]))
+	}
+	cmd.rspChannel <- counts
+}
+
+func (xredis *XRedis) handlePubSubNumPatCommand(cmd PubSubNumPatCommand) {
+	defer close(cmd.rspChannel)
+	cmd.rspChannel <- len(xredis.pubsub.patterns)
+}
+
+func (xredis *XRedis) handleShardSubscribeCommand(cmd ShardSubscribeCommand) {
+	defer close(cmd.rspChannel)
+
+	for _, channel := range cmd.channels {
+		if cmd.subscriber.disconnected {
+			break
+		}
+		xredis.pubsub.shard(channel).add(channel, cmd.subscriber)
+		cmd.subscriber.shardChannels[channel] = struct{}{}
+		xredis.deliver(cmd.subscriber, subscriptionReply(PUBSUB_SHARD_SUBSCRIBE, RespString{channel}, len(cmd.subscriber.shardChannels)))
+	}
+	cmd.rspChannel <- cmd.subscriber.subscriptions()
+}
+
+func (xredis *XRedis) handleShardUnsubscribeCommand(cmd ShardUnsubscribeCommand) {
+	defer close(cmd.rspChannel)
+
+	channels := cmd.channels
+	if len(channels) == 0 {
+		channels = sortedChannels(cmd.subscriber.shardChannels)
+		if len(channels) == 0 {
+			xredis.deliver(cmd.subscriber, subscriptionReply(PUBSUB_SHARD_UNSUBSCRIBE, RespNil{}, 0))
+		}
+	}
+	for _, channel := range channels {
+		delete(cmd.subscriber.shardChannels, channel)
+		xredis.pubsub.removeShardSubscription(channel, cmd.subscriber)
+		xredis.deliver(cmd.subscriber, subscriptionReply(PUBSUB_SHARD_UNSUBSCRIBE, RespString{channel}, len(cmd.subscriber.shardChannels)))
+	}
+	cmd.rspChannel <- cmd.subscriber.subscriptions()
+}
+
+func (xredis *XRedis) handleShardPublishCommand(cmd ShardPublishCommand) {
+	defer close(cmd.rspChannel)
+
+	receivers := 0
+	for subscriber := range xredis.pubsub.shards[keyHashSlot(cmd.channel)][cmd.channel] {
+		if xredis.deliver(subscriber, RespArray{[]RespDataType{RespString{PUBSUB_SHARD_MESSAGE}, RespString{cmd.channel}, RespString{cmd.message}}}) {
+			receivers++
+		}
+	}
+	cmd.rspChannel <- receivers
+}
+
+func (xredis *XRedis) handlePubSubShardChannelsCommand(cmd PubSubShardChannelsCommand) {
+	defer close(cmd.rspChannel)
+
+	channels := make([]string, 0)
+	for _, registry := range xredis.pubsub.shards {
+		for channel := range registry {
+			if cmd.pattern == "" || globMatch(cmd.pattern, channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	slices.Sort(channels)
+	cmd.rspChannel <- channels
+}
+
+func (xredis *XRedis) handlePubSubShardNumSubCommand(cmd PubSubShardNumSubCommand) {
+	defer close(cmd.rspChannel)
+
+	counts := make([]int, 0, len(cmd.channels))
+	for _, channel := range cmd.channels {
+		counts = append(counts, len(xredis.pubsub.shards[keyHashSlot(channel)][channel]))
+	}
+	cmd.rspChannel <- counts
+}
+
+// publish delivers the message to the subscribers of the channel and of
+// every pattern matching it, returning the number of receivers.
+func (xredis *XRedis) publish(channel string, message string) int {
+	receivers := 0
+	for subscriber := range xredis.pubsub.channels[channel] {
+		if xredis.deliver(subscriber, RespArray{[]RespDataType{RespString{PUBSUB_MESSAGE}, RespString{channel}, RespString{message}}}) {
+			receivers++
+		}
+	}
+	for pattern, subscribers := range xredis.pubsub.patterns {
+		if !globMatch(pattern, channel) {
+			continue
+		}
+		for subscriber := range subscribers {
+			if xredis.deliver(subscriber, RespArray{[]RespDataType{RespString{PUBSUB_PATTERN_MESSAGE}, RespString{pattern}, RespString{channel}, RespString{message}}}) {
+				receivers++
+			}
+		}
+	}
+	return receivers
+}
+
+// deliver queues the message to be written to the subscriber without ever
+// blocking the commands goroutine, and returns whether it was queued. As
+// Redis does with the clients over their output buffer limit, a subscriber
+// that can't keep up is disconnected rather than left to miss messages.
+func (xredis *XRedis) deliver(subscriber *Subscriber, message RespDataType) bool {
+	if subscriber.disconnected {
+		return false
+	}
+	select {
+	case subscriber.outbox <- []byte(message.serialize()):
+		return true
+	default:
+		log.Println("Disconnecting slow subscriber")
+		xredis.disconnectSubscriber(subscriber)
+		return false
+	}
+}
+
+// disconnectSubscriber drops every subscription of the subscriber and asks
+// for its connection to be closed.
+func (xredis *XRedis) disconnectSubscriber(subscriber *Subscriber) {
+	for channel := range subscriber.channels {
+		xredis.pubsub.channels.remove(channel, subscriber)
+	}
+	for pattern := range subscriber.patterns {
+		xredis.pubsub.patterns.remove(pattern, subscriber)
+	}
+	for channel := range subscriber.shardChannels {
+		xredis.pubsub.removeShardSubscription(channel, subscriber)
+	}
+	clear(subscriber.channels)
+	clear(subscriber.patterns)
+	clear(subscriber.shardChannels)
+	subscriber.disconnected = true
+	close(subscriber.disconnect)
+}
+
+// count returns the number of channel and pattern subscriptions, which
+// is what the confirmations of the global subscriptions report.
+func (subscriber *Subscriber) count() int {
+	return len(subscriber.channels) + len(subscriber.patterns)
+}
+
+// subscriptions returns the number of subscriptions of every kind. The
+// subscriber is in subscribed mode for as long as it isn't zero.
+func (subscriber *Subscriber) subscriptions() int {
+	return subscriber.count() + len(subscriber.shardChannels)
+}
+
+// shard returns the registry of the slot owning the sharded channel,
+// creating it if needed.
+func (pubsub *PubSub) shard(channel string) subscriptionRegistry {
+	slot := keyHashSlot(channel)
+	if _, ok := pubsub.shards[slot]; !ok {
+		pubsub.shards[slot] = make(subscriptionRegistry)
+	}
+	return pubsub.shards[slot]
+}
+
+// removeShardSubscription removes the subscription to the sharded channel,
+// and the registry of its slot once empty.
+func (pubsub *PubSub) removeShardSubscription(channel string, subscriber *Subscriber) {
+	slot := keyHashSlot(channel)
+	pubsub.shards[slot].remove(channel, subscriber)
+	if len(pubsub.shards[slot]) == 0 {
+		delete(pubsub.shards, slot)
+	}
+}
+
+func (registry subscriptionRegistry) add(channel string, subscriber *Subscriber) {
+	if _, ok := registry[channel]; !ok {
+		registry[channel] = make(map[*Subscriber]struct{})
+	}
+	registry[channel][subscriber] = struct{}{}
+}
+
+func (registry subscriptionRegistry) remove(channel string, subscriber *Subscriber) {
+	delete(registry[channel], subscriber)
+	if len(registry[channel]) == 0 {
+		delete(registry, channel)
+	}
+}
+
+func sortedChannels(subscriptions map[string]struct{}) []string {
+	channels := make([]string, 0, len(subscriptions))
+	for channel := range subscriptions {
+		channels = append(channels, channel)
+	}
+	slices.Sort(channels)
+	return channels
+}
+
+func subscriptionReply(kind string, channel RespDataType, count int) RespDataType {
+	return RespArray{[]RespDataType{RespString{kind}, channel, RespInt{int64(count)}}}
+}
+
+type SubscribeCommand struct {
+	subscriber *Subscriber
+	channels   []string
+	pattern    bool
+	rspChannel chan int
+}
+
+type UnsubscribeCommand struct {
+	subscriber *Subscriber
+	channels   []string
+	pattern    bool
+	rspChannel chan int
+}
+
+type PublishCommand struct {
+	channel    string
+	message    string
+	rspChannel chan int
+}
+
+type PubSubChannelsCommand struct {
+	pattern    string
+	rspChannel chan []string
+}
+
+type PubSubNumSubCommand struct {
+	channels   []string
+	rspChannel chan []int
+}
+
+type PubSubNumPatCommand struct {
+	rspChannel chan int
+}
+
+type ShardSubscribeCommand struct {
+	subscriber *Subscriber
+	channels   []string
+	rspChannel chan int
+}
+
+type ShardUnsubscribeCommand struct {
+	subscriber *Subscriber
+	channels   []string
+	rspChannel chan int
+}
+
+type ShardPublishCommand struct {
+	channel    string
+	message    string
+	rspChannel chan int
+}
+
+type PubSubShardChannelsCommand struct {
+	pattern    string
+	rspChannel chan []string
+}
+
+type PubSubShardNumSubCommand struct {
+	channels   []string
+	rspChannel chan []int
+}
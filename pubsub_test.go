@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishToChannelSubscriber(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber := NewSubscriber(make(chan []byte, 10))
+
+	count := xredis.Subscribe(subscriber, []string{"news"}, false)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n", string(<-subscriber.outbox))
+
+	receivers := xredis.Publish("news", "hello")
+	assert.Equal(t, 1, receivers)
+	assert.Equal(t, "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n", string(<-subscriber.outbox))
+
+	receivers = xredis.Publish("sports", "hello")
+	assert.Equal(t, 0, receivers)
+}
+
+func TestPublishToPatternSubscriber(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber := NewSubscriber(make(chan []byte, 10))
+
+	xredis.Subscribe(subscriber, []string{"news.*"}, true)
+	<-subscriber.outbox
+
+	receivers := xredis.Publish("news.tech", "hello")
+	assert.Equal(t, 1, receivers)
+	assert.Equal(t, "*4\r\n$8\r\npmessage\r\n$6\r\nnews.*\r\n$9\r\nnews.tech\r\n$5\r\nhello\r\n", string(<-subscriber.outbox))
+}
+
+func TestUnsubscribeFromAllChannels(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber := NewSubscriber(make(chan []byte, 10))
+
+	xredis.Subscribe(subscriber, []string{"a", "b"}, false)
+	<-subscriber.outbox
+	<-subscriber.outbox
+
+	count := xredis.Unsubscribe(subscriber, nil, false)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, "*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:1\r\n", string(<-subscriber.outbox))
+	assert.Equal(t, "*3\r\n$11\r\nunsubscribe\r\n$1\r\nb\r\n:0\r\n", string(<-subscriber.outbox))
+	assert.Empty(t, xredis.PubSubChannels(""))
+}
+
+func TestPubSubIntrospection(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber1 := NewSubscriber(make(chan []byte, 10))
+	subscriber2 := NewSubscriber(make(chan []byte, 10))
+
+	xredis.Subscribe(subscriber1, []string{"news", "sports"}, false)
+	xredis.Subscribe(subscriber2, []string{"news"}, false)
+	xredis.Subscribe(subscriber2, []string{"news.*", "sports.*"}, true)
+
+	assert.Equal(t, []string{"news", "sports"}, xredis.PubSubChannels(""))
+	assert.Equal(t, []string{"sports"}, xredis.PubSubChannels("s*"))
+	assert.Equal(t, []int{2, 1, 0}, xredis.PubSubNumSub([]string{"news", "sports", "weather"}))
+	assert.Equal(t, 2, xredis.PubSubNumPat())
+}
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber := NewSubscriber(make(chan []byte, 2))
+	other := NewSubscriber(make(chan []byte, 10))
+
+	xredis.Subscribe(subscriber, []string{"news"}, false)
+	xredis.Subscribe(subscriber, []string{"news.*"}, true)
+	xredis.Subscribe(other, []string{"news"}, false)
+	receivers := xredis.Publish("news", "hello")
+	assert.Equal(t, 1, receivers)
+
+	select {
+	case <-subscriber.disconnect:
+	default:
+		assert.Fail(t, "the slow subscriber wasn't disconnected")
+	}
+	assert.Equal(t, []int{1}, xredis.PubSubNumSub([]string{"news"}))
+	assert.Equal(t, 0, xredis.PubSubNumPat())
+	assert.Equal(t, 0, xredis.Subscribe(subscriber, []string{"sports"}, false))
+	assert.Empty(t, xredis.PubSubChannels("sports"))
+}
+
+func TestWriteOutboxClosesDisconnectedClient(t *testing.T) {
+	server, client := net.Pipe()
+	outbox := make(chan []byte, 1)
+	disconnect := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		writeOutbox(server, outbox, disconnect)
+		close(done)
+	}()
+
+	close(disconnect)
+	_, err := client.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	outbox <- []byte("+OK\r\n")
+	close(outbox)
+	<-done
+}
+
+func TestShardPublishIsSeparateFromGlobalPublish(t *testing.T) {
+	xredis := NewXRedis()
+	shardSubscriber := NewSubscriber(make(chan []byte, 10))
+	globalSubscriber := NewSubscriber(make(chan []byte, 10))
+
+	count := xredis.ShardSubscribe(shardSubscriber, []string{"news"})
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "*3\r\n$10\r\nssubscribe\r\n$4\r\nnews\r\n:1\r\n", string(<-shardSubscriber.outbox))
+	xredis.Subscribe(globalSubscriber, []string{"news"}, false)
+	<-globalSubscriber.outbox
+
+	receivers := xredis.ShardPublish("news", "hello")
+	assert.Equal(t, 1, receivers)
+	assert.Equal(t, "*3\r\n$8\r\nsmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n", string(<-shardSubscriber.outbox))
+	assert.Empty(t, globalSubscriber.outbox)
+
+	receivers = xredis.Publish("news", "hello")
+	assert.Equal(t, 1, receivers)
+	assert.Empty(t, shardSubscriber.outbox)
+
+	assert.Equal(t, []string{"news"}, xredis.PubSubShardChannels(""))
+	assert.Equal(t, []int{1, 0}, xredis.PubSubShardNumSub([]string{"news", "sports"}))
+
+	count = xredis.ShardUnsubscribe(shardSubscriber, nil)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, "*3\r\n$12\r\nsunsubscribe\r\n$4\r\nnews\r\n:0\r\n", string(<-shardSubscriber.outbox))
+	assert.Empty(t, xredis.PubSubShardChannels(""))
+}
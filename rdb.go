@@ -0,0 +1,1218 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc64"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An RDB file, as written by Redis, starts with the magic bytes and the
+// version as 4 digits, followed by opcodes introducing auxiliary fields,
+// database selections and expirations, and by keys prefixed by the type of
+// their value. It ends with the EOF opcode and a CRC64 checksum:
+//
+//	REDIS0009
+//	AUX <name> <value>
+//	SELECTDB <db> RESIZEDB <keys> <expires>
+//	[EXPIRETIME_MS <uint64>] <type> <key> <value>
+//	...
+//	EOF <crc64:uint64>
+//
+// Integers are little endian, while lengths and strings have encodings of
+// their own.
+const RDB_MAGIC = "REDIS"
+
+// Version written, loaded by every Redis since 5.0, and newest version
+// loaded
+const RDB_VERSION = 9
+const RDB_MAX_VERSION = 12
+
+// Version since which the files end with a checksum
+const RDB_CHECKSUM_VERSION = 5
+const RDB_CHECKSUM_SIZE = 8
+
+const RDB_OPCODE_SLOT_INFO = 244
+const RDB_OPCODE_FUNCTION2 = 245
+const RDB_OPCODE_FUNCTION_PRE_GA = 246
+const RDB_OPCODE_MODULE_AUX = 247
+const RDB_OPCODE_IDLE = 248
+const RDB_OPCODE_FREQ = 249
+const RDB_OPCODE_AUX = 250
+const RDB_OPCODE_RESIZEDB = 251
+const RDB_OPCODE_EXPIRETIME_MS = 252
+const RDB_OPCODE_EXPIRETIME = 253
+const RDB_OPCODE_SELECTDB = 254
+const RDB_OPCODE_EOF = 255
+
+const RDB_TYPE_STRING = 0
+const RDB_TYPE_LIST = 1
+const RDB_TYPE_SET = 2
+const RDB_TYPE_ZSET = 3
+const RDB_TYPE_HASH = 4
+const RDB_TYPE_ZSET_2 = 5
+const RDB_TYPE_HASH_ZIPMAP = 9
+const RDB_TYPE_LIST_ZIPLIST = 10
+const RDB_TYPE_SET_INTSET = 11
+const RDB_TYPE_ZSET_ZIPLIST = 12
+const RDB_TYPE_HASH_ZIPLIST = 13
+const RDB_TYPE_LIST_QUICKLIST = 14
+const RDB_TYPE_STREAM_LISTPACKS = 15
+const RDB_TYPE_HASH_LISTPACK = 16
+const RDB_TYPE_ZSET_LISTPACK = 17
+const RDB_TYPE_LIST_QUICKLIST_2 = 18
+const RDB_TYPE_STREAM_LISTPACKS_2 = 19
+const RDB_TYPE_SET_LISTPACK = 20
+const RDB_TYPE_STREAM_LISTPACKS_3 = 21
+
+// Containers of the quicklist nodes, holding either a single element or a
+// listpack of them
+const RDB_QUICKLIST_NODE_PLAIN = 1
+const RDB_QUICKLIST_NODE_PACKED = 2
+
+// Streams are saved as listpacks of entries whose IDs are relative to the
+// key of their listpack. Each starts with a master entry holding the
+// fields that the entries flagged with SAMEFIELDS share, and the entries
+// deleted since are flagged rather than removed. Redis caps the entries of
+// each listpack to 100 by default.
+const RDB_STREAM_NODE_MAX_ENTRIES = 100
+const RDB_STREAM_ID_SIZE = 16
+const RDB_STREAM_ITEM_FLAG_DELETED = 1
+const RDB_STREAM_ITEM_FLAG_SAMEFIELDS = 2
+
+// Intsets hold integers of 2, 4 or 8 bytes after their size and number
+const RDB_INTSET_HEADER_SIZE = 8
+
+// Zipmaps, the hash encoding before Redis 2.6, write lengths from 254 in
+// the 4 bytes that follow and end with 255
+const RDB_ZIPMAP_BIGLEN = 254
+const RDB_ZIPMAP_END = 255
+
+// Lengths start with 2 bits telling how they are encoded: in the 6 bits
+// left, in 14 bits, in the 32 or 64 bits that follow, or as a special
+// encoding of the string they prefix
+const RDB_LENGTH_6BIT = 0
+const RDB_LENGTH_14BIT = 1
+const RDB_LENGTH_32BIT = 0x80
+const RDB_LENGTH_64BIT = 0x81
+const RDB_LENGTH_ENCODED = 3
+
+const RDB_ENCODING_INT8 = 0
+const RDB_ENCODING_INT16 = 1
+const RDB_ENCODING_INT32 = 2
+const RDB_ENCODING_LZF = 3
+
+// Strings longer than this are LZF compressed when it saves more than 4
+// bytes, as Redis does
+const RDB_LZF_MIN_LENGTH = 20
+const RDB_LZF_MIN_SAVING = 4
+
+// Lengths of the scores of the first sorted set encoding telling that the
+// score isn't written as a decimal
+const RDB_ZSET_SCORE_NAN = 253
+const RDB_ZSET_SCORE_POSITIVE_INFINITY = 254
+const RDB_ZSET_SCORE_NEGATIVE_INFINITY = 255
+
+// Redis stores its HyperLogLogs as strings made of a header and either the
+// registers packed in 6 bits, or a run length encoding of them when sparse.
+// The cardinality cached in the header is invalidated by its highest bit.
+const RDB_HYPERLOGLOG_MAGIC = "HYLL"
+const RDB_HYPERLOGLOG_HEADER_SIZE = 16
+const RDB_HYPERLOGLOG_DENSE = 0
+const RDB_HYPERLOGLOG_SPARSE = 1
+const RDB_HYPERLOGLOG_REGISTER_BITS = 6
+const RDB_HYPERLOGLOG_REGISTER_MAX = 1<<RDB_HYPERLOGLOG_REGISTER_BITS - 1
+const RDB_HYPERLOGLOG_DENSE_SIZE = RDB_HYPERLOGLOG_HEADER_SIZE + HYPERLOGLOG_REGISTERS*RDB_HYPERLOGLOG_REGISTER_BITS/8
+const RDB_HYPERLOGLOG_CACHE_INVALID = 1 << 7
+
+// Opcodes of the sparse HyperLogLogs: runs of up to 64 or 16384 zero
+// registers, and runs of up to 4 registers with a value up to 32
+const RDB_HYPERLOGLOG_SPARSE_ZERO = 0x00
+const RDB_HYPERLOGLOG_SPARSE_XZERO = 0x40
+const RDB_HYPERLOGLOG_SPARSE_VAL = 0x80
+
+// Redis checksums with the Jones polynomial, starting from 0 and without
+// inverting the result, unlike the hash/crc64 package
+var RDB_CRC64_TABLE = crc64.MakeTable(0x95AC9329AC4BC9B5)
+
+func rdbChecksum(data []byte) uint64 {
+	return ^crc64.Update(^uint64(0), RDB_CRC64_TABLE, data)
+}
+
+// encodeRDB encodes the databases in the RDB format, failing on values
+// that Redis has no type for.
+func encodeRDB(databases []map[string]XRedisValue) ([]byte, error) {
+	data := fmt.Appendf(nil, "%s%04d", RDB_MAGIC, RDB_VERSION)
+	data = appendRDBAux(data, "redis-bits", strconv.Itoa(strconv.IntSize))
+	data = appendRDBAux(data, "ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	for db, database := range databases {
+		if len(database) == 0 {
+			continue
+		}
+		expires := 0
+		for _, value := range database {
+			if value.ExpirationTimestampMillis != NON_EXPIRATION_TIME {
+				expires++
+			}
+		}
+		data = appendRDBLength(append(data, RDB_OPCODE_SELECTDB), uint64(db))
+		data = append(data, RDB_OPCODE_RESIZEDB)
+		data = appendRDBLength(appendRDBLength(data, uint64(len(database))), uint64(expires))
+
+		for key, value := range database {
+			if value.ExpirationTimestampMillis != NON_EXPIRATION_TIME {
+				data = append(data, RDB_OPCODE_EXPIRETIME_MS)
+				data = binary.LittleEndian.AppendUint64(data, uint64(value.ExpirationTimestampMillis))
+			}
+			typeOffset := len(data)
+			data = appendRDBString(append(data, 0), key)
+			var valueType byte
+			var err error
+			data, valueType, err = appendRDBElement(data, value.Element)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			data[typeOffset] = valueType
+		}
+	}
+	data = append(data, RDB_OPCODE_EOF)
+	return binary.LittleEndian.AppendUint64(data, rdbChecksum(data)), nil
+}
+
+// appendRDBElement appends the encoding of the element, returning its type
+func appendRDBElement(data []byte, element RespDataType) ([]byte, byte, error) {
+	switch element := element.(type) {
+	case RespString:
+		return appendRDBString(data, element.Str), RDB_TYPE_STRING, nil
+	case RespInt:
+		return appendRDBString(data, strconv.FormatInt(element.Value, 10)), RDB_TYPE_STRING, nil
+	case RespArray:
+		data = appendRDBLength(data, uint64(len(element.Elements)))
+		for _, listElement := range element.Elements {
+			str, ok := decodeStringValue(listElement).(RespString)
+			if !ok {
+				return nil, 0, fmt.Errorf("unexpected list element of type %T", listElement)
+			}
+			data = appendRDBString(data, str.Str)
+		}
+		return data, RDB_TYPE_LIST, nil
+	case *SortedSet:
+		data = appendRDBLength(data, uint64(len(element.Members)))
+		for _, member := range element.Members {
+			data = appendRDBString(data, member.Member)
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(member.Score))
+		}
+		return data, RDB_TYPE_ZSET_2, nil
+	case *HyperLogLog:
+		return appendRDBString(data, encodeRDBHyperLogLog(element)), RDB_TYPE_STRING, nil
+	case *Hash:
+		data = appendRDBLength(data, uint64(element.len()))
+		for field, value := range element.fields {
+			data = appendRDBString(appendRDBString(data, field), value)
+		}
+		return data, RDB_TYPE_HASH, nil
+	case *Set:
+		data = appendRDBLength(data, uint64(element.len()))
+		for member := range element.members {
+			data = appendRDBString(data, member)
+		}
+		return data, RDB_TYPE_SET, nil
+	case *Stream:
+		return appendRDBStream(data, element), RDB_TYPE_STREAM_LISTPACKS, nil
+	default:
+		return nil, 0, fmt.Errorf("unexpected value of type %T", element)
+	}
+}
+
+// appendRDBStream appends the stream in the first stream encoding, loaded
+// by every Redis since 5.0. Like Redis loading it, xredis derives the
+// number of entries ever added from the length and forgets the greatest
+// deleted ID, which only serve to compute the lag of the groups.
+func appendRDBStream(data []byte, stream *Stream) []byte {
+	nodes := slices.Collect(slices.Chunk(stream.Entries, RDB_STREAM_NODE_MAX_ENTRIES))
+	data = appendRDBLength(data, uint64(len(nodes)))
+	for _, node := range nodes {
+		data = appendRDBString(data, string(appendRDBStreamID(nil, node[0].ID)))
+		data = appendRDBString(data, encodeRDBStreamNode(node))
+	}
+	data = appendRDBLength(data, uint64(len(stream.Entries)))
+	data = appendRDBLength(appendRDBLength(data, stream.LastID.Ms), stream.LastID.Seq)
+
+	data = appendRDBLength(data, uint64(len(stream.Groups)))
+	for _, name := range slices.Sorted(maps.Keys(stream.Groups)) {
+		group := stream.Groups[name]
+		data = appendRDBString(data, name)
+		data = appendRDBLength(appendRDBLength(data, group.LastDeliveredID.Ms), group.LastDeliveredID.Seq)
+		pending := slices.SortedFunc(maps.Keys(group.Pending), StreamID.compare)
+		data = appendRDBLength(data, uint64(len(pending)))
+		for _, id := range pending {
+			data = appendRDBStreamID(data, id)
+			data = binary.LittleEndian.AppendUint64(data, uint64(group.Pending[id].DeliveryTime))
+			data = appendRDBLength(data, uint64(group.Pending[id].DeliveryCount))
+		}
+		data = appendRDBLength(data, uint64(len(group.Consumers)))
+		for _, consumerName := range slices.Sorted(maps.Keys(group.Consumers)) {
+			consumer := group.Consumers[consumerName]
+			data = appendRDBString(data, consumerName)
+			data = binary.LittleEndian.AppendUint64(data, uint64(consumer.SeenTime))
+			consumerPending := slices.SortedFunc(maps.Keys(consumer.Pending), StreamID.compare)
+			data = appendRDBLength(data, uint64(len(consumerPending)))
+			for _, id := range consumerPending {
+				data = appendRDBStreamID(data, id)
+			}
+		}
+	}
+	return data
+}
+
+// appendRDBStreamID appends the ID as two big endian integers, so that the
+// IDs sort as their bytes do
+func appendRDBStreamID(data []byte, id StreamID) []byte {
+	return binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(data, id.Ms), id.Seq)
+}
+
+// encodeRDBStreamNode encodes the entries as the listpack of a stream
+// node, the fields of the first entry being those of the master entry.
+// Each entry is made of its flags, its ID relative to the first one, its
+// fields, or only their values when they are the master ones, and the
+// number of listpack entries it takes besides this one.
+func encodeRDBStreamNode(entries []StreamEntry) string {
+	master := entries[0]
+	masterFields := streamEntryFieldNames(master.Fields)
+	listpack := &listpackWriter{}
+	listpack.integer(int64(len(entries)))
+	listpack.integer(0) // Deleted entries
+	listpack.integer(int64(len(masterFields)))
+	for _, field := range masterFields {
+		listpack.string(field)
+	}
+	listpack.integer(0) // End of the master entry
+
+	for _, entry := range entries {
+		fields := streamEntryFieldNames(entry.Fields)
+		sameFields := slices.Equal(fields, masterFields)
+		flags := int64(0)
+		if sameFields {
+			flags = RDB_STREAM_ITEM_FLAG_SAMEFIELDS
+		}
+		listpack.integer(flags)
+		listpack.integer(int64(entry.ID.Ms - master.ID.Ms))
+		listpack.integer(int64(entry.ID.Seq - master.ID.Seq))
+		if sameFields {
+			for i := 1; i < len(entry.Fields); i += 2 {
+				listpack.string(entry.Fields[i])
+			}
+			listpack.integer(int64(len(fields) + 3))
+		} else {
+			listpack.integer(int64(len(fields)))
+			for _, field := range entry.Fields {
+				listpack.string(field)
+			}
+			listpack.integer(int64(2*len(fields) + 4))
+		}
+	}
+	return listpack.bytes()
+}
+
+func streamEntryFieldNames(fields []string) []string {
+	names := make([]string, 0, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		names = append(names, fields[i])
+	}
+	return names
+}
+
+// listpackWriter builds a listpack, encoding the canonical integers as
+// integers as Redis does
+type listpackWriter struct {
+	data    []byte
+	entries int
+}
+
+func (listpack *listpackWriter) integer(value int64) {
+	var entry []byte
+	switch {
+	case value >= 0 && value <= 127:
+		entry = []byte{byte(value)}
+	case value >= -1<<12 && value < 1<<12:
+		entry = []byte{0xC0 | byte(value>>8)&0x1F, byte(value)}
+	case value >= math.MinInt16 && value <= math.MaxInt16:
+		entry = binary.LittleEndian.AppendUint16([]byte{0xF1}, uint16(value))
+	case value >= -1<<23 && value < 1<<23:
+		entry = []byte{0xF2, byte(value), byte(value >> 8), byte(value >> 16)}
+	case value >= math.MinInt32 && value <= math.MaxInt32:
+		entry = binary.LittleEndian.AppendUint32([]byte{0xF3}, uint32(value))
+	default:
+		entry = binary.LittleEndian.AppendUint64([]byte{0xF4}, uint64(value))
+	}
+	listpack.append(entry)
+}
+
+func (listpack *listpackWriter) string(str string) {
+	if value, err := strconv.ParseInt(str, 10, 64); err == nil && strconv.FormatInt(value, 10) == str {
+		listpack.integer(value)
+		return
+	}
+	var entry []byte
+	switch {
+	case len(str) < 1<<6:
+		entry = []byte{0x80 | byte(len(str))}
+	case len(str) < 1<<12:
+		entry = []byte{0xE0 | byte(len(str)>>8), byte(len(str))}
+	default:
+		entry = binary.LittleEndian.AppendUint32([]byte{0xF0}, uint32(len(str)))
+	}
+	listpack.append(append(entry, str...))
+}
+
+// append appends the entry followed by its length, 7 bits per byte from
+// the most significant ones, the highest bit being set on all bytes but
+// the first so that it can be read backwards
+func (listpack *listpackWriter) append(entry []byte) {
+	listpack.data = append(listpack.data, entry...)
+	backlen := make([]byte, listpackBacklenSize(len(entry)))
+	for i := range backlen {
+		backlen[i] = byte(len(entry)>>(7*(len(backlen)-1-i))) & 0x7F
+		if i > 0 {
+			backlen[i] |= 0x80
+		}
+	}
+	listpack.data = append(listpack.data, backlen...)
+	listpack.entries++
+}
+
+// bytes returns the listpack, prefixed by its size and its number of
+// entries, which is left unknown past 65534
+func (listpack *listpackWriter) bytes() string {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(6+len(listpack.data)+1))
+	data = binary.LittleEndian.AppendUint16(data, uint16(min(listpack.entries, math.MaxUint16)))
+	return string(append(append(data, listpack.data...), 0xFF))
+}
+
+func appendRDBAux(data []byte, name string, value string) []byte {
+	return appendRDBString(appendRDBString(append(data, RDB_OPCODE_AUX), name), value)
+}
+
+func appendRDBLength(data []byte, length uint64) []byte {
+	switch {
+	case length < 1<<6:
+		return append(data, byte(length))
+	case length < 1<<14:
+		return append(data, byte(RDB_LENGTH_14BIT<<6|length>>8), byte(length))
+	case length <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(data, RDB_LENGTH_32BIT), uint32(length))
+	default:
+		return binary.BigEndian.AppendUint64(append(data, RDB_LENGTH_64BIT), length)
+	}
+}
+
+// appendRDBString appends the string as an integer when it is one that
+// fits in 32 bits, compressed when it's long enough, or as is otherwise.
+func appendRDBString(data []byte, str string) []byte {
+	if value, err := strconv.ParseInt(str, 10, 32); err == nil && strconv.FormatInt(value, 10) == str {
+		switch {
+		case value >= math.MinInt8 && value <= math.MaxInt8:
+			return append(data, RDB_LENGTH_ENCODED<<6|RDB_ENCODING_INT8, byte(value))
+		case value >= math.MinInt16 && value <= math.MaxInt16:
+			return binary.LittleEndian.AppendUint16(append(data, RDB_LENGTH_ENCODED<<6|RDB_ENCODING_INT16), uint16(value))
+		default:
+			return binary.LittleEndian.AppendUint32(append(data, RDB_LENGTH_ENCODED<<6|RDB_ENCODING_INT32), uint32(value))
+		}
+	}
+	if len(str) > RDB_LZF_MIN_LENGTH {
+		if compressed := lzfCompress([]byte(str)); len(compressed) <= len(str)-RDB_LZF_MIN_SAVING {
+			data = append(data, RDB_LENGTH_ENCODED<<6|RDB_ENCODING_LZF)
+			data = appendRDBLength(appendRDBLength(data, uint64(len(compressed))), uint64(len(str)))
+			return append(data, compressed...)
+		}
+	}
+	return append(appendRDBLength(data, uint64(len(str))), str...)
+}
+
+// encodeRDBHyperLogLog encodes the HyperLogLog as a dense one of Redis,
+// which recomputes its cardinality on first use.
+func encodeRDBHyperLogLog(hyperLogLog *HyperLogLog) string {
+	data := make([]byte, RDB_HYPERLOGLOG_DENSE_SIZE)
+	copy(data, RDB_HYPERLOGLOG_MAGIC)
+	data[len(RDB_HYPERLOGLOG_MAGIC)] = RDB_HYPERLOGLOG_DENSE
+	data[RDB_HYPERLOGLOG_HEADER_SIZE-1] = RDB_HYPERLOGLOG_CACHE_INVALID
+	registers := data[RDB_HYPERLOGLOG_HEADER_SIZE:]
+	for index := range HYPERLOGLOG_REGISTERS {
+		value := uint16(hyperLogLog.register(uint16(index)))
+		bit := index * RDB_HYPERLOGLOG_REGISTER_BITS
+		registers[bit/8] |= byte(value << (bit % 8))
+		if bit%8 > 8-RDB_HYPERLOGLOG_REGISTER_BITS {
+			registers[bit/8+1] |= byte(value >> (8 - bit%8))
+		}
+	}
+	return string(data)
+}
+
+// decodeRDBHyperLogLog decodes a HyperLogLog of Redis, returning false if
+// the string isn't one.
+func decodeRDBHyperLogLog(str string) (*HyperLogLog, bool) {
+	if len(str) < RDB_HYPERLOGLOG_HEADER_SIZE || !strings.HasPrefix(str, RDB_HYPERLOGLOG_MAGIC) {
+		return nil, false
+	}
+	hyperLogLog := NewHyperLogLog()
+	registers := []byte(str[RDB_HYPERLOGLOG_HEADER_SIZE:])
+	switch str[len(RDB_HYPERLOGLOG_MAGIC)] {
+	case RDB_HYPERLOGLOG_DENSE:
+		if len(str) != RDB_HYPERLOGLOG_DENSE_SIZE {
+			return nil, false
+		}
+		for index := range HYPERLOGLOG_REGISTERS {
+			bit := index * RDB_HYPERLOGLOG_REGISTER_BITS
+			value := uint16(registers[bit/8]) >> (bit % 8)
+			if bit%8 > 8-RDB_HYPERLOGLOG_REGISTER_BITS {
+				value |= uint16(registers[bit/8+1]) << (8 - bit%8)
+			}
+			if !raiseRDBHyperLogLogRegisters(hyperLogLog, index, 1, uint8(value&RDB_HYPERLOGLOG_REGISTER_MAX)) {
+				return nil, false
+			}
+		}
+	case RDB_HYPERLOGLOG_SPARSE:
+		index := 0
+		for i := 0; i < len(registers); i++ {
+			opcode := registers[i]
+			switch {
+			case opcode&0xC0 == RDB_HYPERLOGLOG_SPARSE_ZERO:
+				index += int(opcode&0x3F) + 1
+			case opcode&0xC0 == RDB_HYPERLOGLOG_SPARSE_XZERO:
+				if i+1 == len(registers) {
+					return nil, false
+				}
+				i++
+				index += int(opcode&0x3F)<<8 | int(registers[i]) + 1
+			default:
+				run := int(opcode&0x03) + 1
+				if !raiseRDBHyperLogLogRegisters(hyperLogLog, index, run, (opcode>>2)&0x1F+1) {
+					return nil, false
+				}
+				index += run
+			}
+		}
+		if index != HYPERLOGLOG_REGISTERS {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return hyperLogLog, true
+}
+
+// raiseRDBHyperLogLogRegisters sets run registers from index to value,
+// failing if they don't exist or if the value can't come from a hash.
+func raiseRDBHyperLogLogRegisters(hyperLogLog *HyperLogLog, index int, run int, value uint8) bool {
+	if index+run > HYPERLOGLOG_REGISTERS || value > HYPERLOGLOG_Q+1 {
+		return false
+	}
+	for i := index; i < index+run; i++ {
+		if value > 0 {
+			hyperLogLog.raiseRegister(uint16(i), value)
+		}
+	}
+	return true
+}
+
+// decodeRDB decodes the databases of an RDB file. The function libraries,
+// which xredis has no use for, are skipped.
+func decodeRDB(data []byte) ([]map[string]XRedisValue, error) {
+	headerSize := len(RDB_MAGIC) + 4
+	if len(data) < headerSize+1 {
+		return nil, ERROR_CORRUPTED_SNAPSHOT
+	}
+	version, err := strconv.Atoi(string(data[len(RDB_MAGIC):headerSize]))
+	if err != nil || version < 1 {
+		return nil, ERROR_CORRUPTED_SNAPSHOT
+	}
+	if version > RDB_MAX_VERSION {
+		return nil, fmt.Errorf("%w RDB version %d", ERROR_UNSUPPORTED_DUMP, version)
+	}
+	end := len(data)
+	if version >= RDB_CHECKSUM_VERSION {
+		end -= RDB_CHECKSUM_SIZE
+		if end < headerSize+1 {
+			return nil, ERROR_CORRUPTED_SNAPSHOT
+		}
+		// A checksum of 0 tells that it wasn't computed
+		checksum := binary.LittleEndian.Uint64(data[end:])
+		if checksum != 0 && checksum != rdbChecksum(data[:end]) {
+			return nil, fmt.Errorf("%w: checksum mismatch", ERROR_CORRUPTED_SNAPSHOT)
+		}
+	}
+
+	reader := &rdbReader{snapshotReader{data: data[headerSize:end]}}
+	databases := []map[string]XRedisValue{make(map[string]XRedisValue)}
+	db := 0
+	expiration := int64(NON_EXPIRATION_TIME)
+	functionLibraries := 0
+	for reader.err == nil {
+		opcode := reader.byte()
+		if opcode == RDB_OPCODE_EOF {
+			break
+		}
+		switch opcode {
+		case RDB_OPCODE_SELECTDB:
+			db = reader.length()
+			for len(databases) <= db && reader.err == nil {
+				databases = append(databases, make(map[string]XRedisValue))
+			}
+		case RDB_OPCODE_RESIZEDB:
+			reader.rawLength()
+			reader.rawLength()
+		case RDB_OPCODE_AUX:
+			reader.string()
+			reader.string()
+		case RDB_OPCODE_EXPIRETIME_MS:
+			if milliseconds := reader.bytes(8); milliseconds != nil {
+				expiration = int64(binary.LittleEndian.Uint64(milliseconds))
+			}
+		case RDB_OPCODE_EXPIRETIME:
+			if seconds := reader.bytes(4); seconds != nil {
+				expiration = int64(int32(binary.LittleEndian.Uint32(seconds))) * 1000
+			}
+		case RDB_OPCODE_IDLE:
+			reader.rawLength()
+		case RDB_OPCODE_FREQ:
+			reader.byte()
+		case RDB_OPCODE_SLOT_INFO:
+			reader.rawLength()
+			reader.rawLength()
+			reader.rawLength()
+		case RDB_OPCODE_FUNCTION2:
+			reader.string()
+			functionLibraries++
+		case RDB_OPCODE_FUNCTION_PRE_GA, RDB_OPCODE_MODULE_AUX:
+			reader.unsupported("opcode", opcode)
+		default:
+			key := reader.string()
+			element := reader.element(opcode)
+			if reader.err == nil {
+				databases[db][key] = XRedisValue{element, expiration}
+			}
+			expiration = NON_EXPIRATION_TIME
+		}
+	}
+	if reader.err == nil && len(reader.data) > 0 {
+		reader.fail()
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	if functionLibraries > 0 {
+		log.Printf("Skipped %d function library(s) of the RDB file, which xredis doesn't support", functionLibraries)
+	}
+	return databases, nil
+}
+
+// rdbReader decodes the fields of an RDB file, failing like snapshotReader
+type rdbReader struct {
+	snapshotReader
+}
+
+func (reader *rdbReader) unsupported(kind string, value byte) {
+	if reader.err == nil {
+		reader.err = fmt.Errorf("%w RDB %s %d", ERROR_UNSUPPORTED_DUMP, kind, value)
+	}
+	reader.data = nil
+}
+
+// rawLength decodes a length, returning whether it is instead the special
+// encoding of a string.
+func (reader *rdbReader) rawLength() (uint64, bool) {
+	first := reader.byte()
+	switch first >> 6 {
+	case RDB_LENGTH_6BIT:
+		return uint64(first & 0x3F), false
+	case RDB_LENGTH_14BIT:
+		return uint64(first&0x3F)<<8 | uint64(reader.byte()), false
+	case RDB_LENGTH_ENCODED:
+		return uint64(first & 0x3F), true
+	}
+	switch first {
+	case RDB_LENGTH_32BIT:
+		if length := reader.bytes(4); length != nil {
+			return uint64(binary.BigEndian.Uint32(length)), false
+		}
+	case RDB_LENGTH_64BIT:
+		if length := reader.bytes(8); length != nil {
+			return binary.BigEndian.Uint64(length), false
+		}
+	default:
+		reader.fail()
+	}
+	return 0, false
+}
+
+// length decodes a number of elements or bytes, which can't exceed the
+// number of bytes left since every element takes at least one.
+func (reader *rdbReader) length() int {
+	length, encoded := reader.rawLength()
+	if encoded || length > uint64(len(reader.data)) {
+		reader.fail()
+		return 0
+	}
+	return int(length)
+}
+
+func (reader *rdbReader) string() string {
+	length, encoded := reader.rawLength()
+	if !encoded {
+		if length > uint64(len(reader.data)) {
+			reader.fail()
+			return ""
+		}
+		return string(reader.bytes(int(length)))
+	}
+
+	switch length {
+	case RDB_ENCODING_INT8:
+		return strconv.Itoa(int(int8(reader.byte())))
+	case RDB_ENCODING_INT16:
+		if value := reader.bytes(2); value != nil {
+			return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(value))))
+		}
+	case RDB_ENCODING_INT32:
+		if value := reader.bytes(4); value != nil {
+			return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(value))))
+		}
+	case RDB_ENCODING_LZF:
+		compressedLength := reader.length()
+		decompressedLength, encoded := reader.rawLength()
+		compressed := reader.bytes(compressedLength)
+		if reader.err != nil || encoded || decompressedLength > math.MaxInt32 {
+			reader.fail()
+			return ""
+		}
+		decompressed, err := lzfDecompress(compressed, int(decompressedLength))
+		if err != nil {
+			reader.fail()
+			return ""
+		}
+		return string(decompressed)
+	default:
+		reader.fail()
+	}
+	return ""
+}
+
+// score decodes a score of the first sorted set encoding, written as a
+// decimal prefixed by its length
+func (reader *rdbReader) score() float64 {
+	switch length := reader.byte(); length {
+	case RDB_ZSET_SCORE_NAN:
+		return math.NaN()
+	case RDB_ZSET_SCORE_POSITIVE_INFINITY:
+		return math.Inf(1)
+	case RDB_ZSET_SCORE_NEGATIVE_INFINITY:
+		return math.Inf(-1)
+	default:
+		score, err := strconv.ParseFloat(string(reader.bytes(int(length))), 64)
+		if err != nil {
+			reader.fail()
+		}
+		return score
+	}
+}
+
+// element decodes a value of the type given, failing on the types that
+// xredis doesn't support.
+func (reader *rdbReader) element(valueType byte) RespDataType {
+	switch valueType {
+	case RDB_TYPE_STRING:
+		str := reader.string()
+		if hyperLogLog, ok := decodeRDBHyperLogLog(str); ok {
+			return hyperLogLog
+		}
+		return RespString{str}
+	case RDB_TYPE_LIST:
+		elements := make([]RespDataType, reader.length())
+		for i := range elements {
+			elements[i] = RespString{reader.string()}
+		}
+		return RespArray{elements}
+	case RDB_TYPE_LIST_ZIPLIST:
+		return reader.list(reader.ziplist())
+	case RDB_TYPE_LIST_QUICKLIST:
+		var elements []string
+		for range reader.length() {
+			elements = append(elements, reader.ziplist()...)
+		}
+		return reader.list(elements)
+	case RDB_TYPE_LIST_QUICKLIST_2:
+		var elements []string
+		for range reader.length() {
+			container, _ := reader.rawLength()
+			switch container {
+			case RDB_QUICKLIST_NODE_PLAIN:
+				elements = append(elements, reader.string())
+			case RDB_QUICKLIST_NODE_PACKED:
+				elements = append(elements, reader.listpack()...)
+			default:
+				reader.fail()
+			}
+		}
+		return reader.list(elements)
+	case RDB_TYPE_ZSET, RDB_TYPE_ZSET_2:
+		sortedSet := NewSortedSet()
+		for range reader.length() {
+			member := reader.string()
+			if valueType == RDB_TYPE_ZSET {
+				sortedSet.add(member, reader.score())
+			} else if score := reader.bytes(8); score != nil {
+				sortedSet.add(member, math.Float64frombits(binary.LittleEndian.Uint64(score)))
+			}
+		}
+		return sortedSet
+	case RDB_TYPE_ZSET_ZIPLIST, RDB_TYPE_ZSET_LISTPACK:
+		var entries []string
+		if valueType == RDB_TYPE_ZSET_ZIPLIST {
+			entries = reader.ziplist()
+		} else {
+			entries = reader.listpack()
+		}
+		if len(entries)%2 != 0 {
+			reader.fail()
+		}
+		sortedSet := NewSortedSet()
+		for pair := range slices.Chunk(entries, 2) {
+			score, err := strconv.ParseFloat(pair[len(pair)-1], 64)
+			if err != nil {
+				reader.fail()
+			}
+			sortedSet.add(pair[0], score)
+		}
+		return sortedSet
+	case RDB_TYPE_SET:
+		members := make([]string, reader.length())
+		for i := range members {
+			members[i] = reader.string()
+		}
+		return reader.set(members)
+	case RDB_TYPE_SET_INTSET:
+		return reader.set(reader.intset())
+	case RDB_TYPE_SET_LISTPACK:
+		return reader.set(reader.listpack())
+	case RDB_TYPE_HASH:
+		entries := make([]string, 2*reader.length())
+		for i := range entries {
+			entries[i] = reader.string()
+		}
+		return reader.hash(entries)
+	case RDB_TYPE_HASH_ZIPMAP:
+		return reader.hash(reader.zipmap())
+	case RDB_TYPE_HASH_ZIPLIST:
+		return reader.hash(reader.ziplist())
+	case RDB_TYPE_HASH_LISTPACK:
+		return reader.hash(reader.listpack())
+	case RDB_TYPE_STREAM_LISTPACKS, RDB_TYPE_STREAM_LISTPACKS_2, RDB_TYPE_STREAM_LISTPACKS_3:
+		return reader.stream(valueType)
+	default:
+		reader.unsupported("value type", valueType)
+		return nil
+	}
+}
+
+func (reader *rdbReader) list(elements []string) RespArray {
+	list := make([]RespDataType, len(elements))
+	for i, element := range elements {
+		list[i] = RespString{element}
+	}
+	return RespArray{list}
+}
+
+// set builds a set of the members, failing if there are none since Redis
+// deletes the empty sets
+func (reader *rdbReader) set(members []string) *Set {
+	if len(members) == 0 {
+		reader.fail()
+	}
+	set := NewSet()
+	for _, member := range members {
+		set.add(member)
+	}
+	return set
+}
+
+// hash builds a hash of the field and value pairs, failing if there are
+// none since Redis deletes the empty hashes
+func (reader *rdbReader) hash(entries []string) *Hash {
+	if len(entries) == 0 || len(entries)%2 != 0 {
+		reader.fail()
+	}
+	hash := NewHash()
+	for pair := range slices.Chunk(entries, 2) {
+		hash.set(pair[0], pair[len(pair)-1])
+	}
+	return hash
+}
+
+// intset decodes the integers of an intset, the encoding of the small sets
+// of integers, stored in 2, 4 or 8 bytes each after the size of the
+// integers and their number.
+func (reader *rdbReader) intset() []string {
+	intset := &snapshotReader{data: []byte(reader.string())}
+	header := intset.bytes(RDB_INTSET_HEADER_SIZE)
+	if header == nil {
+		reader.fail()
+		return nil
+	}
+	size := binary.LittleEndian.Uint32(header)
+	count := binary.LittleEndian.Uint32(header[4:])
+	if (size != 2 && size != 4 && size != 8) || uint64(size)*uint64(count) != uint64(len(intset.data)) {
+		reader.fail()
+		return nil
+	}
+	members := make([]string, count)
+	for i := range members {
+		members[i] = intset.integer(int(size))
+	}
+	return members
+}
+
+// zipmap decodes the fields and values of a zipmap, the encoding of the
+// small hashes before Redis 2.6:
+//
+//	<zmlen:uint8> <len> field <len> <free:uint8> value <free bytes> ... 0xFF
+//
+// The lengths take a byte below 254, or 254 followed by a uint32.
+func (reader *rdbReader) zipmap() []string {
+	zipmap := &snapshotReader{data: []byte(reader.string())}
+	zipmap.byte() // Number of fields, if below 254
+	length := func() int {
+		length := zipmap.byte()
+		if length < RDB_ZIPMAP_BIGLEN {
+			return int(length)
+		}
+		if length == RDB_ZIPMAP_BIGLEN {
+			if bigLength := zipmap.bytes(4); bigLength != nil {
+				return int(binary.LittleEndian.Uint32(bigLength))
+			}
+		}
+		zipmap.fail()
+		return 0
+	}
+	entries := make([]string, 0)
+	for zipmap.err == nil {
+		if len(zipmap.data) > 0 && zipmap.data[0] == RDB_ZIPMAP_END {
+			break
+		}
+		entries = append(entries, string(zipmap.bytes(length())))
+		valueLength := length()
+		free := zipmap.byte()
+		entries = append(entries, string(zipmap.bytes(valueLength)))
+		zipmap.bytes(int(free))
+	}
+	if zipmap.err != nil {
+		reader.fail()
+	}
+	return entries
+}
+
+// stream decodes a stream saved as listpacks of entries, followed by its
+// counters and its consumer groups. Those missing from the older stream
+// encodings are derived as Redis does.
+func (reader *rdbReader) stream(valueType byte) *Stream {
+	stream := NewStream()
+	for range reader.length() {
+		master, ok := decodeRDBStreamID([]byte(reader.string()))
+		entries, valid := decodeRDBStreamNode(master, reader.listpack())
+		if !ok || !valid {
+			reader.fail()
+			return nil
+		}
+		stream.Entries = append(stream.Entries, entries...)
+	}
+	for i := 1; i < len(stream.Entries); i++ {
+		if stream.Entries[i-1].ID.compare(stream.Entries[i].ID) >= 0 {
+			reader.fail()
+			return nil
+		}
+	}
+	length, _ := reader.rawLength()
+	stream.LastID = reader.streamID()
+	stream.EntriesAdded = length
+	if valueType != RDB_TYPE_STREAM_LISTPACKS {
+		reader.streamID() // First ID
+		stream.MaxDeletedID = reader.streamID()
+		stream.EntriesAdded, _ = reader.rawLength()
+	}
+
+	groups := reader.length()
+	if groups > 0 {
+		stream.Groups = make(map[string]*ConsumerGroup, groups)
+	}
+	for range groups {
+		name := reader.string()
+		group := &ConsumerGroup{reader.streamID(), make(map[StreamID]*PendingEntry), make(map[string]*Consumer)}
+		if valueType != RDB_TYPE_STREAM_LISTPACKS {
+			reader.rawLength() // Entries read, which xredis computes
+		}
+		for range reader.length() {
+			id, _ := decodeRDBStreamID(reader.bytes(RDB_STREAM_ID_SIZE))
+			deliveryTime := reader.bytes(8)
+			deliveryCount, _ := reader.rawLength()
+			if deliveryTime != nil {
+				group.Pending[id] = &PendingEntry{"", int64(binary.LittleEndian.Uint64(deliveryTime)), int64(deliveryCount)}
+			}
+		}
+		for range reader.length() {
+			consumerName := reader.string()
+			seenTime := reader.bytes(8)
+			activeTime := seenTime
+			if valueType == RDB_TYPE_STREAM_LISTPACKS_3 {
+				activeTime = reader.bytes(8)
+			}
+			if seenTime == nil || activeTime == nil {
+				break
+			}
+			consumer := &Consumer{int64(binary.LittleEndian.Uint64(seenTime)), int64(binary.LittleEndian.Uint64(activeTime)), make(map[StreamID]bool)}
+			// The entries pending for the consumer are those of the group
+			for range reader.length() {
+				id, _ := decodeRDBStreamID(reader.bytes(RDB_STREAM_ID_SIZE))
+				pendingEntry, ok := group.Pending[id]
+				if !ok || pendingEntry.Consumer != "" {
+					reader.fail()
+					break
+				}
+				pendingEntry.Consumer = consumerName
+				consumer.Pending[id] = true
+			}
+			group.Consumers[consumerName] = consumer
+		}
+		for _, pendingEntry := range group.Pending {
+			if pendingEntry.Consumer == "" {
+				reader.fail()
+				break
+			}
+		}
+		stream.Groups[name] = group
+	}
+	return stream
+}
+
+// streamID decodes an ID written as two lengths
+func (reader *rdbReader) streamID() StreamID {
+	ms, _ := reader.rawLength()
+	seq, _ := reader.rawLength()
+	return StreamID{ms, seq}
+}
+
+// decodeRDBStreamID decodes an ID appended by appendRDBStreamID
+func decodeRDBStreamID(data []byte) (StreamID, bool) {
+	if len(data) != RDB_STREAM_ID_SIZE {
+		return StreamID{}, false
+	}
+	return StreamID{binary.BigEndian.Uint64(data), binary.BigEndian.Uint64(data[8:])}, true
+}
+
+// decodeRDBStreamNode decodes the entries of the listpack of a stream
+// node, as encoded by encodeRDBStreamNode, leaving out those flagged as
+// deleted. It returns false if the node is malformed.
+func decodeRDBStreamNode(master StreamID, listpack []string) ([]StreamEntry, bool) {
+	node := &rdbStreamNode{entries: listpack}
+	count := node.integer()
+	deleted := node.integer()
+	masterFields := make([]string, node.length())
+	for i := range masterFields {
+		masterFields[i] = node.next()
+	}
+	node.next() // End of the master entry
+
+	entries := make([]StreamEntry, 0)
+	for len(node.entries) > 0 && !node.failed {
+		flags := node.integer()
+		id := StreamID{master.Ms + uint64(node.integer()), master.Seq + uint64(node.integer())}
+		var fields []string
+		if flags&RDB_STREAM_ITEM_FLAG_SAMEFIELDS != 0 {
+			fields = make([]string, 0, 2*len(masterFields))
+			for _, field := range masterFields {
+				fields = append(fields, field, node.next())
+			}
+		} else {
+			fields = make([]string, 2*node.length())
+			for i := range fields {
+				fields[i] = node.next()
+			}
+		}
+		node.next() // Number of listpack entries of the entry
+		if flags&RDB_STREAM_ITEM_FLAG_DELETED != 0 {
+			deleted--
+		} else {
+			entries = append(entries, StreamEntry{id, fields})
+			count--
+		}
+	}
+	return entries, !node.failed && count == 0 && deleted == 0
+}
+
+// rdbStreamNode walks the listpack entries of a stream node
+type rdbStreamNode struct {
+	entries []string
+	failed  bool
+}
+
+func (node *rdbStreamNode) next() string {
+	if len(node.entries) == 0 {
+		node.failed = true
+		return ""
+	}
+	entry := node.entries[0]
+	node.entries = node.entries[1:]
+	return entry
+}
+
+func (node *rdbStreamNode) integer() int64 {
+	value, err := strconv.ParseInt(node.next(), 10, 64)
+	if err != nil {
+		node.failed = true
+	}
+	return value
+}
+
+// length decodes a number of fields, which can't exceed the number of
+// listpack entries left
+func (node *rdbStreamNode) length() int {
+	length := node.integer()
+	if length < 0 || length > int64(len(node.entries)) {
+		node.failed = true
+		return 0
+	}
+	return int(length)
+}
+
+// ziplist decodes the entries of a ziplist, the compact list of the RDB
+// files written before Redis 7. Each entry starts with the length of the
+// previous one, followed by the encoding of its string or integer:
+//
+//	00LLLLLL | 01LLLLLL LLLLLLLL | 10000000 <len:uint32 big endian>  strings
+//	11000000 int16 | 11010000 int32 | 11100000 int64 | 11110000 int24 | 11111110 int8
+//	1111VVVV  integer from 0 to 12, stored as VVVV-1
+func (reader *rdbReader) ziplist() []string {
+	ziplist := &snapshotReader{data: []byte(reader.string())}
+	ziplist.bytes(10) // Bytes, offset of the last entry and number of entries
+	entries := make([]string, 0)
+	for ziplist.err == nil {
+		if len(ziplist.data) > 0 && ziplist.data[0] == 0xFF {
+			break
+		}
+		if ziplist.byte() == 0xFE {
+			ziplist.bytes(4)
+		}
+		encoding := ziplist.byte()
+		switch {
+		case encoding>>6 == 0:
+			entries = append(entries, string(ziplist.bytes(int(encoding&0x3F))))
+		case encoding>>6 == 1:
+			entries = append(entries, string(ziplist.bytes(int(encoding&0x3F)<<8|int(ziplist.byte()))))
+		case encoding == 0x80:
+			if length := ziplist.bytes(4); length != nil {
+				entries = append(entries, string(ziplist.bytes(int(binary.BigEndian.Uint32(length)))))
+			}
+		case encoding == 0xC0:
+			entries = append(entries, ziplist.integer(2))
+		case encoding == 0xD0:
+			entries = append(entries, ziplist.integer(4))
+		case encoding == 0xE0:
+			entries = append(entries, ziplist.integer(8))
+		case encoding == 0xF0:
+			entries = append(entries, ziplist.integer(3))
+		case encoding == 0xFE:
+			entries = append(entries, ziplist.integer(1))
+		case encoding > 0xF0 && encoding < 0xFE:
+			entries = append(entries, strconv.Itoa(int(encoding&0x0F)-1))
+		default:
+			ziplist.fail()
+		}
+	}
+	if ziplist.err != nil {
+		reader.fail()
+	}
+	return entries
+}
+
+// listpack decodes the entries of a listpack, the compact list of the RDB
+// files written since Redis 7. Each entry starts with the encoding of its
+// string or integer and ends with its length:
+//
+//	0VVVVVVV  7 bit unsigned integer
+//	10LLLLLL | 1110LLLL LLLLLLLL | 11110000 <len:uint32>  strings
+//	110VVVVV VVVVVVVV  13 bit integer
+//	11110001 int16 | 11110010 int24 | 11110011 int32 | 11110100 int64
+func (reader *rdbReader) listpack() []string {
+	listpack := &snapshotReader{data: []byte(reader.string())}
+	listpack.bytes(6) // Bytes and number of entries
+	entries := make([]string, 0)
+	for listpack.err == nil {
+		encoding := listpack.byte()
+		if encoding == 0xFF {
+			break
+		}
+		left := len(listpack.data)
+		switch {
+		case encoding&0x80 == 0:
+			entries = append(entries, strconv.Itoa(int(encoding)))
+		case encoding&0xC0 == 0x80:
+			entries = append(entries, string(listpack.bytes(int(encoding&0x3F))))
+		case encoding&0xE0 == 0xC0:
+			value := int(encoding&0x1F)<<8 | int(listpack.byte())
+			entries = append(entries, strconv.Itoa(value-(value>>12)<<13))
+		case encoding&0xF0 == 0xE0:
+			entries = append(entries, string(listpack.bytes(int(encoding&0x0F)<<8|int(listpack.byte()))))
+		case encoding == 0xF0:
+			if length := listpack.bytes(4); length != nil {
+				entries = append(entries, string(listpack.bytes(int(binary.LittleEndian.Uint32(length)))))
+			}
+		case encoding == 0xF1:
+			entries = append(entries, listpack.integer(2))
+		case encoding == 0xF2:
+			entries = append(entries, listpack.integer(3))
+		case encoding == 0xF3:
+			entries = append(entries, listpack.integer(4))
+		case encoding == 0xF4:
+			entries = append(entries, listpack.integer(8))
+		default:
+			listpack.fail()
+		}
+		listpack.bytes(listpackBacklenSize(1 + left - len(listpack.data)))
+	}
+	if listpack.err != nil {
+		reader.fail()
+	}
+	return entries
+}
+
+// integer decodes a little endian signed integer of size bytes
+func (reader *snapshotReader) integer(size int) string {
+	value := reader.bytes(size)
+	if value == nil {
+		return ""
+	}
+	var padded [8]byte
+	copy(padded[8-size:], value)
+	return strconv.FormatInt(int64(binary.LittleEndian.Uint64(padded[:]))>>(64-8*size), 10)
+}
+
+// listpackBacklenSize returns the number of bytes taken by the length of
+// a listpack entry, stored after it to walk the listpack backwards.
+func listpackBacklenSize(entryLength int) int {
+	switch {
+	case entryLength <= 127:
+		return 1
+	case entryLength < 16383:
+		return 2
+	case entryLength < 2097151:
+		return 3
+	case entryLength < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
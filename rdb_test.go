@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRDBChecksum(t *testing.T) {
+	assert.Equal(t, uint64(0xe9c6d914c4b8d9ca), rdbChecksum([]byte("123456789")))
+}
+
+func TestRDBLengthAndStringEncodings(t *testing.T) {
+	assert.Equal(t, []byte{0x2A}, appendRDBLength(nil, 42))
+	assert.Equal(t, []byte{0x41, 0x2C}, appendRDBLength(nil, 300))
+	assert.Equal(t, []byte{0x80, 0x00, 0x01, 0x11, 0x70}, appendRDBLength(nil, 70000))
+
+	assert.Equal(t, []byte{0xC0, 0xF6}, appendRDBString(nil, "-10"))
+	assert.Equal(t, []byte{0xC1, 0xD2, 0x04}, appendRDBString(nil, "1234"))
+	assert.Equal(t, []byte{0xC2, 0xA0, 0x86, 0x01, 0x00}, appendRDBString(nil, "100000"))
+	assert.Equal(t, []byte("\x0501234"), appendRDBString(nil, "01234"))
+	assert.Equal(t, byte(0xC3), appendRDBString(nil, strings.Repeat("a", 40))[0])
+
+	for _, str := range []string{"", "0", "-0", "007", "4294967296", "value", strings.Repeat("compressible", 10)} {
+		reader := &rdbReader{snapshotReader{data: appendRDBString(nil, str)}}
+		assert.Equal(t, str, reader.string())
+		assert.Nil(t, reader.err)
+		assert.Empty(t, reader.data)
+	}
+}
+
+func TestRDBRoundTrip(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("string", RespString{"value"})
+	xredis.Set("counter", RespString{"42"})
+	xredis.Set("long", RespString{strings.Repeat("compressible", 10)})
+	xredis.SetWithExpiration("expiring", RespString{"value"}, time.Now().Add(time.Hour))
+	xredis.RPush("list", RespString{"a"})
+	xredis.RPush("list", RespString{"1"})
+	xredis.PFAdd("sparse", []string{"a", "b"})
+	elements := make([]string, 2000)
+	for i := range elements {
+		elements[i] = strconv.Itoa(i)
+	}
+	xredis.PFAdd("dense", elements)
+	xredis.HSet("hash", map[string]string{"field": "value", "counter": "7"})
+	xredis.SAdd("set", []string{"a", "1", strings.Repeat("long", 20)})
+	xredis.GeoAdd("geo", []GeoMember{{"Palermo", GeoPoint{13.361389, 38.115556}}, {"Catania", GeoPoint{15.087269, 37.502669}}}, GeoAddOptions{})
+	db3, _ := xredis.Select(3)
+	db3.Set("other", RespString{"database"})
+
+	data, err := encodeRDB(xredis.databases)
+	assert.Nil(t, err)
+	assert.Equal(t, "REDIS0009", string(data[:9]))
+
+	restored := NewXRedis()
+	assert.Nil(t, restored.Load(data))
+	assert.Equal(t, xredis.databases[DEFAULT_DATABASE], restored.databases[DEFAULT_DATABASE])
+	assert.Equal(t, xredis.databases[3], restored.databases[3])
+	assert.Empty(t, restored.databases[1])
+	count, _ := restored.PFCount([]string{"dense"})
+	expected, _ := xredis.PFCount([]string{"dense"})
+	assert.Equal(t, expected, count)
+}
+
+func TestRDBStreamRoundTrip(t *testing.T) {
+	xredis := NewXRedis()
+	// Enough entries for several nodes, whose fields differ from the
+	// master ones now and then
+	for i := range 2*RDB_STREAM_NODE_MAX_ENTRIES + 10 {
+		fields := []string{"n", strconv.Itoa(i), "big", "12345678901", "text", strings.Repeat("x", 100)}
+		if i%7 == 0 {
+			fields = []string{"other", "-3"}
+		}
+		xredis.XAdd("stream", "*", fields, false, nil)
+	}
+	xredis.XDel("stream", []StreamID{xredis.databases[0]["stream"].Element.(*Stream).Entries[3].ID})
+	xredis.XGroupCreate("stream", "workers", STREAM_MIN_ID, false, false)
+	xredis.XReadGroup("workers", "alice", []StreamRead{{"stream", StreamID{}, true}}, 5, false, false)
+	xredis.XGroupCreateConsumer("stream", "workers", "bob")
+	xredis.XGroupCreate("stream", "idle", STREAM_MIN_ID, true, false)
+
+	data, err := encodeRDB(xredis.databases)
+	assert.Nil(t, err)
+	restored := NewXRedis()
+	assert.Nil(t, restored.Load(data))
+
+	// Like Redis, the counters missing from the first stream encoding are
+	// derived, and the consumers were last active when last seen
+	expected := xredis.databases[0]["stream"].Element.(*Stream).clone()
+	expected.EntriesAdded = uint64(len(expected.Entries))
+	expected.MaxDeletedID = StreamID{}
+	for _, group := range expected.Groups {
+		for _, consumer := range group.Consumers {
+			consumer.ActiveTime = consumer.SeenTime
+		}
+	}
+	assert.Equal(t, expected, restored.databases[0]["stream"].Element)
+}
+
+func TestListpackWriter(t *testing.T) {
+	listpack := &listpackWriter{}
+	values := []string{"0", "127", "128", "-4096", "4095", "-32768", "8388607", "-2147483648", "9223372036854775807", "007", "", strings.Repeat("m", 100), strings.Repeat("l", 5000)}
+	for _, value := range values {
+		listpack.string(value)
+	}
+	reader := &rdbReader{snapshotReader{data: appendRDBString(nil, listpack.bytes())}}
+	assert.Equal(t, values, reader.listpack())
+	assert.Nil(t, reader.err)
+
+	// Lengths past 127 bytes are written from their most significant bits
+	listpack = &listpackWriter{}
+	listpack.append(make([]byte, 300))
+	assert.Equal(t, []byte{0x02, 0xAC}, listpack.data[300:])
+}
+
+// rdbListpack builds a listpack of strings shorter than 64 bytes and of
+// integers of up to 24 bits.
+func rdbListpack(entries ...any) string {
+	var body []byte
+	for _, entry := range entries {
+		var encoded []byte
+		switch entry := entry.(type) {
+		case string:
+			encoded = append([]byte{0x80 | byte(len(entry))}, entry...)
+		case int:
+			switch {
+			case entry >= 0 && entry < 128:
+				encoded = []byte{byte(entry)}
+			case entry >= -4096 && entry < 4096:
+				encoded = []byte{0xC0 | byte(entry>>8)&0x1F, byte(entry)}
+			default:
+				encoded = []byte{0xF2, byte(entry), byte(entry >> 8), byte(entry >> 16)}
+			}
+		}
+		body = append(append(body, encoded...), byte(len(encoded)))
+	}
+	header := binary.LittleEndian.AppendUint32(nil, uint32(6+len(body)+1))
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(entries)))
+	return string(append(append(header, body...), 0xFF))
+}
+
+// rdbZiplist builds a ziplist of strings shorter than 64 bytes and of
+// integers of up to 16 bits.
+func rdbZiplist(entries ...any) string {
+	var body []byte
+	previous := 0
+	for _, entry := range entries {
+		encoded := []byte{byte(previous)}
+		switch entry := entry.(type) {
+		case string:
+			encoded = append(append(encoded, byte(len(entry))), entry...)
+		case int:
+			if entry >= 0 && entry <= 12 {
+				encoded = append(encoded, 0xF1+byte(entry))
+			} else {
+				encoded = binary.LittleEndian.AppendUint16(append(encoded, 0xC0), uint16(entry))
+			}
+		}
+		body = append(body, encoded...)
+		previous = len(encoded)
+	}
+	header := binary.LittleEndian.AppendUint32(nil, uint32(10+len(body)+1))
+	header = binary.LittleEndian.AppendUint32(header, uint32(10+len(body)-previous))
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(entries)))
+	return string(append(append(header, body...), 0xFF))
+}
+
+// TestDecodeRDBOfRedis decodes a file laid out as Redis 7 writes them,
+// with the compact encodings of the small values.
+func TestDecodeRDBOfRedis(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UnixMilli()
+	data := []byte("REDIS0011")
+	data = appendRDBAux(data, "redis-ver", "7.2.4")
+	data = appendRDBAux(data, "aof-base", "0")
+	data = append(data, RDB_OPCODE_FUNCTION2)
+	data = appendRDBString(data, "#!lua name=lib\nredis.register_function('f', function() return 1 end)")
+	data = append(data, RDB_OPCODE_SELECTDB, 0, RDB_OPCODE_RESIZEDB, 12, 1)
+
+	data = appendRDBString(append(data, RDB_TYPE_STRING), "string")
+	data = appendRDBString(data, "hello")
+	data = binary.LittleEndian.AppendUint64(append(data, RDB_OPCODE_EXPIRETIME_MS), uint64(expiration))
+	data = appendRDBString(append(data, RDB_TYPE_STRING), "expiring")
+	data = appendRDBString(data, "soon")
+	data = append(data, RDB_OPCODE_IDLE, 10, RDB_OPCODE_FREQ, 5)
+	data = appendRDBString(append(data, RDB_TYPE_STRING), "counter")
+	data = append(data, 0xC1, 0xD2, 0x04)
+	data = appendRDBString(append(data, RDB_TYPE_STRING), "compressed")
+	data = append(data, 0xC3, 5, 40, 0x00, 'a', 0xE0, 30, 0x00)
+
+	data = appendRDBString(append(data, RDB_TYPE_LIST_QUICKLIST_2), "list")
+	data = append(data, 2, RDB_QUICKLIST_NODE_PACKED)
+	data = appendRDBString(data, rdbListpack("a", 7, -5, 100000))
+	data = appendRDBString(append(data, RDB_QUICKLIST_NODE_PLAIN), "plain")
+	data = appendRDBString(append(data, RDB_TYPE_LIST_QUICKLIST), "quicklist")
+	data = appendRDBString(append(data, 1), rdbZiplist("x", 3, 1000))
+	data = appendRDBString(append(data, RDB_TYPE_LIST_ZIPLIST), "ziplist")
+	data = appendRDBString(data, rdbZiplist("y"))
+
+	data = appendRDBString(append(data, RDB_TYPE_ZSET_LISTPACK), "zset")
+	data = appendRDBString(data, rdbListpack("m1", 1, "m2", "2.5"))
+	data = appendRDBString(append(data, RDB_TYPE_ZSET_ZIPLIST), "zset-ziplist")
+	data = appendRDBString(data, rdbZiplist("m", "-inf"))
+	data = appendRDBString(append(data, RDB_TYPE_ZSET), "zset-old")
+	data = appendRDBString(append(data, 2), "n")
+	data = appendRDBString(append(data, 3, '1', '.', '5'), "p")
+	data = append(data, RDB_ZSET_SCORE_POSITIVE_INFINITY)
+
+	data = appendRDBString(append(data, RDB_TYPE_HASH_LISTPACK), "hash")
+	data = appendRDBString(data, rdbListpack("field", "value"))
+	data = appendRDBString(append(data, RDB_TYPE_HASH), "hash-old")
+	data = appendRDBString(appendRDBString(append(data, 1), "field"), "value")
+	data = appendRDBString(append(data, RDB_TYPE_HASH_ZIPLIST), "hash-ziplist")
+	data = appendRDBString(data, rdbZiplist("k", "v", "n", 5))
+	data = appendRDBString(append(data, RDB_TYPE_HASH_ZIPMAP), "hash-zipmap")
+	data = appendRDBString(data, "\x02\x01a\x01\x001\x01b\x02\x0122x\xFF")
+	data = appendRDBString(append(data, RDB_TYPE_SET_INTSET), "set")
+	data = appendRDBString(data, "\x02\x00\x00\x00\x01\x00\x00\x00\x07\x00")
+	data = appendRDBString(append(data, RDB_TYPE_SET_LISTPACK), "set-listpack")
+	data = appendRDBString(data, rdbListpack("x", 12))
+	data = appendRDBString(append(data, RDB_TYPE_SET), "set-old")
+	data = appendRDBString(appendRDBString(append(data, 2), "p"), "q")
+
+	// A node of entries relative to 5-0: one with the master fields, one
+	// with fields of its own and one deleted
+	data = appendRDBString(append(data, RDB_TYPE_STREAM_LISTPACKS_3), "stream")
+	data = appendRDBString(append(data, 1), string(appendRDBStreamID(nil, StreamID{5, 0})))
+	data = appendRDBString(data, rdbListpack(2, 1, 1, "f", 0,
+		RDB_STREAM_ITEM_FLAG_SAMEFIELDS, 0, 0, "v1", 4,
+		0, 0, 1, 2, "f", "v2", "g", "w", 8,
+		RDB_STREAM_ITEM_FLAG_DELETED|RDB_STREAM_ITEM_FLAG_SAMEFIELDS, 1, 0, "v3", 4))
+	data = append(data, 2, 6, 0, 5, 0, 6, 0, 3)
+	data = appendRDBString(append(data, 1), "workers")
+	data = append(data, 5, 1, 2, 1)
+	data = appendRDBStreamID(data, StreamID{5, 0})
+	data = append(binary.LittleEndian.AppendUint64(data, 1000), 2, 1)
+	data = appendRDBString(data, "alice")
+	data = binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(data, 900), 800)
+	data = appendRDBStreamID(append(data, 1), StreamID{5, 0})
+
+	data = append(data, RDB_OPCODE_SELECTDB, 2, RDB_OPCODE_RESIZEDB, 1, 1)
+	data = binary.LittleEndian.AppendUint32(append(data, RDB_OPCODE_EXPIRETIME), uint32(expiration/1000))
+	data = appendRDBString(append(data, RDB_TYPE_STRING), "seconds")
+	data = appendRDBString(data, "value")
+	data = append(data, RDB_OPCODE_EOF)
+	data = binary.LittleEndian.AppendUint64(data, rdbChecksum(data))
+
+	databases, err := decodeSnapshot(data)
+	assert.Nil(t, err)
+	assert.Len(t, databases, 3)
+	sortedSet := func(members ...SortedSetMember) *SortedSet {
+		sortedSet := NewSortedSet()
+		for _, member := range members {
+			sortedSet.add(member.Member, member.Score)
+		}
+		return sortedSet
+	}
+	hash := func(entries ...string) *Hash {
+		hash := NewHash()
+		for i := 0; i < len(entries); i += 2 {
+			hash.set(entries[i], entries[i+1])
+		}
+		return hash
+	}
+	set := func(members ...string) *Set {
+		set := NewSet()
+		for _, member := range members {
+			set.add(member)
+		}
+		return set
+	}
+	list := func(elements ...string) RespArray {
+		list := RespArray{[]RespDataType{}}
+		for _, element := range elements {
+			list.Elements = append(list.Elements, RespString{element})
+		}
+		return list
+	}
+	assert.Equal(t, map[string]XRedisValue{
+		"string":       {RespString{"hello"}, NON_EXPIRATION_TIME},
+		"expiring":     {RespString{"soon"}, expiration},
+		"counter":      {RespString{"1234"}, NON_EXPIRATION_TIME},
+		"compressed":   {RespString{strings.Repeat("a", 40)}, NON_EXPIRATION_TIME},
+		"list":         {list("a", "7", "-5", "100000", "plain"), NON_EXPIRATION_TIME},
+		"quicklist":    {list("x", "3", "1000"), NON_EXPIRATION_TIME},
+		"ziplist":      {list("y"), NON_EXPIRATION_TIME},
+		"zset":         {sortedSet(SortedSetMember{"m1", 1}, SortedSetMember{"m2", 2.5}), NON_EXPIRATION_TIME},
+		"zset-ziplist": {sortedSet(SortedSetMember{"m", math.Inf(-1)}), NON_EXPIRATION_TIME},
+		"zset-old":     {sortedSet(SortedSetMember{"n", 1.5}, SortedSetMember{"p", math.Inf(1)}), NON_EXPIRATION_TIME},
+		"hash":         {hash("field", "value"), NON_EXPIRATION_TIME},
+		"hash-old":     {hash("field", "value"), NON_EXPIRATION_TIME},
+		"hash-ziplist": {hash("k", "v", "n", "5"), NON_EXPIRATION_TIME},
+		"hash-zipmap":  {hash("a", "1", "b", "22"), NON_EXPIRATION_TIME},
+		"set":          {set("7"), NON_EXPIRATION_TIME},
+		"set-listpack": {set("x", "12"), NON_EXPIRATION_TIME},
+		"set-old":      {set("p", "q"), NON_EXPIRATION_TIME},
+		"stream": {&Stream{
+			Entries:      []StreamEntry{{StreamID{5, 0}, []string{"f", "v1"}}, {StreamID{5, 1}, []string{"f", "v2", "g", "w"}}},
+			LastID:       StreamID{6, 0},
+			EntriesAdded: 3,
+			MaxDeletedID: StreamID{6, 0},
+			Groups: map[string]*ConsumerGroup{"workers": {
+				StreamID{5, 1},
+				map[StreamID]*PendingEntry{{5, 0}: {"alice", 1000, 2}},
+				map[string]*Consumer{"alice": {900, 800, map[StreamID]bool{{5, 0}: true}}},
+			}},
+		}, NON_EXPIRATION_TIME},
+	}, databases[0])
+	assert.Empty(t, databases[1])
+	assert.Equal(t, map[string]XRedisValue{"seconds": {RespString{"value"}, expiration / 1000 * 1000}}, databases[2])
+}
+
+func TestDecodeRDBHyperLogLogOfRedis(t *testing.T) {
+	// Registers 100 and 101 set to 3 among runs of zeros
+	sparse := "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" + "\x40\x63" + "\x89" + "\x7F\x99"
+	hyperLogLog, ok := decodeRDBHyperLogLog(sparse)
+	assert.True(t, ok)
+	assert.Equal(t, map[uint16]uint8{100: 3, 101: 3}, hyperLogLog.Sparse)
+
+	// Too few registers, or values that no hash gives, are plain strings
+	_, ok = decodeRDBHyperLogLog(sparse[:len(sparse)-2])
+	assert.False(t, ok)
+	dense := []byte(encodeRDBHyperLogLog(hyperLogLog))
+	dense[RDB_HYPERLOGLOG_HEADER_SIZE] = RDB_HYPERLOGLOG_REGISTER_MAX
+	_, ok = decodeRDBHyperLogLog(string(dense))
+	assert.False(t, ok)
+	_, ok = decodeRDBHyperLogLog("HYLL")
+	assert.False(t, ok)
+}
+
+func TestCorruptedRDB(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("key", RespString{"value"})
+	data, _ := encodeRDB(xredis.databases)
+
+	flipped := append([]byte{}, data...)
+	flipped[len(flipped)-RDB_CHECKSUM_SIZE-2]++
+	_, err := decodeSnapshot(flipped)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+
+	// Without a checksum, the truncation is still noticed
+	truncated := append(append([]byte{}, data[:len(data)-RDB_CHECKSUM_SIZE-3]...), make([]byte, RDB_CHECKSUM_SIZE)...)
+	_, err = decodeSnapshot(truncated)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+
+	_, err = decodeSnapshot([]byte("REDIS0099\xFF"))
+	assert.EqualError(t, err, "unsupported RDB version 99")
+
+	unsupported := append([]byte("REDIS0011"), RDB_OPCODE_SELECTDB, 0, 7)
+	unsupported = appendRDBString(unsupported, "module")
+	unsupported = append(unsupported, RDB_OPCODE_EOF)
+	unsupported = binary.LittleEndian.AppendUint64(unsupported, rdbChecksum(unsupported))
+	_, err = decodeSnapshot(unsupported)
+	assert.EqualError(t, err, "unsupported RDB value type 7")
+
+	// A field without a value
+	oddHash := appendRDBString(append([]byte("REDIS0011"), RDB_OPCODE_SELECTDB, 0, RDB_TYPE_HASH_LISTPACK), "hash")
+	oddHash = appendRDBString(oddHash, rdbListpack("field"))
+	oddHash = append(oddHash, RDB_OPCODE_EOF)
+	oddHash = binary.LittleEndian.AppendUint64(oddHash, rdbChecksum(oddHash))
+	_, err = decodeSnapshot(oddHash)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+}
+
+func TestSaveInRDBFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	xredis := NewXRedis()
+	xredis.SetDir(filepath.Dir(path))
+	xredis.ConfigSet(map[string]string{CONFIG_DBFILENAME: filepath.Base(path), CONFIG_DBFORMAT: "RDB"})
+	xredis.Set("key", RespString{"value"})
+
+	assert.Nil(t, xredis.Save())
+	data, _ := os.ReadFile(path)
+	assert.True(t, strings.HasPrefix(string(data), "REDIS0009"))
+	restored := NewXRedis()
+	assert.Nil(t, restored.Load(data))
+	assert.Equal(t, RespString{"value"}, restored.Get("key"))
+
+	xredis.XAdd("stream", "1-1", []string{"field", "value"}, false, nil)
+	xredis.HSet("hash", map[string]string{"field": "value"})
+	assert.Nil(t, xredis.Save())
+	data, _ = os.ReadFile(path)
+	restored = NewXRedis()
+	assert.Nil(t, restored.Load(data))
+	entries, _ := restored.XRange("stream", STREAM_MIN_ID, STREAM_MAX_ID, 0, false)
+	assert.Equal(t, []StreamEntry{{StreamID{1, 1}, []string{"field", "value"}}}, entries)
+	fields, _, _ := restored.ScanCollection("hash", TYPE_HASH, 0, "", 10)
+	assert.Equal(t, []RespDataType{RespString{"field"}, RespString{"value"}}, fields)
+	assert.Equal(t, REQUEST_ERROR_INVALID_CONFIG_VALUE, xredis.ConfigSet(map[string]string{CONFIG_DBFORMAT: "json"}).Error())
+}
@@ -2,13 +2,12 @@ package main
 
 import (
 	"errors"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func handleRequest(xredis *XRedis, data []byte) []byte {
+func handleRequest(client *Client, data []byte) []byte {
 	respData, _, err := deserializeRespDataType(data)
 	if err != nil {
 		return []byte(RespError{REQUEST_ERROR_FAILED_DESERIALIZATION}.serialize())
@@ -19,12 +18,37 @@ func handleRequest(xredis *XRedis, data []byte) []byte {
 	}
 
 	commandData, _ := respData.(RespArray) // Cast already previously validated
+	rsp := dispatchRequest(client, commandData)
+	if rsp == nil {
+		return nil
+	}
+	return []byte(rsp.serialize())
+}
+
+func dispatchRequest(client *Client, commandData RespArray) RespDataType {
 	command := strings.ToUpper(commandData.Elements[REQUEST_INDEX].(RespString).Str)
+	if !client.loading && !LOADING_REQUESTS[command] && client.xredis.Loading() {
+		return RespError{REQUEST_ERROR_LOADING}
+	}
+	if client.subscriptions > 0 && !isSubscribedModeRequest(command) {
+		return RespError{REQUEST_ERROR_NOT_ALLOWED_IN_SUBSCRIBED_MODE}
+	}
+	if client.transaction != nil && !isTransactionControlRequest(command) {
+		return queueTransactionRequest(client, command, commandData)
+	}
+	if WRITE_REQUESTS[command] && !client.executing && client.xredis.AppendOnlyWriteFailed() {
+		return RespError{REQUEST_ERROR_AOF_WRITE_FAILED}
+	}
+	// XREADGROUP logs itself, since it can't block inside a transaction
+	if WRITE_REQUESTS[command] && command != REQUEST_XREADGROUP && !client.executing && client.xredis.AppendOnlyEnabled() {
+		return dispatchAppendOnlyRequest(client, commandData)
+	}
+	xredis := client.xredis
 
 	var rsp RespDataType
 	switch command {
 	case REQUEST_PING:
-		rsp = handlePingRequest(commandData)
+		rsp = handlePingRequest(commandData, client)
 	case REQUEST_ECHO:
 		rsp = handleEchoRequest(commandData)
 	case REQUEST_SET:
@@ -45,16 +69,161 @@ func handleRequest(xredis *XRedis, data []byte) []byte {
 		rsp = handleRPushRequest(commandData, xredis)
 	case REQUEST_SAVE:
 		rsp = handleSaveRequest(commandData, xredis)
+	case REQUEST_OBJECT:
+		rsp = handleObjectRequest(commandData, xredis)
+	case REQUEST_KEYS:
+		rsp = handleKeysRequest(commandData, xredis)
+	case REQUEST_SCAN:
+		rsp = handleScanRequest(commandData, xredis)
+	case REQUEST_TYPE:
+		rsp = handleTypeRequest(commandData, xredis)
+	case REQUEST_RANDOMKEY:
+		rsp = handleRandomKeyRequest(commandData, xredis)
+	case REQUEST_DBSIZE:
+		rsp = handleDBSizeRequest(commandData, xredis)
+	case REQUEST_HSCAN:
+		rsp = handleCollectionScanRequest(commandData, xredis, TYPE_HASH)
+	case REQUEST_SSCAN:
+		rsp = handleCollectionScanRequest(commandData, xredis, TYPE_SET)
+	case REQUEST_ZSCAN:
+		rsp = handleCollectionScanRequest(commandData, xredis, TYPE_ZSET)
+	case REQUEST_RENAME:
+		rsp = handleRenameRequest(commandData, xredis)
+	case REQUEST_RENAMENX:
+		rsp = handleRenameNxRequest(commandData, xredis)
+	case REQUEST_COPY:
+		rsp = handleCopyRequest(commandData, xredis)
+	case REQUEST_MOVE:
+		rsp = handleMoveRequest(commandData, xredis)
+	case REQUEST_SELECT:
+		rsp = handleSelectRequest(commandData, client)
+	case REQUEST_FLUSHDB:
+		rsp = handleFlushDBRequest(commandData, xredis)
+	case REQUEST_FLUSHALL:
+		rsp = handleFlushAllRequest(commandData, xredis)
+	case REQUEST_SWAPDB:
+		rsp = handleSwapDBRequest(commandData, xredis)
+	case REQUEST_MULTI:
+		rsp = handleMultiRequest(commandData, client)
+	case REQUEST_EXEC:
+		rsp = handleExecRequest(commandData, client)
+	case REQUEST_DISCARD:
+		rsp = handleDiscardRequest(commandData, client)
+	case REQUEST_WATCH:
+		rsp = handleWatchRequest(commandData, client)
+	case REQUEST_UNWATCH:
+		rsp = handleUnwatchRequest(commandData, client)
+	case REQUEST_SUBSCRIBE:
+		rsp = handleSubscribeRequest(commandData, client, false)
+	case REQUEST_UNSUBSCRIBE:
+		rsp = handleUnsubscribeRequest(commandData, client, false)
+	case REQUEST_PSUBSCRIBE:
+		rsp = handleSubscribeRequest(commandData, client, true)
+	case REQUEST_PUNSUBSCRIBE:
+		rsp = handleUnsubscribeRequest(commandData, client, true)
+	case REQUEST_PUBLISH:
+		rsp = handlePublishRequest(commandData, xredis)
+	case REQUEST_SSUBSCRIBE:
+		rsp = handleShardSubscribeRequest(commandData, client)
+	case REQUEST_SUNSUBSCRIBE:
+		rsp = handleShardUnsubscribeRequest(commandData, client)
+	case REQUEST_SPUBLISH:
+		rsp = handleShardPublishRequest(commandData, xredis)
+	case REQUEST_PUBSUB:
+		rsp = handlePubSubRequest(commandData, xredis)
+	case REQUEST_CONFIG:
+		rsp = handleConfigRequest(commandData, xredis)
+	case REQUEST_XADD:
+		rsp = handleXAddRequest(commandData, xredis)
+	case REQUEST_XRANGE:
+		rsp = handleXRangeRequest(commandData, xredis, false)
+	case REQUEST_XREVRANGE:
+		rsp = handleXRangeRequest(commandData, xredis, true)
+	case REQUEST_XREAD:
+		rsp = handleXReadRequest(commandData, client)
+	case REQUEST_XLEN:
+		rsp = handleXLenRequest(commandData, xredis)
+	case REQUEST_XDEL:
+		rsp = handleXDelRequest(commandData, xredis)
+	case REQUEST_XTRIM:
+		rsp = handleXTrimRequest(commandData, xredis)
+	case REQUEST_XGROUP:
+		rsp = handleXGroupRequest(commandData, xredis)
+	case REQUEST_XREADGROUP:
+		rsp = handleXReadGroupRequest(commandData, client)
+	case REQUEST_XACK:
+		rsp = handleXAckRequest(commandData, xredis)
+	case REQUEST_XPENDING:
+		rsp = handleXPendingRequest(commandData, xredis)
+	case REQUEST_XCLAIM:
+		rsp = handleXClaimRequest(commandData, xredis)
+	case REQUEST_XAUTOCLAIM:
+		rsp = handleXAutoClaimRequest(commandData, xredis)
+	case REQUEST_XINFO:
+		rsp = handleXInfoRequest(commandData, xredis)
+	case REQUEST_SETBIT:
+		rsp = handleSetBitRequest(commandData, xredis)
+	case REQUEST_GETBIT:
+		rsp = handleGetBitRequest(commandData, xredis)
+	case REQUEST_BITCOUNT:
+		rsp = handleBitCountRequest(commandData, xredis)
+	case REQUEST_BITPOS:
+		rsp = handleBitPosRequest(commandData, xredis)
+	case REQUEST_BITOP:
+		rsp = handleBitOpRequest(commandData, xredis)
+	case REQUEST_BITFIELD:
+		rsp = handleBitFieldRequest(commandData, xredis, false)
+	case REQUEST_BITFIELD_RO:
+		rsp = handleBitFieldRequest(commandData, xredis, true)
+	case REQUEST_PFADD:
+		rsp = handlePFAddRequest(commandData, xredis)
+	case REQUEST_PFCOUNT:
+		rsp = handlePFCountRequest(commandData, xredis)
+	case REQUEST_PFMERGE:
+		rsp = handlePFMergeRequest(commandData, xredis)
+	case REQUEST_GEOADD:
+		rsp = handleGeoAddRequest(commandData, xredis)
+	case REQUEST_GEOPOS:
+		rsp = handleGeoPosRequest(commandData, xredis)
+	case REQUEST_GEODIST:
+		rsp = handleGeoDistRequest(commandData, xredis)
+	case REQUEST_GEOHASH:
+		rsp = handleGeoHashRequest(commandData, xredis)
+	case REQUEST_GEOSEARCH:
+		rsp = handleGeoSearchRequest(commandData, xredis)
+	case REQUEST_GEOSEARCHSTORE:
+		rsp = handleGeoSearchStoreRequest(commandData, xredis)
+	case REQUEST_DUMP:
+		rsp = handleDumpRequest(commandData, xredis)
+	case REQUEST_RESTORE:
+		rsp = handleRestoreRequest(commandData, xredis)
+	case REQUEST_BGREWRITEAOF:
+		rsp = handleBgRewriteAofRequest(commandData, xredis)
+	case REQUEST_BGSAVE:
+		rsp = handleBgSaveRequest(commandData, xredis)
+	case REQUEST_LASTSAVE:
+		rsp = handleLastSaveRequest(commandData, xredis)
+	case REQUEST_INFO:
+		rsp = handleInfoRequest(commandData, xredis)
 	default:
 		rsp = RespError{REQUEST_ERROR_INVALID_COMMAND}
 	}
-	return []byte(rsp.serialize())
+	if client.executing {
+		if err := logAppendOnlyRequest(client, command, commandData, rsp); err != nil {
+			return RespError{err.Error()}
+		}
+	}
+	return rsp
 }
 
-func handlePingRequest(requestData RespArray) RespDataType {
+func handlePingRequest(requestData RespArray, client *Client) RespDataType {
 	if len(requestData.Elements) != REQUEST_PING_EXPECTED_SIZE {
 		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
 	}
+	if client.subscriptions > 0 {
+		// Subscribed clients only expect arrays, as in Redis
+		return RespArray{[]RespDataType{RespString{strings.ToLower(REQUEST_PING_RSP)}, RespString{""}}}
+	}
 	return RespString{REQUEST_PING_RSP}
 }
 
@@ -168,22 +337,515 @@ func handleSaveRequest(requestData RespArray, xredis *XRedis) RespDataType {
 	if len(requestData.Elements) != REQUEST_SAVE_EXPECTED_SIZE {
 		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
 	}
-	data := xredis.Serialize()
+	if err := xredis.Save(); err != nil {
+		return RespError{err.Error()}
+	}
+	return RespString{REQUEST_RESULT_OK}
+}
+
+func handleObjectRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_OBJECT_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	subcommand := strings.ToUpper(requestData.Elements[REQUEST_OBJECT_SUBCOMMAND_INDEX].(RespString).Str)
+	if subcommand != OBJECT_SUBCOMMAND_ENCODING {
+		return RespError{REQUEST_ERROR_UNRECOGNIZED_SUBCOMMAND}
+	}
+	key := requestData.Elements[REQUEST_OBJECT_KEY_INDEX].(RespString).Str
+	encoding, exists := xredis.Encoding(key)
+	if !exists {
+		return RespNil{}
+	}
+	return RespString{encoding}
+}
+
+func handleKeysRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_KEYS_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	pattern := requestData.Elements[REQUEST_KEYS_PATTERN_INDEX].(RespString).Str
+	return stringsToRespArray(xredis.Keys(pattern))
+}
 
-	file, err := os.Create(DB_DUMP_FILE)
+func handleScanRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_SCAN_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	cursor, err := strconv.ParseUint(requestData.Elements[REQUEST_SCAN_CURSOR_INDEX].(RespString).Str, 10, 64)
+	if err != nil {
+		return RespError{REQUEST_ERROR_INVALID_CURSOR}
+	}
+	options, err := parseScanOptions(requestData.Elements[REQUEST_SCAN_OPTIONS_INDEX:], true)
 	if err != nil {
 		return RespError{err.Error()}
 	}
-	defer file.Close()
 
-	_, err = file.Write(data)
+	keys, nextCursor := xredis.Scan(cursor, options.pattern, options.count, options.typeName)
+	return scanReply(nextCursor, keys)
+}
+
+func handleTypeRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_TYPE_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_TYPE_KEY_INDEX].(RespString).Str
+	return RespString{xredis.Type(key)}
+}
+
+func handleRandomKeyRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_RANDOMKEY_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key, exists := xredis.RandomKey()
+	if !exists {
+		return RespNil{}
+	}
+	return RespString{key}
+}
+
+func handleDBSizeRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_DBSIZE_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	return RespInt{int64(xredis.DBSize())}
+}
+
+func handleCollectionScanRequest(requestData RespArray, xredis *XRedis, typeName string) RespDataType {
+	if len(requestData.Elements) < REQUEST_COLLECTION_SCAN_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_COLLECTION_SCAN_KEY_INDEX].(RespString).Str
+	cursor, err := strconv.ParseUint(requestData.Elements[REQUEST_COLLECTION_SCAN_CURSOR_INDEX].(RespString).Str, 10, 64)
+	if err != nil {
+		return RespError{REQUEST_ERROR_INVALID_CURSOR}
+	}
+	options, err := parseScanOptions(requestData.Elements[REQUEST_COLLECTION_SCAN_OPTIONS_INDEX:], false)
 	if err != nil {
 		return RespError{err.Error()}
 	}
 
+	entries, nextCursor, err := xredis.ScanCollection(key, typeName, cursor, options.pattern, options.count)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespArray{[]RespDataType{
+		RespString{strconv.FormatUint(nextCursor, 10)},
+		RespArray{entries},
+	}}
+}
+
+func handleRenameRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_RENAME_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	srcKey := requestData.Elements[REQUEST_RENAME_SRC_KEY_INDEX].(RespString).Str
+	dstKey := requestData.Elements[REQUEST_RENAME_DST_KEY_INDEX].(RespString).Str
+	_, err := xredis.Rename(srcKey, dstKey, false)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespString{REQUEST_RESULT_OK}
+}
+
+func handleRenameNxRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_RENAME_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	srcKey := requestData.Elements[REQUEST_RENAME_SRC_KEY_INDEX].(RespString).Str
+	dstKey := requestData.Elements[REQUEST_RENAME_DST_KEY_INDEX].(RespString).Str
+	renamed, err := xredis.Rename(srcKey, dstKey, true)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(bool2Int(renamed))}
+}
+
+func handleCopyRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_COPY_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	srcKey := requestData.Elements[REQUEST_COPY_SRC_KEY_INDEX].(RespString).Str
+	dstKey := requestData.Elements[REQUEST_COPY_DST_KEY_INDEX].(RespString).Str
+
+	dstDB := DEFAULT_DATABASE
+	replace := false
+	options := requestData.Elements[REQUEST_COPY_OPTIONS_INDEX:]
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(options[i].(RespString).Str) {
+		case COPY_OPTION_REPLACE:
+			replace = true
+		case COPY_OPTION_DB:
+			if i+1 >= len(options) {
+				return RespError{REQUEST_ERROR_SYNTAX}
+			}
+			i++
+			db, err := strconv.Atoi(options[i].(RespString).Str)
+			if err != nil {
+				return RespError{REQUEST_ERROR_INVALID_DB_INDEX}
+			}
+			dstDB = db
+		default:
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+	}
+
+	copied, err := xredis.Copy(srcKey, dstKey, dstDB, replace)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(bool2Int(copied))}
+}
+
+func handleMoveRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_MOVE_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_MOVE_KEY_INDEX].(RespString).Str
+	dstDB, err := strconv.Atoi(requestData.Elements[REQUEST_MOVE_DB_INDEX].(RespString).Str)
+	if err != nil {
+		return RespError{REQUEST_ERROR_INVALID_DB_INDEX}
+	}
+	moved, err := xredis.Move(key, dstDB)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(bool2Int(moved))}
+}
+
+func handleDumpRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_DUMP_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_DUMP_KEY_INDEX].(RespString).Str
+	payload, exists := xredis.Dump(key)
+	if !exists {
+		return RespNil{}
+	}
+	return RespString{string(payload)}
+}
+
+func handleRestoreRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_RESTORE_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_RESTORE_KEY_INDEX].(RespString).Str
+	ttl, err := strconv.ParseInt(requestData.Elements[REQUEST_RESTORE_TTL_INDEX].(RespString).Str, 10, 64)
+	if err != nil {
+		return RespError{REQUEST_ERROR_VALUE_NOT_AN_INTEGER}
+	}
+	if ttl < 0 {
+		return RespError{REQUEST_ERROR_INVALID_TTL}
+	}
+	payload := requestData.Elements[REQUEST_RESTORE_PAYLOAD_INDEX].(RespString).Str
+
+	replace := false
+	absoluteTTL := false
+	for _, option := range requestData.Elements[REQUEST_RESTORE_OPTIONS_INDEX:] {
+		switch strings.ToUpper(option.(RespString).Str) {
+		case RESTORE_OPTION_REPLACE:
+			replace = true
+		case RESTORE_OPTION_ABSTTL:
+			absoluteTTL = true
+		default:
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+	}
+
+	// As in Redis, a TTL of 0 means that the key doesn't expire
+	expirationTime := int64(NON_EXPIRATION_TIME)
+	if ttl > 0 && absoluteTTL {
+		expirationTime = ttl
+	} else if ttl > 0 {
+		expirationTime = time.Now().UnixMilli() + ttl
+	}
+	if err := xredis.Restore(key, []byte(payload), expirationTime, replace); err != nil {
+		return RespError{err.Error()}
+	}
 	return RespString{REQUEST_RESULT_OK}
 }
 
+func handleSelectRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) != REQUEST_SELECT_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	db, err := strconv.Atoi(requestData.Elements[REQUEST_SELECT_DB_INDEX].(RespString).Str)
+	if err != nil {
+		return RespError{REQUEST_ERROR_INVALID_DB_INDEX}
+	}
+	selected, err := client.xredis.Select(db)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	client.xredis = selected
+	return RespString{REQUEST_RESULT_OK}
+}
+
+func handleFlushDBRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	async, err := getFlushRequestMode(requestData)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	xredis.FlushDB(async)
+	return RespString{REQUEST_RESULT_OK}
+}
+
+func handleFlushAllRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	async, err := getFlushRequestMode(requestData)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	xredis.FlushAll(async)
+	return RespString{REQUEST_RESULT_OK}
+}
+
+func handleSwapDBRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_SWAPDB_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	db1, err1 := strconv.Atoi(requestData.Elements[REQUEST_SWAPDB_DB1_INDEX].(RespString).Str)
+	db2, err2 := strconv.Atoi(requestData.Elements[REQUEST_SWAPDB_DB2_INDEX].(RespString).Str)
+	if err1 != nil || err2 != nil {
+		return RespError{REQUEST_ERROR_INVALID_DB_INDEX}
+	}
+	if err := xredis.SwapDB(db1, db2); err != nil {
+		return RespError{err.Error()}
+	}
+	return RespString{REQUEST_RESULT_OK}
+}
+
+// getFlushRequestMode tells whether FLUSHDB or FLUSHALL were requested
+// with the ASYNC (true) or SYNC (false, the default) mode.
+func getFlushRequestMode(requestData RespArray) (bool, error) {
+	switch len(requestData.Elements) {
+	case REQUEST_FLUSH_MIN_SIZE:
+		return false, nil
+	case REQUEST_FLUSH_MAX_SIZE:
+		switch strings.ToUpper(requestData.Elements[REQUEST_FLUSH_MODE_INDEX].(RespString).Str) {
+		case FLUSH_MODE_ASYNC:
+			return true, nil
+		case FLUSH_MODE_SYNC:
+			return false, nil
+		default:
+			return false, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+	default:
+		return false, errors.New(REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER)
+	}
+}
+
+func handleConfigRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_CONFIG_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	subcommand := strings.ToUpper(requestData.Elements[REQUEST_CONFIG_SUBCOMMAND_INDEX].(RespString).Str)
+	arguments := requestArguments(requestData, REQUEST_CONFIG_ARGUMENTS_INDEX)
+
+	switch subcommand {
+	case CONFIG_SUBCOMMAND_GET:
+		if len(arguments) == 0 {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		// Parameters matched by several patterns are only replied once
+		values := make(map[string]string)
+		names := make([]string, 0)
+		for _, pattern := range arguments {
+			parameters := xredis.ConfigGet(pattern)
+			for i := 0; i < len(parameters); i += 2 {
+				if _, ok := values[parameters[i]]; !ok {
+					names = append(names, parameters[i])
+				}
+				values[parameters[i]] = parameters[i+1]
+			}
+		}
+		reply := make([]RespDataType, 0, 2*len(names))
+		for _, name := range names {
+			reply = append(reply, RespString{name}, RespString{values[name]})
+		}
+		return RespArray{reply}
+	case CONFIG_SUBCOMMAND_SET:
+		if len(arguments) == 0 || len(arguments)%2 != 0 {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		parameters := make(map[string]string)
+		for i := 0; i < len(arguments); i += 2 {
+			parameters[arguments[i]] = arguments[i+1]
+		}
+		if err := xredis.ConfigSet(parameters); err != nil {
+			return RespError{err.Error()}
+		}
+		return RespString{REQUEST_RESULT_OK}
+	default:
+		return RespError{REQUEST_ERROR_UNRECOGNIZED_SUBCOMMAND}
+	}
+}
+
+func handleMultiRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) != REQUEST_MULTI_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	if client.transaction != nil {
+		return RespError{REQUEST_ERROR_NESTED_MULTI}
+	}
+	client.transaction = &Transaction{}
+	return RespString{REQUEST_RESULT_OK}
+}
+
+func handleExecRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) != REQUEST_EXEC_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	transaction := client.transaction
+	if transaction == nil {
+		return RespError{REQUEST_ERROR_EXEC_WITHOUT_MULTI}
+	}
+	client.transaction = nil
+	if transaction.aborted {
+		client.unwatchAll()
+		return RespError{REQUEST_ERROR_EXEC_ABORTED}
+	}
+	if transaction.writes && client.xredis.AppendOnlyWriteFailed() {
+		client.unwatchAll()
+		return RespError{REQUEST_ERROR_AOF_WRITE_FAILED}
+	}
+
+	var replies []RespDataType
+	var logErr error
+	client.xredis.Atomically(func(tx *XRedis) {
+		watchedKeysChanged := tx.WatchedKeysChanged(client.watchedKeys)
+		tx.Unwatch(client.watchedKeys)
+		client.watchedKeys = nil
+		if watchedKeysChanged {
+			return
+		}
+		replies = make([]RespDataType, 0, len(transaction.requests))
+		txClient := *client
+		txClient.xredis = tx
+		txClient.executing = true
+		txClient.loggedRequests = &[]AppendOnlyLogEntry{}
+		for _, request := range transaction.requests {
+			replies = append(replies, dispatchRequest(&txClient, request))
+		}
+		// The requests are logged together, so that a crash never leaves
+		// part of the transaction in the append only file
+		if len(*txClient.loggedRequests) > 0 {
+			logErr = tx.AppendTransactionToLog(*txClient.loggedRequests)
+		}
+		// A SELECT inside the transaction must outlive it
+		if txClient.xredis.db != client.xredis.db {
+			client.xredis, _ = client.xredis.Select(txClient.xredis.db)
+		}
+	})
+	if logErr != nil {
+		return RespError{logErr.Error()}
+	}
+	if replies == nil {
+		return RespNil{}
+	}
+	return RespArray{replies}
+}
+
+func handleDiscardRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) != REQUEST_DISCARD_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	if client.transaction == nil {
+		return RespError{REQUEST_ERROR_DISCARD_WITHOUT_MULTI}
+	}
+	client.transaction = nil
+	client.unwatchAll()
+	return RespString{REQUEST_RESULT_OK}
+}
+
+func handleWatchRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) < REQUEST_WATCH_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	keys := requestArguments(requestData, REQUEST_WATCH_FIRST_KEY_INDEX)
+	client.watchedKeys = append(client.watchedKeys, client.xredis.Watch(keys)...)
+	return RespString{REQUEST_RESULT_OK}
+}
+
+func handleUnwatchRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) != REQUEST_UNWATCH_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	client.unwatchAll()
+	return RespString{REQUEST_RESULT_OK}
+}
+
+// queueTransactionRequest validates a request sent after MULTI and queues
+// it to be executed by EXEC. Invalid requests abort the transaction.
+func queueTransactionRequest(client *Client, command string, requestData RespArray) RespDataType {
+	// As in Redis, WATCH is refused but leaves the transaction open
+	if command == REQUEST_WATCH {
+		return RespError{REQUEST_ERROR_WATCH_INSIDE_MULTI}
+	}
+	if isSubscribeRequest(command) {
+		client.transaction.aborted = true
+		return RespError{REQUEST_ERROR_SUBSCRIBE_INSIDE_MULTI}
+	}
+	arity, exists := REQUEST_ARITIES[command]
+	if !exists {
+		client.transaction.aborted = true
+		return RespError{REQUEST_ERROR_INVALID_COMMAND}
+	}
+	argumentsNumber := len(requestData.Elements)
+	if (arity > 0 && argumentsNumber != arity) || (arity < 0 && argumentsNumber < -arity) {
+		client.transaction.aborted = true
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	client.transaction.requests = append(client.transaction.requests, requestData)
+	client.transaction.writes = client.transaction.writes || WRITE_REQUESTS[command]
+	return RespString{REQUEST_RESULT_QUEUED}
+}
+
+func isTransactionControlRequest(command string) bool {
+	return command == REQUEST_MULTI || command == REQUEST_EXEC || command == REQUEST_DISCARD
+}
+
+type scanOptions struct {
+	pattern  string
+	count    int
+	typeName string
+}
+
+// parseScanOptions parses the MATCH, COUNT and (when allowed) TYPE
+// options trailing the cursor of the SCAN family of requests.
+func parseScanOptions(args []RespDataType, allowType bool) (scanOptions, error) {
+	options := scanOptions{count: SCAN_DEFAULT_COUNT}
+	if len(args)%2 != 0 {
+		return options, errors.New(REQUEST_ERROR_SYNTAX)
+	}
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1].(RespString).Str
+		switch strings.ToUpper(args[i].(RespString).Str) {
+		case SCAN_OPTION_MATCH:
+			options.pattern = value
+		case SCAN_OPTION_COUNT:
+			count, err := strconv.Atoi(value)
+			if err != nil || count <= 0 {
+				return options, errors.New(REQUEST_ERROR_SYNTAX)
+			}
+			options.count = count
+		case SCAN_OPTION_TYPE:
+			if !allowType {
+				return options, errors.New(REQUEST_ERROR_SYNTAX)
+			}
+			options.typeName = strings.ToLower(value)
+		default:
+			return options, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+	}
+	return options, nil
+}
+
+func scanReply(cursor uint64, members []string) RespDataType {
+	return RespArray{[]RespDataType{
+		RespString{strconv.FormatUint(cursor, 10)},
+		stringsToRespArray(members),
+	}}
+}
+
 func getSetRequestExpirationTime(requestData RespArray) (time.Time, error) {
 	timeoutMode := requestData.Elements[REQUEST_SET_TIMEOUT_MODE_INDEX].(RespString).Str
 	timeoutValue, err := strconv.ParseInt(requestData.Elements[REQUEST_SET_TIMEOUT_INDEX].(RespString).Str, 10, 64)
@@ -218,6 +880,23 @@ func isValidRequest(command RespDataType) bool {
 	return true
 }
 
+// requestArguments returns the arguments of the request starting at index
+func requestArguments(requestData RespArray, index int) []string {
+	arguments := make([]string, 0, max(len(requestData.Elements)-index, 0))
+	for _, argument := range requestData.Elements[index:] {
+		arguments = append(arguments, argument.(RespString).Str)
+	}
+	return arguments
+}
+
+func stringsToRespArray(strs []string) RespArray {
+	elements := make([]RespDataType, 0, len(strs))
+	for _, str := range strs {
+		elements = append(elements, RespString{str})
+	}
+	return RespArray{elements}
+}
+
 func bool2Int(boolVal bool) int {
 	val := 0
 	if boolVal {
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Requests that may modify the databases, which are logged to the append
+// only file when they succeed
+var WRITE_REQUESTS = map[string]bool{
+	REQUEST_SET:            true,
+	REQUEST_DELETE:         true,
+	REQUEST_INCREMENT:      true,
+	REQUEST_DECREMENT:      true,
+	REQUEST_LPUSH:          true,
+	REQUEST_RPUSH:          true,
+	REQUEST_RENAME:         true,
+	REQUEST_RENAMENX:       true,
+	REQUEST_COPY:           true,
+	REQUEST_MOVE:           true,
+	REQUEST_FLUSHDB:        true,
+	REQUEST_FLUSHALL:       true,
+	REQUEST_SWAPDB:         true,
+	REQUEST_XADD:           true,
+	REQUEST_XDEL:           true,
+	REQUEST_XTRIM:          true,
+	REQUEST_XGROUP:         true,
+	REQUEST_XREADGROUP:     true,
+	REQUEST_XACK:           true,
+	REQUEST_XCLAIM:         true,
+	REQUEST_XAUTOCLAIM:     true,
+	REQUEST_SETBIT:         true,
+	REQUEST_BITOP:          true,
+	REQUEST_BITFIELD:       true,
+	REQUEST_PFADD:          true,
+	REQUEST_PFMERGE:        true,
+	REQUEST_GEOADD:         true,
+	REQUEST_GEOSEARCHSTORE: true,
+	REQUEST_RESTORE:        true,
+}
+
+// dispatchAppendOnlyRequest applies a write request and logs it as a
+// single unit, so that the append only file holds the writes of every
+// client in the order they were applied.
+func dispatchAppendOnlyRequest(client *Client, requestData RespArray) RespDataType {
+	var rsp RespDataType
+	client.xredis.Atomically(func(tx *XRedis) {
+		txClient := *client
+		txClient.xredis = tx
+		txClient.executing = true
+		rsp = dispatchRequest(&txClient, requestData)
+	})
+	return rsp
+}
+
+// logAppendOnlyRequest logs the write request once it succeeded. It must
+// run in the transaction that applied the request. The requests applied by
+// EXEC are only collected, to be logged together once all of them ran.
+func logAppendOnlyRequest(client *Client, command string, requestData RespArray, rsp RespDataType) error {
+	xredis := client.xredis
+	if _, failed := rsp.(RespError); failed || !WRITE_REQUESTS[command] || !xredis.AppendOnlyEnabled() {
+		return nil
+	}
+	requests := []RespArray{appendOnlyRequest(command, requestData, rsp)}
+	if command == REQUEST_XCLAIM || command == REQUEST_XAUTOCLAIM {
+		var err error
+		if requests, err = appendOnlyClaimRequests(xredis, command, requestData, rsp); err != nil {
+			return err
+		}
+	}
+	entries := make([]AppendOnlyLogEntry, 0, len(requests))
+	for _, request := range requests {
+		entries = append(entries, AppendOnlyLogEntry{xredis.db, request})
+	}
+	if client.loggedRequests != nil {
+		*client.loggedRequests = append(*client.loggedRequests, entries...)
+		return nil
+	}
+	if len(entries) == 1 {
+		return xredis.AppendToLog(entries[0].request)
+	}
+	return xredis.AppendTransactionToLog(entries)
+}
+
+// appendOnlyClaimRequests rewrites XCLAIM and XAUTOCLAIM, whose outcome
+// depends on the time they were applied, into what they did, as Redis
+// does: a forced XCLAIM of each claimed entry setting its delivery time and
+// count, and of each pending entry found deleted, which acknowledges it.
+// The consumer is created first, as the claim did, and the last delivered
+// ID set by XCLAIM LASTID is set last.
+func appendOnlyClaimRequests(xredis *XRedis, command string, requestData RespArray, rsp RespDataType) ([]RespArray, error) {
+	arguments := requestArguments(requestData, 0)
+	key, group, consumer := arguments[REQUEST_XCLAIM_KEY_INDEX], arguments[REQUEST_XCLAIM_GROUP_INDEX], arguments[REQUEST_XCLAIM_CONSUMER_INDEX]
+	reply := rsp.(RespArray).Elements
+	var claimed []StreamID
+	var ids []StreamID
+	lastID := false
+	if command == REQUEST_XCLAIM {
+		claimed = streamReplyIDs(reply)
+		// The IDs go on until the first option, as when applying it
+		options := arguments[REQUEST_XCLAIM_ARGUMENTS_INDEX:]
+		for len(options) > 0 {
+			id, err := parseStreamID(options[0], 0)
+			if err != nil {
+				break
+			}
+			ids = append(ids, id)
+			options = options[1:]
+		}
+		for _, option := range options {
+			lastID = lastID || strings.ToUpper(option) == STREAM_OPTION_LASTID
+		}
+	} else {
+		claimed = streamReplyIDs(reply[1].(RespArray).Elements)
+		ids = append(slices.Clone(claimed), streamReplyIDs(reply[2].(RespArray).Elements)...)
+	}
+	state, err := xredis.XClaimState(key, group, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := []RespArray{stringsToRespArray([]string{REQUEST_XGROUP, XGROUP_SUBCOMMAND_CREATECONSUMER, key, group, consumer})}
+	for _, id := range claimed {
+		pendingEntry := state.pending[id]
+		requests = append(requests, stringsToRespArray([]string{
+			REQUEST_XCLAIM, key, group, consumer, "0", id.String(),
+			STREAM_OPTION_TIME, strconv.FormatInt(pendingEntry.DeliveryTime, 10),
+			STREAM_OPTION_RETRYCOUNT, strconv.FormatInt(pendingEntry.DeliveryCount, 10),
+			STREAM_OPTION_FORCE, STREAM_OPTION_JUSTID,
+		}))
+	}
+	for _, id := range state.missing {
+		requests = append(requests, stringsToRespArray([]string{
+			REQUEST_XCLAIM, key, group, consumer, "0", id.String(), STREAM_OPTION_FORCE, STREAM_OPTION_JUSTID,
+		}))
+	}
+	if lastID {
+		requests = append(requests, stringsToRespArray([]string{REQUEST_XGROUP, XGROUP_SUBCOMMAND_SETID, key, group, state.lastDeliveredID.String()}))
+	}
+	return requests, nil
+}
+
+// streamReplyIDs returns the IDs of the entries of a reply, made of either
+// the entries or just their IDs.
+func streamReplyIDs(reply []RespDataType) []StreamID {
+	ids := make([]StreamID, 0, len(reply))
+	for _, element := range reply {
+		if entry, ok := element.(RespArray); ok {
+			element = entry.Elements[0]
+		}
+		// The IDs were formatted by the claim itself
+		id, _ := parseStreamID(element.(RespString).Str, 0)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// appendOnlyRequest rewrites the request so that replaying it has the same
+// effect it had when it was applied: relative expirations become absolute,
+// including the TTL of RESTORE, the IDs generated by XADD are made explicit,
+// and XREADGROUP no longer blocks.
+func appendOnlyRequest(command string, requestData RespArray, rsp RespDataType) RespArray {
+	arguments := requestArguments(requestData, 0)
+	switch command {
+	case REQUEST_SET:
+		if len(arguments) != REQUEST_SET_WITH_TIMEOUT_EXPECTED_SIZE {
+			break
+		}
+		mode := arguments[REQUEST_SET_TIMEOUT_MODE_INDEX]
+		if mode != EXPIRATION_MODE_EXPIRE_SECONDS && mode != EXPIRATION_MODE_EXPIRE_MILLISECONDS {
+			break
+		}
+		// The expiration time was already validated when applying the request
+		expirationTime, _ := getSetRequestExpirationTime(requestData)
+		arguments[REQUEST_SET_TIMEOUT_MODE_INDEX] = EXPIRATION_MODE_TIMESTAMP_MILLISECONDS
+		arguments[REQUEST_SET_TIMEOUT_INDEX] = strconv.FormatInt(expirationTime.UnixMilli(), 10)
+	case REQUEST_RESTORE:
+		// The TTL was already validated when applying the request
+		ttl, _ := strconv.ParseInt(arguments[REQUEST_RESTORE_TTL_INDEX], 10, 64)
+		absoluteTTL := slices.ContainsFunc(arguments[REQUEST_RESTORE_OPTIONS_INDEX:], func(option string) bool {
+			return strings.ToUpper(option) == RESTORE_OPTION_ABSTTL
+		})
+		if ttl == 0 || absoluteTTL {
+			break
+		}
+		arguments[REQUEST_RESTORE_TTL_INDEX] = strconv.FormatInt(time.Now().UnixMilli()+ttl, 10)
+		arguments = append(arguments, RESTORE_OPTION_ABSTTL)
+	case REQUEST_XADD:
+		id, added := rsp.(RespString)
+		if !added {
+			break
+		}
+		_, _, i, _ := parseXAddOptions(arguments[REQUEST_XADD_OPTIONS_INDEX:])
+		arguments[REQUEST_XADD_OPTIONS_INDEX+i] = id.Str
+	case REQUEST_XREADGROUP:
+		rewritten := slices.Clone(arguments[:REQUEST_XREADGROUP_OPTIONS_INDEX])
+		for i := REQUEST_XREADGROUP_OPTIONS_INDEX; i < len(arguments); i++ {
+			if strings.ToUpper(arguments[i]) == STREAM_OPTION_STREAMS {
+				rewritten = append(rewritten, arguments[i:]...)
+				break
+			}
+			if strings.ToUpper(arguments[i]) == STREAM_OPTION_BLOCK {
+				i++
+				continue
+			}
+			rewritten = append(rewritten, arguments[i])
+		}
+		arguments = rewritten
+	}
+	return stringsToRespArray(arguments)
+}
+
+func handleBgRewriteAofRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_BGREWRITEAOF_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	if err := xredis.RewriteAppendOnlyFile(); err != nil {
+		return RespError{err.Error()}
+	}
+	return RespString{REQUEST_RESULT_AOF_REWRITE_STARTED}
+}
+
+// replayAppendOnlyFile applies every request of the append only file,
+// the other requests being refused meanwhile. A request, a transaction or
+// a frame cut short at the end of the file, as left by a crash in the
+// middle of a write, is dropped from the file with a warning.
+func replayAppendOnlyFile(xredis *XRedis, path string) (err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decryption := xredis.fileDecryption()
+	client := NewClient(xredis)
+	client.loading = true
+	defer client.Close()
+	xredis.commands <- LoadingStartCommand{int64(len(raw))}
+	defer func() { xredis.finishLoading(nil, err) }()
+
+	// The requests of a file with transforms are only found in complete
+	// frames, so that they can't be cut short
+	data, size, err := unsealFile(raw, decryption)
+	if err != nil {
+		return fmt.Errorf("%s: %w", AOF_ERROR_CORRUPTED, err)
+	}
+	transformed := bytes.HasPrefix(raw, []byte(FILE_HEADER_MAGIC))
+
+	replayed, offset, reported := 0, 0, 0
+	transactionOffset := 0 // Offset of the MULTI of the transaction being replayed
+	for offset < len(data) {
+		request, length, err := deserializeRespDataType(data[offset:])
+		if errors.Is(err, ERROR_INCOMPLETE_RESP_DATA) && !transformed {
+			break
+		}
+		if err != nil || !isValidRequest(request) {
+			return fmt.Errorf("%s at byte %d", AOF_ERROR_CORRUPTED, offset)
+		}
+		if client.transaction == nil {
+			transactionOffset = offset
+		}
+		rsp := dispatchRequest(client, request.(RespArray))
+		if respError, failed := rsp.(RespError); failed {
+			log.Printf("Failed replaying request %d of the append only file: %s", replayed+1, respError.Str)
+		}
+		offset += length
+		replayed++
+		if offset-reported >= LOADING_PROGRESS_CHUNK_SIZE {
+			xredis.commands <- LoadingProgressCommand{int64(offset) * int64(size) / int64(len(data))}
+			reported = offset
+		}
+	}
+	xredis.commands <- LoadingProgressCommand{int64(size)}
+	log.Printf("Replayed %d requests from the append only file", replayed)
+	// The requests of a transaction are written along with their MULTI and
+	// EXEC, within a single frame for the files with transforms
+	if client.transaction != nil && transformed {
+		return fmt.Errorf("%s: transaction without EXEC at byte %d", AOF_ERROR_CORRUPTED, transactionOffset)
+	}
+	if client.transaction != nil {
+		offset = transactionOffset
+	}
+	if offset < len(data) {
+		log.Printf("Append only file truncated at byte %d, dropping its last %d bytes", offset, len(data)-offset)
+		return os.Truncate(path, int64(offset))
+	}
+	if size < len(raw) {
+		log.Printf("Append only file truncated at byte %d, dropping its last %d bytes", size, len(raw)-size)
+		return os.Truncate(path, int64(size))
+	}
+	return nil
+}
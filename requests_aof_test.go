@@ -0,0 +1,273 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRequestsAreLoggedAndReplayed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	xredis.OpenAppendOnlyFile(path)
+	client := NewClient(xredis)
+
+	_ = handleRequest(client, []byte("*5\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n$2\r\nEX\r\n$3\r\n100\r\n"))
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	xaddRsp := handleRequest(client, []byte("*5\r\n$4\r\nXADD\r\n$6\r\nstream\r\n$1\r\n*\r\n$5\r\nfield\r\n$5\r\nvalue\r\n"))
+	_ = handleRequest(client, []byte("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"))
+	failedRsp := handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$3\r\nkey\r\n"))
+	assert.Equal(t, "-ERR VALUE-NOT-NUMERIC-OR-MAX-REACHED\r\n", string(failedRsp))
+	_ = handleRequest(client, []byte("*2\r\n$6\r\nSELECT\r\n$1\r\n1\r\n"))
+	_ = handleRequest(client, []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nother\r\n"))
+	xredis.CloseAppendOnlyFile()
+
+	data, _ := os.ReadFile(path)
+	log := string(data)
+	assert.Equal(t, 2, strings.Count(log, "SELECT"))
+	assert.Equal(t, 1, strings.Count(log, "INCR"))
+	assert.NotContains(t, log, "GET")
+	// Relative expirations and generated IDs are logged as they were applied
+	assert.Contains(t, log, "PXAT")
+	assert.NotContains(t, log, "$2\r\nEX\r\n")
+	assert.Contains(t, log, strings.Split(string(xaddRsp), "\r\n")[1])
+
+	replayed := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.Equal(t, RespString{"value"}, replayed.Get("key"))
+	assert.NotEqual(t, int64(NON_EXPIRATION_TIME), replayed.databases[DEFAULT_DATABASE]["key"].ExpirationTimestampMillis)
+	assert.Equal(t, RespString{"1"}, replayed.Get("counter"))
+	replayedDB1, _ := replayed.Select(1)
+	assert.Equal(t, RespString{"other"}, replayedDB1.Get("key"))
+	replayedClient := NewClient(replayed)
+	xrangeRsp := handleRequest(replayedClient, []byte("*4\r\n$6\r\nXRANGE\r\n$6\r\nstream\r\n$1\r\n-\r\n$1\r\n+\r\n"))
+	assert.Contains(t, string(xrangeRsp), strings.Split(string(xaddRsp), "\r\n")[1])
+}
+
+func TestTransactionRequestsAreLogged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	xredis.OpenAppendOnlyFile(path)
+	client := NewClient(xredis)
+
+	_ = handleRequest(client, []byte("*1\r\n$5\r\nMULTI\r\n"))
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	_ = handleRequest(client, []byte("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"))
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	_ = handleRequest(client, []byte("*1\r\n$4\r\nEXEC\r\n"))
+	xredis.CloseAppendOnlyFile()
+
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "*1\r\n$5\r\nMULTI\r\n*2\r\n$6\r\nSELECT\r\n$1\r\n0\r\n*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"+
+		"*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n*1\r\n$4\r\nEXEC\r\n", string(data))
+
+	replayed := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.Equal(t, RespString{"2"}, replayed.Get("counter"))
+}
+
+func TestReplayTruncatedTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	complete := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n"
+	transaction := "*1\r\n$5\r\nMULTI\r\n*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"
+	for _, cut := range []int{len(transaction), len(transaction) - 3} {
+		os.WriteFile(path, []byte(complete+transaction[:cut]), 0644)
+
+		xredis := NewXRedis()
+		assert.Nil(t, replayAppendOnlyFile(xredis, path))
+		assert.Equal(t, RespString{"value"}, xredis.Get("key"))
+		assert.False(t, xredis.Exists("counter"))
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, complete, string(data))
+	}
+}
+
+func TestWritesAreRefusedAfterAppendOnlyWriteFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	xredis.OpenAppendOnlyFile(path)
+	client := NewClient(xredis)
+	_ = handleRequest(client, []byte("*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n"))
+
+	// Writes to a closed file fail
+	xredis.Atomically(func(tx *XRedis) { tx.aof.file.Close() })
+	setRsp := handleRequest(client, []byte("*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1\r\n2\r\n"))
+	assert.Equal(t, "-"+REQUEST_ERROR_AOF_WRITE_FAILED+"\r\n", string(setRsp))
+	setRsp = handleRequest(client, []byte("*3\r\n$3\r\nSET\r\n$1\r\nc\r\n$1\r\n3\r\n"))
+	assert.Equal(t, "-"+REQUEST_ERROR_AOF_WRITE_FAILED+"\r\n", string(setRsp))
+	_ = handleRequest(client, []byte("*1\r\n$5\r\nMULTI\r\n"))
+	_ = handleRequest(client, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"))
+	execRsp := handleRequest(client, []byte("*1\r\n$4\r\nEXEC\r\n"))
+	assert.Equal(t, "-"+REQUEST_ERROR_AOF_WRITE_FAILED+"\r\n", string(execRsp))
+	getRsp := handleRequest(client, []byte("*2\r\n$3\r\nGET\r\n$1\r\nb\r\n"))
+	assert.Equal(t, "$1\r\n2\r\n", string(getRsp))
+	assert.True(t, xredis.PersistenceInfo().aofLastWriteFailed)
+
+	// The request left pending is written once the file can be written again
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	xredis.Atomically(func(tx *XRedis) { tx.aof.file = file })
+	xredis.commands <- AppendOnlyFsyncCommand{}
+	assert.False(t, xredis.AppendOnlyWriteFailed())
+	setRsp = handleRequest(client, []byte("*3\r\n$3\r\nSET\r\n$1\r\nc\r\n$1\r\n3\r\n"))
+	assert.Equal(t, "$2\r\nOK\r\n", string(setRsp))
+	assert.Nil(t, xredis.CloseAppendOnlyFile())
+
+	replayed := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.Equal(t, RespString{"2"}, replayed.Get("b"))
+	assert.Equal(t, RespString{"3"}, replayed.Get("c"))
+	assert.False(t, replayed.Exists("counter"))
+}
+
+func TestXReadGroupIsLoggedWithoutBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	xredis.OpenAppendOnlyFile(path)
+	client := NewClient(xredis)
+
+	_ = handleRequest(client, []byte("*6\r\n$6\r\nXGROUP\r\n$6\r\nCREATE\r\n$6\r\nstream\r\n$5\r\ngroup\r\n$1\r\n$\r\n$8\r\nMKSTREAM\r\n"))
+	_ = handleRequest(client, []byte("*5\r\n$4\r\nXADD\r\n$6\r\nstream\r\n$3\r\n1-1\r\n$5\r\nfield\r\n$5\r\nvalue\r\n"))
+	_ = handleRequest(client, []byte("*11\r\n$10\r\nXREADGROUP\r\n$5\r\nGROUP\r\n$5\r\ngroup\r\n$8\r\nconsumer\r\n$5\r\nBLOCK\r\n$4\r\n1000\r\n$5\r\nCOUNT\r\n$1\r\n1\r\n$7\r\nSTREAMS\r\n$6\r\nstream\r\n$1\r\n>\r\n"))
+	xredis.CloseAppendOnlyFile()
+
+	data, _ := os.ReadFile(path)
+	assert.True(t, strings.HasSuffix(string(data), "*9\r\n$10\r\nXREADGROUP\r\n$5\r\nGROUP\r\n$5\r\ngroup\r\n$8\r\nconsumer\r\n$5\r\nCOUNT\r\n$1\r\n1\r\n$7\r\nSTREAMS\r\n$6\r\nstream\r\n$1\r\n>\r\n"))
+}
+
+func TestReplayedKeysExpireAsWhenApplied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	xredis.OpenAppendOnlyFile(path)
+	client := NewClient(xredis)
+	request := func(arguments ...string) []byte {
+		return []byte(stringsToRespArray(arguments).serialize())
+	}
+
+	// Incremented before expiring
+	_ = handleRequest(client, request("SET", "before", "1", "PX", "100"))
+	_ = handleRequest(client, request("INCR", "before"))
+	// Incremented once expired, which starts over without expiration
+	_ = handleRequest(client, request("SET", "after", "1", "PX", "10"))
+	time.Sleep(20 * time.Millisecond)
+	incrRsp := handleRequest(client, request("INCR", "after"))
+	assert.Equal(t, "$1\r\n1\r\n", string(incrRsp))
+	xredis.CloseAppendOnlyFile()
+
+	time.Sleep(100 * time.Millisecond)
+	replayed := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	assert.False(t, replayed.Exists("before"))
+	assert.Equal(t, RespString{"1"}, replayed.Get("after"))
+	assert.Equal(t, int64(NON_EXPIRATION_TIME), replayed.databases[DEFAULT_DATABASE]["after"].ExpirationTimestampMillis)
+}
+
+func TestRestoreIsLoggedWithAbsoluteTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	xredis.OpenAppendOnlyFile(path)
+	client := NewClient(xredis)
+	request := func(arguments ...string) []byte {
+		return []byte(stringsToRespArray(arguments).serialize())
+	}
+
+	_ = handleRequest(client, request("RPUSH", "list", "a"))
+	dumpRsp := handleRequest(client, request("DUMP", "list"))
+	payload, _, _ := deserializeRespDataType(dumpRsp)
+	_ = handleRequest(client, request("RESTORE", "expiring", "100000", payload.(RespString).Str))
+	_ = handleRequest(client, request("RESTORE", "persistent", "0", payload.(RespString).Str))
+	xredis.CloseAppendOnlyFile()
+
+	data, _ := os.ReadFile(path)
+	assert.NotContains(t, string(data), "$6\r\n100000\r\n")
+	assert.Equal(t, 1, strings.Count(string(data), RESTORE_OPTION_ABSTTL))
+
+	time.Sleep(200 * time.Millisecond)
+	replayed := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	expiration := xredis.databases[DEFAULT_DATABASE]["expiring"].ExpirationTimestampMillis
+	replayedExpiration := replayed.databases[DEFAULT_DATABASE]["expiring"].ExpirationTimestampMillis
+	assert.InDelta(t, expiration, replayedExpiration, 100)
+	assert.Equal(t, int64(NON_EXPIRATION_TIME), replayed.databases[DEFAULT_DATABASE]["persistent"].ExpirationTimestampMillis)
+}
+
+func TestClaimsAreLoggedAsApplied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	xredis := NewXRedis()
+	xredis.OpenAppendOnlyFile(path)
+	client := NewClient(xredis)
+	request := func(arguments ...string) []byte {
+		return []byte(stringsToRespArray(arguments).serialize())
+	}
+
+	_ = handleRequest(client, request("XGROUP", "CREATE", "stream", "group", "$", "MKSTREAM"))
+	_ = handleRequest(client, request("XADD", "stream", "1-1", "field", "value"))
+	_ = handleRequest(client, request("XADD", "stream", "2-1", "field", "value"))
+	_ = handleRequest(client, request("XADD", "stream", "3-1", "field", "value"))
+	_ = handleRequest(client, request("XREADGROUP", "GROUP", "group", "alice", "STREAMS", "stream", ">"))
+	_ = handleRequest(client, request("XDEL", "stream", "2-1"))
+	// Only claims entries idle for an hour, which none is yet
+	xclaimRsp := handleRequest(client, request("XCLAIM", "stream", "group", "bob", "3600000", "1-1", "LASTID", "3-1"))
+	assert.Equal(t, "*0\r\n", string(xclaimRsp))
+	xclaimRsp = handleRequest(client, request("XCLAIM", "stream", "group", "bob", "0", "3-1", "RETRYCOUNT", "5"))
+	assert.Contains(t, string(xclaimRsp), "3-1")
+	xautoclaimRsp := handleRequest(client, request("XAUTOCLAIM", "stream", "group", "carol", "0", "0"))
+	assert.Contains(t, string(xautoclaimRsp), "*1\r\n$3\r\n2-1\r\n")
+	xredis.CloseAppendOnlyFile()
+
+	data, _ := os.ReadFile(path)
+	log := string(data)
+	assert.NotContains(t, log, "3600000")
+	assert.NotContains(t, log, "XAUTOCLAIM")
+	assert.Contains(t, log, string(request("XCLAIM", "stream", "group", "carol", "0", "2-1", "FORCE", "JUSTID")))
+
+	// Replaying later claims the same entries
+	time.Sleep(10 * time.Millisecond)
+	replayed := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(replayed, path))
+	group := xredis.databases[DEFAULT_DATABASE]["stream"].Element.(*Stream).Groups["group"]
+	replayedGroup := replayed.databases[DEFAULT_DATABASE]["stream"].Element.(*Stream).Groups["group"]
+	assert.Equal(t, group.Pending, replayedGroup.Pending)
+	assert.Equal(t, group.LastDeliveredID, replayedGroup.LastDeliveredID)
+	assert.NotContains(t, replayedGroup.Pending, StreamID{2, 1})
+	assert.Equal(t, "carol", replayedGroup.Pending[StreamID{3, 1}].Consumer)
+	assert.Equal(t, int64(6), replayedGroup.Pending[StreamID{3, 1}].DeliveryCount)
+	assert.Contains(t, replayedGroup.Consumers, "bob")
+}
+
+func TestReplayTruncatedAppendOnlyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	complete := "*5\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n$2\r\nEX\r\n$3\r\n100\r\n"
+	os.WriteFile(path, []byte(complete+"*3\r\n$3\r\nSET\r\n$3\r\nke"), 0644)
+
+	xredis := NewXRedis()
+	assert.Nil(t, replayAppendOnlyFile(xredis, path))
+	assert.Equal(t, RespString{"value"}, xredis.Get("key"))
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, complete, string(data))
+}
+
+func TestReplayCorruptedAppendOnlyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AOF_FILE)
+	os.WriteFile(path, []byte("*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"+"garbage\r\n"), 0644)
+
+	err := replayAppendOnlyFile(NewXRedis(), path)
+	assert.ErrorContains(t, err, AOF_ERROR_CORRUPTED)
+}
+
+func TestBgRewriteAofRequest(t *testing.T) {
+	xredis := NewXRedis()
+	client := NewClient(xredis)
+
+	disabledRsp := handleRequest(client, []byte("*1\r\n$12\r\nBGREWRITEAOF\r\n"))
+	assert.Equal(t, "-ERR APPEND-ONLY-FILE-DISABLED\r\n", string(disabledRsp))
+
+	xredis.OpenAppendOnlyFile(filepath.Join(t.TempDir(), AOF_FILE))
+	defer xredis.CloseAppendOnlyFile()
+	rsp := handleRequest(client, []byte("*1\r\n$12\r\nBGREWRITEAOF\r\n"))
+	assert.Equal(t, "$45\r\nBackground append only file rewriting started\r\n", string(rsp))
+	assert.Eventually(t, func() bool { return !xredis.AppendOnlyRewriteInProgress() }, time.Second, time.Millisecond)
+}
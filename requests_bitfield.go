@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+func handleBitFieldRequest(requestData RespArray, xredis *XRedis, readOnly bool) RespDataType {
+	if len(requestData.Elements) < REQUEST_BITFIELD_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_BITFIELD_KEY_INDEX].(RespString).Str
+	arguments := requestArguments(requestData, REQUEST_BITFIELD_OPERATIONS_INDEX)
+
+	operations := make([]BitFieldOperation, 0)
+	overflow := BITFIELD_OVERFLOW_WRAP
+	for i := 0; i < len(arguments); {
+		kind := strings.ToUpper(arguments[i])
+		if readOnly && kind != BITFIELD_GET {
+			return RespError{REQUEST_ERROR_BITFIELD_RO_ONLY_GET}
+		}
+		switch kind {
+		case BITFIELD_OVERFLOW:
+			if i+1 >= len(arguments) {
+				return RespError{REQUEST_ERROR_SYNTAX}
+			}
+			overflow = strings.ToUpper(arguments[i+1])
+			if overflow != BITFIELD_OVERFLOW_WRAP && overflow != BITFIELD_OVERFLOW_SAT && overflow != BITFIELD_OVERFLOW_FAIL {
+				return RespError{REQUEST_ERROR_INVALID_OVERFLOW_TYPE}
+			}
+			i += 2
+			continue
+		case BITFIELD_GET, BITFIELD_SET, BITFIELD_INCRBY:
+		default:
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+		// GET takes a type and an offset, SET and INCRBY a value as well
+		size := 3
+		if kind != BITFIELD_GET {
+			size = 4
+		}
+		if i+size > len(arguments) {
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+
+		signed, width, err := parseBitFieldType(arguments[i+1])
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		offset, err := parseBitFieldOffset(arguments[i+2], width)
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		operation := BitFieldOperation{kind, signed, width, offset, 0, overflow}
+		i += 3
+		if kind != BITFIELD_GET {
+			value, err := strconv.ParseInt(arguments[i], 10, 64)
+			if err != nil {
+				return RespError{REQUEST_ERROR_VALUE_NOT_AN_INTEGER}
+			}
+			operation.value = value
+			i++
+		}
+		operations = append(operations, operation)
+	}
+
+	results, err := xredis.BitField(key, operations)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	reply := make([]RespDataType, 0, len(results))
+	for _, result := range results {
+		if result.failed {
+			reply = append(reply, RespNil{})
+		} else {
+			reply = append(reply, RespInt{result.value})
+		}
+	}
+	return RespArray{reply}
+}
+
+// parseBitFieldType parses an integer type such as i8 or u16
+func parseBitFieldType(str string) (bool, uint, error) {
+	if len(str) < 2 || (str[0] != 'i' && str[0] != 'u' && str[0] != 'I' && str[0] != 'U') {
+		return false, 0, errors.New(REQUEST_ERROR_INVALID_BITFIELD_TYPE)
+	}
+	signed := str[0] == 'i' || str[0] == 'I'
+	width := uint(0)
+	for _, digit := range str[1:] {
+		if digit < '0' || digit > '9' || width > BITFIELD_MAX_SIGNED_WIDTH {
+			return false, 0, errors.New(REQUEST_ERROR_INVALID_BITFIELD_TYPE)
+		}
+		width = width*10 + uint(digit-'0')
+	}
+	maxWidth := uint(BITFIELD_MAX_UNSIGNED_WIDTH)
+	if signed {
+		maxWidth = BITFIELD_MAX_SIGNED_WIDTH
+	}
+	if width == 0 || width > maxWidth {
+		return false, 0, errors.New(REQUEST_ERROR_INVALID_BITFIELD_TYPE)
+	}
+	return signed, width, nil
+}
+
+// parseBitFieldOffset parses a bit offset, which is multiplied by the
+// width of the integer type when prefixed by #.
+func parseBitFieldOffset(str string, width uint) (uint64, error) {
+	multiplier := uint64(1)
+	if strings.HasPrefix(str, BITFIELD_OFFSET_MULTIPLIER) {
+		multiplier = uint64(width)
+		str = strings.TrimPrefix(str, BITFIELD_OFFSET_MULTIPLIER)
+	}
+	offset, err := strconv.ParseUint(str, 10, 64)
+	if err != nil || offset > BITMAP_MAX_OFFSET/multiplier {
+		return 0, errors.New(REQUEST_ERROR_BIT_OFFSET_OUT_OF_RANGE)
+	}
+	offset *= multiplier
+	if offset+uint64(width)-1 > BITMAP_MAX_OFFSET {
+		return 0, errors.New(REQUEST_ERROR_BIT_OFFSET_OUT_OF_RANGE)
+	}
+	return offset, nil
+}
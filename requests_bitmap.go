@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+func handleSetBitRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_SETBIT_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_SETBIT_KEY_INDEX].(RespString).Str
+	offset, err := parseBitOffset(requestData.Elements[REQUEST_SETBIT_OFFSET_INDEX].(RespString).Str)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	bit, err := parseBit(requestData.Elements[REQUEST_SETBIT_VALUE_INDEX].(RespString).Str)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	previous, err := xredis.SetBit(key, offset, bit)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(previous)}
+}
+
+func handleGetBitRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_GETBIT_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_GETBIT_KEY_INDEX].(RespString).Str
+	offset, err := parseBitOffset(requestData.Elements[REQUEST_GETBIT_OFFSET_INDEX].(RespString).Str)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	bit, err := xredis.GetBit(key, offset)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(bit)}
+}
+
+func handleBitCountRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_BITCOUNT_MIN_SIZE || len(requestData.Elements) > REQUEST_BITCOUNT_MAX_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_BITCOUNT_KEY_INDEX].(RespString).Str
+	arguments := requestArguments(requestData, REQUEST_BITCOUNT_RANGE_INDEX)
+	// Unlike BITPOS, the end can't be left out
+	if len(arguments) == 1 {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	bitRange, err := parseBitRange(arguments)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	count, err := xredis.BitCount(key, bitRange)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{count}
+}
+
+func handleBitPosRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_BITPOS_MIN_SIZE || len(requestData.Elements) > REQUEST_BITPOS_MAX_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_BITPOS_KEY_INDEX].(RespString).Str
+	bit, err := parseBit(requestData.Elements[REQUEST_BITPOS_BIT_INDEX].(RespString).Str)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	bitRange, err := parseBitRange(requestArguments(requestData, REQUEST_BITPOS_RANGE_INDEX))
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	position, err := xredis.BitPos(key, bit, bitRange)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{position}
+}
+
+func handleBitOpRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_BITOP_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	operation := strings.ToUpper(requestData.Elements[REQUEST_BITOP_OPERATION_INDEX].(RespString).Str)
+	destKey := requestData.Elements[REQUEST_BITOP_DEST_KEY_INDEX].(RespString).Str
+	keys := requestArguments(requestData, REQUEST_BITOP_FIRST_KEY_INDEX)
+	switch operation {
+	case BITOP_AND, BITOP_OR, BITOP_XOR:
+	case BITOP_NOT:
+		if len(keys) != 1 {
+			return RespError{REQUEST_ERROR_BITOP_NOT_SINGLE_SOURCE}
+		}
+	default:
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	length, err := xredis.BitOp(operation, destKey, keys)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(length)}
+}
+
+func parseBitOffset(str string) (uint64, error) {
+	offset, err := strconv.ParseUint(str, 10, 64)
+	if err != nil || offset > BITMAP_MAX_OFFSET {
+		return 0, errors.New(REQUEST_ERROR_BIT_OFFSET_OUT_OF_RANGE)
+	}
+	return offset, nil
+}
+
+func parseBit(str string) (int, error) {
+	if str != "0" && str != "1" {
+		return 0, errors.New(REQUEST_ERROR_BIT_OUT_OF_RANGE)
+	}
+	return int(str[0] - '0'), nil
+}
+
+// parseBitRange parses the optional start [end [BYTE|BIT]] arguments of
+// BITCOUNT and BITPOS, returning nil when they are missing.
+func parseBitRange(arguments []string) (*BitRange, error) {
+	if len(arguments) == 0 {
+		return nil, nil
+	}
+	if len(arguments) > 3 {
+		return nil, errors.New(REQUEST_ERROR_SYNTAX)
+	}
+	var bitRange BitRange
+	start, err := strconv.ParseInt(arguments[0], 10, 64)
+	if err != nil {
+		return nil, errors.New(REQUEST_ERROR_VALUE_NOT_AN_INTEGER)
+	}
+	bitRange.start = start
+	if len(arguments) > 1 {
+		end, err := strconv.ParseInt(arguments[1], 10, 64)
+		if err != nil {
+			return nil, errors.New(REQUEST_ERROR_VALUE_NOT_AN_INTEGER)
+		}
+		bitRange.end = end
+		bitRange.hasEnd = true
+	}
+	if len(arguments) > 2 {
+		switch strings.ToUpper(arguments[2]) {
+		case BIT_RANGE_UNIT_BYTE:
+		case BIT_RANGE_UNIT_BIT:
+			bitRange.bitUnit = true
+		default:
+			return nil, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+	}
+	return &bitRange, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetBitAndGetBitRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setbitCommand := "*4\r\n$6\r\nSETBIT\r\n$6\r\nbitmap\r\n$1\r\n7\r\n$1\r\n1\r\n"
+	setbitRsp := handleRequest(client, []byte(setbitCommand))
+	assert.Equal(t, ":0\r\n", string(setbitRsp))
+
+	getbitCommand := "*3\r\n$6\r\nGETBIT\r\n$6\r\nbitmap\r\n$1\r\n7\r\n"
+	getbitRsp := handleRequest(client, []byte(getbitCommand))
+	assert.Equal(t, ":1\r\n", string(getbitRsp))
+
+	invalidBitCommand := "*4\r\n$6\r\nSETBIT\r\n$6\r\nbitmap\r\n$1\r\n7\r\n$1\r\n2\r\n"
+	invalidBitRsp := handleRequest(client, []byte(invalidBitCommand))
+	assert.Equal(t, "-ERR BIT-IS-NOT-AN-INTEGER-OR-OUT-OF-RANGE\r\n", string(invalidBitRsp))
+
+	invalidOffsetCommand := "*4\r\n$6\r\nSETBIT\r\n$6\r\nbitmap\r\n$2\r\n-1\r\n$1\r\n1\r\n"
+	invalidOffsetRsp := handleRequest(client, []byte(invalidOffsetCommand))
+	assert.Equal(t, "-ERR BIT-OFFSET-IS-NOT-AN-INTEGER-OR-OUT-OF-RANGE\r\n", string(invalidOffsetRsp))
+}
+
+func TestBitCountBitPosAndBitOpRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	_ = handleRequest(client, []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$6\r\nfoobar\r\n"))
+	_ = handleRequest(client, []byte("*4\r\n$6\r\nSETBIT\r\n$6\r\nbitmap\r\n$1\r\n7\r\n$1\r\n1\r\n"))
+
+	bitcountCommand := "*5\r\n$8\r\nBITCOUNT\r\n$3\r\nkey\r\n$1\r\n5\r\n$2\r\n30\r\n$3\r\nBIT\r\n"
+	bitcountRsp := handleRequest(client, []byte(bitcountCommand))
+	assert.Equal(t, ":17\r\n", string(bitcountRsp))
+
+	missingEndCommand := "*3\r\n$8\r\nBITCOUNT\r\n$3\r\nkey\r\n$1\r\n1\r\n"
+	missingEndRsp := handleRequest(client, []byte(missingEndCommand))
+	assert.Equal(t, "-ERR SYNTAX-ERROR\r\n", string(missingEndRsp))
+
+	bitposCommand := "*4\r\n$6\r\nBITPOS\r\n$3\r\nkey\r\n$1\r\n1\r\n$1\r\n2\r\n"
+	bitposRsp := handleRequest(client, []byte(bitposCommand))
+	assert.Equal(t, ":17\r\n", string(bitposRsp))
+
+	bitopNotCommand := "*5\r\n$5\r\nBITOP\r\n$3\r\nNOT\r\n$4\r\ndest\r\n$3\r\nkey\r\n$6\r\nbitmap\r\n"
+	bitopNotRsp := handleRequest(client, []byte(bitopNotCommand))
+	assert.Equal(t, "-ERR BITOP-NOT-MUST-BE-CALLED-WITH-A-SINGLE-SOURCE-KEY\r\n", string(bitopNotRsp))
+
+	bitopAndCommand := "*5\r\n$5\r\nBITOP\r\n$3\r\nAND\r\n$4\r\ndest\r\n$3\r\nkey\r\n$6\r\nbitmap\r\n"
+	bitopAndRsp := handleRequest(client, []byte(bitopAndCommand))
+	assert.Equal(t, ":6\r\n", string(bitopAndRsp))
+}
+
+func TestBitFieldRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	bitfieldCommand := "*15\r\n$8\r\nBITFIELD\r\n$8\r\ncounters\r\n$3\r\nSET\r\n$2\r\nu8\r\n$2\r\n#1\r\n$2\r\n65\r\n$3\r\nGET\r\n$2\r\nu8\r\n$1\r\n8\r\n$8\r\nOVERFLOW\r\n$4\r\nFAIL\r\n$6\r\nINCRBY\r\n$2\r\nu8\r\n$2\r\n#1\r\n$3\r\n200\r\n"
+	bitfieldRsp := handleRequest(client, []byte(bitfieldCommand))
+	assert.Equal(t, "*3\r\n:0\r\n:65\r\n$-1\r\n", string(bitfieldRsp))
+
+	invalidTypeCommand := "*5\r\n$8\r\nBITFIELD\r\n$8\r\ncounters\r\n$3\r\nGET\r\n$3\r\nu64\r\n$1\r\n0\r\n"
+	invalidTypeRsp := handleRequest(client, []byte(invalidTypeCommand))
+	assert.Equal(t, "-ERR INVALID-BITFIELD-TYPE\r\n", string(invalidTypeRsp))
+
+	bitfieldRoCommand := "*5\r\n$11\r\nBITFIELD_RO\r\n$8\r\ncounters\r\n$3\r\nGET\r\n$2\r\nu8\r\n$2\r\n#1\r\n"
+	bitfieldRoRsp := handleRequest(client, []byte(bitfieldRoCommand))
+	assert.Equal(t, "*1\r\n:65\r\n", string(bitfieldRoRsp))
+
+	bitfieldRoSetCommand := "*6\r\n$11\r\nBITFIELD_RO\r\n$8\r\ncounters\r\n$3\r\nSET\r\n$2\r\nu8\r\n$1\r\n0\r\n$1\r\n1\r\n"
+	bitfieldRoSetRsp := handleRequest(client, []byte(bitfieldRoSetCommand))
+	assert.Equal(t, "-ERR BITFIELD_RO-ONLY-SUPPORTS-THE-GET-SUBCOMMAND\r\n", string(bitfieldRoSetRsp))
+}
@@ -11,6 +11,74 @@ const REQUEST_DECREMENT = "DECR"
 const REQUEST_LPUSH = "LPUSH"
 const REQUEST_RPUSH = "RPUSH"
 const REQUEST_SAVE = "SAVE"
+const REQUEST_OBJECT = "OBJECT"
+const REQUEST_KEYS = "KEYS"
+const REQUEST_SCAN = "SCAN"
+const REQUEST_TYPE = "TYPE"
+const REQUEST_RANDOMKEY = "RANDOMKEY"
+const REQUEST_DBSIZE = "DBSIZE"
+const REQUEST_HSCAN = "HSCAN"
+const REQUEST_SSCAN = "SSCAN"
+const REQUEST_ZSCAN = "ZSCAN"
+const REQUEST_RENAME = "RENAME"
+const REQUEST_RENAMENX = "RENAMENX"
+const REQUEST_COPY = "COPY"
+const REQUEST_MOVE = "MOVE"
+const REQUEST_SELECT = "SELECT"
+const REQUEST_FLUSHDB = "FLUSHDB"
+const REQUEST_FLUSHALL = "FLUSHALL"
+const REQUEST_SWAPDB = "SWAPDB"
+const REQUEST_MULTI = "MULTI"
+const REQUEST_EXEC = "EXEC"
+const REQUEST_DISCARD = "DISCARD"
+const REQUEST_WATCH = "WATCH"
+const REQUEST_UNWATCH = "UNWATCH"
+const REQUEST_SUBSCRIBE = "SUBSCRIBE"
+const REQUEST_UNSUBSCRIBE = "UNSUBSCRIBE"
+const REQUEST_PSUBSCRIBE = "PSUBSCRIBE"
+const REQUEST_PUNSUBSCRIBE = "PUNSUBSCRIBE"
+const REQUEST_PUBLISH = "PUBLISH"
+const REQUEST_PUBSUB = "PUBSUB"
+const REQUEST_SSUBSCRIBE = "SSUBSCRIBE"
+const REQUEST_SUNSUBSCRIBE = "SUNSUBSCRIBE"
+const REQUEST_SPUBLISH = "SPUBLISH"
+const REQUEST_CONFIG = "CONFIG"
+const REQUEST_XADD = "XADD"
+const REQUEST_XRANGE = "XRANGE"
+const REQUEST_XREVRANGE = "XREVRANGE"
+const REQUEST_XREAD = "XREAD"
+const REQUEST_XLEN = "XLEN"
+const REQUEST_XDEL = "XDEL"
+const REQUEST_XTRIM = "XTRIM"
+const REQUEST_XGROUP = "XGROUP"
+const REQUEST_XREADGROUP = "XREADGROUP"
+const REQUEST_XACK = "XACK"
+const REQUEST_XPENDING = "XPENDING"
+const REQUEST_XCLAIM = "XCLAIM"
+const REQUEST_XAUTOCLAIM = "XAUTOCLAIM"
+const REQUEST_XINFO = "XINFO"
+const REQUEST_SETBIT = "SETBIT"
+const REQUEST_GETBIT = "GETBIT"
+const REQUEST_BITCOUNT = "BITCOUNT"
+const REQUEST_BITPOS = "BITPOS"
+const REQUEST_BITOP = "BITOP"
+const REQUEST_BITFIELD = "BITFIELD"
+const REQUEST_BITFIELD_RO = "BITFIELD_RO"
+const REQUEST_PFADD = "PFADD"
+const REQUEST_PFCOUNT = "PFCOUNT"
+const REQUEST_PFMERGE = "PFMERGE"
+const REQUEST_GEOADD = "GEOADD"
+const REQUEST_GEOPOS = "GEOPOS"
+const REQUEST_GEODIST = "GEODIST"
+const REQUEST_GEOHASH = "GEOHASH"
+const REQUEST_GEOSEARCH = "GEOSEARCH"
+const REQUEST_GEOSEARCHSTORE = "GEOSEARCHSTORE"
+const REQUEST_DUMP = "DUMP"
+const REQUEST_RESTORE = "RESTORE"
+const REQUEST_BGREWRITEAOF = "BGREWRITEAOF"
+const REQUEST_BGSAVE = "BGSAVE"
+const REQUEST_LASTSAVE = "LASTSAVE"
+const REQUEST_INFO = "INFO"
 
 const REQUEST_PING_EXPECTED_SIZE = 1
 const REQUEST_ECHO_EXPECTED_SIZE = 2
@@ -24,6 +92,161 @@ const REQUEST_DECREMENT_EXPECTED_SIZE = 2
 const REQUEST_LPUSH_EXPECTED_SIZE = 3
 const REQUEST_RPUSH_EXPECTED_SIZE = 3
 const REQUEST_SAVE_EXPECTED_SIZE = 1
+const REQUEST_OBJECT_EXPECTED_SIZE = 3
+const REQUEST_KEYS_EXPECTED_SIZE = 2
+const REQUEST_SCAN_MIN_SIZE = 2
+const REQUEST_TYPE_EXPECTED_SIZE = 2
+const REQUEST_RANDOMKEY_EXPECTED_SIZE = 1
+const REQUEST_DBSIZE_EXPECTED_SIZE = 1
+const REQUEST_COLLECTION_SCAN_MIN_SIZE = 3
+const REQUEST_RENAME_EXPECTED_SIZE = 3
+const REQUEST_COPY_MIN_SIZE = 3
+const REQUEST_MOVE_EXPECTED_SIZE = 3
+const REQUEST_SELECT_EXPECTED_SIZE = 2
+const REQUEST_FLUSH_MIN_SIZE = 1
+const REQUEST_FLUSH_MAX_SIZE = 2
+const REQUEST_SWAPDB_EXPECTED_SIZE = 3
+const REQUEST_MULTI_EXPECTED_SIZE = 1
+const REQUEST_EXEC_EXPECTED_SIZE = 1
+const REQUEST_DISCARD_EXPECTED_SIZE = 1
+const REQUEST_WATCH_MIN_SIZE = 2
+const REQUEST_UNWATCH_EXPECTED_SIZE = 1
+const REQUEST_SUBSCRIBE_MIN_SIZE = 2
+const REQUEST_UNSUBSCRIBE_MIN_SIZE = 1
+const REQUEST_PUBLISH_EXPECTED_SIZE = 3
+const REQUEST_PUBSUB_MIN_SIZE = 2
+const REQUEST_CONFIG_MIN_SIZE = 2
+const REQUEST_XADD_MIN_SIZE = 5
+const REQUEST_XRANGE_MIN_SIZE = 4
+const REQUEST_XREAD_MIN_SIZE = 4
+const REQUEST_XLEN_EXPECTED_SIZE = 2
+const REQUEST_XDEL_MIN_SIZE = 3
+const REQUEST_XTRIM_MIN_SIZE = 4
+const REQUEST_XGROUP_MIN_SIZE = 2
+const REQUEST_XGROUP_CREATE_MIN_SIZE = 5
+const REQUEST_XGROUP_SETID_EXPECTED_SIZE = 5
+const REQUEST_XGROUP_DESTROY_EXPECTED_SIZE = 4
+const REQUEST_XGROUP_CONSUMER_EXPECTED_SIZE = 5
+const REQUEST_XREADGROUP_MIN_SIZE = 7
+const REQUEST_XACK_MIN_SIZE = 4
+const REQUEST_XPENDING_MIN_SIZE = 3
+const REQUEST_XPENDING_EXTENDED_MIN_SIZE = 6
+const REQUEST_XPENDING_EXTENDED_MAX_SIZE = 9
+const REQUEST_XCLAIM_MIN_SIZE = 6
+const REQUEST_XAUTOCLAIM_MIN_SIZE = 6
+const REQUEST_XINFO_MIN_SIZE = 2
+const REQUEST_XINFO_STREAM_EXPECTED_SIZE = 3
+const REQUEST_XINFO_GROUPS_EXPECTED_SIZE = 3
+const REQUEST_XINFO_CONSUMERS_EXPECTED_SIZE = 4
+const REQUEST_SETBIT_EXPECTED_SIZE = 4
+const REQUEST_GETBIT_EXPECTED_SIZE = 3
+const REQUEST_BITCOUNT_MIN_SIZE = 2
+const REQUEST_BITCOUNT_MAX_SIZE = 5
+const REQUEST_BITPOS_MIN_SIZE = 3
+const REQUEST_BITPOS_MAX_SIZE = 6
+const REQUEST_BITOP_MIN_SIZE = 4
+const REQUEST_BITFIELD_MIN_SIZE = 2
+const REQUEST_PFADD_MIN_SIZE = 2
+const REQUEST_PFCOUNT_MIN_SIZE = 2
+const REQUEST_PFMERGE_MIN_SIZE = 2
+const REQUEST_GEOADD_MIN_SIZE = 5
+const REQUEST_GEOPOS_MIN_SIZE = 2
+const REQUEST_GEODIST_MIN_SIZE = 4
+const REQUEST_GEODIST_MAX_SIZE = 5
+const REQUEST_GEOHASH_MIN_SIZE = 2
+const REQUEST_GEOSEARCH_MIN_SIZE = 7
+const REQUEST_GEOSEARCHSTORE_MIN_SIZE = 8
+const REQUEST_DUMP_EXPECTED_SIZE = 2
+const REQUEST_RESTORE_MIN_SIZE = 4
+const REQUEST_BGREWRITEAOF_EXPECTED_SIZE = 1
+const REQUEST_BGSAVE_EXPECTED_SIZE = 1
+const REQUEST_LASTSAVE_EXPECTED_SIZE = 1
+const REQUEST_INFO_MIN_SIZE = 1
+
+// Number of elements of each request, used to validate the requests
+// queued by transactions. As in Redis a negative arity -N means that
+// the request takes at least N elements.
+var REQUEST_ARITIES = map[string]int{
+	REQUEST_PING:           REQUEST_PING_EXPECTED_SIZE,
+	REQUEST_ECHO:           REQUEST_ECHO_EXPECTED_SIZE,
+	REQUEST_GET:            REQUEST_GET_EXPECTED_SIZE,
+	REQUEST_SET:            -REQUEST_SET_EXPECTED_SIZE,
+	REQUEST_EXISTS:         REQUEST_EXISTS_EXPECTED_SIZE,
+	REQUEST_DELETE:         REQUEST_DELETE_EXPECTED_SIZE,
+	REQUEST_INCREMENT:      REQUEST_INCREMENT_EXPECTED_SIZE,
+	REQUEST_DECREMENT:      REQUEST_DECREMENT_EXPECTED_SIZE,
+	REQUEST_LPUSH:          REQUEST_LPUSH_EXPECTED_SIZE,
+	REQUEST_RPUSH:          REQUEST_RPUSH_EXPECTED_SIZE,
+	REQUEST_SAVE:           REQUEST_SAVE_EXPECTED_SIZE,
+	REQUEST_OBJECT:         REQUEST_OBJECT_EXPECTED_SIZE,
+	REQUEST_KEYS:           REQUEST_KEYS_EXPECTED_SIZE,
+	REQUEST_SCAN:           -REQUEST_SCAN_MIN_SIZE,
+	REQUEST_TYPE:           REQUEST_TYPE_EXPECTED_SIZE,
+	REQUEST_RANDOMKEY:      REQUEST_RANDOMKEY_EXPECTED_SIZE,
+	REQUEST_DBSIZE:         REQUEST_DBSIZE_EXPECTED_SIZE,
+	REQUEST_HSCAN:          -REQUEST_COLLECTION_SCAN_MIN_SIZE,
+	REQUEST_SSCAN:          -REQUEST_COLLECTION_SCAN_MIN_SIZE,
+	REQUEST_ZSCAN:          -REQUEST_COLLECTION_SCAN_MIN_SIZE,
+	REQUEST_RENAME:         REQUEST_RENAME_EXPECTED_SIZE,
+	REQUEST_RENAMENX:       REQUEST_RENAME_EXPECTED_SIZE,
+	REQUEST_COPY:           -REQUEST_COPY_MIN_SIZE,
+	REQUEST_MOVE:           REQUEST_MOVE_EXPECTED_SIZE,
+	REQUEST_SELECT:         REQUEST_SELECT_EXPECTED_SIZE,
+	REQUEST_FLUSHDB:        -REQUEST_FLUSH_MIN_SIZE,
+	REQUEST_FLUSHALL:       -REQUEST_FLUSH_MIN_SIZE,
+	REQUEST_SWAPDB:         REQUEST_SWAPDB_EXPECTED_SIZE,
+	REQUEST_MULTI:          REQUEST_MULTI_EXPECTED_SIZE,
+	REQUEST_EXEC:           REQUEST_EXEC_EXPECTED_SIZE,
+	REQUEST_DISCARD:        REQUEST_DISCARD_EXPECTED_SIZE,
+	REQUEST_WATCH:          -REQUEST_WATCH_MIN_SIZE,
+	REQUEST_UNWATCH:        REQUEST_UNWATCH_EXPECTED_SIZE,
+	REQUEST_SUBSCRIBE:      -REQUEST_SUBSCRIBE_MIN_SIZE,
+	REQUEST_UNSUBSCRIBE:    -REQUEST_UNSUBSCRIBE_MIN_SIZE,
+	REQUEST_PSUBSCRIBE:     -REQUEST_SUBSCRIBE_MIN_SIZE,
+	REQUEST_PUNSUBSCRIBE:   -REQUEST_UNSUBSCRIBE_MIN_SIZE,
+	REQUEST_PUBLISH:        REQUEST_PUBLISH_EXPECTED_SIZE,
+	REQUEST_PUBSUB:         -REQUEST_PUBSUB_MIN_SIZE,
+	REQUEST_SSUBSCRIBE:     -REQUEST_SUBSCRIBE_MIN_SIZE,
+	REQUEST_SUNSUBSCRIBE:   -REQUEST_UNSUBSCRIBE_MIN_SIZE,
+	REQUEST_SPUBLISH:       REQUEST_PUBLISH_EXPECTED_SIZE,
+	REQUEST_CONFIG:         -REQUEST_CONFIG_MIN_SIZE,
+	REQUEST_XADD:           -REQUEST_XADD_MIN_SIZE,
+	REQUEST_XRANGE:         -REQUEST_XRANGE_MIN_SIZE,
+	REQUEST_XREVRANGE:      -REQUEST_XRANGE_MIN_SIZE,
+	REQUEST_XREAD:          -REQUEST_XREAD_MIN_SIZE,
+	REQUEST_XLEN:           REQUEST_XLEN_EXPECTED_SIZE,
+	REQUEST_XDEL:           -REQUEST_XDEL_MIN_SIZE,
+	REQUEST_XTRIM:          -REQUEST_XTRIM_MIN_SIZE,
+	REQUEST_XGROUP:         -REQUEST_XGROUP_MIN_SIZE,
+	REQUEST_XREADGROUP:     -REQUEST_XREADGROUP_MIN_SIZE,
+	REQUEST_XACK:           -REQUEST_XACK_MIN_SIZE,
+	REQUEST_XPENDING:       -REQUEST_XPENDING_MIN_SIZE,
+	REQUEST_XCLAIM:         -REQUEST_XCLAIM_MIN_SIZE,
+	REQUEST_XAUTOCLAIM:     -REQUEST_XAUTOCLAIM_MIN_SIZE,
+	REQUEST_XINFO:          -REQUEST_XINFO_MIN_SIZE,
+	REQUEST_SETBIT:         REQUEST_SETBIT_EXPECTED_SIZE,
+	REQUEST_GETBIT:         REQUEST_GETBIT_EXPECTED_SIZE,
+	REQUEST_BITCOUNT:       -REQUEST_BITCOUNT_MIN_SIZE,
+	REQUEST_BITPOS:         -REQUEST_BITPOS_MIN_SIZE,
+	REQUEST_BITOP:          -REQUEST_BITOP_MIN_SIZE,
+	REQUEST_BITFIELD:       -REQUEST_BITFIELD_MIN_SIZE,
+	REQUEST_BITFIELD_RO:    -REQUEST_BITFIELD_MIN_SIZE,
+	REQUEST_PFADD:          -REQUEST_PFADD_MIN_SIZE,
+	REQUEST_PFCOUNT:        -REQUEST_PFCOUNT_MIN_SIZE,
+	REQUEST_PFMERGE:        -REQUEST_PFMERGE_MIN_SIZE,
+	REQUEST_GEOADD:         -REQUEST_GEOADD_MIN_SIZE,
+	REQUEST_GEOPOS:         -REQUEST_GEOPOS_MIN_SIZE,
+	REQUEST_GEODIST:        -REQUEST_GEODIST_MIN_SIZE,
+	REQUEST_GEOHASH:        -REQUEST_GEOHASH_MIN_SIZE,
+	REQUEST_GEOSEARCH:      -REQUEST_GEOSEARCH_MIN_SIZE,
+	REQUEST_GEOSEARCHSTORE: -REQUEST_GEOSEARCHSTORE_MIN_SIZE,
+	REQUEST_DUMP:           REQUEST_DUMP_EXPECTED_SIZE,
+	REQUEST_RESTORE:        -REQUEST_RESTORE_MIN_SIZE,
+	REQUEST_BGREWRITEAOF:   REQUEST_BGREWRITEAOF_EXPECTED_SIZE,
+	REQUEST_BGSAVE:         REQUEST_BGSAVE_EXPECTED_SIZE,
+	REQUEST_LASTSAVE:       REQUEST_LASTSAVE_EXPECTED_SIZE,
+	REQUEST_INFO:           -REQUEST_INFO_MIN_SIZE,
+}
 
 const REQUEST_INDEX = 0
 const REQUEST_ECHO_VALUE = 1
@@ -40,6 +263,203 @@ const REQUEST_LPUSH_KEY_INDEX = 1
 const REQUEST_LPUSH_VALUE_INDEX = 2
 const REQUEST_RPUSH_KEY_INDEX = 1
 const REQUEST_RPUSH_VALUE_INDEX = 2
+const REQUEST_OBJECT_SUBCOMMAND_INDEX = 1
+const REQUEST_OBJECT_KEY_INDEX = 2
+const REQUEST_KEYS_PATTERN_INDEX = 1
+const REQUEST_SCAN_CURSOR_INDEX = 1
+const REQUEST_SCAN_OPTIONS_INDEX = 2
+const REQUEST_TYPE_KEY_INDEX = 1
+const REQUEST_COLLECTION_SCAN_KEY_INDEX = 1
+const REQUEST_COLLECTION_SCAN_CURSOR_INDEX = 2
+const REQUEST_COLLECTION_SCAN_OPTIONS_INDEX = 3
+const REQUEST_RENAME_SRC_KEY_INDEX = 1
+const REQUEST_RENAME_DST_KEY_INDEX = 2
+const REQUEST_COPY_SRC_KEY_INDEX = 1
+const REQUEST_COPY_DST_KEY_INDEX = 2
+const REQUEST_COPY_OPTIONS_INDEX = 3
+const REQUEST_MOVE_KEY_INDEX = 1
+const REQUEST_MOVE_DB_INDEX = 2
+const REQUEST_SELECT_DB_INDEX = 1
+const REQUEST_FLUSH_MODE_INDEX = 1
+const REQUEST_SWAPDB_DB1_INDEX = 1
+const REQUEST_SWAPDB_DB2_INDEX = 2
+const REQUEST_WATCH_FIRST_KEY_INDEX = 1
+const REQUEST_SUBSCRIBE_FIRST_CHANNEL_INDEX = 1
+const REQUEST_UNSUBSCRIBE_FIRST_CHANNEL_INDEX = 1
+const REQUEST_PUBLISH_CHANNEL_INDEX = 1
+const REQUEST_PUBLISH_MESSAGE_INDEX = 2
+const REQUEST_PUBSUB_SUBCOMMAND_INDEX = 1
+const REQUEST_PUBSUB_ARGUMENTS_INDEX = 2
+const REQUEST_CONFIG_SUBCOMMAND_INDEX = 1
+const REQUEST_CONFIG_ARGUMENTS_INDEX = 2
+const REQUEST_XADD_KEY_INDEX = 1
+const REQUEST_XADD_OPTIONS_INDEX = 2
+const REQUEST_XRANGE_KEY_INDEX = 1
+const REQUEST_XRANGE_START_INDEX = 2
+const REQUEST_XRANGE_END_INDEX = 3
+const REQUEST_XRANGE_OPTIONS_INDEX = 4
+const REQUEST_XREAD_OPTIONS_INDEX = 1
+const REQUEST_XLEN_KEY_INDEX = 1
+const REQUEST_XDEL_KEY_INDEX = 1
+const REQUEST_XDEL_FIRST_ID_INDEX = 2
+const REQUEST_XTRIM_KEY_INDEX = 1
+const REQUEST_XTRIM_STRATEGY_INDEX = 2
+const REQUEST_XGROUP_SUBCOMMAND_INDEX = 1
+const REQUEST_XGROUP_KEY_INDEX = 2
+const REQUEST_XGROUP_GROUP_INDEX = 3
+const REQUEST_XGROUP_ID_INDEX = 4
+const REQUEST_XGROUP_CONSUMER_INDEX = 4
+const REQUEST_XGROUP_OPTIONS_INDEX = 5
+const REQUEST_XREADGROUP_GROUP_OPTION_INDEX = 1
+const REQUEST_XREADGROUP_GROUP_INDEX = 2
+const REQUEST_XREADGROUP_CONSUMER_INDEX = 3
+const REQUEST_XREADGROUP_OPTIONS_INDEX = 4
+const REQUEST_XACK_KEY_INDEX = 1
+const REQUEST_XACK_GROUP_INDEX = 2
+const REQUEST_XACK_FIRST_ID_INDEX = 3
+const REQUEST_XPENDING_KEY_INDEX = 1
+const REQUEST_XPENDING_GROUP_INDEX = 2
+const REQUEST_XPENDING_OPTIONS_INDEX = 3
+const REQUEST_XCLAIM_KEY_INDEX = 1
+const REQUEST_XCLAIM_GROUP_INDEX = 2
+const REQUEST_XCLAIM_CONSUMER_INDEX = 3
+const REQUEST_XCLAIM_MIN_IDLE_INDEX = 4
+const REQUEST_XCLAIM_ARGUMENTS_INDEX = 5
+const REQUEST_XAUTOCLAIM_KEY_INDEX = 1
+const REQUEST_XAUTOCLAIM_GROUP_INDEX = 2
+const REQUEST_XAUTOCLAIM_CONSUMER_INDEX = 3
+const REQUEST_XAUTOCLAIM_MIN_IDLE_INDEX = 4
+const REQUEST_XAUTOCLAIM_START_INDEX = 5
+const REQUEST_XAUTOCLAIM_OPTIONS_INDEX = 6
+const REQUEST_XINFO_SUBCOMMAND_INDEX = 1
+const REQUEST_XINFO_KEY_INDEX = 2
+const REQUEST_XINFO_GROUP_INDEX = 3
+const REQUEST_SETBIT_KEY_INDEX = 1
+const REQUEST_SETBIT_OFFSET_INDEX = 2
+const REQUEST_SETBIT_VALUE_INDEX = 3
+const REQUEST_GETBIT_KEY_INDEX = 1
+const REQUEST_GETBIT_OFFSET_INDEX = 2
+const REQUEST_BITCOUNT_KEY_INDEX = 1
+const REQUEST_BITCOUNT_RANGE_INDEX = 2
+const REQUEST_BITPOS_KEY_INDEX = 1
+const REQUEST_BITPOS_BIT_INDEX = 2
+const REQUEST_BITPOS_RANGE_INDEX = 3
+const REQUEST_BITOP_OPERATION_INDEX = 1
+const REQUEST_BITOP_DEST_KEY_INDEX = 2
+const REQUEST_BITOP_FIRST_KEY_INDEX = 3
+const REQUEST_BITFIELD_KEY_INDEX = 1
+const REQUEST_BITFIELD_OPERATIONS_INDEX = 2
+const REQUEST_PFADD_KEY_INDEX = 1
+const REQUEST_PFADD_FIRST_ELEMENT_INDEX = 2
+const REQUEST_PFCOUNT_FIRST_KEY_INDEX = 1
+const REQUEST_PFMERGE_DEST_KEY_INDEX = 1
+const REQUEST_PFMERGE_FIRST_KEY_INDEX = 2
+const REQUEST_GEOADD_KEY_INDEX = 1
+const REQUEST_GEOADD_OPTIONS_INDEX = 2
+const REQUEST_GEOPOS_KEY_INDEX = 1
+const REQUEST_GEOPOS_FIRST_MEMBER_INDEX = 2
+const REQUEST_GEODIST_KEY_INDEX = 1
+const REQUEST_GEODIST_MEMBER1_INDEX = 2
+const REQUEST_GEODIST_MEMBER2_INDEX = 3
+const REQUEST_GEODIST_UNIT_INDEX = 4
+const REQUEST_GEOHASH_KEY_INDEX = 1
+const REQUEST_GEOHASH_FIRST_MEMBER_INDEX = 2
+const REQUEST_GEOSEARCH_KEY_INDEX = 1
+const REQUEST_GEOSEARCH_OPTIONS_INDEX = 2
+const REQUEST_GEOSEARCHSTORE_DEST_KEY_INDEX = 1
+const REQUEST_GEOSEARCHSTORE_SRC_KEY_INDEX = 2
+const REQUEST_GEOSEARCHSTORE_OPTIONS_INDEX = 3
+const REQUEST_DUMP_KEY_INDEX = 1
+const REQUEST_RESTORE_KEY_INDEX = 1
+const REQUEST_RESTORE_TTL_INDEX = 2
+const REQUEST_RESTORE_PAYLOAD_INDEX = 3
+const REQUEST_RESTORE_OPTIONS_INDEX = 4
+const REQUEST_INFO_FIRST_SECTION_INDEX = 1
+
+const OBJECT_SUBCOMMAND_ENCODING = "ENCODING"
+
+const SCAN_OPTION_MATCH = "MATCH"
+const SCAN_OPTION_COUNT = "COUNT"
+const SCAN_OPTION_TYPE = "TYPE"
+const SCAN_DEFAULT_COUNT = 10
+
+const COPY_OPTION_DB = "DB"
+const COPY_OPTION_REPLACE = "REPLACE"
+
+const RESTORE_OPTION_REPLACE = "REPLACE"
+const RESTORE_OPTION_ABSTTL = "ABSTTL"
+
+const PUBSUB_SUBCOMMAND_CHANNELS = "CHANNELS"
+const PUBSUB_SUBCOMMAND_NUMSUB = "NUMSUB"
+const PUBSUB_SUBCOMMAND_NUMPAT = "NUMPAT"
+const PUBSUB_SUBCOMMAND_SHARDCHANNELS = "SHARDCHANNELS"
+const PUBSUB_SUBCOMMAND_SHARDNUMSUB = "SHARDNUMSUB"
+
+const CONFIG_SUBCOMMAND_GET = "GET"
+const CONFIG_SUBCOMMAND_SET = "SET"
+
+const STREAM_OPTION_NOMKSTREAM = "NOMKSTREAM"
+const STREAM_OPTION_MAXLEN = "MAXLEN"
+const STREAM_OPTION_MINID = "MINID"
+const STREAM_OPTION_LIMIT = "LIMIT"
+const STREAM_OPTION_COUNT = "COUNT"
+const STREAM_OPTION_BLOCK = "BLOCK"
+const STREAM_OPTION_STREAMS = "STREAMS"
+const STREAM_TRIM_EXACT = "="
+const STREAM_TRIM_APPROXIMATE = "~"
+const STREAM_RANGE_MIN = "-"
+const STREAM_RANGE_MAX = "+"
+const STREAM_RANGE_EXCLUSIVE = "("
+const STREAM_READ_LAST = "$"
+const STREAM_READ_NEW = ">"
+const STREAM_OPTION_MKSTREAM = "MKSTREAM"
+const STREAM_OPTION_GROUP = "GROUP"
+const STREAM_OPTION_NOACK = "NOACK"
+const STREAM_OPTION_IDLE = "IDLE"
+const STREAM_OPTION_TIME = "TIME"
+const STREAM_OPTION_RETRYCOUNT = "RETRYCOUNT"
+const STREAM_OPTION_FORCE = "FORCE"
+const STREAM_OPTION_JUSTID = "JUSTID"
+const STREAM_OPTION_LASTID = "LASTID"
+const STREAM_AUTOCLAIM_DEFAULT_COUNT = 100
+
+const XGROUP_SUBCOMMAND_CREATE = "CREATE"
+const XGROUP_SUBCOMMAND_SETID = "SETID"
+const XGROUP_SUBCOMMAND_DESTROY = "DESTROY"
+const XGROUP_SUBCOMMAND_CREATECONSUMER = "CREATECONSUMER"
+const XGROUP_SUBCOMMAND_DELCONSUMER = "DELCONSUMER"
+
+const XINFO_SUBCOMMAND_STREAM = "STREAM"
+const XINFO_SUBCOMMAND_GROUPS = "GROUPS"
+const XINFO_SUBCOMMAND_CONSUMERS = "CONSUMERS"
+
+const BIT_RANGE_UNIT_BYTE = "BYTE"
+const BIT_RANGE_UNIT_BIT = "BIT"
+
+const BITFIELD_OFFSET_MULTIPLIER = "#"
+
+const GEO_OPTION_NX = "NX"
+const GEO_OPTION_XX = "XX"
+const GEO_OPTION_CH = "CH"
+const GEO_OPTION_FROMMEMBER = "FROMMEMBER"
+const GEO_OPTION_FROMLONLAT = "FROMLONLAT"
+const GEO_OPTION_BYRADIUS = "BYRADIUS"
+const GEO_OPTION_BYBOX = "BYBOX"
+const GEO_OPTION_ASC = "ASC"
+const GEO_OPTION_DESC = "DESC"
+const GEO_OPTION_COUNT = "COUNT"
+const GEO_OPTION_ANY = "ANY"
+const GEO_OPTION_WITHCOORD = "WITHCOORD"
+const GEO_OPTION_WITHDIST = "WITHDIST"
+const GEO_OPTION_WITHHASH = "WITHHASH"
+const GEO_OPTION_STOREDIST = "STOREDIST"
+const GEO_UNIT_METERS = "M"
+const GEO_UNIT_KILOMETERS = "KM"
+const GEO_UNIT_FEET = "FT"
+const GEO_UNIT_MILES = "MI"
+
+const FLUSH_MODE_ASYNC = "ASYNC"
+const FLUSH_MODE_SYNC = "SYNC"
 
 const EXPIRATION_MODE_EXPIRE_SECONDS = "EX"
 const EXPIRATION_MODE_EXPIRE_MILLISECONDS = "PX"
@@ -49,6 +469,9 @@ const EXPIRATION_MODE_TIMESTAMP_MILLISECONDS = "PXAT"
 const REQUEST_PING_RSP = "PONG"
 const REQUEST_RESULT_OK = "OK"
 const REQUEST_RESULT_FAIL = "FAILED"
+const REQUEST_RESULT_QUEUED = "QUEUED"
+const REQUEST_RESULT_AOF_REWRITE_STARTED = "Background append only file rewriting started"
+const REQUEST_RESULT_BGSAVE_STARTED = "Background saving started"
 const REQUEST_ERROR_FAILED_DESERIALIZATION = "ERR FAILED-DESERIALIZING"
 const REQUEST_ERROR_UNEXPECTED_ARG_TYPE = "ERR UNEXPECTED-ARGUMENT-TYPE"
 const REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER = "ERR INVALID-ARGUMENTS-NUMBER"
@@ -57,3 +480,56 @@ const REQUEST_ERROR_UNRECOGNIZED_TIMEOUT_MODE = "ERR UNRECOGNIZED-TIMEOUT-MODE"
 const REQUEST_ERROR_INVALID_TIMEOUT_VALUE = "ERR INVALID-TIMEOUT-VALUE"
 const REQUEST_ERROR_VALUE_NOT_NUMERIC_OR_MAX_REACHED = "ERR VALUE-NOT-NUMERIC-OR-MAX-REACHED"
 const REQUEST_ERROR_VALUE_NOT_A_LIST = "ERR VALUE-NOT-A-LIST"
+const REQUEST_ERROR_UNRECOGNIZED_SUBCOMMAND = "ERR UNRECOGNIZED-SUBCOMMAND"
+const REQUEST_ERROR_INVALID_CURSOR = "ERR INVALID-CURSOR"
+const REQUEST_ERROR_SYNTAX = "ERR SYNTAX-ERROR"
+const REQUEST_ERROR_WRONG_TYPE = "ERR VALUE-WRONG-TYPE"
+const REQUEST_ERROR_NO_SUCH_KEY = "ERR NO-SUCH-KEY"
+const REQUEST_ERROR_DB_INDEX_OUT_OF_RANGE = "ERR DB-INDEX-OUT-OF-RANGE"
+const REQUEST_ERROR_INVALID_DB_INDEX = "ERR INVALID-DB-INDEX"
+const REQUEST_ERROR_SAME_SOURCE_AND_DESTINATION = "ERR SAME-SOURCE-AND-DESTINATION"
+const REQUEST_ERROR_NESTED_MULTI = "ERR MULTI-CALLS-CAN-NOT-BE-NESTED"
+const REQUEST_ERROR_EXEC_WITHOUT_MULTI = "ERR EXEC-WITHOUT-MULTI"
+const REQUEST_ERROR_DISCARD_WITHOUT_MULTI = "ERR DISCARD-WITHOUT-MULTI"
+const REQUEST_ERROR_EXEC_ABORTED = "EXECABORT TRANSACTION-DISCARDED-BECAUSE-OF-PREVIOUS-ERRORS"
+const REQUEST_ERROR_WATCH_INSIDE_MULTI = "ERR WATCH-INSIDE-MULTI-IS-NOT-ALLOWED"
+const REQUEST_ERROR_SUBSCRIBE_INSIDE_MULTI = "ERR SUBSCRIBE-INSIDE-MULTI-IS-NOT-ALLOWED"
+const REQUEST_ERROR_NOT_ALLOWED_IN_SUBSCRIBED_MODE = "ERR ONLY-SUBSCRIBE-UNSUBSCRIBE-AND-PING-ALLOWED-IN-THIS-CONTEXT"
+const REQUEST_ERROR_UNKNOWN_CONFIG_PARAMETER = "ERR UNKNOWN-CONFIG-PARAMETER"
+const REQUEST_ERROR_INVALID_CONFIG_VALUE = "ERR INVALID-CONFIG-VALUE"
+const REQUEST_ERROR_IMMUTABLE_CONFIG_PARAMETER = "ERR IMMUTABLE-CONFIG-PARAMETER"
+const REQUEST_ERROR_CROSS_SLOT = "CROSSSLOT KEYS-IN-REQUEST-DONT-HASH-TO-THE-SAME-SLOT"
+const REQUEST_ERROR_INVALID_STREAM_ID = "ERR INVALID-STREAM-ID"
+const REQUEST_ERROR_STREAM_ID_ZERO = "ERR STREAM-ID-MUST-BE-GREATER-THAN-0-0"
+const REQUEST_ERROR_STREAM_ID_TOO_SMALL = "ERR STREAM-ID-EQUAL-OR-SMALLER-THAN-TOP-ITEM"
+const REQUEST_ERROR_UNBALANCED_XREAD_STREAMS = "ERR UNBALANCED-XREAD-LIST-OF-STREAMS"
+const REQUEST_ERROR_XGROUP_KEY_MUST_EXIST = "ERR XGROUP-REQUIRES-THE-KEY-TO-EXIST"
+const REQUEST_ERROR_BUSY_GROUP = "BUSYGROUP CONSUMER-GROUP-NAME-ALREADY-EXISTS"
+const REQUEST_ERROR_NO_GROUP = "NOGROUP NO-SUCH-KEY-OR-CONSUMER-GROUP"
+const REQUEST_ERROR_XREADGROUP_LAST_ID = "ERR $-CAN-NOT-BE-USED-WITH-XREADGROUP"
+const REQUEST_ERROR_BIT_OFFSET_OUT_OF_RANGE = "ERR BIT-OFFSET-IS-NOT-AN-INTEGER-OR-OUT-OF-RANGE"
+const REQUEST_ERROR_BIT_OUT_OF_RANGE = "ERR BIT-IS-NOT-AN-INTEGER-OR-OUT-OF-RANGE"
+const REQUEST_ERROR_VALUE_NOT_AN_INTEGER = "ERR VALUE-IS-NOT-AN-INTEGER-OR-OUT-OF-RANGE"
+const REQUEST_ERROR_BITOP_NOT_SINGLE_SOURCE = "ERR BITOP-NOT-MUST-BE-CALLED-WITH-A-SINGLE-SOURCE-KEY"
+const REQUEST_ERROR_INVALID_BITFIELD_TYPE = "ERR INVALID-BITFIELD-TYPE"
+const REQUEST_ERROR_INVALID_OVERFLOW_TYPE = "ERR INVALID-OVERFLOW-TYPE"
+const REQUEST_ERROR_BITFIELD_RO_ONLY_GET = "ERR BITFIELD_RO-ONLY-SUPPORTS-THE-GET-SUBCOMMAND"
+const REQUEST_ERROR_VALUE_NOT_A_FLOAT = "ERR VALUE-IS-NOT-A-VALID-FLOAT"
+const REQUEST_ERROR_INVALID_LONGITUDE_LATITUDE = "ERR INVALID-LONGITUDE-LATITUDE-PAIR"
+const REQUEST_ERROR_NX_AND_XX = "ERR XX-AND-NX-OPTIONS-AT-THE-SAME-TIME-ARE-NOT-COMPATIBLE"
+const REQUEST_ERROR_UNSUPPORTED_GEO_UNIT = "ERR UNSUPPORTED-UNIT-PROVIDED-PLEASE-USE-M-KM-FT-MI"
+const REQUEST_ERROR_GEO_MEMBER_NOT_FOUND = "ERR COULD-NOT-DECODE-REQUESTED-ZSET-MEMBER"
+const REQUEST_ERROR_GEOSEARCH_ONE_CENTER = "ERR EXACTLY-ONE-OF-FROMMEMBER-OR-FROMLONLAT-CAN-BE-SPECIFIED"
+const REQUEST_ERROR_GEOSEARCH_ONE_SHAPE = "ERR EXACTLY-ONE-OF-BYRADIUS-AND-BYBOX-CAN-BE-SPECIFIED"
+const REQUEST_ERROR_GEO_NEGATIVE_SIZE = "ERR RADIUS-WIDTH-AND-HEIGHT-CAN-NOT-BE-NEGATIVE"
+const REQUEST_ERROR_COUNT_NOT_POSITIVE = "ERR COUNT-MUST-BE-GREATER-THAN-0"
+const REQUEST_ERROR_ANY_REQUIRES_COUNT = "ERR ANY-REQUIRES-COUNT"
+const REQUEST_ERROR_AOF_ALREADY_ENABLED = "ERR APPEND-ONLY-FILE-ALREADY-ENABLED"
+const REQUEST_ERROR_AOF_DISABLED = "ERR APPEND-ONLY-FILE-DISABLED"
+const REQUEST_ERROR_AOF_REWRITE_IN_PROGRESS = "ERR BACKGROUND-APPEND-ONLY-FILE-REWRITING-ALREADY-IN-PROGRESS"
+const REQUEST_ERROR_AOF_WRITE_FAILED = "MISCONF ERRORS-WRITING-TO-THE-APPEND-ONLY-FILE"
+const REQUEST_ERROR_BGSAVE_IN_PROGRESS = "ERR BACKGROUND-SAVE-ALREADY-IN-PROGRESS"
+const REQUEST_ERROR_BUSY_KEY = "BUSYKEY TARGET-KEY-NAME-ALREADY-EXISTS"
+const REQUEST_ERROR_INVALID_DUMP_PAYLOAD = "ERR DUMP-PAYLOAD-VERSION-OR-CHECKSUM-ARE-WRONG"
+const REQUEST_ERROR_INVALID_TTL = "ERR INVALID-TTL-VALUE-MUST-BE-GREATER-OR-EQUAL-TO-0"
+const REQUEST_ERROR_LOADING = "LOADING XREDIS-IS-LOADING-THE-DATASET-IN-MEMORY"
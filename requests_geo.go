@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Meters in each unit of distance
+var GEO_UNITS = map[string]float64{
+	GEO_UNIT_METERS:     1,
+	GEO_UNIT_KILOMETERS: 1000,
+	GEO_UNIT_FEET:       0.3048,
+	GEO_UNIT_MILES:      1609.34,
+}
+
+// geoSearchOptions are the options of GEOSEARCH and GEOSEARCHSTORE, the
+// reply options being only accepted by the former and STOREDIST by the
+// latter.
+type geoSearchOptions struct {
+	query     GeoQuery
+	withCoord bool
+	withDist  bool
+	withHash  bool
+	storeDist bool
+}
+
+func handleGeoAddRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_GEOADD_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_GEOADD_KEY_INDEX].(RespString).Str
+	arguments := requestArguments(requestData, REQUEST_GEOADD_OPTIONS_INDEX)
+
+	var options GeoAddOptions
+	i := 0
+	for ; i < len(arguments); i++ {
+		switch strings.ToUpper(arguments[i]) {
+		case GEO_OPTION_NX:
+			options.onlyNew = true
+			continue
+		case GEO_OPTION_XX:
+			options.onlyExisting = true
+			continue
+		case GEO_OPTION_CH:
+			options.countChanged = true
+			continue
+		}
+		break
+	}
+	if options.onlyNew && options.onlyExisting {
+		return RespError{REQUEST_ERROR_NX_AND_XX}
+	}
+	arguments = arguments[i:]
+	if len(arguments) == 0 || len(arguments)%3 != 0 {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+
+	members := make([]GeoMember, 0, len(arguments)/3)
+	for j := 0; j < len(arguments); j += 3 {
+		point, err := parseGeoPoint(arguments[j], arguments[j+1])
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		members = append(members, GeoMember{arguments[j+2], point})
+	}
+	count, err := xredis.GeoAdd(key, members, options)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(count)}
+}
+
+func handleGeoPosRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_GEOPOS_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_GEOPOS_KEY_INDEX].(RespString).Str
+	positions, err := xredis.GeoPos(key, requestArguments(requestData, REQUEST_GEOPOS_FIRST_MEMBER_INDEX))
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	elements := make([]RespDataType, 0, len(positions))
+	for _, position := range positions {
+		if position == nil {
+			elements = append(elements, RespNil{})
+			continue
+		}
+		elements = append(elements, geoCoordinatesReply(*position))
+	}
+	return RespArray{elements}
+}
+
+func handleGeoDistRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_GEODIST_MIN_SIZE || len(requestData.Elements) > REQUEST_GEODIST_MAX_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_GEODIST_KEY_INDEX].(RespString).Str
+	member1 := requestData.Elements[REQUEST_GEODIST_MEMBER1_INDEX].(RespString).Str
+	member2 := requestData.Elements[REQUEST_GEODIST_MEMBER2_INDEX].(RespString).Str
+	unit := GEO_UNITS[GEO_UNIT_METERS]
+	if len(requestData.Elements) > REQUEST_GEODIST_UNIT_INDEX {
+		var err error
+		if unit, err = parseGeoUnit(requestData.Elements[REQUEST_GEODIST_UNIT_INDEX].(RespString).Str); err != nil {
+			return RespError{err.Error()}
+		}
+	}
+
+	distance, exists, err := xredis.GeoDist(key, member1, member2)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	if !exists {
+		return RespNil{}
+	}
+	return RespString{formatGeoDistance(distance / unit)}
+}
+
+func handleGeoHashRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_GEOHASH_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_GEOHASH_KEY_INDEX].(RespString).Str
+	positions, err := xredis.GeoPos(key, requestArguments(requestData, REQUEST_GEOHASH_FIRST_MEMBER_INDEX))
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	elements := make([]RespDataType, 0, len(positions))
+	for _, position := range positions {
+		if position == nil {
+			elements = append(elements, RespNil{})
+			continue
+		}
+		elements = append(elements, RespString{position.geohash()})
+	}
+	return RespArray{elements}
+}
+
+func handleGeoSearchRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_GEOSEARCH_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_GEOSEARCH_KEY_INDEX].(RespString).Str
+	options, err := parseGeoSearchOptions(requestArguments(requestData, REQUEST_GEOSEARCH_OPTIONS_INDEX), false)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+
+	results, err := xredis.GeoSearch(key, options.query)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	elements := make([]RespDataType, 0, len(results))
+	for _, result := range results {
+		if !options.withDist && !options.withHash && !options.withCoord {
+			elements = append(elements, RespString{result.member})
+			continue
+		}
+		entry := []RespDataType{RespString{result.member}}
+		if options.withDist {
+			entry = append(entry, RespString{formatGeoDistance(result.distance)})
+		}
+		if options.withHash {
+			entry = append(entry, RespInt{int64(result.hash)})
+		}
+		if options.withCoord {
+			entry = append(entry, geoCoordinatesReply(result.point))
+		}
+		elements = append(elements, RespArray{entry})
+	}
+	return RespArray{elements}
+}
+
+func handleGeoSearchStoreRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_GEOSEARCHSTORE_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	destKey := requestData.Elements[REQUEST_GEOSEARCHSTORE_DEST_KEY_INDEX].(RespString).Str
+	srcKey := requestData.Elements[REQUEST_GEOSEARCHSTORE_SRC_KEY_INDEX].(RespString).Str
+	options, err := parseGeoSearchOptions(requestArguments(requestData, REQUEST_GEOSEARCHSTORE_OPTIONS_INDEX), true)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+
+	count, err := xredis.GeoSearchStore(destKey, srcKey, options.query, options.storeDist)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(count)}
+}
+
+// parseGeoSearchOptions parses FROMMEMBER member | FROMLONLAT longitude
+// latitude, BYRADIUS radius unit | BYBOX width height unit, and the
+// optional ASC | DESC, COUNT count [ANY] and the reply or store options.
+func parseGeoSearchOptions(arguments []string, store bool) (geoSearchOptions, error) {
+	var options geoSearchOptions
+	hasCenter, hasShape := false, false
+	for i := 0; i < len(arguments); i++ {
+		option := strings.ToUpper(arguments[i])
+		switch {
+		case option == GEO_OPTION_FROMMEMBER && i+1 < len(arguments):
+			if hasCenter {
+				return options, errors.New(REQUEST_ERROR_GEOSEARCH_ONE_CENTER)
+			}
+			options.query.fromMember = arguments[i+1]
+			hasCenter = true
+			i++
+		case option == GEO_OPTION_FROMLONLAT && i+2 < len(arguments):
+			if hasCenter {
+				return options, errors.New(REQUEST_ERROR_GEOSEARCH_ONE_CENTER)
+			}
+			point, err := parseGeoPoint(arguments[i+1], arguments[i+2])
+			if err != nil {
+				return options, err
+			}
+			options.query.from = &point
+			hasCenter = true
+			i += 2
+		case option == GEO_OPTION_BYRADIUS && i+2 < len(arguments):
+			if hasShape {
+				return options, errors.New(REQUEST_ERROR_GEOSEARCH_ONE_SHAPE)
+			}
+			radius, err := parseGeoSize(arguments[i+1])
+			if err != nil {
+				return options, err
+			}
+			unit, err := parseGeoUnit(arguments[i+2])
+			if err != nil {
+				return options, err
+			}
+			options.query.shape = GeoShape{radius: radius, unit: unit}
+			hasShape = true
+			i += 2
+		case option == GEO_OPTION_BYBOX && i+3 < len(arguments):
+			if hasShape {
+				return options, errors.New(REQUEST_ERROR_GEOSEARCH_ONE_SHAPE)
+			}
+			width, err := parseGeoSize(arguments[i+1])
+			if err != nil {
+				return options, err
+			}
+			height, err := parseGeoSize(arguments[i+2])
+			if err != nil {
+				return options, err
+			}
+			unit, err := parseGeoUnit(arguments[i+3])
+			if err != nil {
+				return options, err
+			}
+			options.query.shape = GeoShape{byBox: true, width: width, height: height, unit: unit}
+			hasShape = true
+			i += 3
+		case option == GEO_OPTION_ASC:
+			options.query.sort = GEO_SORT_ASC
+		case option == GEO_OPTION_DESC:
+			options.query.sort = GEO_SORT_DESC
+		case option == GEO_OPTION_COUNT && i+1 < len(arguments):
+			count, err := strconv.Atoi(arguments[i+1])
+			if err != nil {
+				return options, errors.New(REQUEST_ERROR_VALUE_NOT_AN_INTEGER)
+			}
+			if count <= 0 {
+				return options, errors.New(REQUEST_ERROR_COUNT_NOT_POSITIVE)
+			}
+			options.query.count = count
+			i++
+			if i+1 < len(arguments) && strings.ToUpper(arguments[i+1]) == GEO_OPTION_ANY {
+				options.query.any = true
+				i++
+			}
+		case option == GEO_OPTION_ANY:
+			return options, errors.New(REQUEST_ERROR_ANY_REQUIRES_COUNT)
+		case option == GEO_OPTION_WITHCOORD && !store:
+			options.withCoord = true
+		case option == GEO_OPTION_WITHDIST && !store:
+			options.withDist = true
+		case option == GEO_OPTION_WITHHASH && !store:
+			options.withHash = true
+		case option == GEO_OPTION_STOREDIST && store:
+			options.storeDist = true
+		default:
+			return options, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+	}
+	if !hasCenter {
+		return options, errors.New(REQUEST_ERROR_GEOSEARCH_ONE_CENTER)
+	}
+	if !hasShape {
+		return options, errors.New(REQUEST_ERROR_GEOSEARCH_ONE_SHAPE)
+	}
+	return options, nil
+}
+
+func parseGeoPoint(longitudeStr string, latitudeStr string) (GeoPoint, error) {
+	longitude, err := parseGeoFloat(longitudeStr)
+	if err != nil {
+		return GeoPoint{}, err
+	}
+	latitude, err := parseGeoFloat(latitudeStr)
+	if err != nil {
+		return GeoPoint{}, err
+	}
+	point := GeoPoint{longitude, latitude}
+	if !point.valid() {
+		return GeoPoint{}, errors.New(REQUEST_ERROR_INVALID_LONGITUDE_LATITUDE)
+	}
+	return point, nil
+}
+
+func parseGeoSize(str string) (float64, error) {
+	size, err := parseGeoFloat(str)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 {
+		return 0, errors.New(REQUEST_ERROR_GEO_NEGATIVE_SIZE)
+	}
+	return size, nil
+}
+
+func parseGeoFloat(str string) (float64, error) {
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(value) {
+		return 0, errors.New(REQUEST_ERROR_VALUE_NOT_A_FLOAT)
+	}
+	return value, nil
+}
+
+func parseGeoUnit(str string) (float64, error) {
+	unit, ok := GEO_UNITS[strings.ToUpper(str)]
+	if !ok {
+		return 0, errors.New(REQUEST_ERROR_UNSUPPORTED_GEO_UNIT)
+	}
+	return unit, nil
+}
+
+func geoCoordinatesReply(point GeoPoint) RespArray {
+	return RespArray{[]RespDataType{
+		RespString{strconv.FormatFloat(point.longitude, 'f', -1, 64)},
+		RespString{strconv.FormatFloat(point.latitude, 'f', -1, 64)},
+	}}
+}
+
+// formatGeoDistance formats distances with 4 decimals as in Redis
+func formatGeoDistance(distance float64) string {
+	return strconv.FormatFloat(distance, 'f', 4, 64)
+}
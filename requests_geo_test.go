@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoAddGeoPosGeoDistAndGeoHashRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	geoaddCommand := "*8\r\n$6\r\nGEOADD\r\n$6\r\nSicily\r\n$9\r\n13.361389\r\n$9\r\n38.115556\r\n$7\r\nPalermo\r\n$9\r\n15.087269\r\n$9\r\n37.502669\r\n$7\r\nCatania\r\n"
+	geoaddRsp := handleRequest(client, []byte(geoaddCommand))
+	assert.Equal(t, ":2\r\n", string(geoaddRsp))
+
+	invalidPairCommand := "*5\r\n$6\r\nGEOADD\r\n$6\r\nSicily\r\n$2\r\n13\r\n$2\r\n86\r\n$4\r\nPole\r\n"
+	invalidPairRsp := handleRequest(client, []byte(invalidPairCommand))
+	assert.Equal(t, "-ERR INVALID-LONGITUDE-LATITUDE-PAIR\r\n", string(invalidPairRsp))
+
+	nxXxCommand := "*7\r\n$6\r\nGEOADD\r\n$6\r\nSicily\r\n$2\r\nNX\r\n$2\r\nXX\r\n$2\r\n13\r\n$2\r\n38\r\n$7\r\nPalermo\r\n"
+	nxXxRsp := handleRequest(client, []byte(nxXxCommand))
+	assert.Equal(t, "-ERR XX-AND-NX-OPTIONS-AT-THE-SAME-TIME-ARE-NOT-COMPATIBLE\r\n", string(nxXxRsp))
+
+	geoposCommand := "*4\r\n$6\r\nGEOPOS\r\n$6\r\nSicily\r\n$7\r\nPalermo\r\n$7\r\nMissing\r\n"
+	geoposRsp := handleRequest(client, []byte(geoposCommand))
+	assert.Equal(t, "*2\r\n*2\r\n$18\r\n13.361389338970184\r\n$16\r\n38.1155563954963\r\n$-1\r\n", string(geoposRsp))
+
+	geodistCommand := "*5\r\n$7\r\nGEODIST\r\n$6\r\nSicily\r\n$7\r\nPalermo\r\n$7\r\nCatania\r\n$2\r\nkm\r\n"
+	geodistRsp := handleRequest(client, []byte(geodistCommand))
+	assert.Equal(t, "$8\r\n166.2742\r\n", string(geodistRsp))
+
+	invalidUnitCommand := "*5\r\n$7\r\nGEODIST\r\n$6\r\nSicily\r\n$7\r\nPalermo\r\n$7\r\nCatania\r\n$2\r\nyd\r\n"
+	invalidUnitRsp := handleRequest(client, []byte(invalidUnitCommand))
+	assert.Equal(t, "-ERR UNSUPPORTED-UNIT-PROVIDED-PLEASE-USE-M-KM-FT-MI\r\n", string(invalidUnitRsp))
+
+	geohashCommand := "*5\r\n$7\r\nGEOHASH\r\n$6\r\nSicily\r\n$7\r\nPalermo\r\n$7\r\nCatania\r\n$7\r\nMissing\r\n"
+	geohashRsp := handleRequest(client, []byte(geohashCommand))
+	assert.Equal(t, "*3\r\n$11\r\nsqc8b49rny0\r\n$11\r\nsqdtr74hyu0\r\n$-1\r\n", string(geohashRsp))
+}
+
+func TestGeoSearchAndGeoSearchStoreRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+	_ = handleRequest(client, []byte("*8\r\n$6\r\nGEOADD\r\n$6\r\nSicily\r\n$9\r\n13.361389\r\n$9\r\n38.115556\r\n$7\r\nPalermo\r\n$9\r\n15.087269\r\n$9\r\n37.502669\r\n$7\r\nCatania\r\n"))
+
+	geosearchCommand := "*10\r\n$9\r\nGEOSEARCH\r\n$6\r\nSicily\r\n$10\r\nFROMLONLAT\r\n$2\r\n15\r\n$2\r\n37\r\n$8\r\nBYRADIUS\r\n$3\r\n200\r\n$2\r\nkm\r\n$3\r\nASC\r\n$8\r\nWITHDIST\r\n"
+	geosearchRsp := handleRequest(client, []byte(geosearchCommand))
+	assert.Equal(t, "*2\r\n*2\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n*2\r\n$7\r\nPalermo\r\n$8\r\n190.4424\r\n", string(geosearchRsp))
+
+	geosearchBoxCommand := "*10\r\n$9\r\nGEOSEARCH\r\n$6\r\nSicily\r\n$10\r\nFROMLONLAT\r\n$2\r\n15\r\n$2\r\n37\r\n$5\r\nBYBOX\r\n$3\r\n200\r\n$3\r\n200\r\n$2\r\nkm\r\n$9\r\nWITHCOORD\r\n"
+	geosearchBoxRsp := handleRequest(client, []byte(geosearchBoxCommand))
+	assert.Equal(t, "*1\r\n*2\r\n$7\r\nCatania\r\n*2\r\n$18\r\n15.087267458438873\r\n$17\r\n37.50266842333162\r\n", string(geosearchBoxRsp))
+
+	missingShapeCommand := "*7\r\n$9\r\nGEOSEARCH\r\n$6\r\nSicily\r\n$10\r\nFROMMEMBER\r\n$7\r\nPalermo\r\n$5\r\nCOUNT\r\n$1\r\n1\r\n$3\r\nASC\r\n"
+	missingShapeRsp := handleRequest(client, []byte(missingShapeCommand))
+	assert.Equal(t, "-ERR EXACTLY-ONE-OF-BYRADIUS-AND-BYBOX-CAN-BE-SPECIFIED\r\n", string(missingShapeRsp))
+
+	missingMemberCommand := "*7\r\n$9\r\nGEOSEARCH\r\n$6\r\nSicily\r\n$10\r\nFROMMEMBER\r\n$4\r\nRome\r\n$8\r\nBYRADIUS\r\n$1\r\n1\r\n$1\r\nm\r\n"
+	missingMemberRsp := handleRequest(client, []byte(missingMemberCommand))
+	assert.Equal(t, "-ERR COULD-NOT-DECODE-REQUESTED-ZSET-MEMBER\r\n", string(missingMemberRsp))
+
+	geosearchstoreCommand := "*10\r\n$14\r\nGEOSEARCHSTORE\r\n$4\r\ndest\r\n$6\r\nSicily\r\n$10\r\nFROMLONLAT\r\n$2\r\n15\r\n$2\r\n37\r\n$8\r\nBYRADIUS\r\n$3\r\n100\r\n$2\r\nkm\r\n$9\r\nSTOREDIST\r\n"
+	geosearchstoreRsp := handleRequest(client, []byte(geosearchstoreCommand))
+	assert.Equal(t, ":1\r\n", string(geosearchstoreRsp))
+
+	withDistStoreCommand := "*10\r\n$14\r\nGEOSEARCHSTORE\r\n$4\r\ndest\r\n$6\r\nSicily\r\n$10\r\nFROMLONLAT\r\n$2\r\n15\r\n$2\r\n37\r\n$8\r\nBYRADIUS\r\n$3\r\n100\r\n$2\r\nkm\r\n$8\r\nWITHDIST\r\n"
+	withDistStoreRsp := handleRequest(client, []byte(withDistStoreCommand))
+	assert.Equal(t, "-ERR SYNTAX-ERROR\r\n", string(withDistStoreRsp))
+
+	zscanCommand := "*5\r\n$5\r\nZSCAN\r\n$4\r\ndest\r\n$1\r\n0\r\n$5\r\nMATCH\r\n$7\r\nCatania\r\n"
+	zscanRsp := handleRequest(client, []byte(zscanCommand))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*2\r\n$7\r\nCatania\r\n$17\r\n56.44125787015818\r\n", string(zscanRsp))
+}
@@ -0,0 +1,35 @@
+package main
+
+func handlePFAddRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_PFADD_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_PFADD_KEY_INDEX].(RespString).Str
+	changed, err := xredis.PFAdd(key, requestArguments(requestData, REQUEST_PFADD_FIRST_ELEMENT_INDEX))
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(bool2Int(changed))}
+}
+
+func handlePFCountRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_PFCOUNT_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	count, err := xredis.PFCount(requestArguments(requestData, REQUEST_PFCOUNT_FIRST_KEY_INDEX))
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(count)}
+}
+
+func handlePFMergeRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_PFMERGE_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	destKey := requestData.Elements[REQUEST_PFMERGE_DEST_KEY_INDEX].(RespString).Str
+	if err := xredis.PFMerge(destKey, requestArguments(requestData, REQUEST_PFMERGE_FIRST_KEY_INDEX)); err != nil {
+		return RespError{err.Error()}
+	}
+	return RespString{REQUEST_RESULT_OK}
+}
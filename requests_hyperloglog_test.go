@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPFAddPFCountAndPFMergeRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	pfaddCommand := "*4\r\n$5\r\nPFADD\r\n$8\r\nvisitors\r\n$5\r\nalice\r\n$3\r\nbob\r\n"
+	pfaddRsp := handleRequest(client, []byte(pfaddCommand))
+	assert.Equal(t, ":1\r\n", string(pfaddRsp))
+
+	pfaddCommand = "*3\r\n$5\r\nPFADD\r\n$8\r\nvisitors\r\n$5\r\nalice\r\n"
+	pfaddRsp = handleRequest(client, []byte(pfaddCommand))
+	assert.Equal(t, ":0\r\n", string(pfaddRsp))
+
+	_ = handleRequest(client, []byte("*3\r\n$5\r\nPFADD\r\n$5\r\nother\r\n$5\r\ncarol\r\n"))
+	pfcountCommand := "*3\r\n$7\r\nPFCOUNT\r\n$8\r\nvisitors\r\n$5\r\nother\r\n"
+	pfcountRsp := handleRequest(client, []byte(pfcountCommand))
+	assert.Equal(t, ":3\r\n", string(pfcountRsp))
+
+	pfmergeCommand := "*4\r\n$7\r\nPFMERGE\r\n$3\r\nall\r\n$8\r\nvisitors\r\n$5\r\nother\r\n"
+	pfmergeRsp := handleRequest(client, []byte(pfmergeCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(pfmergeRsp))
+
+	pfcountRsp = handleRequest(client, []byte("*2\r\n$7\r\nPFCOUNT\r\n$3\r\nall\r\n"))
+	assert.Equal(t, ":3\r\n", string(pfcountRsp))
+
+	_ = handleRequest(client, []byte("*3\r\n$5\r\nLPUSH\r\n$4\r\nlist\r\n$1\r\na\r\n"))
+	wrongTypeRsp := handleRequest(client, []byte("*3\r\n$5\r\nPFADD\r\n$4\r\nlist\r\n$1\r\na\r\n"))
+	assert.Equal(t, "-ERR VALUE-WRONG-TYPE\r\n", string(wrongTypeRsp))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+)
+
+func handleSubscribeRequest(requestData RespArray, client *Client, pattern bool) RespDataType {
+	if len(requestData.Elements) < REQUEST_SUBSCRIBE_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	channels := requestArguments(requestData, REQUEST_SUBSCRIBE_FIRST_CHANNEL_INDEX)
+	client.subscriptions = client.xredis.Subscribe(client.subscriber, channels, pattern)
+	return nil // Replies are delivered through the subscriber
+}
+
+func handleUnsubscribeRequest(requestData RespArray, client *Client, pattern bool) RespDataType {
+	if len(requestData.Elements) < REQUEST_UNSUBSCRIBE_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	channels := requestArguments(requestData, REQUEST_UNSUBSCRIBE_FIRST_CHANNEL_INDEX)
+	client.subscriptions = client.xredis.Unsubscribe(client.subscriber, channels, pattern)
+	return nil // Replies are delivered through the subscriber
+}
+
+func handleShardSubscribeRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) < REQUEST_SUBSCRIBE_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	channels := requestArguments(requestData, REQUEST_SUBSCRIBE_FIRST_CHANNEL_INDEX)
+	if !sameHashSlot(channels) {
+		return RespError{REQUEST_ERROR_CROSS_SLOT}
+	}
+	client.subscriptions = client.xredis.ShardSubscribe(client.subscriber, channels)
+	return nil // Replies are delivered through the subscriber
+}
+
+func handleShardUnsubscribeRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) < REQUEST_UNSUBSCRIBE_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	channels := requestArguments(requestData, REQUEST_UNSUBSCRIBE_FIRST_CHANNEL_INDEX)
+	if !sameHashSlot(channels) {
+		return RespError{REQUEST_ERROR_CROSS_SLOT}
+	}
+	client.subscriptions = client.xredis.ShardUnsubscribe(client.subscriber, channels)
+	return nil // Replies are delivered through the subscriber
+}
+
+func handlePublishRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_PUBLISH_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	channel := requestData.Elements[REQUEST_PUBLISH_CHANNEL_INDEX].(RespString).Str
+	message := requestData.Elements[REQUEST_PUBLISH_MESSAGE_INDEX].(RespString).Str
+	return RespInt{int64(xredis.Publish(channel, message))}
+}
+
+func handleShardPublishRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_PUBLISH_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	channel := requestData.Elements[REQUEST_PUBLISH_CHANNEL_INDEX].(RespString).Str
+	message := requestData.Elements[REQUEST_PUBLISH_MESSAGE_INDEX].(RespString).Str
+	return RespInt{int64(xredis.ShardPublish(channel, message))}
+}
+
+func handlePubSubRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_PUBSUB_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	subcommand := strings.ToUpper(requestData.Elements[REQUEST_PUBSUB_SUBCOMMAND_INDEX].(RespString).Str)
+	arguments := requestArguments(requestData, REQUEST_PUBSUB_ARGUMENTS_INDEX)
+
+	switch subcommand {
+	case PUBSUB_SUBCOMMAND_CHANNELS, PUBSUB_SUBCOMMAND_SHARDCHANNELS:
+		if len(arguments) > 1 {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		pattern := ""
+		if len(arguments) == 1 {
+			pattern = arguments[0]
+		}
+		if subcommand == PUBSUB_SUBCOMMAND_SHARDCHANNELS {
+			return stringsToRespArray(xredis.PubSubShardChannels(pattern))
+		}
+		return stringsToRespArray(xredis.PubSubChannels(pattern))
+	case PUBSUB_SUBCOMMAND_NUMSUB, PUBSUB_SUBCOMMAND_SHARDNUMSUB:
+		var counts []int
+		if subcommand == PUBSUB_SUBCOMMAND_SHARDNUMSUB {
+			counts = xredis.PubSubShardNumSub(arguments)
+		} else {
+			counts = xredis.PubSubNumSub(arguments)
+		}
+		reply := make([]RespDataType, 0, 2*len(arguments))
+		for i, channel := range arguments {
+			reply = append(reply, RespString{channel}, RespInt{int64(counts[i])})
+		}
+		return RespArray{reply}
+	case PUBSUB_SUBCOMMAND_NUMPAT:
+		if len(arguments) != 0 {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		return RespInt{int64(xredis.PubSubNumPat())}
+	default:
+		return RespError{REQUEST_ERROR_UNRECOGNIZED_SUBCOMMAND}
+	}
+}
+
+// isSubscribedModeRequest tells whether the request can be sent by a
+// client with active subscriptions.
+func isSubscribedModeRequest(command string) bool {
+	switch command {
+	case REQUEST_SUBSCRIBE, REQUEST_UNSUBSCRIBE, REQUEST_PSUBSCRIBE, REQUEST_PUNSUBSCRIBE,
+		REQUEST_SSUBSCRIBE, REQUEST_SUNSUBSCRIBE, REQUEST_PING:
+		return true
+	default:
+		return false
+	}
+}
+
+func isSubscribeRequest(command string) bool {
+	switch command {
+	case REQUEST_SUBSCRIBE, REQUEST_UNSUBSCRIBE, REQUEST_PSUBSCRIBE, REQUEST_PUNSUBSCRIBE,
+		REQUEST_SSUBSCRIBE, REQUEST_SUNSUBSCRIBE:
+		return true
+	default:
+		return false
+	}
+}
+
+// sameHashSlot tells whether all the sharded channels are owned by the
+// same slot, as required by the sharded subscription requests.
+func sameHashSlot(channels []string) bool {
+	for _, channel := range channels {
+		if keyHashSlot(channel) != keyHashSlot(channels[0]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeAndPublishRequests(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber := NewClient(xredis)
+	publisher := NewClient(xredis)
+
+	subscribeCommand := "*2\r\n$9\r\nSUBSCRIBE\r\n$4\r\nnews\r\n"
+	subscribeRsp := handleRequest(subscriber, []byte(subscribeCommand))
+	assert.Nil(t, subscribeRsp)
+	assert.Equal(t, "*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n", string(<-subscriber.outbox))
+
+	publishCommand := "*3\r\n$7\r\nPUBLISH\r\n$4\r\nnews\r\n$5\r\nhello\r\n"
+	publishRsp := handleRequest(publisher, []byte(publishCommand))
+	assert.Equal(t, ":1\r\n", string(publishRsp))
+	assert.Equal(t, "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n", string(<-subscriber.outbox))
+}
+
+func TestSubscribedModeOnlyAllowsSubscribeRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	subscribeCommand := "*2\r\n$10\r\nPSUBSCRIBE\r\n$1\r\n*\r\n"
+	_ = handleRequest(client, []byte(subscribeCommand))
+	<-client.outbox
+
+	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
+	getRsp := handleRequest(client, []byte(getCommand))
+	assert.Equal(t, "-ERR ONLY-SUBSCRIBE-UNSUBSCRIBE-AND-PING-ALLOWED-IN-THIS-CONTEXT\r\n", string(getRsp))
+
+	pingCommand := "*1\r\n$4\r\nPING\r\n"
+	pingRsp := handleRequest(client, []byte(pingCommand))
+	assert.Equal(t, "*2\r\n$4\r\npong\r\n$0\r\n\r\n", string(pingRsp))
+
+	unsubscribeCommand := "*1\r\n$12\r\nPUNSUBSCRIBE\r\n"
+	_ = handleRequest(client, []byte(unsubscribeCommand))
+	assert.Equal(t, "*3\r\n$12\r\npunsubscribe\r\n$1\r\n*\r\n:0\r\n", string(<-client.outbox))
+
+	getRsp = handleRequest(client, []byte(getCommand))
+	assert.Equal(t, "$-1\r\n", string(getRsp))
+}
+
+func TestPubSubRequest(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber := NewClient(xredis)
+	client := NewClient(xredis)
+
+	subscribeCommand := "*3\r\n$9\r\nSUBSCRIBE\r\n$4\r\nnews\r\n$6\r\nsports\r\n"
+	_ = handleRequest(subscriber, []byte(subscribeCommand))
+
+	channelsCommand := "*3\r\n$6\r\nPUBSUB\r\n$8\r\nCHANNELS\r\n$2\r\nn*\r\n"
+	channelsRsp := handleRequest(client, []byte(channelsCommand))
+	assert.Equal(t, "*1\r\n$4\r\nnews\r\n", string(channelsRsp))
+
+	numSubCommand := "*4\r\n$6\r\nPUBSUB\r\n$6\r\nNUMSUB\r\n$4\r\nnews\r\n$7\r\nweather\r\n"
+	numSubRsp := handleRequest(client, []byte(numSubCommand))
+	assert.Equal(t, "*4\r\n$4\r\nnews\r\n:1\r\n$7\r\nweather\r\n:0\r\n", string(numSubRsp))
+
+	numPatCommand := "*2\r\n$6\r\nPUBSUB\r\n$6\r\nNUMPAT\r\n"
+	numPatRsp := handleRequest(client, []byte(numPatCommand))
+	assert.Equal(t, ":0\r\n", string(numPatRsp))
+}
+
+func TestClientCloseUnsubscribes(t *testing.T) {
+	xredis := NewXRedis()
+	client := NewClient(xredis)
+
+	subscribeCommand := "*2\r\n$9\r\nSUBSCRIBE\r\n$4\r\nnews\r\n"
+	_ = handleRequest(client, []byte(subscribeCommand))
+	client.Close()
+
+	assert.Equal(t, 0, xredis.Publish("news", "hello"))
+}
+
+func TestShardSubscribeRequests(t *testing.T) {
+	xredis := NewXRedis()
+	subscriber := NewClient(xredis)
+	publisher := NewClient(xredis)
+
+	crossSlotCommand := "*3\r\n$10\r\nSSUBSCRIBE\r\n$3\r\nfoo\r\n$3\r\nbar\r\n"
+	crossSlotRsp := handleRequest(subscriber, []byte(crossSlotCommand))
+	assert.Equal(t, "-CROSSSLOT KEYS-IN-REQUEST-DONT-HASH-TO-THE-SAME-SLOT\r\n", string(crossSlotRsp))
+
+	subscribeCommand := "*3\r\n$10\r\nSSUBSCRIBE\r\n$9\r\n{user}:in\r\n$10\r\n{user}:out\r\n"
+	subscribeRsp := handleRequest(subscriber, []byte(subscribeCommand))
+	assert.Nil(t, subscribeRsp)
+	assert.Equal(t, "*3\r\n$10\r\nssubscribe\r\n$9\r\n{user}:in\r\n:1\r\n", string(<-subscriber.outbox))
+	assert.Equal(t, "*3\r\n$10\r\nssubscribe\r\n$10\r\n{user}:out\r\n:2\r\n", string(<-subscriber.outbox))
+
+	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
+	getRsp := handleRequest(subscriber, []byte(getCommand))
+	assert.Equal(t, "-ERR ONLY-SUBSCRIBE-UNSUBSCRIBE-AND-PING-ALLOWED-IN-THIS-CONTEXT\r\n", string(getRsp))
+
+	publishCommand := "*3\r\n$8\r\nSPUBLISH\r\n$9\r\n{user}:in\r\n$5\r\nhello\r\n"
+	publishRsp := handleRequest(publisher, []byte(publishCommand))
+	assert.Equal(t, ":1\r\n", string(publishRsp))
+	assert.Equal(t, "*3\r\n$8\r\nsmessage\r\n$9\r\n{user}:in\r\n$5\r\nhello\r\n", string(<-subscriber.outbox))
+
+	numSubCommand := "*3\r\n$6\r\nPUBSUB\r\n$11\r\nSHARDNUMSUB\r\n$9\r\n{user}:in\r\n"
+	numSubRsp := handleRequest(publisher, []byte(numSubCommand))
+	assert.Equal(t, "*2\r\n$9\r\n{user}:in\r\n:1\r\n", string(numSubRsp))
+}
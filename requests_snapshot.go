@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Requests served while the databases are being loaded, the others being
+// refused
+var LOADING_REQUESTS = map[string]bool{
+	REQUEST_INFO:   true,
+	REQUEST_CONFIG: true,
+}
+
+func handleBgSaveRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_BGSAVE_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	if err := xredis.BackgroundSave(); err != nil {
+		return RespError{err.Error()}
+	}
+	return RespString{REQUEST_RESULT_BGSAVE_STARTED}
+}
+
+func handleLastSaveRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_LASTSAVE_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	return RespInt{xredis.LastSave()}
+}
+
+// handleInfoRequest replies the requested sections of the server
+// information, of which only the persistence one is supported.
+func handleInfoRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	persistence := len(requestData.Elements) == 1
+	for _, section := range requestArguments(requestData, REQUEST_INFO_FIRST_SECTION_INDEX) {
+		if INFO_PERSISTENCE_SECTIONS[strings.ToLower(section)] {
+			persistence = true
+		}
+	}
+	if !persistence {
+		return RespString{""}
+	}
+
+	info := xredis.PersistenceInfo()
+	fields := [][2]string{{"loading", strconv.Itoa(bool2Int(info.loading))}}
+	// As in Redis, the progress is only reported while loading
+	if info.loading {
+		loadedPercentage := 0.0
+		if info.loadingTotalBytes > 0 {
+			loadedPercentage = 100 * float64(info.loadingLoadedBytes) / float64(info.loadingTotalBytes)
+		}
+		fields = append(fields, [][2]string{
+			{"loading_start_time", strconv.FormatInt(info.loadingStartTime, 10)},
+			{"loading_total_bytes", strconv.FormatInt(info.loadingTotalBytes, 10)},
+			{"loading_loaded_bytes", strconv.FormatInt(info.loadingLoadedBytes, 10)},
+			{"loading_loaded_perc", strconv.FormatFloat(loadedPercentage, 'f', 2, 64)},
+		}...)
+	}
+	fields = append(fields, [][2]string{
+		{"rdb_changes_since_last_save", strconv.FormatInt(info.changesSinceLastSave, 10)},
+		{"rdb_bgsave_in_progress", strconv.Itoa(bool2Int(info.bgsaveInProgress))},
+		{"rdb_last_save_time", strconv.FormatInt(info.lastSaveTime, 10)},
+		{"rdb_last_bgsave_status", infoStatus(info.lastBgsaveFailed)},
+		{"rdb_last_load_keys_loaded", strconv.FormatInt(info.lastLoadKeys, 10)},
+		{"rdb_last_load_status", infoStatus(info.lastLoadFailed)},
+		{"aof_enabled", strconv.Itoa(bool2Int(info.aofEnabled))},
+		{"aof_rewrite_in_progress", strconv.Itoa(bool2Int(info.aofRewriteInProgress))},
+		{"aof_last_write_status", infoStatus(info.aofLastWriteFailed)},
+	}...)
+	var builder strings.Builder
+	builder.WriteString(INFO_PERSISTENCE_HEADER + "\r\n")
+	for _, field := range fields {
+		builder.WriteString(field[0] + ":" + field[1] + "\r\n")
+	}
+	return RespString{builder.String()}
+}
+
+func infoStatus(failed bool) string {
+	if failed {
+		return INFO_STATUS_ERR
+	}
+	return INFO_STATUS_OK
+}
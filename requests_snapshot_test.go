@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBgSaveAndLastSaveRequests(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.SetDir(t.TempDir())
+	client := NewClient(xredis)
+
+	rsp := handleRequest(client, []byte("*1\r\n$6\r\nBGSAVE\r\n"))
+	assert.Equal(t, "$25\r\nBackground saving started\r\n", string(rsp))
+	assert.Eventually(t, func() bool { return !xredis.PersistenceInfo().bgsaveInProgress }, time.Second, time.Millisecond)
+
+	lastSaveRsp := handleRequest(client, []byte("*1\r\n$8\r\nLASTSAVE\r\n"))
+	assert.Equal(t, ":"+strconv.FormatInt(xredis.LastSave(), 10)+"\r\n", string(lastSaveRsp))
+}
+
+func TestInfoPersistenceRequest(t *testing.T) {
+	xredis := NewXRedis()
+	client := NewClient(xredis)
+
+	info := "# Persistence\r\n" +
+		"loading:0\r\n" +
+		"rdb_changes_since_last_save:0\r\n" +
+		"rdb_bgsave_in_progress:0\r\n" +
+		fmt.Sprintf("rdb_last_save_time:%d\r\n", xredis.LastSave()) +
+		"rdb_last_bgsave_status:ok\r\n" +
+		"rdb_last_load_keys_loaded:0\r\n" +
+		"rdb_last_load_status:ok\r\n" +
+		"aof_enabled:0\r\n" +
+		"aof_rewrite_in_progress:0\r\n" +
+		"aof_last_write_status:ok\r\n"
+	expected := RespString{info}.serialize()
+	assert.Equal(t, expected, string(handleRequest(client, []byte("*1\r\n$4\r\nINFO\r\n"))))
+	assert.Equal(t, expected, string(handleRequest(client, []byte("*2\r\n$4\r\nINFO\r\n$11\r\nPERSISTENCE\r\n"))))
+	assert.Equal(t, "$0\r\n\r\n", string(handleRequest(client, []byte("*2\r\n$4\r\nINFO\r\n$6\r\nmemory\r\n"))))
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func handleXAddRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XADD_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XADD_KEY_INDEX].(RespString).Str
+	arguments := requestArguments(requestData, REQUEST_XADD_OPTIONS_INDEX)
+	noMkStream, trim, i, err := parseXAddOptions(arguments)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+
+	fields := arguments[min(i+1, len(arguments)):]
+	if i >= len(arguments) || len(fields) == 0 || len(fields)%2 != 0 {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	id, added, err := xredis.XAdd(key, arguments[i], fields, noMkStream, trim)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	if !added {
+		return RespNil{}
+	}
+	return RespString{id.String()}
+}
+
+func handleXRangeRequest(requestData RespArray, xredis *XRedis, reverse bool) RespDataType {
+	if len(requestData.Elements) < REQUEST_XRANGE_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XRANGE_KEY_INDEX].(RespString).Str
+	startIndex, endIndex := REQUEST_XRANGE_START_INDEX, REQUEST_XRANGE_END_INDEX
+	if reverse {
+		startIndex, endIndex = endIndex, startIndex
+	}
+	start, err := parseStreamRangeID(requestData.Elements[startIndex].(RespString).Str, false)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	end, err := parseStreamRangeID(requestData.Elements[endIndex].(RespString).Str, true)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+
+	count := 0
+	options := requestArguments(requestData, REQUEST_XRANGE_OPTIONS_INDEX)
+	if len(options) > 0 {
+		if len(options) != 2 || strings.ToUpper(options[0]) != STREAM_OPTION_COUNT {
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+		count, err = strconv.Atoi(options[1])
+		if err != nil {
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+		if count <= 0 {
+			return RespArray{[]RespDataType{}}
+		}
+	}
+
+	entries, err := xredis.XRange(key, start, end, count, reverse)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return streamEntriesReply(entries)
+}
+
+func handleXReadRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) < REQUEST_XREAD_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	arguments := requestArguments(requestData, REQUEST_XREAD_OPTIONS_INDEX)
+	options, i, err := parseStreamReadOptions(arguments, false)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	reads, err := parseStreamReads(arguments, i, false)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return waitStreamReads(client, reads, options, func(reads []StreamRead, block bool) ([]StreamReadResult, *StreamWaiter, error) {
+		return client.xredis.XRead(reads, options.count, block)
+	})
+}
+
+func handleXLenRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) != REQUEST_XLEN_EXPECTED_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XLEN_KEY_INDEX].(RespString).Str
+	length, err := xredis.XLen(key)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(length)}
+}
+
+func handleXDelRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XDEL_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XDEL_KEY_INDEX].(RespString).Str
+	var ids []StreamID
+	for _, str := range requestArguments(requestData, REQUEST_XDEL_FIRST_ID_INDEX) {
+		id, err := parseStreamID(str, 0)
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		ids = append(ids, id)
+	}
+	deleted, err := xredis.XDel(key, ids)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(deleted)}
+}
+
+func handleXTrimRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XTRIM_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XTRIM_KEY_INDEX].(RespString).Str
+	arguments := requestArguments(requestData, REQUEST_XTRIM_STRATEGY_INDEX)
+	trim, next, err := parseStreamTrim(arguments, 0)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	if next != len(arguments) {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	trimmed, err := xredis.XTrim(key, trim)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(trimmed)}
+}
+
+// streamReadOptions are the options of XREAD and XREADGROUP preceding the
+// STREAMS keyword
+type streamReadOptions struct {
+	count        int
+	block        bool
+	blockTimeout time.Duration
+	noAck        bool // Only allowed by XREADGROUP
+}
+
+// parseStreamReadOptions parses the options up to STREAMS, returning its
+// index.
+func parseStreamReadOptions(arguments []string, group bool) (streamReadOptions, int, error) {
+	var options streamReadOptions
+	i := 0
+	for ; i < len(arguments) && strings.ToUpper(arguments[i]) != STREAM_OPTION_STREAMS; i += 2 {
+		if group && strings.ToUpper(arguments[i]) == STREAM_OPTION_NOACK {
+			options.noAck = true
+			i--
+			continue
+		}
+		if i+1 >= len(arguments) {
+			return options, i, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+		value, err := strconv.Atoi(arguments[i+1])
+		switch strings.ToUpper(arguments[i]) {
+		case STREAM_OPTION_COUNT:
+			if err != nil {
+				return options, i, errors.New(REQUEST_ERROR_SYNTAX)
+			}
+			options.count = value
+		case STREAM_OPTION_BLOCK:
+			if err != nil || value < 0 {
+				return options, i, errors.New(REQUEST_ERROR_INVALID_TIMEOUT_VALUE)
+			}
+			options.block = true
+			options.blockTimeout = time.Duration(value) * time.Millisecond
+		default:
+			return options, i, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+	}
+	return options, i, nil
+}
+
+// parseStreamReads parses the keys and IDs following the STREAMS keyword
+// at index i. XREAD accepts $ as ID while XREADGROUP accepts > instead.
+func parseStreamReads(arguments []string, i int, group bool) ([]StreamRead, error) {
+	streams := arguments[min(i+1, len(arguments)):]
+	if i >= len(arguments) || len(streams) == 0 || len(streams)%2 != 0 {
+		return nil, errors.New(REQUEST_ERROR_UNBALANCED_XREAD_STREAMS)
+	}
+	lastID := STREAM_READ_LAST
+	if group {
+		lastID = STREAM_READ_NEW
+	}
+	keys, ids := streams[:len(streams)/2], streams[len(streams)/2:]
+	reads := make([]StreamRead, 0, len(keys))
+	for j, key := range keys {
+		if ids[j] == lastID {
+			reads = append(reads, StreamRead{key, StreamID{}, true})
+			continue
+		}
+		if group && ids[j] == STREAM_READ_LAST {
+			return nil, errors.New(REQUEST_ERROR_XREADGROUP_LAST_ID)
+		}
+		id, err := parseStreamID(ids[j], 0)
+		if err != nil {
+			return nil, err
+		}
+		reads = append(reads, StreamRead{key, id, false})
+	}
+	return reads, nil
+}
+
+// waitStreamReads performs the reads, retrying them each time the waiter
+// returned by read is woken up until they succeed or the timeout expires.
+func waitStreamReads(client *Client, reads []StreamRead, options streamReadOptions,
+	read func([]StreamRead, bool) ([]StreamReadResult, *StreamWaiter, error)) RespDataType {
+	// Transactions can't wait for other clients to write, since no one
+	// else runs until they are over, so reads never block inside them
+	block := options.block && !client.executing
+	var timeout <-chan time.Time
+	if block && options.blockTimeout > 0 {
+		timer := time.NewTimer(options.blockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		results, waiter, err := read(reads, block)
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		if waiter == nil {
+			return streamReadReply(results)
+		}
+		select {
+		case <-waiter.ready:
+			reads = waiter.reads
+		case <-timeout:
+			client.xredis.CancelStreamWait(waiter)
+			return RespNil{}
+		}
+	}
+}
+
+// parseXAddOptions parses the NOMKSTREAM and trimming options of XADD,
+// returning the index of the ID following them.
+func parseXAddOptions(arguments []string) (bool, *StreamTrim, int, error) {
+	noMkStream := false
+	var trim *StreamTrim
+	i := 0
+	for ; i < len(arguments); i++ {
+		option := strings.ToUpper(arguments[i])
+		if option == STREAM_OPTION_NOMKSTREAM {
+			noMkStream = true
+			continue
+		}
+		if option != STREAM_OPTION_MAXLEN && option != STREAM_OPTION_MINID {
+			break
+		}
+		parsedTrim, next, err := parseStreamTrim(arguments, i)
+		if err != nil {
+			return false, nil, i, err
+		}
+		trim = &parsedTrim
+		i = next - 1
+	}
+	return noMkStream, trim, i, nil
+}
+
+// parseStreamTrim parses a MAXLEN|MINID [=|~] threshold [LIMIT count]
+// trimming strategy starting at index i, returning the index following it.
+func parseStreamTrim(arguments []string, i int) (StreamTrim, int, error) {
+	var trim StreamTrim
+	trim.byMinID = strings.ToUpper(arguments[i]) == STREAM_OPTION_MINID
+	i++
+	approximate := false
+	if i < len(arguments) && (arguments[i] == STREAM_TRIM_EXACT || arguments[i] == STREAM_TRIM_APPROXIMATE) {
+		approximate = arguments[i] == STREAM_TRIM_APPROXIMATE
+		i++
+	}
+	if i >= len(arguments) {
+		return trim, i, errors.New(REQUEST_ERROR_SYNTAX)
+	}
+	if trim.byMinID {
+		minID, err := parseStreamID(arguments[i], 0)
+		if err != nil {
+			return trim, i, err
+		}
+		trim.minID = minID
+	} else {
+		maxLen, err := strconv.Atoi(arguments[i])
+		if err != nil || maxLen < 0 {
+			return trim, i, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+		trim.maxLen = maxLen
+	}
+	i++
+
+	if i < len(arguments) && strings.ToUpper(arguments[i]) == STREAM_OPTION_LIMIT {
+		// As in Redis the limit only makes sense for approximate trimming,
+		// which is trimmed exactly here but may leave entries behind
+		if !approximate || i+1 >= len(arguments) {
+			return trim, i, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+		limit, err := strconv.Atoi(arguments[i+1])
+		if err != nil || limit < 0 {
+			return trim, i, errors.New(REQUEST_ERROR_SYNTAX)
+		}
+		trim.limit = limit
+		i += 2
+	}
+	return trim, i, nil
+}
+
+// parseStreamRangeID parses the start or end of an XRANGE, which can be
+// - and + for the smallest and greatest IDs, an ID prefixed by ( to
+// exclude it, or an ID without sequence covering the whole millisecond.
+func parseStreamRangeID(str string, isEnd bool) (StreamID, error) {
+	switch {
+	case str == STREAM_RANGE_MIN:
+		return STREAM_MIN_ID, nil
+	case str == STREAM_RANGE_MAX:
+		return STREAM_MAX_ID, nil
+	}
+
+	missingSeq := uint64(0)
+	if isEnd {
+		missingSeq = STREAM_MAX_ID.Seq
+	}
+	exclusive := strings.HasPrefix(str, STREAM_RANGE_EXCLUSIVE)
+	id, err := parseStreamID(strings.TrimPrefix(str, STREAM_RANGE_EXCLUSIVE), missingSeq)
+	if err != nil || !exclusive {
+		return id, err
+	}
+	if isEnd {
+		if id == STREAM_MIN_ID {
+			return id, errors.New(REQUEST_ERROR_INVALID_STREAM_ID)
+		}
+		return id.previous(), nil
+	}
+	if id == STREAM_MAX_ID {
+		return id, errors.New(REQUEST_ERROR_INVALID_STREAM_ID)
+	}
+	return id.next(), nil
+}
+
+func streamReadReply(results []StreamReadResult) RespDataType {
+	if len(results) == 0 {
+		return RespNil{}
+	}
+	reply := make([]RespDataType, 0, len(results))
+	for _, result := range results {
+		reply = append(reply, RespArray{[]RespDataType{RespString{result.key}, streamEntriesReply(result.entries)}})
+	}
+	return RespArray{reply}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+func handleXGroupRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XGROUP_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	subcommand := strings.ToUpper(requestData.Elements[REQUEST_XGROUP_SUBCOMMAND_INDEX].(RespString).Str)
+	arguments := requestArguments(requestData, REQUEST_XGROUP_KEY_INDEX)
+
+	switch subcommand {
+	case XGROUP_SUBCOMMAND_CREATE:
+		if len(requestData.Elements) < REQUEST_XGROUP_CREATE_MIN_SIZE {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		mkStream := false
+		for _, option := range requestArguments(requestData, REQUEST_XGROUP_OPTIONS_INDEX) {
+			if strings.ToUpper(option) != STREAM_OPTION_MKSTREAM {
+				return RespError{REQUEST_ERROR_SYNTAX}
+			}
+			mkStream = true
+		}
+		id, lastID, err := parseStreamGroupID(arguments[2])
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		if err := xredis.XGroupCreate(arguments[0], arguments[1], id, lastID, mkStream); err != nil {
+			return RespError{err.Error()}
+		}
+		return RespString{REQUEST_RESULT_OK}
+	case XGROUP_SUBCOMMAND_SETID:
+		if len(requestData.Elements) != REQUEST_XGROUP_SETID_EXPECTED_SIZE {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		id, lastID, err := parseStreamGroupID(arguments[2])
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		if err := xredis.XGroupSetID(arguments[0], arguments[1], id, lastID); err != nil {
+			return RespError{err.Error()}
+		}
+		return RespString{REQUEST_RESULT_OK}
+	case XGROUP_SUBCOMMAND_DESTROY:
+		if len(requestData.Elements) != REQUEST_XGROUP_DESTROY_EXPECTED_SIZE {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		destroyed, err := xredis.XGroupDestroy(arguments[0], arguments[1])
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		return RespInt{int64(bool2Int(destroyed))}
+	case XGROUP_SUBCOMMAND_CREATECONSUMER:
+		if len(requestData.Elements) != REQUEST_XGROUP_CONSUMER_EXPECTED_SIZE {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		created, err := xredis.XGroupCreateConsumer(arguments[0], arguments[1], arguments[2])
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		return RespInt{int64(bool2Int(created))}
+	case XGROUP_SUBCOMMAND_DELCONSUMER:
+		if len(requestData.Elements) != REQUEST_XGROUP_CONSUMER_EXPECTED_SIZE {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		pending, err := xredis.XGroupDelConsumer(arguments[0], arguments[1], arguments[2])
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		return RespInt{int64(pending)}
+	default:
+		return RespError{REQUEST_ERROR_UNRECOGNIZED_SUBCOMMAND}
+	}
+}
+
+func handleXReadGroupRequest(requestData RespArray, client *Client) RespDataType {
+	if len(requestData.Elements) < REQUEST_XREADGROUP_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	if strings.ToUpper(requestData.Elements[REQUEST_XREADGROUP_GROUP_OPTION_INDEX].(RespString).Str) != STREAM_OPTION_GROUP {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	group := requestData.Elements[REQUEST_XREADGROUP_GROUP_INDEX].(RespString).Str
+	consumer := requestData.Elements[REQUEST_XREADGROUP_CONSUMER_INDEX].(RespString).Str
+	arguments := requestArguments(requestData, REQUEST_XREADGROUP_OPTIONS_INDEX)
+	options, i, err := parseStreamReadOptions(arguments, true)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	reads, err := parseStreamReads(arguments, i, true)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return waitStreamReads(client, reads, options, func(reads []StreamRead, block bool) ([]StreamReadResult, *StreamWaiter, error) {
+		if client.executing || !client.xredis.AppendOnlyEnabled() {
+			return client.xredis.XReadGroup(group, consumer, reads, options.count, options.noAck, block)
+		}
+		// Each attempt is logged along with the read, unless it blocks
+		var results []StreamReadResult
+		var waiter *StreamWaiter
+		var err error
+		client.xredis.Atomically(func(tx *XRedis) {
+			results, waiter, err = tx.XReadGroup(group, consumer, reads, options.count, options.noAck, block)
+			if err == nil && waiter == nil {
+				err = tx.AppendToLog(appendOnlyRequest(REQUEST_XREADGROUP, requestData, nil))
+			}
+		})
+		return results, waiter, err
+	})
+}
+
+func handleXAckRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XACK_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XACK_KEY_INDEX].(RespString).Str
+	group := requestData.Elements[REQUEST_XACK_GROUP_INDEX].(RespString).Str
+	var ids []StreamID
+	for _, str := range requestArguments(requestData, REQUEST_XACK_FIRST_ID_INDEX) {
+		id, err := parseStreamID(str, 0)
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		ids = append(ids, id)
+	}
+	acknowledged, err := xredis.XAck(key, group, ids)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return RespInt{int64(acknowledged)}
+}
+
+func handleXPendingRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XPENDING_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XPENDING_KEY_INDEX].(RespString).Str
+	group := requestData.Elements[REQUEST_XPENDING_GROUP_INDEX].(RespString).Str
+	arguments := requestArguments(requestData, REQUEST_XPENDING_OPTIONS_INDEX)
+
+	if len(arguments) == 0 {
+		summary, err := xredis.XPendingSummary(key, group)
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		if summary.count == 0 {
+			return RespArray{[]RespDataType{RespInt{0}, RespNil{}, RespNil{}, RespNil{}}}
+		}
+		consumers := make([]RespDataType, 0, len(summary.consumers))
+		for _, consumer := range summary.consumers {
+			consumers = append(consumers, stringsToRespArray([]string{consumer.consumer, strconv.Itoa(consumer.count)}))
+		}
+		return RespArray{[]RespDataType{
+			RespInt{int64(summary.count)},
+			RespString{summary.smallest.String()},
+			RespString{summary.greatest.String()},
+			RespArray{consumers},
+		}}
+	}
+
+	if len(requestData.Elements) < REQUEST_XPENDING_EXTENDED_MIN_SIZE || len(requestData.Elements) > REQUEST_XPENDING_EXTENDED_MAX_SIZE {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	minIdle := int64(0)
+	if strings.ToUpper(arguments[0]) == STREAM_OPTION_IDLE {
+		var err error
+		if minIdle, err = strconv.ParseInt(arguments[1], 10, 64); err != nil || minIdle < 0 {
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+		arguments = arguments[2:]
+	}
+	if len(arguments) < 3 || len(arguments) > 4 {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	start, err := parseStreamRangeID(arguments[0], false)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	end, err := parseStreamRangeID(arguments[1], true)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	count, err := strconv.Atoi(arguments[2])
+	if err != nil {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	consumer := ""
+	if len(arguments) == 4 {
+		consumer = arguments[3]
+	}
+	if count <= 0 {
+		return RespArray{[]RespDataType{}}
+	}
+
+	entries, err := xredis.XPending(key, group, start, end, count, consumer, minIdle)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	reply := make([]RespDataType, 0, len(entries))
+	for _, entry := range entries {
+		reply = append(reply, RespArray{[]RespDataType{
+			RespString{entry.id.String()},
+			RespString{entry.consumer},
+			RespInt{entry.idle},
+			RespInt{entry.deliveryCount},
+		}})
+	}
+	return RespArray{reply}
+}
+
+func handleXClaimRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XCLAIM_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XCLAIM_KEY_INDEX].(RespString).Str
+	group := requestData.Elements[REQUEST_XCLAIM_GROUP_INDEX].(RespString).Str
+	consumer := requestData.Elements[REQUEST_XCLAIM_CONSUMER_INDEX].(RespString).Str
+	minIdle, err := strconv.ParseInt(requestData.Elements[REQUEST_XCLAIM_MIN_IDLE_INDEX].(RespString).Str, 10, 64)
+	if err != nil || minIdle < 0 {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	arguments := requestArguments(requestData, REQUEST_XCLAIM_ARGUMENTS_INDEX)
+
+	// The IDs go on until the first option
+	var ids []StreamID
+	i := 0
+	for ; i < len(arguments); i++ {
+		id, err := parseStreamID(arguments[i], 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return RespError{REQUEST_ERROR_INVALID_STREAM_ID}
+	}
+
+	options := StreamClaimOptions{idle: -1, time: -1, retryCount: -1}
+	for ; i < len(arguments); i++ {
+		option := strings.ToUpper(arguments[i])
+		switch option {
+		case STREAM_OPTION_FORCE:
+			options.force = true
+			continue
+		case STREAM_OPTION_JUSTID:
+			options.justID = true
+			continue
+		}
+		if i+1 >= len(arguments) {
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+		i++
+		if option == STREAM_OPTION_LASTID {
+			lastID, err := parseStreamID(arguments[i], 0)
+			if err != nil {
+				return RespError{err.Error()}
+			}
+			options.lastID = &lastID
+			continue
+		}
+		value, err := strconv.ParseInt(arguments[i], 10, 64)
+		if err != nil || value < 0 {
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+		switch option {
+		case STREAM_OPTION_IDLE:
+			options.idle = value
+		case STREAM_OPTION_TIME:
+			options.time = value
+		case STREAM_OPTION_RETRYCOUNT:
+			options.retryCount = value
+		default:
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+	}
+
+	claimed, err := xredis.XClaim(key, group, consumer, minIdle, ids, options)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	return streamClaimReply(claimed, options.justID)
+}
+
+func handleXAutoClaimRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XAUTOCLAIM_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	key := requestData.Elements[REQUEST_XAUTOCLAIM_KEY_INDEX].(RespString).Str
+	group := requestData.Elements[REQUEST_XAUTOCLAIM_GROUP_INDEX].(RespString).Str
+	consumer := requestData.Elements[REQUEST_XAUTOCLAIM_CONSUMER_INDEX].(RespString).Str
+	minIdle, err := strconv.ParseInt(requestData.Elements[REQUEST_XAUTOCLAIM_MIN_IDLE_INDEX].(RespString).Str, 10, 64)
+	if err != nil || minIdle < 0 {
+		return RespError{REQUEST_ERROR_SYNTAX}
+	}
+	start, err := parseStreamRangeID(requestData.Elements[REQUEST_XAUTOCLAIM_START_INDEX].(RespString).Str, false)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+
+	count := STREAM_AUTOCLAIM_DEFAULT_COUNT
+	justID := false
+	arguments := requestArguments(requestData, REQUEST_XAUTOCLAIM_OPTIONS_INDEX)
+	for i := 0; i < len(arguments); i++ {
+		switch strings.ToUpper(arguments[i]) {
+		case STREAM_OPTION_JUSTID:
+			justID = true
+		case STREAM_OPTION_COUNT:
+			if i+1 >= len(arguments) {
+				return RespError{REQUEST_ERROR_SYNTAX}
+			}
+			i++
+			if count, err = strconv.Atoi(arguments[i]); err != nil || count <= 0 {
+				return RespError{REQUEST_ERROR_SYNTAX}
+			}
+		default:
+			return RespError{REQUEST_ERROR_SYNTAX}
+		}
+	}
+
+	result, err := xredis.XAutoClaim(key, group, consumer, minIdle, start, count, justID)
+	if err != nil {
+		return RespError{err.Error()}
+	}
+	deleted := make([]RespDataType, 0, len(result.deleted))
+	for _, id := range result.deleted {
+		deleted = append(deleted, RespString{id.String()})
+	}
+	return RespArray{[]RespDataType{
+		RespString{result.next.String()},
+		streamClaimReply(result.claimed, justID),
+		RespArray{deleted},
+	}}
+}
+
+func handleXInfoRequest(requestData RespArray, xredis *XRedis) RespDataType {
+	if len(requestData.Elements) < REQUEST_XINFO_MIN_SIZE {
+		return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+	}
+	subcommand := strings.ToUpper(requestData.Elements[REQUEST_XINFO_SUBCOMMAND_INDEX].(RespString).Str)
+
+	switch subcommand {
+	case XINFO_SUBCOMMAND_STREAM:
+		if len(requestData.Elements) != REQUEST_XINFO_STREAM_EXPECTED_SIZE {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		info, err := xredis.XInfoStream(requestData.Elements[REQUEST_XINFO_KEY_INDEX].(RespString).Str)
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		return RespArray{[]RespDataType{
+			RespString{"length"}, RespInt{int64(info.length)},
+			RespString{"last-generated-id"}, RespString{info.lastGeneratedID.String()},
+			RespString{"max-deleted-entry-id"}, RespString{info.maxDeletedID.String()},
+			RespString{"entries-added"}, RespInt{int64(info.entriesAdded)},
+			RespString{"groups"}, RespInt{int64(info.groups)},
+			RespString{"first-entry"}, streamEntryReply(info.firstEntry),
+			RespString{"last-entry"}, streamEntryReply(info.lastEntry),
+		}}
+	case XINFO_SUBCOMMAND_GROUPS:
+		if len(requestData.Elements) != REQUEST_XINFO_GROUPS_EXPECTED_SIZE {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		infos, err := xredis.XInfoGroups(requestData.Elements[REQUEST_XINFO_KEY_INDEX].(RespString).Str)
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		reply := make([]RespDataType, 0, len(infos))
+		for _, info := range infos {
+			reply = append(reply, RespArray{[]RespDataType{
+				RespString{"name"}, RespString{info.name},
+				RespString{"consumers"}, RespInt{int64(info.consumers)},
+				RespString{"pending"}, RespInt{int64(info.pending)},
+				RespString{"last-delivered-id"}, RespString{info.lastDeliveredID.String()},
+				RespString{"entries-read"}, RespInt{int64(info.entriesRead)},
+				RespString{"lag"}, RespInt{int64(info.lag)},
+			}})
+		}
+		return RespArray{reply}
+	case XINFO_SUBCOMMAND_CONSUMERS:
+		if len(requestData.Elements) != REQUEST_XINFO_CONSUMERS_EXPECTED_SIZE {
+			return RespError{REQUEST_ERROR_INVALID_ARGUMENTS_NUMBER}
+		}
+		key := requestData.Elements[REQUEST_XINFO_KEY_INDEX].(RespString).Str
+		group := requestData.Elements[REQUEST_XINFO_GROUP_INDEX].(RespString).Str
+		infos, err := xredis.XInfoConsumers(key, group)
+		if err != nil {
+			return RespError{err.Error()}
+		}
+		reply := make([]RespDataType, 0, len(infos))
+		for _, info := range infos {
+			reply = append(reply, RespArray{[]RespDataType{
+				RespString{"name"}, RespString{info.name},
+				RespString{"pending"}, RespInt{int64(info.pending)},
+				RespString{"idle"}, RespInt{info.idle},
+				RespString{"inactive"}, RespInt{info.inactive},
+			}})
+		}
+		return RespArray{reply}
+	default:
+		return RespError{REQUEST_ERROR_UNRECOGNIZED_SUBCOMMAND}
+	}
+}
+
+// parseStreamGroupID parses the last delivered ID of XGROUP CREATE and
+// SETID, where $ stands for the last ID of the stream.
+func parseStreamGroupID(str string) (StreamID, bool, error) {
+	if str == STREAM_READ_LAST {
+		return StreamID{}, true, nil
+	}
+	id, err := parseStreamID(str, 0)
+	return id, false, err
+}
+
+func streamClaimReply(claimed []StreamEntry, justID bool) RespDataType {
+	if !justID {
+		return streamEntriesReply(claimed)
+	}
+	ids := make([]RespDataType, 0, len(claimed))
+	for _, entry := range claimed {
+		ids = append(ids, RespString{entry.ID.String()})
+	}
+	return RespArray{ids}
+}
+
+func streamEntryReply(entry *StreamEntry) RespDataType {
+	if entry == nil {
+		return RespNil{}
+	}
+	return streamEntriesReply([]StreamEntry{*entry}).Elements[0]
+}
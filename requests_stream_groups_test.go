@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXGroupCreateRequest(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	_ = handleRequest(client, []byte("*5\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$3\r\n1-0\r\n$1\r\na\r\n$1\r\n1\r\n"))
+	xgroupCommand := "*5\r\n$6\r\nXGROUP\r\n$6\r\nCREATE\r\n$6\r\nevents\r\n$7\r\nworkers\r\n$1\r\n0\r\n"
+	xgroupRsp := handleRequest(client, []byte(xgroupCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(xgroupRsp))
+
+	xgroupRsp = handleRequest(client, []byte(xgroupCommand))
+	assert.Equal(t, "-BUSYGROUP CONSUMER-GROUP-NAME-ALREADY-EXISTS\r\n", string(xgroupRsp))
+
+	missingCommand := "*5\r\n$6\r\nXGROUP\r\n$6\r\nCREATE\r\n$5\r\nother\r\n$7\r\nworkers\r\n$1\r\n$\r\n"
+	missingRsp := handleRequest(client, []byte(missingCommand))
+	assert.Equal(t, "-ERR XGROUP-REQUIRES-THE-KEY-TO-EXIST\r\n", string(missingRsp))
+
+	mkStreamCommand := "*6\r\n$6\r\nXGROUP\r\n$6\r\nCREATE\r\n$5\r\nother\r\n$7\r\nworkers\r\n$1\r\n$\r\n$8\r\nMKSTREAM\r\n"
+	mkStreamRsp := handleRequest(client, []byte(mkStreamCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(mkStreamRsp))
+}
+
+func TestXReadGroupAndXAckRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	_ = handleRequest(client, []byte("*5\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$3\r\n1-0\r\n$1\r\na\r\n$1\r\n1\r\n"))
+	_ = handleRequest(client, []byte("*5\r\n$6\r\nXGROUP\r\n$6\r\nCREATE\r\n$6\r\nevents\r\n$7\r\nworkers\r\n$1\r\n0\r\n"))
+
+	xreadgroupCommand := "*9\r\n$10\r\nXREADGROUP\r\n$5\r\nGROUP\r\n$7\r\nworkers\r\n$5\r\nalice\r\n$5\r\nCOUNT\r\n$1\r\n1\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$1\r\n>\r\n"
+	xreadgroupRsp := handleRequest(client, []byte(xreadgroupCommand))
+	assert.Equal(t, "*1\r\n*2\r\n$6\r\nevents\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n", string(xreadgroupRsp))
+
+	xreadgroupRsp = handleRequest(client, []byte(xreadgroupCommand))
+	assert.Equal(t, "$-1\r\n", string(xreadgroupRsp))
+
+	lastIDCommand := "*7\r\n$10\r\nXREADGROUP\r\n$5\r\nGROUP\r\n$7\r\nworkers\r\n$5\r\nalice\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$1\r\n$\r\n"
+	lastIDRsp := handleRequest(client, []byte(lastIDCommand))
+	assert.Equal(t, "-ERR $-CAN-NOT-BE-USED-WITH-XREADGROUP\r\n", string(lastIDRsp))
+
+	xpendingCommand := "*3\r\n$8\r\nXPENDING\r\n$6\r\nevents\r\n$7\r\nworkers\r\n"
+	xpendingRsp := handleRequest(client, []byte(xpendingCommand))
+	assert.Equal(t, "*4\r\n:1\r\n$3\r\n1-0\r\n$3\r\n1-0\r\n*1\r\n*2\r\n$5\r\nalice\r\n$1\r\n1\r\n", string(xpendingRsp))
+
+	xackCommand := "*4\r\n$4\r\nXACK\r\n$6\r\nevents\r\n$7\r\nworkers\r\n$3\r\n1-0\r\n"
+	xackRsp := handleRequest(client, []byte(xackCommand))
+	assert.Equal(t, ":1\r\n", string(xackRsp))
+
+	xpendingRsp = handleRequest(client, []byte(xpendingCommand))
+	assert.Equal(t, "*4\r\n:0\r\n$-1\r\n$-1\r\n$-1\r\n", string(xpendingRsp))
+}
+
+func TestXClaimAndXAutoClaimRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	_ = handleRequest(client, []byte("*5\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$3\r\n1-0\r\n$1\r\na\r\n$1\r\n1\r\n"))
+	_ = handleRequest(client, []byte("*5\r\n$6\r\nXGROUP\r\n$6\r\nCREATE\r\n$6\r\nevents\r\n$7\r\nworkers\r\n$1\r\n0\r\n"))
+	_ = handleRequest(client, []byte("*9\r\n$10\r\nXREADGROUP\r\n$5\r\nGROUP\r\n$7\r\nworkers\r\n$5\r\nalice\r\n$5\r\nCOUNT\r\n$1\r\n1\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$1\r\n>\r\n"))
+
+	xclaimCommand := "*7\r\n$6\r\nXCLAIM\r\n$6\r\nevents\r\n$7\r\nworkers\r\n$3\r\nbob\r\n$1\r\n0\r\n$3\r\n1-0\r\n$6\r\nJUSTID\r\n"
+	xclaimRsp := handleRequest(client, []byte(xclaimCommand))
+	assert.Equal(t, "*1\r\n$3\r\n1-0\r\n", string(xclaimRsp))
+
+	xautoclaimCommand := "*7\r\n$10\r\nXAUTOCLAIM\r\n$6\r\nevents\r\n$7\r\nworkers\r\n$3\r\nbob\r\n$1\r\n0\r\n$1\r\n0\r\n$6\r\nJUSTID\r\n"
+	xautoclaimRsp := handleRequest(client, []byte(xautoclaimCommand))
+	assert.Equal(t, "*3\r\n$3\r\n0-0\r\n*1\r\n$3\r\n1-0\r\n*0\r\n", string(xautoclaimRsp))
+
+	xinfoCommand := "*4\r\n$5\r\nXINFO\r\n$9\r\nCONSUMERS\r\n$6\r\nevents\r\n$7\r\nmissing\r\n"
+	xinfoRsp := handleRequest(client, []byte(xinfoCommand))
+	assert.Equal(t, "-NOGROUP NO-SUCH-KEY-OR-CONSUMER-GROUP\r\n", string(xinfoRsp))
+}
+
+func TestBlockingXReadGroupRequest(t *testing.T) {
+	xredis := NewXRedis()
+	reader := NewClient(xredis)
+	writer := NewClient(xredis)
+
+	_ = handleRequest(writer, []byte("*6\r\n$6\r\nXGROUP\r\n$6\r\nCREATE\r\n$6\r\nevents\r\n$7\r\nworkers\r\n$1\r\n$\r\n$8\r\nMKSTREAM\r\n"))
+	xreadgroupCommand := "*9\r\n$10\r\nXREADGROUP\r\n$5\r\nGROUP\r\n$7\r\nworkers\r\n$5\r\nalice\r\n$5\r\nBLOCK\r\n$1\r\n0\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$1\r\n>\r\n"
+	xreadgroupRsp := make(chan []byte)
+	go func() {
+		xreadgroupRsp <- handleRequest(reader, []byte(xreadgroupCommand))
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	_ = handleRequest(writer, []byte("*5\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$3\r\n1-0\r\n$1\r\na\r\n$1\r\n1\r\n"))
+	assert.Equal(t, "*1\r\n*2\r\n$6\r\nevents\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n", string(<-xreadgroupRsp))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXAddAndXRangeRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	xaddCommand := "*7\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$6\r\nMAXLEN\r\n$1\r\n2\r\n$3\r\n1-1\r\n$1\r\na\r\n$1\r\n1\r\n"
+	xaddRsp := handleRequest(client, []byte(xaddCommand))
+	assert.Equal(t, "$3\r\n1-1\r\n", string(xaddRsp))
+
+	xaddCommand = "*5\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$3\r\n2-*\r\n$1\r\nb\r\n$1\r\n2\r\n"
+	xaddRsp = handleRequest(client, []byte(xaddCommand))
+	assert.Equal(t, "$3\r\n2-0\r\n", string(xaddRsp))
+
+	xrangeCommand := "*4\r\n$6\r\nXRANGE\r\n$6\r\nevents\r\n$4\r\n(1-1\r\n$1\r\n+\r\n"
+	xrangeRsp := handleRequest(client, []byte(xrangeCommand))
+	assert.Equal(t, "*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n", string(xrangeRsp))
+
+	xrevrangeCommand := "*6\r\n$9\r\nXREVRANGE\r\n$6\r\nevents\r\n$1\r\n+\r\n$1\r\n1\r\n$5\r\nCOUNT\r\n$1\r\n1\r\n"
+	xrevrangeRsp := handleRequest(client, []byte(xrevrangeCommand))
+	assert.Equal(t, "*1\r\n*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n", string(xrevrangeRsp))
+
+	invalidCommand := "*5\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$3\r\n1-*\r\n$1\r\nb\r\n$1\r\n2\r\n"
+	invalidRsp := handleRequest(client, []byte(invalidCommand))
+	assert.Equal(t, "-ERR STREAM-ID-EQUAL-OR-SMALLER-THAN-TOP-ITEM\r\n", string(invalidRsp))
+}
+
+func TestXReadRequest(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	xaddCommand := "*5\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$3\r\n1-0\r\n$1\r\na\r\n$1\r\n1\r\n"
+	_ = handleRequest(client, []byte(xaddCommand))
+
+	xreadCommand := "*6\r\n$5\r\nXREAD\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$5\r\nother\r\n$1\r\n0\r\n$1\r\n0\r\n"
+	xreadRsp := handleRequest(client, []byte(xreadCommand))
+	assert.Equal(t, "*1\r\n*2\r\n$6\r\nevents\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n", string(xreadRsp))
+
+	unbalancedCommand := "*5\r\n$5\r\nXREAD\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$5\r\nother\r\n$1\r\n0\r\n"
+	unbalancedRsp := handleRequest(client, []byte(unbalancedCommand))
+	assert.Equal(t, "-ERR UNBALANCED-XREAD-LIST-OF-STREAMS\r\n", string(unbalancedRsp))
+}
+
+func TestBlockingXReadRequest(t *testing.T) {
+	xredis := NewXRedis()
+	reader := NewClient(xredis)
+	writer := NewClient(xredis)
+
+	xreadCommand := "*6\r\n$5\r\nXREAD\r\n$5\r\nBLOCK\r\n$1\r\n0\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$1\r\n$\r\n"
+	xreadRsp := make(chan []byte)
+	go func() {
+		xreadRsp <- handleRequest(reader, []byte(xreadCommand))
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	xaddCommand := "*5\r\n$4\r\nXADD\r\n$6\r\nevents\r\n$3\r\n1-0\r\n$1\r\na\r\n$1\r\n1\r\n"
+	_ = handleRequest(writer, []byte(xaddCommand))
+	assert.Equal(t, "*1\r\n*2\r\n$6\r\nevents\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n", string(<-xreadRsp))
+}
+
+func TestBlockingXReadRequestTimesOut(t *testing.T) {
+	xredis := NewXRedis()
+	client := NewClient(xredis)
+
+	xreadCommand := "*6\r\n$5\r\nXREAD\r\n$5\r\nBLOCK\r\n$2\r\n10\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$1\r\n$\r\n"
+	xreadRsp := handleRequest(client, []byte(xreadCommand))
+	assert.Equal(t, "$-1\r\n", string(xreadRsp))
+	assert.Empty(t, xredis.streamWaiters[DEFAULT_DATABASE])
+}
+
+func TestBlockingXReadDoesNotBlockInsideTransaction(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	_ = handleRequest(client, []byte("*1\r\n$5\r\nMULTI\r\n"))
+	xreadCommand := "*6\r\n$5\r\nXREAD\r\n$5\r\nBLOCK\r\n$1\r\n0\r\n$7\r\nSTREAMS\r\n$6\r\nevents\r\n$1\r\n$\r\n"
+	_ = handleRequest(client, []byte(xreadCommand))
+	execRsp := handleRequest(client, []byte("*1\r\n$4\r\nEXEC\r\n"))
+	assert.Equal(t, "*1\r\n$-1\r\n", string(execRsp))
+}
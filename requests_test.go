@@ -9,116 +9,116 @@ import (
 )
 
 func TestInvalidCommand(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	invalidCommand := "*1\r\n$18\r\nNONEXISTENTCOMMAND\r\n"
-	rsp := handleRequest(xredis, []byte(invalidCommand))
+	rsp := handleRequest(client, []byte(invalidCommand))
 	assert.Equal(t, "-ERR INVALID-COMMAND\r\n", string(rsp))
 }
 
 func TestInvalidSerializedRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	invalidCommand := "xxxx"
-	rsp := handleRequest(xredis, []byte(invalidCommand))
+	rsp := handleRequest(client, []byte(invalidCommand))
 	assert.Equal(t, "-ERR FAILED-DESERIALIZING\r\n", string(rsp))
 }
 func TestNonArrayRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	invalidCommand := ":1\r\n"
-	rsp := handleRequest(xredis, []byte(invalidCommand))
+	rsp := handleRequest(client, []byte(invalidCommand))
 	assert.Equal(t, "-ERR UNEXPECTED-ARGUMENT-TYPE\r\n", string(rsp))
 }
 
 func TestPingRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	pingCommand := "*1\r\n$4\r\nPING\r\n"
-	rsp := handleRequest(xredis, []byte(pingCommand))
+	rsp := handleRequest(client, []byte(pingCommand))
 	assert.Equal(t, "$4\r\nPONG\r\n", string(rsp))
 }
 
 func TestEchoRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	echoCommand := "*2\r\n$4\r\nECHO\r\n$16\r\necho-hello-world\r\n"
-	rsp := handleRequest(xredis, []byte(echoCommand))
+	rsp := handleRequest(client, []byte(echoCommand))
 	assert.Equal(t, "$16\r\necho-hello-world\r\n", string(rsp))
 }
 
 func TestSetAndGetRequests(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
-	setRsp := handleRequest(xredis, []byte(setCommand))
+	setRsp := handleRequest(client, []byte(setCommand))
 	assert.Equal(t, "$2\r\nOK\r\n", string(setRsp))
 
 	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp := handleRequest(xredis, []byte(getCommand))
+	getRsp := handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$3\r\nbli\r\n", string(getRsp))
 }
 
 func TestDeleteRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
-	_ = handleRequest(xredis, []byte(setCommand))
+	_ = handleRequest(client, []byte(setCommand))
 	deleteCommand := "*2\r\n$3\r\nDEL\r\n$3\r\nbla\r\n"
-	deleteRsp := handleRequest(xredis, []byte(deleteCommand))
+	deleteRsp := handleRequest(client, []byte(deleteCommand))
 	assert.Equal(t, ":1\r\n", string(deleteRsp))
 }
 
 func TestExistsRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	existsCommand := "*2\r\n$6\r\nEXISTS\r\n$3\r\nbla\r\n"
-	existsRsp := handleRequest(xredis, []byte(existsCommand))
+	existsRsp := handleRequest(client, []byte(existsCommand))
 	assert.Equal(t, ":0\r\n", string(existsRsp))
 
 	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
-	_ = handleRequest(xredis, []byte(setCommand))
+	_ = handleRequest(client, []byte(setCommand))
 	existsCommand = "*2\r\n$6\r\nEXISTS\r\n$3\r\nbla\r\n"
-	existsRsp = handleRequest(xredis, []byte(existsCommand))
+	existsRsp = handleRequest(client, []byte(existsCommand))
 	assert.Equal(t, ":1\r\n", string(existsRsp))
 }
 
 func TestSetAndGetRequestWithExpirationModeEx(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	setCommand := "*5\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n$2\r\nEX\r\n$1\r\n1\r\n"
-	_ = handleRequest(xredis, []byte(setCommand))
+	_ = handleRequest(client, []byte(setCommand))
 
 	time.Sleep(995 * time.Millisecond)
 	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp := handleRequest(xredis, []byte(getCommand))
+	getRsp := handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$3\r\nbli\r\n", string(getRsp))
 
 	time.Sleep(10 * time.Millisecond)
 	getCommand = "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp = handleRequest(xredis, []byte(getCommand))
+	getRsp = handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$-1\r\n", string(getRsp))
 }
 
 func TestSetAndGetRequestWithExpirationModePx(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	setCommand := "*5\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n$2\r\nPX\r\n$3\r\n100\r\n"
-	_ = handleRequest(xredis, []byte(setCommand))
+	_ = handleRequest(client, []byte(setCommand))
 
 	time.Sleep(95 * time.Millisecond)
 	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp := handleRequest(xredis, []byte(getCommand))
+	getRsp := handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$3\r\nbli\r\n", string(getRsp))
 
 	time.Sleep(10 * time.Millisecond)
 	getCommand = "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp = handleRequest(xredis, []byte(getCommand))
+	getRsp = handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$-1\r\n", string(getRsp))
 }
 
 func TestSetAndGetRequestWithExpirationModeExat(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	now := time.Now().UnixMilli()
 	expireTimestamp := (now / 1000) + int64(1)
@@ -126,122 +126,455 @@ func TestSetAndGetRequestWithExpirationModeExat(t *testing.T) {
 	expireTimestampStrLen := strconv.Itoa(len(expireTimestampStr))
 	millisToExpireTimestamp := expireTimestamp*1000 - now
 	setCommand := "*5\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n$4\r\nEXAT\r\n$" + expireTimestampStrLen + "\r\n" + expireTimestampStr + "\r\n"
-	_ = handleRequest(xredis, []byte(setCommand))
+	_ = handleRequest(client, []byte(setCommand))
 
 	time.Sleep(time.Duration((millisToExpireTimestamp - 5)) * time.Millisecond)
 	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp := handleRequest(xredis, []byte(getCommand))
+	getRsp := handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$3\r\nbli\r\n", string(getRsp))
 
 	time.Sleep(10 * time.Millisecond)
 	getCommand = "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp = handleRequest(xredis, []byte(getCommand))
+	getRsp = handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$-1\r\n", string(getRsp))
 }
 
 func TestSetAndGetRequestWithExpirationModePxat(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	expireTimestampStr := strconv.FormatInt(time.Now().UnixMilli()+int64(1000), 10)
 	expireTimestampStrLen := strconv.Itoa(len(expireTimestampStr))
 	setCommand := "*5\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n$4\r\nPXAT\r\n$" + expireTimestampStrLen + "\r\n" + expireTimestampStr + "\r\n"
-	_ = handleRequest(xredis, []byte(setCommand))
+	_ = handleRequest(client, []byte(setCommand))
 
 	time.Sleep(995 * time.Millisecond)
 	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp := handleRequest(xredis, []byte(getCommand))
+	getRsp := handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$3\r\nbli\r\n", string(getRsp))
 
 	time.Sleep(10 * time.Millisecond)
 	getCommand = "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
-	getRsp = handleRequest(xredis, []byte(getCommand))
+	getRsp = handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "$-1\r\n", string(getRsp))
 }
 
 func TestSetAndGetRequestWithInvalidExpirationMode(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	setCommand := "*5\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n$2\r\nXX\r\n$4\r\n1000\r\n"
-	rsp := handleRequest(xredis, []byte(setCommand))
+	rsp := handleRequest(client, []byte(setCommand))
 	assert.Equal(t, "-ERR UNRECOGNIZED-TIMEOUT-MODE\r\n", string(rsp))
 }
 
 func TestSetAndGetRequestWithInvalidExpirationValue(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	setCommand := "*5\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n$2\r\nPX\r\n$4\r\nxxxx\r\n"
-	rsp := handleRequest(xredis, []byte(setCommand))
+	rsp := handleRequest(client, []byte(setCommand))
 	assert.Equal(t, "-ERR INVALID-TIMEOUT-VALUE\r\n", string(rsp))
 }
 
 func TestIncrementRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	incrCommand := "*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"
-	incrRsp := handleRequest(xredis, []byte(incrCommand))
+	incrRsp := handleRequest(client, []byte(incrCommand))
 	assert.Equal(t, "$1\r\n1\r\n", string(incrRsp))
 
-	incrRsp = handleRequest(xredis, []byte(incrCommand))
+	incrRsp = handleRequest(client, []byte(incrCommand))
 	assert.Equal(t, "$1\r\n2\r\n", string(incrRsp))
 }
 
 func TestDecrementRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	decrCommand := "*2\r\n$4\r\nDECR\r\n$7\r\ncounter\r\n"
-	decrRsp := handleRequest(xredis, []byte(decrCommand))
+	decrRsp := handleRequest(client, []byte(decrCommand))
 	assert.Equal(t, "$2\r\n-1\r\n", string(decrRsp))
 
-	decrRsp = handleRequest(xredis, []byte(decrCommand))
+	decrRsp = handleRequest(client, []byte(decrCommand))
 	assert.Equal(t, "$2\r\n-2\r\n", string(decrRsp))
 }
 
 func TestIncrementNonNumericKeyRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	setCommand := "*3\r\n$3\r\nSET\r\n$7\r\ncounter\r\n$4\r\ntext\r\n"
-	_ = handleRequest(xredis, []byte(setCommand))
+	_ = handleRequest(client, []byte(setCommand))
 	incrCommand := "*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"
-	incrRsp := handleRequest(xredis, []byte(incrCommand))
+	incrRsp := handleRequest(client, []byte(incrCommand))
 	assert.Equal(t, "-ERR VALUE-NOT-NUMERIC-OR-MAX-REACHED\r\n", string(incrRsp))
 }
 
 func TestDecrementNonNumericKeyRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	setCommand := "*3\r\n$3\r\nSET\r\n$7\r\ncounter\r\n$4\r\ntext\r\n"
-	_ = handleRequest(xredis, []byte(setCommand))
+	_ = handleRequest(client, []byte(setCommand))
 	decrCommand := "*2\r\n$4\r\nDECR\r\n$7\r\ncounter\r\n"
-	decrRsp := handleRequest(xredis, []byte(decrCommand))
+	decrRsp := handleRequest(client, []byte(decrCommand))
 	assert.Equal(t, "-ERR VALUE-NOT-NUMERIC-OR-MAX-REACHED\r\n", string(decrRsp))
 }
 
 func TestLPushRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	lpushCommand1 := "*3\r\n$5\r\nLPUSH\r\n$4\r\nlist\r\n$4\r\nxxxx\r\n"
 	lpushCommand2 := "*3\r\n$5\r\nLPUSH\r\n$4\r\nlist\r\n$4\r\nyyyy\r\n"
 	lpushCommand3 := "*3\r\n$5\r\nLPUSH\r\n$4\r\nlist\r\n$4\r\nzzzz\r\n"
-	_ = handleRequest(xredis, []byte(lpushCommand1))
-	_ = handleRequest(xredis, []byte(lpushCommand2))
-	_ = handleRequest(xredis, []byte(lpushCommand3))
+	_ = handleRequest(client, []byte(lpushCommand1))
+	_ = handleRequest(client, []byte(lpushCommand2))
+	_ = handleRequest(client, []byte(lpushCommand3))
 
 	getCommand := "*2\r\n$3\r\nGET\r\n$4\r\nlist\r\n"
-	getRsp := handleRequest(xredis, []byte(getCommand))
+	getRsp := handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "*3\r\n$4\r\nzzzz\r\n$4\r\nyyyy\r\n$4\r\nxxxx\r\n", string(getRsp))
 }
 
 func TestRPushRequest(t *testing.T) {
-	xredis := NewXRedis()
+	client := NewClient(NewXRedis())
 
 	rpushCommand1 := "*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$4\r\nxxxx\r\n"
 	rpushCommand2 := "*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$4\r\nyyyy\r\n"
 	rpushCommand3 := "*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$4\r\nzzzz\r\n"
-	_ = handleRequest(xredis, []byte(rpushCommand1))
-	_ = handleRequest(xredis, []byte(rpushCommand2))
-	_ = handleRequest(xredis, []byte(rpushCommand3))
+	_ = handleRequest(client, []byte(rpushCommand1))
+	_ = handleRequest(client, []byte(rpushCommand2))
+	_ = handleRequest(client, []byte(rpushCommand3))
 
 	getCommand := "*2\r\n$3\r\nGET\r\n$4\r\nlist\r\n"
-	getRsp := handleRequest(xredis, []byte(getCommand))
+	getRsp := handleRequest(client, []byte(getCommand))
 	assert.Equal(t, "*3\r\n$4\r\nxxxx\r\n$4\r\nyyyy\r\n$4\r\nzzzz\r\n", string(getRsp))
 }
+
+func TestObjectEncodingRequest(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$7\r\ncounter\r\n$2\r\n42\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	objectCommand := "*3\r\n$6\r\nOBJECT\r\n$8\r\nENCODING\r\n$7\r\ncounter\r\n"
+	objectRsp := handleRequest(client, []byte(objectCommand))
+	assert.Equal(t, "$3\r\nint\r\n", string(objectRsp))
+
+	getCommand := "*2\r\n$3\r\nGET\r\n$7\r\ncounter\r\n"
+	getRsp := handleRequest(client, []byte(getCommand))
+	assert.Equal(t, "$2\r\n42\r\n", string(getRsp))
+
+	objectCommand = "*3\r\n$6\r\nOBJECT\r\n$8\r\nENCODING\r\n$7\r\nmissing\r\n"
+	objectRsp = handleRequest(client, []byte(objectCommand))
+	assert.Equal(t, "$-1\r\n", string(objectRsp))
+}
+
+func TestTypeRequest(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	typeCommand := "*2\r\n$4\r\nTYPE\r\n$3\r\nbla\r\n"
+	typeRsp := handleRequest(client, []byte(typeCommand))
+	assert.Equal(t, "$6\r\nstring\r\n", string(typeRsp))
+}
+
+func TestScanAndDBSizeRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	scanCommand := "*6\r\n$4\r\nSCAN\r\n$1\r\n0\r\n$5\r\nMATCH\r\n$2\r\nb*\r\n$5\r\nCOUNT\r\n$3\r\n100\r\n"
+	scanRsp := handleRequest(client, []byte(scanCommand))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*1\r\n$3\r\nbla\r\n", string(scanRsp))
+
+	dbSizeCommand := "*1\r\n$6\r\nDBSIZE\r\n"
+	dbSizeRsp := handleRequest(client, []byte(dbSizeCommand))
+	assert.Equal(t, ":1\r\n", string(dbSizeRsp))
+}
+
+func TestScanRequestWithInvalidOptions(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	scanCommand := "*3\r\n$4\r\nSCAN\r\n$1\r\n0\r\n$5\r\nMATCH\r\n"
+	scanRsp := handleRequest(client, []byte(scanCommand))
+	assert.Equal(t, "-ERR SYNTAX-ERROR\r\n", string(scanRsp))
+
+	scanCommand = "*2\r\n$4\r\nSCAN\r\n$1\r\nx\r\n"
+	scanRsp = handleRequest(client, []byte(scanCommand))
+	assert.Equal(t, "-ERR INVALID-CURSOR\r\n", string(scanRsp))
+}
+
+func TestCollectionScanRequestOnMissingKey(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	hscanCommand := "*3\r\n$5\r\nHSCAN\r\n$4\r\nhash\r\n$1\r\n0\r\n"
+	hscanRsp := handleRequest(client, []byte(hscanCommand))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*0\r\n", string(hscanRsp))
+}
+
+func TestCollectionScanRequestOnWrongType(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	sscanCommand := "*3\r\n$5\r\nSSCAN\r\n$3\r\nbla\r\n$1\r\n0\r\n"
+	sscanRsp := handleRequest(client, []byte(sscanCommand))
+	assert.Equal(t, "-ERR VALUE-WRONG-TYPE\r\n", string(sscanRsp))
+}
+
+func TestRenameRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	renameCommand := "*3\r\n$6\r\nRENAME\r\n$3\r\nbla\r\n$3\r\nblo\r\n"
+	renameRsp := handleRequest(client, []byte(renameCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(renameRsp))
+
+	renameRsp = handleRequest(client, []byte(renameCommand))
+	assert.Equal(t, "-ERR NO-SUCH-KEY\r\n", string(renameRsp))
+
+	_ = handleRequest(client, []byte(setCommand))
+	renameNxCommand := "*3\r\n$8\r\nRENAMENX\r\n$3\r\nbla\r\n$3\r\nblo\r\n"
+	renameNxRsp := handleRequest(client, []byte(renameNxCommand))
+	assert.Equal(t, ":0\r\n", string(renameNxRsp))
+}
+
+func TestCopyRequest(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	copyCommand := "*4\r\n$4\r\nCOPY\r\n$3\r\nbla\r\n$3\r\nblo\r\n$7\r\nREPLACE\r\n"
+	copyRsp := handleRequest(client, []byte(copyCommand))
+	assert.Equal(t, ":1\r\n", string(copyRsp))
+
+	copyCommand = "*5\r\n$4\r\nCOPY\r\n$3\r\nbla\r\n$3\r\nblo\r\n$2\r\nDB\r\n$2\r\n99\r\n"
+	copyRsp = handleRequest(client, []byte(copyCommand))
+	assert.Equal(t, "-ERR DB-INDEX-OUT-OF-RANGE\r\n", string(copyRsp))
+}
+
+func TestSelectRequest(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	selectCommand := "*2\r\n$6\r\nSELECT\r\n$1\r\n1\r\n"
+	selectRsp := handleRequest(client, []byte(selectCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(selectRsp))
+
+	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
+	getRsp := handleRequest(client, []byte(getCommand))
+	assert.Equal(t, "$-1\r\n", string(getRsp))
+
+	selectCommand = "*2\r\n$6\r\nSELECT\r\n$2\r\n16\r\n"
+	selectRsp = handleRequest(client, []byte(selectCommand))
+	assert.Equal(t, "-ERR DB-INDEX-OUT-OF-RANGE\r\n", string(selectRsp))
+}
+
+func TestSelectIsPerClient(t *testing.T) {
+	xredis := NewXRedis()
+	client1 := NewClient(xredis)
+	client2 := NewClient(xredis)
+
+	selectCommand := "*2\r\n$6\r\nSELECT\r\n$1\r\n1\r\n"
+	_ = handleRequest(client1, []byte(selectCommand))
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client1, []byte(setCommand))
+
+	existsCommand := "*2\r\n$6\r\nEXISTS\r\n$3\r\nbla\r\n"
+	existsRsp := handleRequest(client2, []byte(existsCommand))
+	assert.Equal(t, ":0\r\n", string(existsRsp))
+}
+
+func TestFlushAndSwapDBRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	swapCommand := "*3\r\n$6\r\nSWAPDB\r\n$1\r\n0\r\n$1\r\n1\r\n"
+	swapRsp := handleRequest(client, []byte(swapCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(swapRsp))
+
+	selectCommand := "*2\r\n$6\r\nSELECT\r\n$1\r\n1\r\n"
+	_ = handleRequest(client, []byte(selectCommand))
+	dbSizeCommand := "*1\r\n$6\r\nDBSIZE\r\n"
+	dbSizeRsp := handleRequest(client, []byte(dbSizeCommand))
+	assert.Equal(t, ":1\r\n", string(dbSizeRsp))
+
+	flushCommand := "*2\r\n$7\r\nFLUSHDB\r\n$5\r\nASYNC\r\n"
+	flushRsp := handleRequest(client, []byte(flushCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(flushRsp))
+	dbSizeRsp = handleRequest(client, []byte(dbSizeCommand))
+	assert.Equal(t, ":0\r\n", string(dbSizeRsp))
+
+	flushCommand = "*2\r\n$8\r\nFLUSHALL\r\n$4\r\nLATE\r\n"
+	flushRsp = handleRequest(client, []byte(flushCommand))
+	assert.Equal(t, "-ERR SYNTAX-ERROR\r\n", string(flushRsp))
+}
+
+func TestMultiExecRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	multiCommand := "*1\r\n$5\r\nMULTI\r\n"
+	multiRsp := handleRequest(client, []byte(multiCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(multiRsp))
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	setRsp := handleRequest(client, []byte(setCommand))
+	assert.Equal(t, "$6\r\nQUEUED\r\n", string(setRsp))
+	incrCommand := "*2\r\n$4\r\nINCR\r\n$3\r\nbla\r\n"
+	incrRsp := handleRequest(client, []byte(incrCommand))
+	assert.Equal(t, "$6\r\nQUEUED\r\n", string(incrRsp))
+
+	execCommand := "*1\r\n$4\r\nEXEC\r\n"
+	execRsp := handleRequest(client, []byte(execCommand))
+	assert.Equal(t, "*2\r\n$2\r\nOK\r\n-ERR VALUE-NOT-NUMERIC-OR-MAX-REACHED\r\n", string(execRsp))
+
+	getCommand := "*2\r\n$3\r\nGET\r\n$3\r\nbla\r\n"
+	getRsp := handleRequest(client, []byte(getCommand))
+	assert.Equal(t, "$3\r\nbli\r\n", string(getRsp))
+}
+
+func TestMultiDiscardRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	multiCommand := "*1\r\n$5\r\nMULTI\r\n"
+	_ = handleRequest(client, []byte(multiCommand))
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	discardCommand := "*1\r\n$7\r\nDISCARD\r\n"
+	discardRsp := handleRequest(client, []byte(discardCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(discardRsp))
+
+	existsCommand := "*2\r\n$6\r\nEXISTS\r\n$3\r\nbla\r\n"
+	existsRsp := handleRequest(client, []byte(existsCommand))
+	assert.Equal(t, ":0\r\n", string(existsRsp))
+
+	execCommand := "*1\r\n$4\r\nEXEC\r\n"
+	execRsp := handleRequest(client, []byte(execCommand))
+	assert.Equal(t, "-ERR EXEC-WITHOUT-MULTI\r\n", string(execRsp))
+}
+
+func TestExecAbortsOnQueuingErrors(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	multiCommand := "*1\r\n$5\r\nMULTI\r\n"
+	_ = handleRequest(client, []byte(multiCommand))
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	getCommand := "*1\r\n$3\r\nGET\r\n"
+	getRsp := handleRequest(client, []byte(getCommand))
+	assert.Equal(t, "-ERR INVALID-ARGUMENTS-NUMBER\r\n", string(getRsp))
+
+	execCommand := "*1\r\n$4\r\nEXEC\r\n"
+	execRsp := handleRequest(client, []byte(execCommand))
+	assert.Equal(t, "-EXECABORT TRANSACTION-DISCARDED-BECAUSE-OF-PREVIOUS-ERRORS\r\n", string(execRsp))
+
+	existsCommand := "*2\r\n$6\r\nEXISTS\r\n$3\r\nbla\r\n"
+	existsRsp := handleRequest(client, []byte(existsCommand))
+	assert.Equal(t, ":0\r\n", string(existsRsp))
+}
+
+func TestSelectInsideTransaction(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	multiCommand := "*1\r\n$5\r\nMULTI\r\n"
+	_ = handleRequest(client, []byte(multiCommand))
+	selectCommand := "*2\r\n$6\r\nSELECT\r\n$1\r\n1\r\n"
+	_ = handleRequest(client, []byte(selectCommand))
+	execCommand := "*1\r\n$4\r\nEXEC\r\n"
+	_ = handleRequest(client, []byte(execCommand))
+
+	assert.Equal(t, 1, client.xredis.db)
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	setRsp := handleRequest(client, []byte(setCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(setRsp))
+}
+
+func TestWatchAbortsExecWhenKeyChanges(t *testing.T) {
+	xredis := NewXRedis()
+	client1 := NewClient(xredis)
+	client2 := NewClient(xredis)
+
+	watchCommand := "*2\r\n$5\r\nWATCH\r\n$5\r\nstock\r\n"
+	watchRsp := handleRequest(client1, []byte(watchCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(watchRsp))
+	multiCommand := "*1\r\n$5\r\nMULTI\r\n"
+	_ = handleRequest(client1, []byte(multiCommand))
+	decrCommand := "*2\r\n$4\r\nDECR\r\n$5\r\nstock\r\n"
+	_ = handleRequest(client1, []byte(decrCommand))
+
+	setCommand := "*3\r\n$3\r\nSET\r\n$5\r\nstock\r\n$2\r\n10\r\n"
+	_ = handleRequest(client2, []byte(setCommand))
+
+	execCommand := "*1\r\n$4\r\nEXEC\r\n"
+	execRsp := handleRequest(client1, []byte(execCommand))
+	assert.Equal(t, "$-1\r\n", string(execRsp))
+
+	getCommand := "*2\r\n$3\r\nGET\r\n$5\r\nstock\r\n"
+	getRsp := handleRequest(client1, []byte(getCommand))
+	assert.Equal(t, "$2\r\n10\r\n", string(getRsp))
+
+	// EXEC unwatches every key, so the next transaction goes through
+	_ = handleRequest(client1, []byte(multiCommand))
+	_ = handleRequest(client1, []byte(decrCommand))
+	execRsp = handleRequest(client1, []byte(execCommand))
+	assert.Equal(t, "*1\r\n$1\r\n9\r\n", string(execRsp))
+}
+
+func TestUnwatchRequest(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	watchCommand := "*2\r\n$5\r\nWATCH\r\n$3\r\nbla\r\n"
+	_ = handleRequest(client, []byte(watchCommand))
+	setCommand := "*3\r\n$3\r\nSET\r\n$3\r\nbla\r\n$3\r\nbli\r\n"
+	_ = handleRequest(client, []byte(setCommand))
+	unwatchCommand := "*1\r\n$7\r\nUNWATCH\r\n"
+	unwatchRsp := handleRequest(client, []byte(unwatchCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(unwatchRsp))
+
+	multiCommand := "*1\r\n$5\r\nMULTI\r\n"
+	_ = handleRequest(client, []byte(multiCommand))
+	execCommand := "*1\r\n$4\r\nEXEC\r\n"
+	execRsp := handleRequest(client, []byte(execCommand))
+	assert.Equal(t, "*0\r\n", string(execRsp))
+}
+
+func TestWatchInsideMultiIsRefusedWithoutAbortingTransaction(t *testing.T) {
+	client := NewClient(NewXRedis())
+
+	multiCommand := "*1\r\n$5\r\nMULTI\r\n"
+	_ = handleRequest(client, []byte(multiCommand))
+	watchCommand := "*2\r\n$5\r\nWATCH\r\n$3\r\nbla\r\n"
+	watchRsp := handleRequest(client, []byte(watchCommand))
+	assert.Equal(t, "-ERR WATCH-INSIDE-MULTI-IS-NOT-ALLOWED\r\n", string(watchRsp))
+	incrCommand := "*2\r\n$4\r\nINCR\r\n$7\r\ncounter\r\n"
+	_ = handleRequest(client, []byte(incrCommand))
+
+	execCommand := "*1\r\n$4\r\nEXEC\r\n"
+	execRsp := handleRequest(client, []byte(execCommand))
+	assert.Equal(t, "*1\r\n$1\r\n1\r\n", string(execRsp))
+}
+
+func TestDumpAndRestoreRequests(t *testing.T) {
+	client := NewClient(NewXRedis())
+	_ = handleRequest(client, []byte("*3\r\n$5\r\nRPUSH\r\n$4\r\nlist\r\n$1\r\na\r\n"))
+
+	dumpRsp := handleRequest(client, []byte("*2\r\n$4\r\nDUMP\r\n$4\r\nlist\r\n"))
+	payload, _, _ := deserializeRespDataType(dumpRsp)
+	restoreCommand := stringsToRespArray([]string{"RESTORE", "copy", "100000", payload.(RespString).Str}).serialize()
+	restoreRsp := handleRequest(client, []byte(restoreCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(restoreRsp))
+	typeRsp := handleRequest(client, []byte("*2\r\n$4\r\nTYPE\r\n$4\r\ncopy\r\n"))
+	assert.Equal(t, "$4\r\nlist\r\n", string(typeRsp))
+
+	busyRsp := handleRequest(client, []byte(restoreCommand))
+	assert.Equal(t, "-BUSYKEY TARGET-KEY-NAME-ALREADY-EXISTS\r\n", string(busyRsp))
+	replaceCommand := stringsToRespArray([]string{"RESTORE", "copy", "0", payload.(RespString).Str, "REPLACE"}).serialize()
+	replaceRsp := handleRequest(client, []byte(replaceCommand))
+	assert.Equal(t, "$2\r\nOK\r\n", string(replaceRsp))
+
+	negativeTTLRsp := handleRequest(client, []byte("*4\r\n$7\r\nRESTORE\r\n$3\r\nkey\r\n$2\r\n-1\r\n$1\r\nx\r\n"))
+	assert.Equal(t, "-ERR INVALID-TTL-VALUE-MUST-BE-GREATER-OR-EQUAL-TO-0\r\n", string(negativeTTLRsp))
+	invalidRsp := handleRequest(client, []byte("*4\r\n$7\r\nRESTORE\r\n$3\r\nkey\r\n$1\r\n0\r\n$1\r\nx\r\n"))
+	assert.Equal(t, "-ERR DUMP-PAYLOAD-VERSION-OR-CHECKSUM-ARE-WRONG\r\n", string(invalidRsp))
+	missingRsp := handleRequest(client, []byte("*2\r\n$4\r\nDUMP\r\n$7\r\nmissing\r\n"))
+	assert.Equal(t, "$-1\r\n", string(missingRsp))
+}
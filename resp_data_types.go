@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -85,19 +86,26 @@ func (respNil RespNil) serialize() string {
 	return builder.String()
 }
 
+// ERROR_INCOMPLETE_RESP_DATA is wrapped by the deserialization errors of
+// data cut short, which would be valid had it been complete
+var ERROR_INCOMPLETE_RESP_DATA = errors.New("Incomplete data")
+
 func deserializeRespDataType(data []byte) (RespDataType, int, error) {
+	if len(data) == 0 {
+		return nil, 0, ERROR_INCOMPLETE_RESP_DATA
+	}
 	switch string(data[0]) {
 	case SERIALIZATION_PREFIX_STRING:
 		terminationIndex := bytes.Index(data, []byte(SERIALIZATION_SEPARATOR))
 		if terminationIndex == -1 {
-			return nil, 0, errors.New("Missing string termination")
+			return nil, 0, fmt.Errorf("Missing string termination: %w", ERROR_INCOMPLETE_RESP_DATA)
 		}
 		bytesConsumed := terminationIndex + len(SERIALIZATION_SEPARATOR)
 		return RespString{string(data[1:terminationIndex])}, bytesConsumed, nil
 	case SERIALIZATION_PREFIX_INT:
 		terminationIndex := bytes.Index(data, []byte(SERIALIZATION_SEPARATOR))
 		if terminationIndex == -1 {
-			return nil, 0, errors.New("Missing int termination")
+			return nil, 0, fmt.Errorf("Missing int termination: %w", ERROR_INCOMPLETE_RESP_DATA)
 		}
 		val, err := strconv.ParseInt(string(data[1:terminationIndex]), 10, 64)
 		if err != nil {
@@ -108,14 +116,14 @@ func deserializeRespDataType(data []byte) (RespDataType, int, error) {
 	case SERIALIZATION_PREFIX_ERROR:
 		terminationIndex := bytes.Index(data, []byte(SERIALIZATION_SEPARATOR))
 		if terminationIndex == -1 {
-			return nil, 0, errors.New("Missing error termination")
+			return nil, 0, fmt.Errorf("Missing error termination: %w", ERROR_INCOMPLETE_RESP_DATA)
 		}
 		bytesConsumed := terminationIndex + len(SERIALIZATION_SEPARATOR)
 		return RespError{string(data[1:terminationIndex])}, bytesConsumed, nil
 	case SERIALIZATION_PREFIX_BULK_STRING:
 		sizeTerminationIndex := bytes.Index(data, []byte(SERIALIZATION_SEPARATOR))
 		if sizeTerminationIndex == -1 {
-			return nil, 0, errors.New("Missing bulk string size termination")
+			return nil, 0, fmt.Errorf("Missing bulk string size termination: %w", ERROR_INCOMPLETE_RESP_DATA)
 		}
 		strSize, err := strconv.Atoi(string(data[1:sizeTerminationIndex]))
 		if err != nil {
@@ -123,11 +131,17 @@ func deserializeRespDataType(data []byte) (RespDataType, int, error) {
 		}
 		strInitialPos := sizeTerminationIndex + len(SERIALIZATION_SEPARATOR)
 		bytesConsumed := sizeTerminationIndex + 2*len(SERIALIZATION_SEPARATOR) + strSize
+		if strSize < 0 {
+			return nil, 0, errors.New("Negative bulk string size")
+		}
+		if len(data) < bytesConsumed {
+			return nil, 0, fmt.Errorf("Missing bulk string content: %w", ERROR_INCOMPLETE_RESP_DATA)
+		}
 		return RespString{string(data[strInitialPos : strInitialPos+strSize])}, bytesConsumed, nil
 	case SERIALIZATION_PREFIX_ARRAY:
 		sizeTerminationIndex := bytes.Index(data, []byte(SERIALIZATION_SEPARATOR))
 		if sizeTerminationIndex == -1 {
-			return nil, 0, errors.New("Missing array size termination")
+			return nil, 0, fmt.Errorf("Missing array size termination: %w", ERROR_INCOMPLETE_RESP_DATA)
 		}
 		arraySize, err := strconv.Atoi(string(data[1:sizeTerminationIndex]))
 		if err != nil {
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"cmp"
+	"hash/fnv"
+	"math"
+	"slices"
+)
+
+// Members are visited by SCAN and the per type scanners in the order of
+// their 64 bit hash and the cursor is the hash to resume from, so the order
+// doesn't depend on what is added or removed between calls: every member
+// present for the whole scan is returned at least once, no matter how the
+// collection changes. Members sharing a hash are always returned in the
+// same batch. A returned cursor of 0 signals the end of the scan.
+
+// SCAN_INDEX_CHUNK_SIZE is the number of entries a chunk of a scan index
+// starts with. Chunks are split once they reach twice that size.
+const SCAN_INDEX_CHUNK_SIZE = 256
+
+// scanIndex keeps the members of a collection, or the keys of a database,
+// in scan order, so that each call only does O(log N + COUNT) work. The
+// entries are split into chunks so that adding or removing a member only
+// moves the entries of one chunk. Collections and databases build it on
+// their first scan and keep it up to date from then on.
+type scanIndex struct {
+	chunks [][]scanIndexEntry // Each one non empty, ordered by hash, then by member
+}
+
+type scanIndexEntry struct {
+	hash   uint64
+	member string
+}
+
+func newScanIndex(members []string) *scanIndex {
+	entries := make([]scanIndexEntry, 0, len(members))
+	for _, member := range members {
+		entries = append(entries, scanIndexEntry{scanHash(member), member})
+	}
+	slices.SortFunc(entries, compareScanIndexEntries)
+	index := &scanIndex{}
+	for chunk := range slices.Chunk(entries, SCAN_INDEX_CHUNK_SIZE) {
+		index.chunks = append(index.chunks, chunk)
+	}
+	return index
+}
+
+func (index *scanIndex) add(member string) {
+	entry := scanIndexEntry{scanHash(member), member}
+	if len(index.chunks) == 0 {
+		index.chunks = append(index.chunks, []scanIndexEntry{entry})
+		return
+	}
+	c := min(index.chunkOf(entry), len(index.chunks)-1)
+	chunk := index.chunks[c]
+	position, found := slices.BinarySearchFunc(chunk, entry, compareScanIndexEntries)
+	if found {
+		return
+	}
+	chunk = slices.Insert(chunk, position, entry)
+	if len(chunk) < 2*SCAN_INDEX_CHUNK_SIZE {
+		index.chunks[c] = chunk
+		return
+	}
+	// Both halves are cloned so that neither grows over the other
+	half := len(chunk) / 2
+	index.chunks[c] = slices.Clone(chunk[:half])
+	index.chunks = slices.Insert(index.chunks, c+1, slices.Clone(chunk[half:]))
+}
+
+func (index *scanIndex) remove(member string) {
+	entry := scanIndexEntry{scanHash(member), member}
+	c := index.chunkOf(entry)
+	if c == len(index.chunks) {
+		return
+	}
+	position, found := slices.BinarySearchFunc(index.chunks[c], entry, compareScanIndexEntries)
+	if !found {
+		return
+	}
+	index.chunks[c] = slices.Delete(index.chunks[c], position, position+1)
+	if len(index.chunks[c]) == 0 {
+		index.chunks = slices.Delete(index.chunks, c, c+1)
+	}
+}
+
+// chunkOf returns the first chunk whose last entry isn't before the entry,
+// that is the chunk holding it if it is indexed, or len(chunks) if the
+// entry comes after every indexed one.
+func (index *scanIndex) chunkOf(entry scanIndexEntry) int {
+	c, _ := slices.BinarySearchFunc(index.chunks, entry, func(chunk []scanIndexEntry, entry scanIndexEntry) int {
+		return compareScanIndexEntries(chunk[len(chunk)-1], entry)
+	})
+	return c
+}
+
+// scan returns the members of the count distinct hashes starting at cursor
+func (index *scanIndex) scan(cursor uint64, count int) ([]string, uint64) {
+	c := index.chunkOf(scanIndexEntry{hash: cursor})
+	if c == len(index.chunks) {
+		return nil, 0
+	}
+	start, _ := slices.BinarySearchFunc(index.chunks[c], cursor, func(entry scanIndexEntry, hash uint64) int {
+		return cmp.Compare(entry.hash, hash)
+	})
+	var batch []string
+	distinctHashes := 0
+	previous := scanIndexEntry{}
+	for ; c < len(index.chunks); c++ {
+		for _, entry := range index.chunks[c][start:] {
+			if len(batch) == 0 || entry.hash != previous.hash {
+				if distinctHashes == count {
+					return batch, previous.hash + 1
+				}
+				distinctHashes++
+			}
+			batch = append(batch, entry.member)
+			previous = entry
+		}
+		start = 0
+	}
+	return batch, 0
+}
+
+func compareScanIndexEntries(a scanIndexEntry, b scanIndexEntry) int {
+	if c := cmp.Compare(a.hash, b.hash); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.member, b.member)
+}
+
+func scanHash(member string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(member))
+	// Cursor 0 marks both the start and the end of a scan, so the last
+	// hash value must not be reachable as a "next" cursor.
+	return min(hash.Sum64(), math.MaxUint64-1)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanIndexVisitsEveryMember(t *testing.T) {
+	var members []string
+	for i := range 100 {
+		members = append(members, strconv.Itoa(i))
+	}
+	index := newScanIndex(members)
+
+	var scanned []string
+	cursor := uint64(0)
+	for {
+		batch, nextCursor := index.scan(cursor, 7)
+		assert.LessOrEqual(t, len(batch), 7)
+		scanned = append(scanned, batch...)
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.ElementsMatch(t, members, scanned)
+}
+
+func TestScanIndexToleratesModification(t *testing.T) {
+	var members []string
+	for i := range 50 {
+		members = append(members, "stable-"+strconv.Itoa(i))
+	}
+	index := newScanIndex(members)
+
+	seen := make(map[string]bool)
+	cursor := uint64(0)
+	for iteration := 0; ; iteration++ {
+		batch, nextCursor := index.scan(cursor, 5)
+		for _, member := range batch {
+			seen[member] = true
+		}
+		index.add("added-" + strconv.Itoa(iteration))
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := range 50 {
+		assert.True(t, seen["stable-"+strconv.Itoa(i)])
+	}
+}
+
+func TestScanIndexEmpty(t *testing.T) {
+	batch, cursor := newScanIndex(nil).scan(0, 10)
+	assert.Empty(t, batch)
+	assert.Equal(t, uint64(0), cursor)
+}
+
+func TestScanIndexFollowsItsCollection(t *testing.T) {
+	var members []string
+	for i := range 100 {
+		members = append(members, strconv.Itoa(i))
+	}
+	index := newScanIndex(members)
+	index.add("100")
+	index.add("100")
+	index.remove("0")
+	index.remove("missing")
+	indexed := append(slices.Clone(members[1:]), "100")
+	rebuilt := newScanIndex(indexed)
+
+	var scanned []string
+	cursor := uint64(0)
+	for {
+		batch, nextCursor := index.scan(cursor, 7)
+		scanned = append(scanned, batch...)
+		expected, expectedCursor := rebuilt.scan(cursor, 7)
+		assert.Equal(t, expected, batch)
+		assert.Equal(t, expectedCursor, nextCursor)
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.ElementsMatch(t, indexed, scanned)
+}
+
+func TestScanIndexSplitsAndDropsChunks(t *testing.T) {
+	index := newScanIndex(nil)
+	for i := range 10 * SCAN_INDEX_CHUNK_SIZE {
+		index.add(strconv.Itoa(i))
+	}
+	assert.Greater(t, len(index.chunks), 5)
+	for i := range 9 * SCAN_INDEX_CHUNK_SIZE {
+		index.remove(strconv.Itoa(i))
+	}
+
+	var scanned []string
+	cursor := uint64(0)
+	for {
+		batch, nextCursor := index.scan(cursor, 100)
+		scanned = append(scanned, batch...)
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	var remaining []string
+	for i := 9 * SCAN_INDEX_CHUNK_SIZE; i < 10*SCAN_INDEX_CHUNK_SIZE; i++ {
+		remaining = append(remaining, strconv.Itoa(i))
+	}
+	assert.Equal(t, len(remaining), len(scanned))
+	assert.ElementsMatch(t, remaining, scanned)
+	for _, chunk := range index.chunks {
+		assert.NotEmpty(t, chunk)
+		assert.Less(t, len(chunk), 2*SCAN_INDEX_CHUNK_SIZE)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"slices"
+)
+
+const ENCODING_INTSET = "intset"
+
+// Maximum number of integers kept in an intset, as in Redis
+const INTSET_MAX_ENTRIES = 512
+
+// Set holds unique members. Sets left without members are deleted, as in
+// Redis.
+type Set struct {
+	members map[string]struct{}
+	index   *scanIndex // Built by the first SSCAN
+}
+
+func NewSet() *Set {
+	return &Set{members: make(map[string]struct{})}
+}
+
+// SAdd adds the members to the set, creating it if needed, and returns the
+// number of members added.
+func (xredis *XRedis) SAdd(key string, members []string) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- SAddCommand{xredis.db, key, members, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// SRem removes the members from the set and returns how many were there.
+func (xredis *XRedis) SRem(key string, members []string) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- SRemCommand{xredis.db, key, members, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) handleSAddCommand(cmd SAddCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	set, exists, err := xredis.getSet(cmd.db, cmd.key)
+	if err != nil {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+	if !exists {
+		set = NewSet()
+	}
+	added := 0
+	for _, member := range cmd.members {
+		if set.add(member) {
+			added++
+		}
+	}
+
+	if added > 0 {
+		if !exists {
+			xredis.setValue(cmd.db, cmd.key, XRedisValue{set, NON_EXPIRATION_TIME})
+		}
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_SET, KEYSPACE_EVENT_SADD, cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- added
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleSRemCommand(cmd SRemCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	set, exists, err := xredis.getSet(cmd.db, cmd.key)
+	if !exists {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+	removed := 0
+	for _, member := range cmd.members {
+		if set.remove(member) {
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		if set.len() == 0 {
+			xredis.deleteValue(cmd.db, cmd.key)
+		}
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_SET, KEYSPACE_EVENT_SREM, cmd.db, cmd.key)
+		if set.len() == 0 {
+			xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_DEL, cmd.db, cmd.key)
+		}
+	}
+	cmd.rspChannel <- removed
+	cmd.errorChannel <- nil
+}
+
+// getSet returns the set stored at key, failing if the key holds a value of
+// another type.
+func (xredis *XRedis) getSet(db int, key string) (*Set, bool, error) {
+	value, exists := xredis.getAndInvalidateIfExpired(db, key)
+	if !exists {
+		return nil, false, nil
+	}
+	set, ok := value.Element.(*Set)
+	if !ok {
+		return nil, false, errors.New(REQUEST_ERROR_WRONG_TYPE)
+	}
+	return set, true, nil
+}
+
+// add adds the member, returning whether it wasn't there yet.
+func (set *Set) add(member string) bool {
+	if set.contains(member) {
+		return false
+	}
+	set.members[member] = struct{}{}
+	if set.index != nil {
+		set.index.add(member)
+	}
+	return true
+}
+
+func (set *Set) remove(member string) bool {
+	if !set.contains(member) {
+		return false
+	}
+	delete(set.members, member)
+	if set.index != nil {
+		set.index.remove(member)
+	}
+	return true
+}
+
+func (set *Set) contains(member string) bool {
+	_, exists := set.members[member]
+	return exists
+}
+
+func (set *Set) len() int {
+	return len(set.members)
+}
+
+// encoding reports the encoding Redis would use for the set: an intset for
+// small sets of canonical integers, a listpack for other small sets.
+func (set *Set) encoding() string {
+	if set.len() <= INTSET_MAX_ENTRIES && set.onlyIntegers() {
+		return ENCODING_INTSET
+	}
+	if set.len() <= LISTPACK_MAX_ENTRIES {
+		return ENCODING_LISTPACK
+	}
+	return ENCODING_HASHTABLE
+}
+
+func (set *Set) onlyIntegers() bool {
+	for member := range set.members {
+		if _, isInt := encodeStringValue(RespString{member}).(RespInt); !isInt {
+			return false
+		}
+	}
+	return true
+}
+
+func (set *Set) clone() *Set {
+	return &Set{members: maps.Clone(set.members)}
+}
+
+func (set *Set) collectionType() string {
+	return TYPE_SET
+}
+
+func (set *Set) scan(cursor uint64, count int) ([]string, uint64) {
+	if set.index == nil {
+		set.index = newScanIndex(slices.Collect(maps.Keys(set.members)))
+	}
+	return set.index.scan(cursor, count)
+}
+
+func (set *Set) scanEntry(member string) []RespDataType {
+	return []RespDataType{RespString{member}}
+}
+
+// serialize replies the members, as SMEMBERS would, in order so that the
+// reply is stable
+func (set *Set) serialize() string {
+	elements := make([]RespDataType, 0, set.len())
+	for _, member := range slices.Sorted(maps.Keys(set.members)) {
+		elements = append(elements, RespString{member})
+	}
+	return RespArray{elements}.serialize()
+}
+
+type SAddCommand struct {
+	db           int
+	key          string
+	members      []string
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type SRemCommand struct {
+	db           int
+	key          string
+	members      []string
+	rspChannel   chan int
+	errorChannel chan error
+}
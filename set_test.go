@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSAddAndSRem(t *testing.T) {
+	xredis := NewXRedis()
+
+	added, err := xredis.SAdd("set", []string{"a", "b", "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, added)
+	added, _ = xredis.SAdd("set", []string{"b", "c"})
+	assert.Equal(t, 1, added)
+	assert.Equal(t, TYPE_SET, xredis.Type("set"))
+
+	removed, err := xredis.SRem("set", []string{"a", "missing"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, removed)
+	members, _, _ := xredis.ScanCollection("set", TYPE_SET, 0, "", 10)
+	assert.ElementsMatch(t, []RespDataType{RespString{"b"}, RespString{"c"}}, members)
+
+	xredis.SRem("set", []string{"b", "c"})
+	assert.False(t, xredis.Exists("set"))
+
+	xredis.HSet("hash", map[string]string{"a": "1"})
+	_, err = xredis.SAdd("hash", []string{"a"})
+	assert.NotNil(t, err)
+}
+
+func TestSetEncoding(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.SAdd("set", []string{"1", "2", "3"})
+	encoding, _ := xredis.Encoding("set")
+	assert.Equal(t, ENCODING_INTSET, encoding)
+
+	xredis.SAdd("set", []string{"a"})
+	encoding, _ = xredis.Encoding("set")
+	assert.Equal(t, ENCODING_LISTPACK, encoding)
+
+	members := make([]string, 0, LISTPACK_MAX_ENTRIES)
+	for i := range LISTPACK_MAX_ENTRIES {
+		members = append(members, "member-"+strconv.Itoa(i))
+	}
+	xredis.SAdd("set", members)
+	encoding, _ = xredis.Encoding("set")
+	assert.Equal(t, ENCODING_HASHTABLE, encoding)
+}
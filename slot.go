@@ -0,0 +1,35 @@
+package main
+
+import "strings"
+
+// Number of hash slots the key space is split into, as in Redis Cluster
+const HASH_SLOTS_NUMBER = 16384
+
+// keyHashSlot returns the slot owning the key (or sharded channel). When
+// the key holds a non-empty {hash tag} only the tag is hashed, so that
+// related keys can be forced into the same slot.
+func keyHashSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % HASH_SLOTS_NUMBER
+}
+
+// crc16 is the CRC-16/XMODEM checksum (polynomial 0x1021) used by Redis
+// Cluster to assign keys to slots.
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
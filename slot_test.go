@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrc16(t *testing.T) {
+	assert.Equal(t, uint16(0x31C3), crc16("123456789"))
+}
+
+func TestKeyHashSlot(t *testing.T) {
+	assert.Equal(t, 12182, keyHashSlot("foo"))
+	assert.Equal(t, keyHashSlot("foo"), keyHashSlot("{foo}.bar"))
+	assert.Equal(t, keyHashSlot("foo"), keyHashSlot("bar{foo}{zap}"))
+	assert.Equal(t, int(crc16("{}foo"))%HASH_SLOTS_NUMBER, keyHashSlot("{}foo")) // Empty tags are ignored
+}
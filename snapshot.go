@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const CONFIG_DIR = "dir"
+const CONFIG_DBFILENAME = "dbfilename"
+const CONFIG_SAVE = "save"
+const CONFIG_DBFILENAME_BACKUPS = "dbfilename-backups"
+const CONFIG_DBFORMAT = "dbformat"
+
+// Formats the dumps can be saved in: the snapshot format of xredis, or the
+// RDB format of Redis, which has no streams
+const DUMP_FORMAT_XREDIS = "xredis"
+const DUMP_FORMAT_RDB = "rdb"
+
+// Number of previous dumps kept by default, to roll back to
+const DUMP_DEFAULT_BACKUPS = 1
+
+const DUMP_TEMP_FILE_SUFFIX = ".temp-*"
+
+// Save points of Redis: after an hour if a key changed, after 5 minutes if
+// 100 keys changed, or after a minute if 10000 keys changed
+const SAVE_POINTS_REDIS_DEFAULT = "3600 1 300 100 60 10000"
+
+const SAVE_POINTS_CHECK_INTERVAL = time.Second
+
+// Seconds to wait after a failed background save before the save points
+// can trigger another one
+const BGSAVE_RETRY_DELAY = 5
+
+// Snapshots tracks the saves of the databases to the dump file, and their
+// loads. It is owned by the commands goroutine, only loading being read by
+// the others.
+type Snapshots struct {
+	bgsaveInProgress bool
+	lastSaveTime     int64 // Unix time in seconds of the last successful save
+	lastBgsaveTry    int64 // Unix time in seconds of the last background save started
+	lastBgsaveFailed bool
+	dirty            int64 // Changes to the keys since the last successful save
+	dirtyBeforeSave  int64 // Changes to the keys when the background save started
+	shutdown         *ShutdownCommand
+	stopSavePoints   chan struct{}       // Closed by the shutdown to stop checking the save points
+	captures         []*DatabasesCapture // Copies of the databases in progress, for the background saves and rewrites
+
+	loading            atomic.Bool
+	loadingStartTime   int64 // Unix time in seconds at which the load started
+	loadingTotalBytes  int64
+	loadingLoadedBytes int64
+	lastLoadKeys       int64 // Keys in the databases after the last successful load
+	lastLoadFailed     bool
+}
+
+// SavePoint triggers a background save once the keys changed the given
+// number of times, provided that the last save is older than the seconds.
+type SavePoint struct {
+	seconds int64
+	changes int64
+}
+
+func NewSnapshots() *Snapshots {
+	// As in Redis, the data is considered saved when the server starts
+	return &Snapshots{lastSaveTime: time.Now().Unix(), stopSavePoints: make(chan struct{})}
+}
+
+// Save writes the databases to the dump file, blocking every other client
+// until it is done.
+func (xredis *XRedis) Save() error {
+	errorChan := make(chan error)
+	xredis.commands <- SaveDumpCommand{errorChan}
+	return <-errorChan
+}
+
+// BackgroundSave writes a snapshot of the databases, as they are when it
+// is called, to the dump file while the requests keep being processed.
+func (xredis *XRedis) BackgroundSave() error {
+	errorChan := make(chan error)
+	xredis.commands <- BackgroundSaveCommand{errorChan}
+	return <-errorChan
+}
+
+// Shutdown saves the databases, if save points are configured, and closes
+// the append only file. A background save in progress completes first, and
+// no other starts afterwards.
+func (xredis *XRedis) Shutdown() error {
+	errorChan := make(chan error)
+	xredis.commands <- ShutdownCommand{errorChan}
+	return <-errorChan
+}
+
+// LastSave returns the Unix time in seconds of the last successful save
+func (xredis *XRedis) LastSave() int64 {
+	rspChan := make(chan int64)
+	xredis.commands <- LastSaveCommand{rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) handleSaveDumpCommand(cmd SaveDumpCommand) {
+	defer close(cmd.errorChannel)
+
+	if xredis.snapshots.bgsaveInProgress {
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_BGSAVE_IN_PROGRESS)
+		return
+	}
+	cmd.errorChannel <- xredis.save()
+}
+
+func (xredis *XRedis) save() error {
+	data, err := encodeDump(xredis.config.dbFormat, xredis.config.fileTransforms(), xredis.databases)
+	if err == nil {
+		err = writeDumpFile(xredis.config.dumpPath(), data, xredis.config.dbFilenameBackups)
+	}
+	if err != nil {
+		return err
+	}
+	xredis.snapshots.lastSaveTime = time.Now().Unix()
+	xredis.snapshots.dirty = 0
+	return nil
+}
+
+func (xredis *XRedis) handleBackgroundSaveCommand(cmd BackgroundSaveCommand) {
+	defer close(cmd.errorChannel)
+
+	if xredis.snapshots.bgsaveInProgress {
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_BGSAVE_IN_PROGRESS)
+		return
+	}
+	xredis.startBackgroundSave()
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleBackgroundSaveDoneCommand(cmd BackgroundSaveDoneCommand) {
+	xredis.snapshots.bgsaveInProgress = false
+	xredis.snapshots.lastBgsaveFailed = cmd.err != nil
+	if cmd.err != nil {
+		log.Println("Background saving failed: ", cmd.err)
+	} else {
+		// Only the changes made while saving remain unsaved
+		xredis.snapshots.lastSaveTime = time.Now().Unix()
+		xredis.snapshots.dirty -= xredis.snapshots.dirtyBeforeSave
+		log.Println("Background saving terminated with success")
+	}
+	if xredis.snapshots.shutdown != nil {
+		xredis.shutdown()
+	}
+}
+
+// handleSavePointsCheckCommand starts a background save once a save point
+// is reached. Nothing is saved while loading, which would replace the dump
+// by the part loaded so far.
+func (xredis *XRedis) handleSavePointsCheckCommand(cmd SavePointsCheckCommand) {
+	snapshots := xredis.snapshots
+	if snapshots.bgsaveInProgress || snapshots.shutdown != nil || snapshots.loading.Load() {
+		return
+	}
+	now := time.Now().Unix()
+	if snapshots.lastBgsaveFailed && now-snapshots.lastBgsaveTry < BGSAVE_RETRY_DELAY {
+		return
+	}
+	for _, savePoint := range xredis.config.savePoints {
+		if snapshots.dirty >= savePoint.changes && now-snapshots.lastSaveTime >= savePoint.seconds {
+			log.Printf("%d changes in %d seconds. Saving...", savePoint.changes, savePoint.seconds)
+			xredis.startBackgroundSave()
+			return
+		}
+	}
+}
+
+func (xredis *XRedis) handleShutdownCommand(cmd ShutdownCommand) {
+	xredis.snapshots.shutdown = &cmd
+	if !xredis.snapshots.bgsaveInProgress {
+		xredis.shutdown()
+	}
+}
+
+// shutdown completes the pending shutdown once no background save is in
+// progress anymore.
+func (xredis *XRedis) shutdown() {
+	cmd := xredis.snapshots.shutdown
+	defer close(cmd.errorChannel)
+
+	if xredis.snapshots.stopSavePoints != nil {
+		close(xredis.snapshots.stopSavePoints)
+		xredis.snapshots.stopSavePoints = nil
+	}
+
+	var err error
+	if len(xredis.config.savePoints) > 0 {
+		log.Println("Saving the final snapshot before exiting")
+		err = xredis.save()
+	}
+	if closeErr := xredis.closeAppendOnlyFile(); err == nil {
+		err = closeErr
+	}
+	cmd.errorChannel <- err
+}
+
+// startBackgroundSave captures the databases, which are then written to
+// the dump file by another goroutine so that requests keep being processed
+// meanwhile.
+func (xredis *XRedis) startBackgroundSave() {
+	path := xredis.config.dumpPath()
+	backups := xredis.config.dbFilenameBackups
+	format := xredis.config.dbFormat
+	transforms := xredis.config.fileTransforms()
+	xredis.snapshots.bgsaveInProgress = true
+	xredis.snapshots.lastBgsaveTry = time.Now().Unix()
+	xredis.snapshots.dirtyBeforeSave = xredis.snapshots.dirty
+	xredis.startCapture(func(databases []map[string]XRedisValue) {
+		go func() {
+			data, err := encodeDump(format, transforms, databases)
+			if err == nil {
+				err = writeDumpFile(path, data, backups)
+			}
+			xredis.commands <- BackgroundSaveDoneCommand{err}
+		}()
+	})
+}
+
+// checkSavePointsEverySecond asks the commands goroutine to check the save
+// points every second, until stop is closed.
+func (xredis *XRedis) checkSavePointsEverySecond(stop chan struct{}) {
+	ticker := time.NewTicker(SAVE_POINTS_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case xredis.commands <- SavePointsCheckCommand{}:
+			case <-stop:
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (xredis *XRedis) handleLastSaveCommand(cmd LastSaveCommand) {
+	defer close(cmd.rspChannel)
+	cmd.rspChannel <- xredis.snapshots.lastSaveTime
+}
+
+// encodeDump encodes the databases in the format of the dumps, then
+// applies the transforms to the file.
+func encodeDump(format string, transforms FileTransforms, databases []map[string]XRedisValue) ([]byte, error) {
+	var data []byte
+	var err error
+	if format == DUMP_FORMAT_RDB {
+		data, err = encodeRDB(databases)
+	} else {
+		data, err = encodeSnapshot(databases)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sealFile(data, transforms)
+}
+
+// parseSavePoints parses save points given as pairs of seconds and changes,
+// such as "3600 1 300 100". An empty string configures none.
+func parseSavePoints(value string) ([]SavePoint, error) {
+	fields := strings.Fields(value)
+	if len(fields)%2 != 0 {
+		return nil, errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+	}
+	savePoints := make([]SavePoint, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err1 := strconv.ParseInt(fields[i], 10, 64)
+		changes, err2 := strconv.ParseInt(fields[i+1], 10, 64)
+		if err1 != nil || err2 != nil || seconds < 1 || changes < 0 {
+			return nil, errors.New(REQUEST_ERROR_INVALID_CONFIG_VALUE)
+		}
+		savePoints = append(savePoints, SavePoint{seconds, changes})
+	}
+	return savePoints, nil
+}
+
+func formatSavePoints(savePoints []SavePoint) string {
+	fields := make([]string, 0, 2*len(savePoints))
+	for _, savePoint := range savePoints {
+		fields = append(fields, strconv.FormatInt(savePoint.seconds, 10), strconv.FormatInt(savePoint.changes, 10))
+	}
+	return strings.Join(fields, " ")
+}
+
+// writeDumpFile replaces the dump file at path in a way that survives a
+// crash at any point: the data is written and flushed to a temporary file,
+// which is then renamed over the dump once the previous dumps are rotated.
+func writeDumpFile(path string, data []byte, backups int) error {
+	dir := filepath.Dir(path)
+	file, err := os.CreateTemp(dir, filepath.Base(path)+DUMP_TEMP_FILE_SUFFIX)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		rotateDumpFiles(path, backups)
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return syncDir(dir)
+}
+
+// rotateDumpFiles keeps the current dump as the first of the backups, the
+// oldest one being dropped. The dump itself is linked rather than moved so
+// that there is always a dump at path.
+func rotateDumpFiles(path string, backups int) {
+	if backups < 1 {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	for i := backups - 1; i >= 1; i-- {
+		if err := os.Rename(dumpBackupPath(path, i), dumpBackupPath(path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println("Failed rotating the dump backups: ", err)
+		}
+	}
+	os.Remove(dumpBackupPath(path, 1))
+	if err := os.Link(path, dumpBackupPath(path, 1)); err != nil {
+		log.Println("Failed backing up the dump: ", err)
+	}
+}
+
+// dumpBackupPath returns the path of the nth most recent backup of a dump
+func dumpBackupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// syncDir flushes the entries of the directory to disk, so that a file
+// created or renamed in it can't vanish on a crash.
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+type SaveDumpCommand struct {
+	errorChannel chan error
+}
+
+type BackgroundSaveCommand struct {
+	errorChannel chan error
+}
+
+type BackgroundSaveDoneCommand struct {
+	err error
+}
+
+type SavePointsCheckCommand struct {
+}
+
+type ShutdownCommand struct {
+	errorChannel chan error
+}
+
+type LastSaveCommand struct {
+	rspChannel chan int64
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"iter"
+	"maps"
+	"math"
+	"time"
+)
+
+// Keys copied by each capture between two commands
+const CAPTURE_STEP_KEYS = 1024
+
+// DatabasesCapture takes a point in time copy of the live keys, for the
+// background saves and rewrites, a few keys at a time between commands
+// rather than all at once. A key is copied when its database walk reaches
+// it, or right before it is accessed if that comes first, so that the
+// writes made meanwhile never reach the copy.
+type DatabasesCapture struct {
+	databases []map[string]XRedisValue // Copy being built
+	captured  []map[string]struct{}    // Keys of the database being walked, or yet to be, handled already
+	time      int64                    // Unix time in milliseconds of the capture, the keys expired by then being left out
+	db        int                      // Database being walked
+	next      func() (string, XRedisValue, bool)
+	stop      func()
+	done      func(databases []map[string]XRedisValue)
+}
+
+// startCapture starts capturing the databases as they are now, handing the
+// copy to done from the commands goroutine once complete.
+func (xredis *XRedis) startCapture(done func(databases []map[string]XRedisValue)) {
+	capture := &DatabasesCapture{
+		databases: make([]map[string]XRedisValue, len(xredis.databases)),
+		captured:  make([]map[string]struct{}, len(xredis.databases)),
+		time:      time.Now().UnixMilli(),
+		db:        -1,
+		done:      done,
+	}
+	for db := range xredis.databases {
+		capture.databases[db] = make(map[string]XRedisValue)
+		capture.captured[db] = make(map[string]struct{})
+	}
+	xredis.snapshots.captures = append(xredis.snapshots.captures, capture)
+}
+
+// stepCaptures copies the next keys of every capture in progress, handing
+// over the copies completed.
+func (xredis *XRedis) stepCaptures(keys int) {
+	var inProgress, completed []*DatabasesCapture
+	for _, capture := range xredis.snapshots.captures {
+		if capture.step(xredis.databases, keys) {
+			completed = append(completed, capture)
+		} else {
+			inProgress = append(inProgress, capture)
+		}
+	}
+	xredis.snapshots.captures = inProgress
+	for _, capture := range completed {
+		capture.done(capture.databases)
+	}
+}
+
+// completeCaptures completes the captures in progress, before the whole
+// databases are replaced or flushed.
+func (xredis *XRedis) completeCaptures() {
+	xredis.stepCaptures(math.MaxInt)
+}
+
+// captureKey copies the key, as it is before being accessed, to the
+// captures that haven't copied it yet.
+func (xredis *XRedis) captureKey(db int, key string) {
+	for _, capture := range xredis.snapshots.captures {
+		// The keys added to the databases walked already came later
+		if db < capture.db {
+			continue
+		}
+		value, exists := xredis.databases[db][key]
+		capture.copy(db, key, value, exists)
+	}
+}
+
+// step copies the next keys of the databases walk, returning whether the
+// capture is complete. A Go map can be modified while being walked, the
+// keys added meanwhile having been handled already when walked.
+func (capture *DatabasesCapture) step(databases []map[string]XRedisValue, keys int) bool {
+	for keys > 0 {
+		if capture.next == nil {
+			if capture.db++; capture.db == len(databases) {
+				return true
+			}
+			capture.next, capture.stop = iter.Pull2(maps.All(databases[capture.db]))
+		}
+		key, value, ok := capture.next()
+		if !ok {
+			capture.stop()
+			capture.next = nil
+			// Only the keys of the databases not walked yet are tracked
+			capture.captured[capture.db] = nil
+			continue
+		}
+		capture.copy(capture.db, key, value, true)
+		keys--
+	}
+	return false
+}
+
+// copy handles the key the first time it is met, either copying its value
+// or recording that it didn't exist when the capture started.
+func (capture *DatabasesCapture) copy(db int, key string, value XRedisValue, exists bool) {
+	if _, handled := capture.captured[db][key]; handled {
+		return
+	}
+	capture.captured[db][key] = struct{}{}
+	if !exists || (value.ExpirationTimestampMillis != NON_EXPIRATION_TIME && capture.time > value.ExpirationTimestampMillis) {
+		return
+	}
+	capture.databases[db][key] = XRedisValue{cloneValue(value.Element), value.ExpirationTimestampMillis}
+}
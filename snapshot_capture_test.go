@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureIsPointInTime(t *testing.T) {
+	xredis := NewXRedis()
+	keys := 3 * CAPTURE_STEP_KEYS
+	for i := range keys {
+		xredis.Set("key:"+strconv.Itoa(i), RespString{"before"})
+	}
+	xredis.SAdd("set", []string{"a"})
+	xredis.Set("deleted", RespString{"before"})
+	xredis.SetWithExpiration("expiring", RespString{"before"}, time.Now().Add(50*time.Millisecond))
+
+	// The keys are written after the walk got through part of them only
+	captured := make(chan []map[string]XRedisValue, 1)
+	xredis.Atomically(func(tx *XRedis) {
+		tx.startCapture(func(databases []map[string]XRedisValue) { captured <- databases })
+		tx.stepCaptures(CAPTURE_STEP_KEYS)
+		for i := range keys {
+			tx.Set("key:"+strconv.Itoa(i), RespString{"after"})
+		}
+		tx.SAdd("set", []string{"b"})
+		tx.Delete("deleted")
+		tx.Set("added", RespString{"after"})
+	})
+	databases := <-captured
+
+	assert.Len(t, databases[0], keys+3)
+	for i := range keys {
+		assert.Equal(t, RespString{"before"}, databases[0]["key:"+strconv.Itoa(i)].Element)
+	}
+	assert.Equal(t, 1, databases[0]["set"].Element.(*Set).len())
+	assert.Contains(t, databases[0], "deleted")
+	assert.Contains(t, databases[0], "expiring")
+	assert.NotContains(t, databases[0], "added")
+}
+
+func TestCaptureCompletesBeforeFlush(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("key", RespString{"before"})
+
+	captured := make(chan []map[string]XRedisValue, 1)
+	xredis.Atomically(func(tx *XRedis) {
+		tx.startCapture(func(databases []map[string]XRedisValue) { captured <- databases })
+		tx.FlushAll(false)
+		tx.Set("key", RespString{"after"})
+		assert.Empty(t, tx.snapshots.captures)
+	})
+	databases := <-captured
+	assert.Equal(t, RespString{"before"}, databases[0]["key"].Element)
+}
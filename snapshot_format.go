@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"math"
+)
+
+// A snapshot starts with the magic bytes and the version of its format,
+// followed by a section for each database that holds keys, and ends with
+// the EOF opcode and a CRC64 checksum of everything before it:
+//
+//	XREDIS <version:uint16>
+//	DATABASE <db:uvarint> <keys:uvarint> [<type:byte> <key> <expiration:varint> <value>]...
+//	...
+//	EOF <crc64:uint64>
+//
+// Integers are little endian, strings are prefixed by their length as an
+// uvarint and the values are encoded according to their type.
+const SNAPSHOT_MAGIC = "XREDIS"
+const SNAPSHOT_FORMAT_VERSION = 1
+const SNAPSHOT_VERSION_SIZE = 2
+const SNAPSHOT_CHECKSUM_SIZE = 8
+
+const SNAPSHOT_OPCODE_DATABASE = 0xFE
+const SNAPSHOT_OPCODE_EOF = 0xFF
+
+// Encodings of the values, which must never be renumbered
+const SNAPSHOT_TYPE_STRING = 0
+const SNAPSHOT_TYPE_INT = 1
+const SNAPSHOT_TYPE_LIST = 2
+const SNAPSHOT_TYPE_STREAM = 3
+const SNAPSHOT_TYPE_HYPERLOGLOG = 4
+const SNAPSHOT_TYPE_SORTED_SET = 5
+const SNAPSHOT_TYPE_HASH = 6
+const SNAPSHOT_TYPE_SET = 7
+
+const SNAPSHOT_HYPERLOGLOG_SPARSE = 0
+const SNAPSHOT_HYPERLOGLOG_DENSE = 1
+
+var ERROR_CORRUPTED_SNAPSHOT = errors.New("corrupted snapshot")
+
+// Wrapped by the errors of the dumps written by newer versions, or holding
+// what xredis can't load
+var ERROR_UNSUPPORTED_DUMP = errors.New("unsupported")
+
+var SNAPSHOT_CRC64_TABLE = crc64.MakeTable(crc64.ECMA)
+
+// encodeSnapshot encodes the databases in the snapshot format
+func encodeSnapshot(databases []map[string]XRedisValue) ([]byte, error) {
+	data := append([]byte(SNAPSHOT_MAGIC), 0, 0)
+	binary.LittleEndian.PutUint16(data[len(SNAPSHOT_MAGIC):], SNAPSHOT_FORMAT_VERSION)
+	for db, database := range databases {
+		if len(database) == 0 {
+			continue
+		}
+		data = append(data, SNAPSHOT_OPCODE_DATABASE)
+		data = binary.AppendUvarint(data, uint64(db))
+		data = binary.AppendUvarint(data, uint64(len(database)))
+		for key, value := range database {
+			var err error
+			data, err = appendSnapshotValue(data, key, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	data = append(data, SNAPSHOT_OPCODE_EOF)
+	return binary.LittleEndian.AppendUint64(data, crc64.Checksum(data, SNAPSHOT_CRC64_TABLE)), nil
+}
+
+// decodeSnapshot decodes the databases of a snapshot, of an RDB file, or
+// of a gob encoded dump written before the snapshot format existed.
+func decodeSnapshot(data []byte) ([]map[string]XRedisValue, error) {
+	if bytes.HasPrefix(data, []byte(RDB_MAGIC)) {
+		return decodeRDB(data)
+	}
+	if !bytes.HasPrefix(data, []byte(SNAPSHOT_MAGIC)) {
+		return decodeDatabases(data)
+	}
+	if len(data) < len(SNAPSHOT_MAGIC)+SNAPSHOT_VERSION_SIZE+1+SNAPSHOT_CHECKSUM_SIZE {
+		return nil, ERROR_CORRUPTED_SNAPSHOT
+	}
+	version := binary.LittleEndian.Uint16(data[len(SNAPSHOT_MAGIC):])
+	if version > SNAPSHOT_FORMAT_VERSION {
+		return nil, fmt.Errorf("%w snapshot format version %d", ERROR_UNSUPPORTED_DUMP, version)
+	}
+	checksumOffset := len(data) - SNAPSHOT_CHECKSUM_SIZE
+	if binary.LittleEndian.Uint64(data[checksumOffset:]) != crc64.Checksum(data[:checksumOffset], SNAPSHOT_CRC64_TABLE) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ERROR_CORRUPTED_SNAPSHOT)
+	}
+
+	reader := &snapshotReader{data: data[len(SNAPSHOT_MAGIC)+SNAPSHOT_VERSION_SIZE : checksumOffset]}
+	databases := make([]map[string]XRedisValue, 0)
+	for reader.err == nil {
+		opcode := reader.byte()
+		if opcode == SNAPSHOT_OPCODE_EOF {
+			break
+		}
+		if opcode != SNAPSHOT_OPCODE_DATABASE {
+			reader.fail()
+			break
+		}
+		db := reader.length()
+		keys := reader.length()
+		for len(databases) <= db && reader.err == nil {
+			databases = append(databases, make(map[string]XRedisValue))
+		}
+		for range keys {
+			if reader.err != nil {
+				break
+			}
+			key, value := reader.value()
+			databases[db][key] = value
+		}
+	}
+	if reader.err == nil && len(reader.data) > 0 {
+		reader.fail()
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	return databases, nil
+}
+
+// appendSnapshotValue appends the type, key, expiration and encoded value
+func appendSnapshotValue(data []byte, key string, value XRedisValue) ([]byte, error) {
+	start := len(data)
+	data = append(data, 0)
+	data = appendSnapshotString(data, key)
+	data = binary.AppendVarint(data, value.ExpirationTimestampMillis)
+	data, valueType, err := appendSnapshotElement(data, value.Element)
+	if err != nil {
+		return nil, err
+	}
+	data[start] = valueType
+	return data, nil
+}
+
+// appendSnapshotElement appends the encoding of the element, returning
+// its type.
+func appendSnapshotElement(data []byte, element RespDataType) ([]byte, byte, error) {
+	switch element := element.(type) {
+	case RespString:
+		return appendSnapshotString(data, element.Str), SNAPSHOT_TYPE_STRING, nil
+	case RespInt:
+		return binary.AppendVarint(data, element.Value), SNAPSHOT_TYPE_INT, nil
+	case RespArray:
+		data = binary.AppendUvarint(data, uint64(len(element.Elements)))
+		for _, listElement := range element.Elements {
+			str, ok := decodeStringValue(listElement).(RespString)
+			if !ok {
+				return nil, 0, fmt.Errorf("unexpected list element of type %T", listElement)
+			}
+			data = appendSnapshotString(data, str.Str)
+		}
+		return data, SNAPSHOT_TYPE_LIST, nil
+	case *Stream:
+		return appendSnapshotStream(data, element), SNAPSHOT_TYPE_STREAM, nil
+	case *HyperLogLog:
+		if element.isDense() {
+			return append(append(data, SNAPSHOT_HYPERLOGLOG_DENSE), element.Registers...), SNAPSHOT_TYPE_HYPERLOGLOG, nil
+		}
+		data = append(data, SNAPSHOT_HYPERLOGLOG_SPARSE)
+		data = binary.AppendUvarint(data, uint64(len(element.Sparse)))
+		for index, register := range element.Sparse {
+			data = binary.LittleEndian.AppendUint16(data, index)
+			data = append(data, register)
+		}
+		return data, SNAPSHOT_TYPE_HYPERLOGLOG, nil
+	case *SortedSet:
+		data = binary.AppendUvarint(data, uint64(len(element.Members)))
+		for _, member := range element.Members {
+			data = appendSnapshotString(data, member.Member)
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(member.Score))
+		}
+		return data, SNAPSHOT_TYPE_SORTED_SET, nil
+	case *Hash:
+		data = binary.AppendUvarint(data, uint64(element.len()))
+		for field, value := range element.fields {
+			data = appendSnapshotString(appendSnapshotString(data, field), value)
+		}
+		return data, SNAPSHOT_TYPE_HASH, nil
+	case *Set:
+		data = binary.AppendUvarint(data, uint64(element.len()))
+		for member := range element.members {
+			data = appendSnapshotString(data, member)
+		}
+		return data, SNAPSHOT_TYPE_SET, nil
+	default:
+		return nil, 0, fmt.Errorf("unexpected value of type %T", element)
+	}
+}
+
+// appendSnapshotStream appends the entries, including the deleted ones, the
+// counters and the consumer groups of the stream. The entries pending for
+// each consumer are not repeated, being those of the group.
+func appendSnapshotStream(data []byte, stream *Stream) []byte {
+	data = binary.AppendUvarint(data, uint64(len(stream.Entries)))
+	for _, entry := range stream.Entries {
+		data = appendSnapshotStreamID(data, entry.ID)
+		// Deleted entries, without fields, are told apart from the others
+		// by storing the number of fields plus one
+		if entry.Fields == nil {
+			data = binary.AppendUvarint(data, 0)
+			continue
+		}
+		data = binary.AppendUvarint(data, uint64(len(entry.Fields)+1))
+		for _, field := range entry.Fields {
+			data = appendSnapshotString(data, field)
+		}
+	}
+	data = appendSnapshotStreamID(data, stream.LastID)
+	data = binary.AppendUvarint(data, stream.EntriesAdded)
+	data = appendSnapshotStreamID(data, stream.MaxDeletedID)
+
+	data = binary.AppendUvarint(data, uint64(len(stream.Groups)))
+	for name, group := range stream.Groups {
+		data = appendSnapshotString(data, name)
+		data = appendSnapshotStreamID(data, group.LastDeliveredID)
+		data = binary.AppendUvarint(data, uint64(len(group.Pending)))
+		for id, pendingEntry := range group.Pending {
+			data = appendSnapshotStreamID(data, id)
+			data = appendSnapshotString(data, pendingEntry.Consumer)
+			data = binary.AppendVarint(data, pendingEntry.DeliveryTime)
+			data = binary.AppendVarint(data, pendingEntry.DeliveryCount)
+		}
+		data = binary.AppendUvarint(data, uint64(len(group.Consumers)))
+		for consumerName, consumer := range group.Consumers {
+			data = appendSnapshotString(data, consumerName)
+			data = binary.AppendVarint(data, consumer.SeenTime)
+			data = binary.AppendVarint(data, consumer.ActiveTime)
+		}
+	}
+	return data
+}
+
+func appendSnapshotStreamID(data []byte, id StreamID) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(data, id.Ms), id.Seq)
+}
+
+func appendSnapshotString(data []byte, str string) []byte {
+	return append(binary.AppendUvarint(data, uint64(len(str))), str...)
+}
+
+// snapshotReader decodes the fields of a snapshot. Once a field can't be
+// decoded, err is set and every following field is decoded as a zero value.
+type snapshotReader struct {
+	data []byte
+	err  error
+}
+
+func (reader *snapshotReader) fail() {
+	if reader.err == nil {
+		reader.err = ERROR_CORRUPTED_SNAPSHOT
+	}
+	reader.data = nil
+}
+
+func (reader *snapshotReader) bytes(n int) []byte {
+	if n < 0 || n > len(reader.data) {
+		reader.fail()
+		return nil
+	}
+	bytes := reader.data[:n]
+	reader.data = reader.data[n:]
+	return bytes
+}
+
+func (reader *snapshotReader) byte() byte {
+	bytes := reader.bytes(1)
+	if bytes == nil {
+		return 0
+	}
+	return bytes[0]
+}
+
+func (reader *snapshotReader) uvarint() uint64 {
+	value, n := binary.Uvarint(reader.data)
+	if n <= 0 {
+		reader.fail()
+		return 0
+	}
+	reader.data = reader.data[n:]
+	return value
+}
+
+func (reader *snapshotReader) varint() int64 {
+	value, n := binary.Varint(reader.data)
+	if n <= 0 {
+		reader.fail()
+		return 0
+	}
+	reader.data = reader.data[n:]
+	return value
+}
+
+// length decodes a number of elements, which can't exceed the number of
+// bytes left since every element takes at least one.
+func (reader *snapshotReader) length() int {
+	length := reader.uvarint()
+	if length > uint64(len(reader.data)) {
+		reader.fail()
+		return 0
+	}
+	return int(length)
+}
+
+func (reader *snapshotReader) string() string {
+	return string(reader.bytes(reader.length()))
+}
+
+func (reader *snapshotReader) streamID() StreamID {
+	return StreamID{reader.uvarint(), reader.uvarint()}
+}
+
+// value decodes a key and its value, as appended by appendSnapshotValue
+func (reader *snapshotReader) value() (string, XRedisValue) {
+	valueType := reader.byte()
+	key := reader.string()
+	expiration := reader.varint()
+	return key, XRedisValue{reader.element(valueType), expiration}
+}
+
+// element decodes a value encoded as valueType
+func (reader *snapshotReader) element(valueType byte) RespDataType {
+	switch valueType {
+	case SNAPSHOT_TYPE_STRING:
+		return RespString{reader.string()}
+	case SNAPSHOT_TYPE_INT:
+		return RespInt{reader.varint()}
+	case SNAPSHOT_TYPE_LIST:
+		elements := make([]RespDataType, reader.length())
+		for i := range elements {
+			elements[i] = RespString{reader.string()}
+		}
+		return RespArray{elements}
+	case SNAPSHOT_TYPE_STREAM:
+		return reader.stream()
+	case SNAPSHOT_TYPE_HYPERLOGLOG:
+		hyperLogLog := NewHyperLogLog()
+		if reader.byte() == SNAPSHOT_HYPERLOGLOG_DENSE {
+			hyperLogLog.Sparse = nil
+			hyperLogLog.Registers = bytes.Clone(reader.bytes(HYPERLOGLOG_REGISTERS))
+			return hyperLogLog
+		}
+		for range reader.length() {
+			index := reader.bytes(2)
+			register := reader.byte()
+			if index != nil && binary.LittleEndian.Uint16(index) < HYPERLOGLOG_REGISTERS {
+				hyperLogLog.Sparse[binary.LittleEndian.Uint16(index)] = register
+			} else {
+				reader.fail()
+			}
+		}
+		return hyperLogLog
+	case SNAPSHOT_TYPE_SORTED_SET:
+		sortedSet := NewSortedSet()
+		for range reader.length() {
+			member := reader.string()
+			score := reader.bytes(8)
+			if score != nil {
+				sortedSet.add(member, math.Float64frombits(binary.LittleEndian.Uint64(score)))
+			}
+		}
+		return sortedSet
+	case SNAPSHOT_TYPE_HASH:
+		hash := NewHash()
+		for range reader.length() {
+			field := reader.string()
+			hash.set(field, reader.string())
+		}
+		return hash
+	case SNAPSHOT_TYPE_SET:
+		set := NewSet()
+		for range reader.length() {
+			set.add(reader.string())
+		}
+		return set
+	default:
+		reader.fail()
+		return RespNil{}
+	}
+}
+
+func (reader *snapshotReader) stream() *Stream {
+	stream := NewStream()
+	stream.Entries = make([]StreamEntry, reader.length())
+	for i := range stream.Entries {
+		stream.Entries[i].ID = reader.streamID()
+		if fields := reader.length(); fields > 0 {
+			stream.Entries[i].Fields = make([]string, fields-1)
+			for j := range stream.Entries[i].Fields {
+				stream.Entries[i].Fields[j] = reader.string()
+			}
+		}
+	}
+	stream.LastID = reader.streamID()
+	stream.EntriesAdded = reader.uvarint()
+	stream.MaxDeletedID = reader.streamID()
+
+	groups := reader.length()
+	if groups > 0 {
+		stream.Groups = make(map[string]*ConsumerGroup, groups)
+	}
+	for range groups {
+		name := reader.string()
+		group := &ConsumerGroup{reader.streamID(), make(map[StreamID]*PendingEntry), make(map[string]*Consumer)}
+		for range reader.length() {
+			id := reader.streamID()
+			group.Pending[id] = &PendingEntry{reader.string(), reader.varint(), reader.varint()}
+		}
+		for range reader.length() {
+			consumerName := reader.string()
+			group.Consumers[consumerName] = &Consumer{reader.varint(), reader.varint(), make(map[StreamID]bool)}
+		}
+		for id, pendingEntry := range group.Pending {
+			consumer, ok := group.Consumers[pendingEntry.Consumer]
+			if !ok {
+				reader.fail()
+				break
+			}
+			consumer.Pending[id] = true
+		}
+		stream.Groups[name] = group
+	}
+	return stream
+}
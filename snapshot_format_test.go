@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc64"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("string", RespString{"value"})
+	xredis.Set("counter", RespString{"-42"})
+	xredis.SetWithExpiration("expiring", RespString{"value"}, time.Now().Add(time.Hour))
+	xredis.RPush("list", RespString{"a"})
+	xredis.RPush("list", RespString{"b"})
+	xredis.XAdd("stream", "1-1", []string{"f1", "v1"}, false, nil)
+	xredis.XAdd("stream", "2-1", []string{"f2", "v2", "f3", "v3"}, false, nil)
+	xredis.XAdd("stream", "3-1", []string{"f4", "v4"}, false, nil)
+	xredis.XDel("stream", []StreamID{{2, 1}})
+	xredis.XGroupCreate("stream", "group", StreamID{}, false, false)
+	xredis.XGroupCreateConsumer("stream", "group", "idle")
+	xredis.XReadGroup("group", "reader", []StreamRead{{"stream", StreamID{}, true}}, 1, false, false)
+	xredis.PFAdd("sparse", []string{"a", "b"})
+	elements := make([]string, 2000)
+	for i := range elements {
+		elements[i] = strconv.Itoa(i)
+	}
+	xredis.PFAdd("dense", elements)
+	xredis.GeoAdd("geo", []GeoMember{{"Palermo", GeoPoint{13.361389, 38.115556}}, {"Catania", GeoPoint{15.087269, 37.502669}}}, GeoAddOptions{})
+	xredis.HSet("hash", map[string]string{"field": "value", "empty": ""})
+	xredis.SAdd("set", []string{"a", "b", "c"})
+	db3, _ := xredis.Select(3)
+	db3.Set("other", RespString{"database"})
+
+	data := xredis.Serialize()
+	assert.True(t, bytes.HasPrefix(data, []byte(SNAPSHOT_MAGIC)))
+	databases, err := decodeSnapshot(data)
+	assert.Nil(t, err)
+	assert.Len(t, databases, 4)
+	assert.Equal(t, xredis.databases[DEFAULT_DATABASE], databases[DEFAULT_DATABASE])
+	assert.Empty(t, databases[1])
+	assert.Equal(t, xredis.databases[3], databases[3])
+}
+
+func TestCorruptedSnapshot(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("key", RespString{"value"})
+	data := xredis.Serialize()
+
+	flipped := bytes.Clone(data)
+	flipped[len(SNAPSHOT_MAGIC)+SNAPSHOT_VERSION_SIZE+3]++
+	_, err := decodeSnapshot(flipped)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+
+	_, err = decodeSnapshot(data[:len(data)-1])
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+	_, err = decodeSnapshot([]byte(SNAPSHOT_MAGIC))
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+
+	// A valid checksum doesn't make up for a malformed content
+	malformed := append([]byte(SNAPSHOT_MAGIC), 1, 0, SNAPSHOT_OPCODE_DATABASE, 0, 1, 42)
+	malformed = binary.LittleEndian.AppendUint64(malformed, crc64.Checksum(malformed, SNAPSHOT_CRC64_TABLE))
+	_, err = decodeSnapshot(malformed)
+	assert.ErrorIs(t, err, ERROR_CORRUPTED_SNAPSHOT)
+}
+
+func TestSnapshotOfNewerFormatVersion(t *testing.T) {
+	data := append([]byte(SNAPSHOT_MAGIC), SNAPSHOT_FORMAT_VERSION+1, 0, SNAPSHOT_OPCODE_EOF)
+	data = binary.LittleEndian.AppendUint64(data, crc64.Checksum(data, SNAPSHOT_CRC64_TABLE))
+
+	_, err := decodeSnapshot(data)
+	assert.EqualError(t, err, "unsupported snapshot format version 2")
+}
+
+func TestGobDumpIsMigrated(t *testing.T) {
+	databases := []map[string]XRedisValue{{"key": {RespString{"value"}, NON_EXPIRATION_TIME}}, {"list": {RespArray{[]RespDataType{RespString{"a"}}}, NON_EXPIRATION_TIME}}}
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(databases)
+
+	xredis := NewXRedis()
+	assert.Nil(t, xredis.Load(buf.Bytes()))
+	assert.Equal(t, RespString{"value"}, xredis.Get("key"))
+	db1, _ := xredis.Select(1)
+	assert.Equal(t, RespArray{[]RespDataType{RespString{"a"}}}, db1.Get("list"))
+	assert.True(t, bytes.HasPrefix(xredis.Serialize(), []byte(SNAPSHOT_MAGIC)))
+}
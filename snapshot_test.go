@@ -0,0 +1,198 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveWritesTheDumpFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DB_DUMP_FILE)
+	xredis := NewXRedis()
+	xredis.SetDir(filepath.Dir(path))
+	xredis.Set("key", RespString{"value"})
+
+	assert.Nil(t, xredis.Save())
+
+	data, _ := os.ReadFile(path)
+	loaded := NewXRedis()
+	assert.Nil(t, loaded.Load(data))
+	assert.Equal(t, RespString{"value"}, loaded.Get("key"))
+}
+
+func TestBackgroundSaveIsPointInTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DB_DUMP_FILE)
+	xredis := NewXRedis()
+	xredis.SetDir(filepath.Dir(path))
+	xredis.Set("key", RespString{"before"})
+	xredis.XAdd("stream", "1-1", []string{"field", "value"}, false, nil)
+
+	// Nothing can complete the save before the transaction ends, so the
+	// writes below are applied while it is in progress
+	xredis.Atomically(func(tx *XRedis) {
+		assert.Nil(t, tx.BackgroundSave())
+		assert.Equal(t, REQUEST_ERROR_BGSAVE_IN_PROGRESS, tx.BackgroundSave().Error())
+		assert.Equal(t, REQUEST_ERROR_BGSAVE_IN_PROGRESS, tx.Save().Error())
+		assert.True(t, tx.PersistenceInfo().bgsaveInProgress)
+		tx.Set("key", RespString{"after"})
+		tx.XAdd("stream", "2-1", []string{"field", "value"}, false, nil)
+	})
+	assert.Eventually(t, func() bool { return !xredis.PersistenceInfo().bgsaveInProgress }, time.Second, time.Millisecond)
+	assert.False(t, xredis.PersistenceInfo().lastBgsaveFailed)
+
+	data, _ := os.ReadFile(path)
+	loaded := NewXRedis()
+	assert.Nil(t, loaded.Load(data))
+	assert.Equal(t, RespString{"before"}, loaded.Get("key"))
+	length, _ := loaded.XLen("stream")
+	assert.Equal(t, 1, length)
+}
+
+func TestBackgroundSaveFailure(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.SetDir(filepath.Join(t.TempDir(), "missing"))
+	lastSave := xredis.LastSave()
+
+	assert.Nil(t, xredis.BackgroundSave())
+	assert.Eventually(t, func() bool { return !xredis.PersistenceInfo().bgsaveInProgress }, time.Second, time.Millisecond)
+	assert.True(t, xredis.PersistenceInfo().lastBgsaveFailed)
+	assert.Equal(t, lastSave, xredis.LastSave())
+	assert.NotNil(t, xredis.Save())
+}
+
+func TestChangesSinceLastSave(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.SetDir(t.TempDir())
+
+	xredis.Set("a", RespString{"1"})
+	xredis.Increment("a")
+	xredis.Get("a")
+	assert.Equal(t, int64(2), xredis.PersistenceInfo().changesSinceLastSave)
+
+	assert.Nil(t, xredis.Save())
+	assert.Equal(t, int64(0), xredis.PersistenceInfo().changesSinceLastSave)
+
+	xredis.Set("b", RespString{"2"})
+	xredis.FlushDB(false)
+	assert.Equal(t, int64(3), xredis.PersistenceInfo().changesSinceLastSave)
+}
+
+func TestSavePointsTriggerBackgroundSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DB_DUMP_FILE)
+	xredis := NewXRedis()
+	xredis.SetDir(filepath.Dir(path))
+	xredis.ConfigSet(map[string]string{CONFIG_SAVE: "1 2"})
+
+	xredis.Set("a", RespString{"1"})
+	time.Sleep(2 * SAVE_POINTS_CHECK_INTERVAL)
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	xredis.Set("b", RespString{"2"})
+	assert.Eventually(t, func() bool {
+		info := xredis.PersistenceInfo()
+		return !info.bgsaveInProgress && info.changesSinceLastSave == 0
+	}, 3*SAVE_POINTS_CHECK_INTERVAL, 10*time.Millisecond)
+	data, _ := os.ReadFile(path)
+	loaded := NewXRedis()
+	assert.Nil(t, loaded.Load(data))
+	assert.Equal(t, 2, loaded.DBSize())
+}
+
+func TestShutdownSavesAndClosesAppendOnlyFile(t *testing.T) {
+	dir := t.TempDir()
+	xredis := NewXRedis()
+	xredis.SetDir(dir)
+	xredis.ConfigSet(map[string]string{CONFIG_SAVE: "3600 1"})
+	xredis.OpenAppendOnlyFile(filepath.Join(dir, AOF_FILE))
+	xredis.Set("key", RespString{"value"})
+
+	// The background save in progress completes before the final save
+	assert.Nil(t, xredis.BackgroundSave())
+	assert.Nil(t, xredis.Shutdown())
+	assert.False(t, xredis.AppendOnlyEnabled())
+	assert.False(t, xredis.PersistenceInfo().bgsaveInProgress)
+
+	data, _ := os.ReadFile(filepath.Join(dir, DB_DUMP_FILE))
+	loaded := NewXRedis()
+	assert.Nil(t, loaded.Load(data))
+	assert.Equal(t, RespString{"value"}, loaded.Get("key"))
+}
+
+func TestShutdownWithoutSavePoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DB_DUMP_FILE)
+	xredis := NewXRedis()
+	xredis.SetDir(filepath.Dir(path))
+	xredis.Set("key", RespString{"value"})
+
+	assert.Nil(t, xredis.Shutdown())
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestShutdownStopsCheckingSavePoints(t *testing.T) {
+	xredis := NewXRedis()
+	stop := xredis.snapshots.stopSavePoints
+
+	assert.Nil(t, xredis.Shutdown())
+	assert.Nil(t, xredis.Shutdown())
+	select {
+	case <-stop:
+	default:
+		t.Fatal("the save points are still checked")
+	}
+}
+
+func TestSaveConfig(t *testing.T) {
+	xredis := NewXRedis()
+
+	assert.Nil(t, xredis.ConfigSet(map[string]string{CONFIG_SAVE: SAVE_POINTS_REDIS_DEFAULT}))
+	assert.Equal(t, []string{CONFIG_SAVE, SAVE_POINTS_REDIS_DEFAULT}, xredis.ConfigGet(CONFIG_SAVE))
+	assert.Nil(t, xredis.ConfigSet(map[string]string{CONFIG_SAVE: ""}))
+	assert.Equal(t, []string{CONFIG_SAVE, ""}, xredis.ConfigGet(CONFIG_SAVE))
+
+	for _, invalid := range []string{"3600", "0 1", "60 -1", "60 x"} {
+		err := xredis.ConfigSet(map[string]string{CONFIG_SAVE: invalid})
+		assert.Equal(t, REQUEST_ERROR_INVALID_CONFIG_VALUE, err.Error(), invalid)
+	}
+}
+
+func TestSaveRotatesPreviousDumps(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DB_DUMP_FILE)
+	xredis := NewXRedis()
+	xredis.SetDir(dir)
+	xredis.ConfigSet(map[string]string{CONFIG_DBFILENAME_BACKUPS: "2"})
+
+	for _, value := range []string{"1", "2", "3", "4"} {
+		xredis.Set("key", RespString{value})
+		assert.Nil(t, xredis.Save())
+	}
+
+	for path, value := range map[string]string{path: "4", path + ".1": "3", path + ".2": "2"} {
+		data, _ := os.ReadFile(path)
+		loaded := NewXRedis()
+		assert.Nil(t, loaded.Load(data))
+		assert.Equal(t, RespString{value}, loaded.Get("key"), path)
+	}
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 3)
+}
+
+func TestFailedSaveLeavesNoTemporaryFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DB_DUMP_FILE)
+	xredis := NewXRedis()
+	xredis.SetDir(dir)
+	xredis.ConfigSet(map[string]string{CONFIG_DBFILENAME_BACKUPS: "0"})
+	// The dump can't be renamed over a directory
+	os.Mkdir(path, 0755)
+	os.WriteFile(filepath.Join(path, "file"), nil, 0644)
+
+	assert.NotNil(t, xredis.Save())
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
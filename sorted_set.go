@@ -0,0 +1,145 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+const ENCODING_SKIPLIST = "skiplist"
+
+// SortedSet holds unique members ordered by their score, members with the
+// same score being ordered lexicographically. Its fields are exported so
+// that it can be persisted in the dump.
+type SortedSet struct {
+	Scores  map[string]float64
+	Members []SortedSetMember // Ordered by score, then by member
+	index   *scanIndex        // Built by the first ZSCAN
+}
+
+type SortedSetMember struct {
+	Member string
+	Score  float64
+}
+
+func NewSortedSet() *SortedSet {
+	return &SortedSet{Scores: make(map[string]float64), Members: make([]SortedSetMember, 0)}
+}
+
+// getSortedSet returns the sorted set stored at key, failing if the key
+// holds a value of another type.
+func (xredis *XRedis) getSortedSet(db int, key string) (*SortedSet, bool, error) {
+	value, exists := xredis.getAndInvalidateIfExpired(db, key)
+	if !exists {
+		return nil, false, nil
+	}
+	sortedSet, ok := value.Element.(*SortedSet)
+	if !ok {
+		return nil, false, errors.New(REQUEST_ERROR_WRONG_TYPE)
+	}
+	return sortedSet, true, nil
+}
+
+// add sets the score of the member, returning whether it was added and
+// whether its score changed if it was already there.
+func (sortedSet *SortedSet) add(member string, score float64) (bool, bool) {
+	current, exists := sortedSet.Scores[member]
+	if exists && current == score {
+		return false, false
+	}
+	if exists {
+		sortedSet.remove(member)
+	}
+	sortedSet.Scores[member] = score
+	index, _ := slices.BinarySearchFunc(sortedSet.Members, SortedSetMember{member, score}, compareSortedSetMembers)
+	sortedSet.Members = slices.Insert(sortedSet.Members, index, SortedSetMember{member, score})
+	if sortedSet.index != nil {
+		sortedSet.index.add(member)
+	}
+	return !exists, exists
+}
+
+func (sortedSet *SortedSet) remove(member string) bool {
+	score, exists := sortedSet.Scores[member]
+	if !exists {
+		return false
+	}
+	delete(sortedSet.Scores, member)
+	index, _ := slices.BinarySearchFunc(sortedSet.Members, SortedSetMember{member, score}, compareSortedSetMembers)
+	sortedSet.Members = slices.Delete(sortedSet.Members, index, index+1)
+	if sortedSet.index != nil {
+		sortedSet.index.remove(member)
+	}
+	return true
+}
+
+func (sortedSet *SortedSet) score(member string) (float64, bool) {
+	score, exists := sortedSet.Scores[member]
+	return score, exists
+}
+
+// rangeByScore returns the members whose score is between minScore and
+// maxScore, both included, in order.
+func (sortedSet *SortedSet) rangeByScore(minScore float64, maxScore float64) []SortedSetMember {
+	start, _ := slices.BinarySearchFunc(sortedSet.Members, minScore, func(member SortedSetMember, score float64) int {
+		return cmp.Compare(member.Score, score)
+	})
+	end := start
+	for end < len(sortedSet.Members) && sortedSet.Members[end].Score <= maxScore {
+		end++
+	}
+	return sortedSet.Members[start:end]
+}
+
+func (sortedSet *SortedSet) len() int {
+	return len(sortedSet.Members)
+}
+
+func (sortedSet *SortedSet) encoding() string {
+	if sortedSet.len() <= LISTPACK_MAX_ENTRIES {
+		return ENCODING_LISTPACK
+	}
+	return ENCODING_SKIPLIST
+}
+
+func (sortedSet *SortedSet) clone() *SortedSet {
+	return &SortedSet{Scores: maps.Clone(sortedSet.Scores), Members: slices.Clone(sortedSet.Members)}
+}
+
+func (sortedSet *SortedSet) collectionType() string {
+	return TYPE_ZSET
+}
+
+func (sortedSet *SortedSet) scan(cursor uint64, count int) ([]string, uint64) {
+	if sortedSet.index == nil {
+		sortedSet.index = newScanIndex(slices.Collect(maps.Keys(sortedSet.Scores)))
+	}
+	return sortedSet.index.scan(cursor, count)
+}
+
+func (sortedSet *SortedSet) scanEntry(member string) []RespDataType {
+	return []RespDataType{RespString{member}, RespString{formatScore(sortedSet.Scores[member])}}
+}
+
+// serialize replies the members in order along with their scores, as
+// ZRANGE key 0 -1 WITHSCORES would
+func (sortedSet *SortedSet) serialize() string {
+	elements := make([]RespDataType, 0, 2*sortedSet.len())
+	for _, member := range sortedSet.Members {
+		elements = append(elements, RespString{member.Member}, RespString{formatScore(member.Score)})
+	}
+	return RespArray{elements}.serialize()
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func compareSortedSetMembers(a SortedSetMember, b SortedSetMember) int {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Member, b.Member)
+}
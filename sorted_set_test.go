@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedSetOrdersMembersByScoreThenMember(t *testing.T) {
+	sortedSet := NewSortedSet()
+
+	added, changed := sortedSet.add("b", 2)
+	assert.True(t, added)
+	assert.False(t, changed)
+	sortedSet.add("c", 1)
+	sortedSet.add("a", 2)
+	assert.Equal(t, []SortedSetMember{{"c", 1}, {"a", 2}, {"b", 2}}, sortedSet.Members)
+
+	added, changed = sortedSet.add("c", 3)
+	assert.False(t, added)
+	assert.True(t, changed)
+	assert.Equal(t, []SortedSetMember{{"a", 2}, {"b", 2}, {"c", 3}}, sortedSet.Members)
+
+	assert.True(t, sortedSet.remove("b"))
+	assert.False(t, sortedSet.remove("b"))
+	assert.Equal(t, []SortedSetMember{{"a", 2}, {"c", 3}}, sortedSet.Members)
+}
+
+func TestSortedSetRangeByScore(t *testing.T) {
+	sortedSet := NewSortedSet()
+	for i, member := range []string{"a", "b", "c", "d"} {
+		sortedSet.add(member, float64(i))
+	}
+
+	assert.Equal(t, []SortedSetMember{{"b", 1}, {"c", 2}}, sortedSet.rangeByScore(1, 2))
+	assert.Equal(t, []SortedSetMember{{"d", 3}}, sortedSet.rangeByScore(2.5, 10))
+	assert.Empty(t, sortedSet.rangeByScore(4, 5))
+}
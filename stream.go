@@ -0,0 +1,595 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const TYPE_STREAM = "stream"
+const ENCODING_STREAM = "stream"
+
+const STREAM_ID_SEPARATOR = "-"
+const STREAM_ID_AUTO = "*"
+
+// Stream is an append-only log of entries ordered by their IDs. Its fields
+// are exported so that it can be persisted in the dump.
+type Stream struct {
+	Entries      []StreamEntry
+	LastID       StreamID // ID of the last entry ever added, even if deleted since
+	EntriesAdded uint64   // Number of entries ever added
+	MaxDeletedID StreamID // Greatest ID among the deleted or trimmed entries
+	Groups       map[string]*ConsumerGroup
+}
+
+// StreamID identifies an entry by its creation time in milliseconds and a
+// sequence number telling apart the entries created in the same millisecond.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string // Field and value pairs, in the order they were added, nil if the entry was deleted
+}
+
+// StreamTrim is the trimming strategy of XADD and XTRIM. Entries are
+// removed from the start of the stream until at most maxLen are left or,
+// with MINID, until every entry left has an ID not lower than minID.
+type StreamTrim struct {
+	byMinID bool
+	maxLen  int
+	minID   StreamID
+	limit   int // Maximum number of entries removed, if positive
+}
+
+// StreamRead is the position from which XREAD or XREADGROUP reads a stream
+type StreamRead struct {
+	key  string
+	id   StreamID // Only the entries after this ID are read
+	last bool     // Set for $, which reads the entries added after the call, or > for XREADGROUP
+}
+
+type StreamReadResult struct {
+	key     string
+	entries []StreamEntry
+}
+
+// StreamWaiter is a client blocked by XREAD or XREADGROUP until any of the
+// streams it reads changes.
+type StreamWaiter struct {
+	db    int
+	reads []StreamRead // Reads to be retried once woken up, with $ resolved
+	ready chan struct{}
+}
+
+var STREAM_MIN_ID = StreamID{0, 0}
+var STREAM_MAX_ID = StreamID{math.MaxUint64, math.MaxUint64}
+
+func NewStream() *Stream {
+	return &Stream{Entries: make([]StreamEntry, 0)}
+}
+
+// XAdd appends an entry to the stream, creating it unless noMkStream is
+// set. The id is either *, a complete ID or a <ms>-* ID whose sequence is
+// generated. The returned bool is false when the stream doesn't exist and
+// noMkStream prevented its creation.
+func (xredis *XRedis) XAdd(key string, id string, fields []string, noMkStream bool, trim *StreamTrim) (StreamID, bool, error) {
+	rspChan := make(chan StreamID)
+	addedChan := make(chan bool)
+	errorChan := make(chan error)
+	xredis.commands <- XAddCommand{xredis.db, key, id, fields, noMkStream, trim, rspChan, addedChan, errorChan}
+	return <-rspChan, <-addedChan, <-errorChan
+}
+
+// XRange returns the entries with IDs between start and end, both
+// included, at most count of them if count is positive. With reverse the
+// entries are returned from end to start.
+func (xredis *XRedis) XRange(key string, start StreamID, end StreamID, count int, reverse bool) ([]StreamEntry, error) {
+	rspChan := make(chan []StreamEntry)
+	errorChan := make(chan error)
+	xredis.commands <- XRangeCommand{xredis.db, key, start, end, count, reverse, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XRead returns the entries added to each stream after the ID it is read
+// from. When nothing is found and block is set the returned waiter is
+// woken up once any of the streams changes, so that the read is retried.
+func (xredis *XRedis) XRead(reads []StreamRead, count int, block bool) ([]StreamReadResult, *StreamWaiter, error) {
+	rspChan := make(chan []StreamReadResult)
+	waiterChan := make(chan *StreamWaiter)
+	errorChan := make(chan error)
+	xredis.commands <- XReadCommand{xredis.db, reads, count, block, rspChan, waiterChan, errorChan}
+	return <-rspChan, <-waiterChan, <-errorChan
+}
+
+// CancelStreamWait stops the waiter from being woken up, once its client
+// gave up on waiting.
+func (xredis *XRedis) CancelStreamWait(waiter *StreamWaiter) {
+	doneChan := make(chan struct{})
+	xredis.commands <- CancelStreamWaitCommand{waiter, doneChan}
+	<-doneChan // Wait for completion
+}
+
+func (xredis *XRedis) XLen(key string) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- XLenCommand{xredis.db, key, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XDel removes the entries with the given IDs and returns how many existed
+func (xredis *XRedis) XDel(key string, ids []StreamID) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- XDelCommand{xredis.db, key, ids, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XTrim trims the stream and returns how many entries were removed
+func (xredis *XRedis) XTrim(key string, trim StreamTrim) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- XTrimCommand{xredis.db, key, trim, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) handleXAddCommand(cmd XAddCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.addedChannel)
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil || (!exists && cmd.noMkStream) {
+		cmd.rspChannel <- StreamID{}
+		cmd.addedChannel <- false
+		cmd.errorChannel <- err
+		return
+	}
+	if !exists {
+		stream = NewStream()
+	}
+
+	id, err := stream.nextID(cmd.id)
+	if err != nil {
+		cmd.rspChannel <- StreamID{}
+		cmd.addedChannel <- false
+		cmd.errorChannel <- err
+		return
+	}
+	if !exists {
+		xredis.setValue(cmd.db, cmd.key, XRedisValue{stream, NON_EXPIRATION_TIME})
+	}
+	stream.Entries = append(stream.Entries, StreamEntry{id, slices.Clone(cmd.fields)})
+	stream.LastID = id
+	stream.EntriesAdded++
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XADD, cmd.db, cmd.key)
+	if cmd.trim != nil && stream.trim(*cmd.trim) > 0 {
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XTRIM, cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- id
+	cmd.addedChannel <- true
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXRangeCommand(cmd XRangeCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil || !exists {
+		cmd.rspChannel <- []StreamEntry{}
+		cmd.errorChannel <- err
+		return
+	}
+	cmd.rspChannel <- stream.rangeEntries(cmd.start, cmd.end, cmd.count, cmd.reverse)
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXReadCommand(cmd XReadCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.waiterChannel)
+	defer close(cmd.errorChannel)
+
+	results := make([]StreamReadResult, 0)
+	reads := make([]StreamRead, 0, len(cmd.reads))
+	for _, read := range cmd.reads {
+		stream, exists, err := xredis.getStream(cmd.db, read.key)
+		if err != nil {
+			cmd.rspChannel <- nil
+			cmd.waiterChannel <- nil
+			cmd.errorChannel <- err
+			return
+		}
+		if read.last {
+			read = StreamRead{read.key, STREAM_MIN_ID, false}
+			if exists {
+				read.id = stream.LastID
+			}
+		}
+		reads = append(reads, read)
+		if !exists || read.id == STREAM_MAX_ID {
+			continue
+		}
+		entries := stream.rangeEntries(read.id.next(), STREAM_MAX_ID, cmd.count, false)
+		if len(entries) > 0 {
+			results = append(results, StreamReadResult{read.key, entries})
+		}
+	}
+
+	var waiter *StreamWaiter
+	if len(results) == 0 && cmd.block {
+		waiter = xredis.addStreamWaiter(cmd.db, reads)
+	}
+	cmd.rspChannel <- results
+	cmd.waiterChannel <- waiter
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleCancelStreamWaitCommand(cmd CancelStreamWaitCommand) {
+	defer close(cmd.done)
+	xredis.removeStreamWaiter(cmd.waiter)
+}
+
+func (xredis *XRedis) handleXLenCommand(cmd XLenCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil || !exists {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+	cmd.rspChannel <- len(stream.Entries)
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXDelCommand(cmd XDelCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil || !exists {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+
+	deleted := 0
+	for _, id := range cmd.ids {
+		index, found := stream.findEntry(id)
+		if !found {
+			continue
+		}
+		stream.Entries = slices.Delete(stream.Entries, index, index+1)
+		stream.MaxDeletedID = maxStreamID(stream.MaxDeletedID, id)
+		deleted++
+	}
+	if deleted > 0 {
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XDEL, cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- deleted
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXTrimCommand(cmd XTrimCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil || !exists {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+
+	trimmed := stream.trim(cmd.trim)
+	if trimmed > 0 {
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XTRIM, cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- trimmed
+	cmd.errorChannel <- nil
+}
+
+// getStream returns the stream stored at key, failing if the key holds a
+// value of another type.
+func (xredis *XRedis) getStream(db int, key string) (*Stream, bool, error) {
+	value, exists := xredis.getAndInvalidateIfExpired(db, key)
+	if !exists {
+		return nil, false, nil
+	}
+	stream, ok := value.Element.(*Stream)
+	if !ok {
+		return nil, false, errors.New(REQUEST_ERROR_WRONG_TYPE)
+	}
+	return stream, true, nil
+}
+
+// signalStreamWaiters wakes up the clients blocked on the key, which then
+// retry their reads. Waking them up on changes other than new entries is
+// harmless: they just block again.
+func (xredis *XRedis) signalStreamWaiters(db int, key string) {
+	for waiter := range xredis.streamWaiters[db][key] {
+		xredis.removeStreamWaiter(waiter)
+		close(waiter.ready)
+	}
+}
+
+func (xredis *XRedis) signalAllStreamWaiters(db int) {
+	for key := range xredis.streamWaiters[db] {
+		xredis.signalStreamWaiters(db, key)
+	}
+}
+
+func (xredis *XRedis) addStreamWaiter(db int, reads []StreamRead) *StreamWaiter {
+	waiter := &StreamWaiter{db, reads, make(chan struct{})}
+	for _, read := range reads {
+		if _, ok := xredis.streamWaiters[db][read.key]; !ok {
+			xredis.streamWaiters[db][read.key] = make(map[*StreamWaiter]struct{})
+		}
+		xredis.streamWaiters[db][read.key][waiter] = struct{}{}
+	}
+	return waiter
+}
+
+func (xredis *XRedis) removeStreamWaiter(waiter *StreamWaiter) {
+	for _, read := range waiter.reads {
+		delete(xredis.streamWaiters[waiter.db][read.key], waiter)
+		if len(xredis.streamWaiters[waiter.db][read.key]) == 0 {
+			delete(xredis.streamWaiters[waiter.db], read.key)
+		}
+	}
+}
+
+// nextID returns the ID of the entry to be added as requested by XADD
+func (stream *Stream) nextID(requestedID string) (StreamID, error) {
+	if requestedID == STREAM_ID_AUTO {
+		now := uint64(time.Now().UnixMilli())
+		if now > stream.LastID.Ms {
+			return StreamID{now, 0}, nil
+		}
+		return stream.incrementedLastID()
+	}
+
+	var id StreamID
+	msPart, seqPart, hasSeq := strings.Cut(requestedID, STREAM_ID_SEPARATOR)
+	if hasSeq && seqPart == STREAM_ID_AUTO {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return StreamID{}, errors.New(REQUEST_ERROR_INVALID_STREAM_ID)
+		}
+		switch {
+		case ms < stream.LastID.Ms:
+			return StreamID{}, errors.New(REQUEST_ERROR_STREAM_ID_TOO_SMALL)
+		case ms == stream.LastID.Ms && stream.EntriesAdded > 0:
+			return stream.incrementedLastID()
+		case ms == 0:
+			id = StreamID{0, 1} // 0-0 is never a valid ID
+		default:
+			id = StreamID{ms, 0}
+		}
+	} else {
+		parsedID, err := parseStreamID(requestedID, 0)
+		if err != nil {
+			return StreamID{}, err
+		}
+		id = parsedID
+	}
+
+	if id == STREAM_MIN_ID {
+		return StreamID{}, errors.New(REQUEST_ERROR_STREAM_ID_ZERO)
+	}
+	if id.compare(stream.LastID) <= 0 {
+		return StreamID{}, errors.New(REQUEST_ERROR_STREAM_ID_TOO_SMALL)
+	}
+	return id, nil
+}
+
+func (stream *Stream) incrementedLastID() (StreamID, error) {
+	if stream.LastID == STREAM_MAX_ID {
+		return StreamID{}, errors.New(REQUEST_ERROR_STREAM_ID_TOO_SMALL)
+	}
+	return stream.LastID.next(), nil
+}
+
+func (stream *Stream) rangeEntries(start StreamID, end StreamID, count int, reverse bool) []StreamEntry {
+	entries := make([]StreamEntry, 0)
+	if start.compare(end) > 0 {
+		return entries
+	}
+	first, _ := stream.findEntry(start)
+	last, found := stream.findEntry(end)
+	if !found {
+		last--
+	}
+	for i := range max(last-first+1, 0) {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		index := first + i
+		if reverse {
+			index = last - i
+		}
+		entries = append(entries, stream.Entries[index])
+	}
+	return entries
+}
+
+// findEntry returns the index of the entry with the given ID or, if there
+// is none, the index where it would be inserted.
+func (stream *Stream) findEntry(id StreamID) (int, bool) {
+	return slices.BinarySearchFunc(stream.Entries, id, func(entry StreamEntry, id StreamID) int {
+		return entry.ID.compare(id)
+	})
+}
+
+func (stream *Stream) trim(trim StreamTrim) int {
+	trimmed := len(stream.Entries) - trim.maxLen
+	if trim.byMinID {
+		trimmed, _ = stream.findEntry(trim.minID)
+	}
+	if trim.limit > 0 {
+		trimmed = min(trimmed, trim.limit)
+	}
+	if trimmed <= 0 {
+		return 0
+	}
+	stream.MaxDeletedID = maxStreamID(stream.MaxDeletedID, stream.Entries[trimmed-1].ID)
+	stream.Entries = slices.Delete(stream.Entries, 0, trimmed)
+	return trimmed
+}
+
+// clone deep copies the stream, except for the entry fields which are
+// never modified once added.
+func (stream *Stream) clone() *Stream {
+	clone := *stream
+	clone.Entries = slices.Clone(stream.Entries)
+	if stream.Groups != nil {
+		clone.Groups = make(map[string]*ConsumerGroup, len(stream.Groups))
+		for name, group := range stream.Groups {
+			clone.Groups[name] = group.clone()
+		}
+	}
+	return &clone
+}
+
+// serialize replies the whole stream, as XRANGE - + would
+func (stream *Stream) serialize() string {
+	return streamEntriesReply(stream.Entries).serialize()
+}
+
+func (id StreamID) compare(other StreamID) int {
+	if id.Ms != other.Ms {
+		return cmp.Compare(id.Ms, other.Ms)
+	}
+	return cmp.Compare(id.Seq, other.Seq)
+}
+
+// next returns the smallest ID greater than id, which must not be the
+// greatest ID possible.
+func (id StreamID) next() StreamID {
+	if id.Seq == math.MaxUint64 {
+		return StreamID{id.Ms + 1, 0}
+	}
+	return StreamID{id.Ms, id.Seq + 1}
+}
+
+// previous returns the greatest ID smaller than id, which must not be 0-0
+func (id StreamID) previous() StreamID {
+	if id.Seq == 0 {
+		return StreamID{id.Ms - 1, math.MaxUint64}
+	}
+	return StreamID{id.Ms, id.Seq - 1}
+}
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d%s%d", id.Ms, STREAM_ID_SEPARATOR, id.Seq)
+}
+
+// parseStreamID parses a <ms>-<seq> ID. When the sequence is missing, as
+// allowed by range queries, it is set to missingSeq.
+func parseStreamID(str string, missingSeq uint64) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(str, STREAM_ID_SEPARATOR)
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, errors.New(REQUEST_ERROR_INVALID_STREAM_ID)
+	}
+	if !hasSeq {
+		return StreamID{ms, missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, errors.New(REQUEST_ERROR_INVALID_STREAM_ID)
+	}
+	return StreamID{ms, seq}, nil
+}
+
+func maxStreamID(a StreamID, b StreamID) StreamID {
+	if a.compare(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+func streamEntriesReply(entries []StreamEntry) RespArray {
+	reply := make([]RespDataType, 0, len(entries))
+	for _, entry := range entries {
+		var fields RespDataType = RespNil{}
+		if entry.Fields != nil {
+			fields = stringsToRespArray(entry.Fields)
+		}
+		reply = append(reply, RespArray{[]RespDataType{RespString{entry.ID.String()}, fields}})
+	}
+	return RespArray{reply}
+}
+
+type XAddCommand struct {
+	db           int
+	key          string
+	id           string
+	fields       []string
+	noMkStream   bool
+	trim         *StreamTrim
+	rspChannel   chan StreamID
+	addedChannel chan bool
+	errorChannel chan error
+}
+
+type XRangeCommand struct {
+	db           int
+	key          string
+	start        StreamID
+	end          StreamID
+	count        int
+	reverse      bool
+	rspChannel   chan []StreamEntry
+	errorChannel chan error
+}
+
+type XReadCommand struct {
+	db            int
+	reads         []StreamRead
+	count         int
+	block         bool
+	rspChannel    chan []StreamReadResult
+	waiterChannel chan *StreamWaiter
+	errorChannel  chan error
+}
+
+type CancelStreamWaitCommand struct {
+	waiter *StreamWaiter
+	done   chan struct{}
+}
+
+type XLenCommand struct {
+	db           int
+	key          string
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type XDelCommand struct {
+	db           int
+	key          string
+	ids          []StreamID
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type XTrimCommand struct {
+	db           int
+	key          string
+	trim         StreamTrim
+	rspChannel   chan int
+	errorChannel chan error
+}
@@ -0,0 +1,930 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ConsumerGroup tracks which entries of a stream were delivered to which
+// consumers, keeping the delivered entries pending until acknowledged.
+type ConsumerGroup struct {
+	LastDeliveredID StreamID
+	Pending         map[StreamID]*PendingEntry
+	Consumers       map[string]*Consumer
+}
+
+// PendingEntry is an entry delivered to a consumer but not acknowledged
+type PendingEntry struct {
+	Consumer      string
+	DeliveryTime  int64 // Unix time in milliseconds of the last delivery
+	DeliveryCount int64
+}
+
+type Consumer struct {
+	SeenTime   int64             // Unix time in milliseconds of the last read or claim attempt
+	ActiveTime int64             // Unix time in milliseconds of the last successful read or claim
+	Pending    map[StreamID]bool // IDs of the entries pending for the consumer
+}
+
+// StreamClaimOptions are the XCLAIM options changing how entries are
+// claimed. Negative values stand for options that weren't given.
+type StreamClaimOptions struct {
+	idle       int64 // Idle time to set, instead of resetting it
+	time       int64 // Unix time in milliseconds to set as delivery time
+	retryCount int64 // Delivery count to set, instead of incrementing it
+	force      bool  // Claim entries that aren't pending for anyone yet
+	justID     bool  // Neither increment the delivery count nor reply entries
+	lastID     *StreamID
+}
+
+type StreamAutoClaimResult struct {
+	next    StreamID // Cursor to continue from, 0-0 when the scan is over
+	claimed []StreamEntry
+	deleted []StreamID // Pending IDs whose entries no longer exist
+}
+
+// StreamClaimState is the state of some entries of a consumer group once
+// they were claimed, for the claims to be logged as they were applied.
+type StreamClaimState struct {
+	lastDeliveredID StreamID
+	pending         map[StreamID]PendingEntry // Of the IDs that are pending
+	missing         []StreamID                // IDs without an entry in the stream
+}
+
+type StreamPendingSummary struct {
+	count     int
+	smallest  StreamID
+	greatest  StreamID
+	consumers []ConsumerPendingCount
+}
+
+type ConsumerPendingCount struct {
+	consumer string
+	count    int
+}
+
+type StreamPendingEntry struct {
+	id            StreamID
+	consumer      string
+	idle          int64
+	deliveryCount int64
+}
+
+type StreamInfo struct {
+	length          int
+	lastGeneratedID StreamID
+	maxDeletedID    StreamID
+	entriesAdded    uint64
+	groups          int
+	firstEntry      *StreamEntry
+	lastEntry       *StreamEntry
+}
+
+type ConsumerGroupInfo struct {
+	name            string
+	consumers       int
+	pending         int
+	lastDeliveredID StreamID
+	entriesRead     uint64
+	lag             uint64
+}
+
+type ConsumerInfo struct {
+	name     string
+	pending  int
+	idle     int64
+	inactive int64 // -1 if the consumer never read nor claimed anything
+}
+
+// XGroupCreate creates a consumer group that delivers the entries after
+// id, or after the last entry of the stream when lastID is set.
+func (xredis *XRedis) XGroupCreate(key string, group string, id StreamID, lastID bool, mkStream bool) error {
+	errorChan := make(chan error)
+	xredis.commands <- XGroupCreateCommand{xredis.db, key, group, id, lastID, mkStream, errorChan}
+	return <-errorChan
+}
+
+func (xredis *XRedis) XGroupSetID(key string, group string, id StreamID, lastID bool) error {
+	errorChan := make(chan error)
+	xredis.commands <- XGroupSetIDCommand{xredis.db, key, group, id, lastID, errorChan}
+	return <-errorChan
+}
+
+func (xredis *XRedis) XGroupDestroy(key string, group string) (bool, error) {
+	rspChan := make(chan bool)
+	errorChan := make(chan error)
+	xredis.commands <- XGroupDestroyCommand{xredis.db, key, group, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) XGroupCreateConsumer(key string, group string, consumer string) (bool, error) {
+	rspChan := make(chan bool)
+	errorChan := make(chan error)
+	xredis.commands <- XGroupCreateConsumerCommand{xredis.db, key, group, consumer, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XGroupDelConsumer deletes the consumer and returns how many entries
+// were pending for it, which are no longer pending for anyone.
+func (xredis *XRedis) XGroupDelConsumer(key string, group string, consumer string) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- XGroupDelConsumerCommand{xredis.db, key, group, consumer, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XReadGroup reads the streams on behalf of a consumer of the group. The
+// reads with last set deliver the entries never delivered to the group,
+// the other ones return the entries pending for the consumer after their
+// ID. As with XRead, a waiter is returned when nothing new was delivered
+// and block is set.
+func (xredis *XRedis) XReadGroup(group string, consumer string, reads []StreamRead, count int, noAck bool, block bool) ([]StreamReadResult, *StreamWaiter, error) {
+	rspChan := make(chan []StreamReadResult)
+	waiterChan := make(chan *StreamWaiter)
+	errorChan := make(chan error)
+	xredis.commands <- XReadGroupCommand{xredis.db, group, consumer, reads, count, noAck, block, rspChan, waiterChan, errorChan}
+	return <-rspChan, <-waiterChan, <-errorChan
+}
+
+// XAck acknowledges the entries, which are no longer pending, and returns
+// how many of them were pending.
+func (xredis *XRedis) XAck(key string, group string, ids []StreamID) (int, error) {
+	rspChan := make(chan int)
+	errorChan := make(chan error)
+	xredis.commands <- XAckCommand{xredis.db, key, group, ids, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) XPendingSummary(key string, group string) (StreamPendingSummary, error) {
+	rspChan := make(chan StreamPendingSummary)
+	errorChan := make(chan error)
+	xredis.commands <- XPendingSummaryCommand{xredis.db, key, group, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XPending returns up to count pending entries between start and end
+// that were idle for at least minIdle milliseconds, only those of the
+// consumer if one is given.
+func (xredis *XRedis) XPending(key string, group string, start StreamID, end StreamID, count int, consumer string, minIdle int64) ([]StreamPendingEntry, error) {
+	rspChan := make(chan []StreamPendingEntry)
+	errorChan := make(chan error)
+	xredis.commands <- XPendingCommand{xredis.db, key, group, start, end, count, consumer, minIdle, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XClaim transfers to the consumer the pending entries that were idle for
+// at least minIdle milliseconds, returning the claimed entries.
+func (xredis *XRedis) XClaim(key string, group string, consumer string, minIdle int64, ids []StreamID, options StreamClaimOptions) ([]StreamEntry, error) {
+	rspChan := make(chan []StreamEntry)
+	errorChan := make(chan error)
+	xredis.commands <- XClaimCommand{xredis.db, key, group, consumer, minIdle, ids, options, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XAutoClaim examines up to count pending entries from start on, claiming
+// those that were idle for at least minIdle milliseconds. Entries deleted
+// from the stream are no longer pending and returned apart.
+func (xredis *XRedis) XAutoClaim(key string, group string, consumer string, minIdle int64, start StreamID, count int, justID bool) (StreamAutoClaimResult, error) {
+	rspChan := make(chan StreamAutoClaimResult)
+	errorChan := make(chan error)
+	xredis.commands <- XAutoClaimCommand{xredis.db, key, group, consumer, minIdle, start, count, justID, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// XClaimState returns the state of the entries of the group, as left by
+// XCLAIM or XAUTOCLAIM.
+func (xredis *XRedis) XClaimState(key string, group string, ids []StreamID) (StreamClaimState, error) {
+	rspChan := make(chan StreamClaimState)
+	errorChan := make(chan error)
+	xredis.commands <- XClaimStateCommand{xredis.db, key, group, ids, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) XInfoStream(key string) (StreamInfo, error) {
+	rspChan := make(chan StreamInfo)
+	errorChan := make(chan error)
+	xredis.commands <- XInfoStreamCommand{xredis.db, key, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) XInfoGroups(key string) ([]ConsumerGroupInfo, error) {
+	rspChan := make(chan []ConsumerGroupInfo)
+	errorChan := make(chan error)
+	xredis.commands <- XInfoGroupsCommand{xredis.db, key, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) XInfoConsumers(key string, group string) ([]ConsumerInfo, error) {
+	rspChan := make(chan []ConsumerInfo)
+	errorChan := make(chan error)
+	xredis.commands <- XInfoConsumersCommand{xredis.db, key, group, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) handleXGroupCreateCommand(cmd XGroupCreateCommand) {
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil {
+		cmd.errorChannel <- err
+		return
+	}
+	if !exists {
+		if !cmd.mkStream {
+			cmd.errorChannel <- errors.New(REQUEST_ERROR_XGROUP_KEY_MUST_EXIST)
+			return
+		}
+		stream = NewStream()
+		xredis.setValue(cmd.db, cmd.key, XRedisValue{stream, NON_EXPIRATION_TIME})
+	}
+	if _, ok := stream.Groups[cmd.group]; ok {
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_BUSY_GROUP)
+		return
+	}
+
+	if stream.Groups == nil {
+		stream.Groups = make(map[string]*ConsumerGroup)
+	}
+	lastDeliveredID := cmd.id
+	if cmd.lastID {
+		lastDeliveredID = stream.LastID
+	}
+	stream.Groups[cmd.group] = &ConsumerGroup{lastDeliveredID, make(map[StreamID]*PendingEntry), make(map[string]*Consumer)}
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XGROUP_CREATE, cmd.db, cmd.key)
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXGroupSetIDCommand(cmd XGroupSetIDCommand) {
+	defer close(cmd.errorChannel)
+
+	stream, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.errorChannel <- err
+		return
+	}
+	group.LastDeliveredID = cmd.id
+	if cmd.lastID {
+		group.LastDeliveredID = stream.LastID
+	}
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XGROUP_SETID, cmd.db, cmd.key)
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXGroupDestroyCommand(cmd XGroupDestroyCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil || !exists {
+		if err == nil {
+			err = errors.New(REQUEST_ERROR_XGROUP_KEY_MUST_EXIST)
+		}
+		cmd.rspChannel <- false
+		cmd.errorChannel <- err
+		return
+	}
+	if _, ok := stream.Groups[cmd.group]; !ok {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- nil
+		return
+	}
+
+	delete(stream.Groups, cmd.group)
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XGROUP_DESTROY, cmd.db, cmd.key)
+	cmd.rspChannel <- true
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXGroupCreateConsumerCommand(cmd XGroupCreateConsumerCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	_, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- err
+		return
+	}
+	_, created := xredis.groupConsumer(cmd.db, cmd.key, group, cmd.consumer)
+	cmd.rspChannel <- created
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXGroupDelConsumerCommand(cmd XGroupDelConsumerCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	_, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+	consumer, ok := group.Consumers[cmd.consumer]
+	if !ok {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- nil
+		return
+	}
+
+	for id := range consumer.Pending {
+		delete(group.Pending, id)
+	}
+	delete(group.Consumers, cmd.consumer)
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XGROUP_DELCONSUMER, cmd.db, cmd.key)
+	cmd.rspChannel <- len(consumer.Pending)
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXReadGroupCommand(cmd XReadGroupCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.waiterChannel)
+	defer close(cmd.errorChannel)
+
+	// Every group must exist before anything is delivered
+	streams := make([]*Stream, 0, len(cmd.reads))
+	groups := make([]*ConsumerGroup, 0, len(cmd.reads))
+	for _, read := range cmd.reads {
+		stream, group, err := xredis.getConsumerGroup(cmd.db, read.key, cmd.group)
+		if err != nil {
+			cmd.rspChannel <- nil
+			cmd.waiterChannel <- nil
+			cmd.errorChannel <- err
+			return
+		}
+		streams = append(streams, stream)
+		groups = append(groups, group)
+	}
+
+	now := time.Now().UnixMilli()
+	results := make([]StreamReadResult, 0)
+	for i, read := range cmd.reads {
+		stream, group := streams[i], groups[i]
+		consumer, _ := xredis.groupConsumer(cmd.db, read.key, group, cmd.consumer)
+		consumer.SeenTime = now
+
+		if !read.last {
+			// Reading the history of the consumer never blocks, so there
+			// is always a result even if it's empty
+			results = append(results, StreamReadResult{read.key, group.consumerHistory(stream, consumer, read.id, cmd.count)})
+			continue
+		}
+		if group.LastDeliveredID == STREAM_MAX_ID {
+			continue
+		}
+		entries := stream.rangeEntries(group.LastDeliveredID.next(), STREAM_MAX_ID, cmd.count, false)
+		if len(entries) == 0 {
+			continue
+		}
+		for _, entry := range entries {
+			group.LastDeliveredID = entry.ID
+			if !cmd.noAck {
+				group.deliver(entry.ID, cmd.consumer, now, false)
+			}
+		}
+		consumer.ActiveTime = now
+		xredis.touchKey(cmd.db, read.key)
+		results = append(results, StreamReadResult{read.key, entries})
+	}
+
+	var waiter *StreamWaiter
+	if len(results) == 0 && cmd.block {
+		waiter = xredis.addStreamWaiter(cmd.db, cmd.reads)
+	}
+	cmd.rspChannel <- results
+	cmd.waiterChannel <- waiter
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXAckCommand(cmd XAckCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	_, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- 0
+		cmd.errorChannel <- err
+		return
+	}
+
+	acknowledged := 0
+	for _, id := range cmd.ids {
+		if _, ok := group.Pending[id]; ok {
+			group.acknowledge(id)
+			acknowledged++
+		}
+	}
+	if acknowledged > 0 {
+		xredis.touchKey(cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- acknowledged
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXPendingSummaryCommand(cmd XPendingSummaryCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	_, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- StreamPendingSummary{}
+		cmd.errorChannel <- err
+		return
+	}
+
+	summary := StreamPendingSummary{count: len(group.Pending)}
+	ids := group.pendingIDs()
+	if len(ids) > 0 {
+		summary.smallest, summary.greatest = ids[0], ids[len(ids)-1]
+	}
+	for _, name := range slices.Sorted(maps.Keys(group.Consumers)) {
+		if count := len(group.Consumers[name].Pending); count > 0 {
+			summary.consumers = append(summary.consumers, ConsumerPendingCount{name, count})
+		}
+	}
+	cmd.rspChannel <- summary
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXPendingCommand(cmd XPendingCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	_, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- nil
+		cmd.errorChannel <- err
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	entries := make([]StreamPendingEntry, 0)
+	for _, id := range group.pendingIDs() {
+		if len(entries) == cmd.count {
+			break
+		}
+		pendingEntry := group.Pending[id]
+		idle := now - pendingEntry.DeliveryTime
+		if id.compare(cmd.start) < 0 || id.compare(cmd.end) > 0 || idle < cmd.minIdle {
+			continue
+		}
+		if cmd.consumer != "" && pendingEntry.Consumer != cmd.consumer {
+			continue
+		}
+		entries = append(entries, StreamPendingEntry{id, pendingEntry.Consumer, idle, pendingEntry.DeliveryCount})
+	}
+	cmd.rspChannel <- entries
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXClaimCommand(cmd XClaimCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- nil
+		cmd.errorChannel <- err
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	consumer, _ := xredis.groupConsumer(cmd.db, cmd.key, group, cmd.consumer)
+	consumer.SeenTime = now
+	changed := false
+	if cmd.options.lastID != nil && cmd.options.lastID.compare(group.LastDeliveredID) > 0 {
+		group.LastDeliveredID = *cmd.options.lastID
+		changed = true
+	}
+
+	deliveryTime := now
+	if cmd.options.idle >= 0 {
+		deliveryTime = now - cmd.options.idle
+	}
+	if cmd.options.time >= 0 {
+		deliveryTime = cmd.options.time
+	}
+
+	claimed := make([]StreamEntry, 0)
+	for _, id := range cmd.ids {
+		index, exists := stream.findEntry(id)
+		pendingEntry, pending := group.Pending[id]
+		if !pending && (!cmd.options.force || !exists) {
+			continue
+		}
+		if !exists {
+			// The entry was deleted, so there is nothing left to process
+			group.acknowledge(id)
+			changed = true
+			continue
+		}
+		if pending && now-pendingEntry.DeliveryTime < cmd.minIdle {
+			continue
+		}
+
+		group.deliver(id, cmd.consumer, deliveryTime, cmd.options.justID)
+		if cmd.options.retryCount >= 0 {
+			group.Pending[id].DeliveryCount = cmd.options.retryCount
+		}
+		claimed = append(claimed, stream.Entries[index])
+	}
+	if len(claimed) > 0 {
+		consumer.ActiveTime = now
+	}
+	if changed || len(claimed) > 0 {
+		xredis.touchKey(cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- claimed
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXAutoClaimCommand(cmd XAutoClaimCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- StreamAutoClaimResult{}
+		cmd.errorChannel <- err
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	consumer, _ := xredis.groupConsumer(cmd.db, cmd.key, group, cmd.consumer)
+	consumer.SeenTime = now
+
+	result := StreamAutoClaimResult{claimed: make([]StreamEntry, 0), deleted: make([]StreamID, 0)}
+	ids := group.pendingIDs()
+	first, _ := slices.BinarySearchFunc(ids, cmd.start, StreamID.compare)
+	examined := 0
+	for _, id := range ids[first:] {
+		if examined == cmd.count {
+			result.next = id
+			break
+		}
+		examined++
+		index, exists := stream.findEntry(id)
+		if !exists {
+			group.acknowledge(id)
+			result.deleted = append(result.deleted, id)
+			continue
+		}
+		if now-group.Pending[id].DeliveryTime < cmd.minIdle {
+			continue
+		}
+		group.deliver(id, cmd.consumer, now, cmd.justID)
+		result.claimed = append(result.claimed, stream.Entries[index])
+	}
+	if len(result.claimed) > 0 {
+		consumer.ActiveTime = now
+	}
+	if len(result.claimed) > 0 || len(result.deleted) > 0 {
+		xredis.touchKey(cmd.db, cmd.key)
+	}
+	cmd.rspChannel <- result
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXClaimStateCommand(cmd XClaimStateCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- StreamClaimState{}
+		cmd.errorChannel <- err
+		return
+	}
+	state := StreamClaimState{group.LastDeliveredID, make(map[StreamID]PendingEntry), nil}
+	for _, id := range cmd.ids {
+		if pendingEntry, ok := group.Pending[id]; ok {
+			state.pending[id] = *pendingEntry
+		}
+		if _, exists := stream.findEntry(id); !exists {
+			state.missing = append(state.missing, id)
+		}
+	}
+	cmd.rspChannel <- state
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXInfoStreamCommand(cmd XInfoStreamCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil || !exists {
+		if err == nil {
+			err = errors.New(REQUEST_ERROR_NO_SUCH_KEY)
+		}
+		cmd.rspChannel <- StreamInfo{}
+		cmd.errorChannel <- err
+		return
+	}
+
+	info := StreamInfo{len(stream.Entries), stream.LastID, stream.MaxDeletedID, stream.EntriesAdded, len(stream.Groups), nil, nil}
+	if len(stream.Entries) > 0 {
+		info.firstEntry = &stream.Entries[0]
+		info.lastEntry = &stream.Entries[len(stream.Entries)-1]
+	}
+	cmd.rspChannel <- info
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXInfoGroupsCommand(cmd XInfoGroupsCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	stream, exists, err := xredis.getStream(cmd.db, cmd.key)
+	if err != nil || !exists {
+		if err == nil {
+			err = errors.New(REQUEST_ERROR_NO_SUCH_KEY)
+		}
+		cmd.rspChannel <- nil
+		cmd.errorChannel <- err
+		return
+	}
+
+	infos := make([]ConsumerGroupInfo, 0, len(stream.Groups))
+	for _, name := range slices.Sorted(maps.Keys(stream.Groups)) {
+		group := stream.Groups[name]
+		// The entries yet to be delivered are still in the stream, so the
+		// lag is exact even if entries were deleted
+		first, _ := stream.findEntry(group.LastDeliveredID)
+		lag := uint64(len(stream.Entries) - first)
+		if first < len(stream.Entries) && stream.Entries[first].ID == group.LastDeliveredID {
+			lag--
+		}
+		infos = append(infos, ConsumerGroupInfo{name, len(group.Consumers), len(group.Pending), group.LastDeliveredID, stream.EntriesAdded - lag, lag})
+	}
+	cmd.rspChannel <- infos
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleXInfoConsumersCommand(cmd XInfoConsumersCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	_, group, err := xredis.getConsumerGroup(cmd.db, cmd.key, cmd.group)
+	if err != nil {
+		cmd.rspChannel <- nil
+		cmd.errorChannel <- err
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	infos := make([]ConsumerInfo, 0, len(group.Consumers))
+	for _, name := range slices.Sorted(maps.Keys(group.Consumers)) {
+		consumer := group.Consumers[name]
+		inactive := int64(-1)
+		if consumer.ActiveTime > 0 {
+			inactive = now - consumer.ActiveTime
+		}
+		infos = append(infos, ConsumerInfo{name, len(consumer.Pending), now - consumer.SeenTime, inactive})
+	}
+	cmd.rspChannel <- infos
+	cmd.errorChannel <- nil
+}
+
+// getConsumerGroup returns the stream stored at key and its group, failing
+// if either doesn't exist.
+func (xredis *XRedis) getConsumerGroup(db int, key string, groupName string) (*Stream, *ConsumerGroup, error) {
+	stream, exists, err := xredis.getStream(db, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, errors.New(REQUEST_ERROR_NO_GROUP)
+	}
+	group, ok := stream.Groups[groupName]
+	if !ok {
+		return nil, nil, errors.New(REQUEST_ERROR_NO_GROUP)
+	}
+	return stream, group, nil
+}
+
+// groupConsumer returns the consumer of the group, creating it if needed
+func (xredis *XRedis) groupConsumer(db int, key string, group *ConsumerGroup, name string) (*Consumer, bool) {
+	if consumer, ok := group.Consumers[name]; ok {
+		return consumer, false
+	}
+	consumer := &Consumer{time.Now().UnixMilli(), 0, make(map[StreamID]bool)}
+	group.Consumers[name] = consumer
+	xredis.touchKey(db, key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STREAM, KEYSPACE_EVENT_XGROUP_CREATECONSUMER, db, key)
+	return consumer, true
+}
+
+// deliver makes the entry pending for the consumer, taking it from the
+// consumer it was pending for if any. Unless keepCount is set the delivery
+// count is incremented.
+func (group *ConsumerGroup) deliver(id StreamID, consumerName string, deliveryTime int64, keepCount bool) {
+	pendingEntry, ok := group.Pending[id]
+	if !ok {
+		pendingEntry = &PendingEntry{}
+		group.Pending[id] = pendingEntry
+	} else if previous, ok := group.Consumers[pendingEntry.Consumer]; ok {
+		delete(previous.Pending, id)
+	}
+	pendingEntry.Consumer = consumerName
+	pendingEntry.DeliveryTime = deliveryTime
+	if !keepCount {
+		pendingEntry.DeliveryCount++
+	}
+	group.Consumers[consumerName].Pending[id] = true
+}
+
+func (group *ConsumerGroup) acknowledge(id StreamID) {
+	if pendingEntry, ok := group.Pending[id]; ok {
+		if consumer, ok := group.Consumers[pendingEntry.Consumer]; ok {
+			delete(consumer.Pending, id)
+		}
+		delete(group.Pending, id)
+	}
+}
+
+func (group *ConsumerGroup) pendingIDs() []StreamID {
+	return slices.SortedFunc(maps.Keys(group.Pending), StreamID.compare)
+}
+
+// consumerHistory returns the entries pending for the consumer after id.
+// Entries deleted from the stream since their delivery have no fields.
+func (group *ConsumerGroup) consumerHistory(stream *Stream, consumer *Consumer, id StreamID, count int) []StreamEntry {
+	entries := make([]StreamEntry, 0)
+	for _, pendingID := range slices.SortedFunc(maps.Keys(consumer.Pending), StreamID.compare) {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		if pendingID.compare(id) <= 0 {
+			continue
+		}
+		entry := StreamEntry{pendingID, nil}
+		if index, exists := stream.findEntry(pendingID); exists {
+			entry = stream.Entries[index]
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (group *ConsumerGroup) clone() *ConsumerGroup {
+	clone := &ConsumerGroup{group.LastDeliveredID, make(map[StreamID]*PendingEntry, len(group.Pending)), make(map[string]*Consumer, len(group.Consumers))}
+	for id, pendingEntry := range group.Pending {
+		pendingEntryClone := *pendingEntry
+		clone.Pending[id] = &pendingEntryClone
+	}
+	for name, consumer := range group.Consumers {
+		clone.Consumers[name] = &Consumer{consumer.SeenTime, consumer.ActiveTime, maps.Clone(consumer.Pending)}
+	}
+	return clone
+}
+
+type XGroupCreateCommand struct {
+	db           int
+	key          string
+	group        string
+	id           StreamID
+	lastID       bool
+	mkStream     bool
+	errorChannel chan error
+}
+
+type XGroupSetIDCommand struct {
+	db           int
+	key          string
+	group        string
+	id           StreamID
+	lastID       bool
+	errorChannel chan error
+}
+
+type XGroupDestroyCommand struct {
+	db           int
+	key          string
+	group        string
+	rspChannel   chan bool
+	errorChannel chan error
+}
+
+type XGroupCreateConsumerCommand struct {
+	db           int
+	key          string
+	group        string
+	consumer     string
+	rspChannel   chan bool
+	errorChannel chan error
+}
+
+type XGroupDelConsumerCommand struct {
+	db           int
+	key          string
+	group        string
+	consumer     string
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type XReadGroupCommand struct {
+	db            int
+	group         string
+	consumer      string
+	reads         []StreamRead
+	count         int
+	noAck         bool
+	block         bool
+	rspChannel    chan []StreamReadResult
+	waiterChannel chan *StreamWaiter
+	errorChannel  chan error
+}
+
+type XAckCommand struct {
+	db           int
+	key          string
+	group        string
+	ids          []StreamID
+	rspChannel   chan int
+	errorChannel chan error
+}
+
+type XPendingSummaryCommand struct {
+	db           int
+	key          string
+	group        string
+	rspChannel   chan StreamPendingSummary
+	errorChannel chan error
+}
+
+type XPendingCommand struct {
+	db           int
+	key          string
+	group        string
+	start        StreamID
+	end          StreamID
+	count        int
+	consumer     string
+	minIdle      int64
+	rspChannel   chan []StreamPendingEntry
+	errorChannel chan error
+}
+
+type XClaimCommand struct {
+	db           int
+	key          string
+	group        string
+	consumer     string
+	minIdle      int64
+	ids          []StreamID
+	options      StreamClaimOptions
+	rspChannel   chan []StreamEntry
+	errorChannel chan error
+}
+
+type XAutoClaimCommand struct {
+	db           int
+	key          string
+	group        string
+	consumer     string
+	minIdle      int64
+	start        StreamID
+	count        int
+	justID       bool
+	rspChannel   chan StreamAutoClaimResult
+	errorChannel chan error
+}
+
+type XClaimStateCommand struct {
+	db           int
+	key          string
+	group        string
+	ids          []StreamID
+	rspChannel   chan StreamClaimState
+	errorChannel chan error
+}
+
+type XInfoStreamCommand struct {
+	db           int
+	key          string
+	rspChannel   chan StreamInfo
+	errorChannel chan error
+}
+
+type XInfoGroupsCommand struct {
+	db           int
+	key          string
+	rspChannel   chan []ConsumerGroupInfo
+	errorChannel chan error
+}
+
+type XInfoConsumersCommand struct {
+	db           int
+	key          string
+	group        string
+	rspChannel   chan []ConsumerInfo
+	errorChannel chan error
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newStreamWithGroup(t *testing.T) *XRedis {
+	xredis := NewXRedis()
+	xredis.XAdd("events", "1-0", []string{"a", "1"}, false, nil)
+	xredis.XAdd("events", "2-0", []string{"a", "2"}, false, nil)
+	xredis.XAdd("events", "3-0", []string{"a", "3"}, false, nil)
+	assert.Nil(t, xredis.XGroupCreate("events", "workers", STREAM_MIN_ID, false, false))
+	return xredis
+}
+
+func TestXGroupCreate(t *testing.T) {
+	xredis := newStreamWithGroup(t)
+
+	err := xredis.XGroupCreate("events", "workers", STREAM_MIN_ID, false, false)
+	assert.Equal(t, REQUEST_ERROR_BUSY_GROUP, err.Error())
+
+	err = xredis.XGroupCreate("other", "workers", STREAM_MIN_ID, true, false)
+	assert.Equal(t, REQUEST_ERROR_XGROUP_KEY_MUST_EXIST, err.Error())
+
+	assert.Nil(t, xredis.XGroupCreate("other", "workers", STREAM_MIN_ID, true, true))
+	length, _ := xredis.XLen("other")
+	assert.Equal(t, 0, length)
+
+	destroyed, _ := xredis.XGroupDestroy("other", "workers")
+	assert.True(t, destroyed)
+	destroyed, _ = xredis.XGroupDestroy("other", "workers")
+	assert.False(t, destroyed)
+}
+
+func TestXReadGroupDeliversNewEntriesOnce(t *testing.T) {
+	xredis := newStreamWithGroup(t)
+	reads := []StreamRead{{"events", StreamID{}, true}}
+
+	results, _, err := xredis.XReadGroup("workers", "alice", reads, 2, false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []StreamReadResult{{"events", []StreamEntry{
+		{StreamID{1, 0}, []string{"a", "1"}},
+		{StreamID{2, 0}, []string{"a", "2"}},
+	}}}, results)
+
+	results, _, _ = xredis.XReadGroup("workers", "bob", reads, 0, false, false)
+	assert.Equal(t, []StreamReadResult{{"events", []StreamEntry{{StreamID{3, 0}, []string{"a", "3"}}}}}, results)
+
+	results, waiter, _ := xredis.XReadGroup("workers", "bob", reads, 0, false, true)
+	assert.Empty(t, results)
+	xredis.XAdd("events", "4-0", []string{"a", "4"}, false, nil)
+	<-waiter.ready
+
+	summary, _ := xredis.XPendingSummary("events", "workers")
+	assert.Equal(t, StreamPendingSummary{3, StreamID{1, 0}, StreamID{3, 0}, []ConsumerPendingCount{{"alice", 2}, {"bob", 1}}}, summary)
+
+	_, _, err = xredis.XReadGroup("missing", "alice", reads, 0, false, false)
+	assert.Equal(t, REQUEST_ERROR_NO_GROUP, err.Error())
+}
+
+func TestXReadGroupHistoryAndXAck(t *testing.T) {
+	xredis := newStreamWithGroup(t)
+	xredis.XReadGroup("workers", "alice", []StreamRead{{"events", StreamID{}, true}}, 0, false, false)
+
+	acknowledged, _ := xredis.XAck("events", "workers", []StreamID{{1, 0}, {9, 0}})
+	assert.Equal(t, 1, acknowledged)
+	xredis.XDel("events", []StreamID{{3, 0}})
+
+	history := []StreamRead{{"events", STREAM_MIN_ID, false}}
+	results, _, _ := xredis.XReadGroup("workers", "alice", history, 0, false, false)
+	assert.Equal(t, []StreamReadResult{{"events", []StreamEntry{
+		{StreamID{2, 0}, []string{"a", "2"}},
+		{StreamID{3, 0}, nil},
+	}}}, results)
+
+	results, _, _ = xredis.XReadGroup("workers", "bob", history, 0, false, false)
+	assert.Equal(t, []StreamReadResult{{"events", []StreamEntry{}}}, results)
+}
+
+func TestXReadGroupWithNoAck(t *testing.T) {
+	xredis := newStreamWithGroup(t)
+	xredis.XReadGroup("workers", "alice", []StreamRead{{"events", StreamID{}, true}}, 0, true, false)
+
+	summary, _ := xredis.XPendingSummary("events", "workers")
+	assert.Equal(t, 0, summary.count)
+	groups, _ := xredis.XInfoGroups("events")
+	assert.Equal(t, StreamID{3, 0}, groups[0].lastDeliveredID)
+	assert.Equal(t, uint64(0), groups[0].lag)
+}
+
+func TestXPendingAndXClaim(t *testing.T) {
+	xredis := newStreamWithGroup(t)
+	xredis.XReadGroup("workers", "alice", []StreamRead{{"events", StreamID{}, true}}, 2, false, false)
+
+	entries, _ := xredis.XPending("events", "workers", STREAM_MIN_ID, STREAM_MAX_ID, 10, "alice", 0)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, int64(1), entries[0].deliveryCount)
+
+	options := StreamClaimOptions{idle: -1, time: -1, retryCount: -1}
+	claimed, _ := xredis.XClaim("events", "workers", "bob", 3600000, []StreamID{{1, 0}}, options)
+	assert.Empty(t, claimed)
+
+	claimed, _ = xredis.XClaim("events", "workers", "bob", 0, []StreamID{{1, 0}, {3, 0}}, options)
+	assert.Equal(t, []StreamEntry{{StreamID{1, 0}, []string{"a", "1"}}}, claimed)
+
+	options.force = true
+	claimed, _ = xredis.XClaim("events", "workers", "bob", 0, []StreamID{{3, 0}}, options)
+	assert.Len(t, claimed, 1)
+
+	entries, _ = xredis.XPending("events", "workers", STREAM_MIN_ID, STREAM_MAX_ID, 10, "bob", 0)
+	assert.Equal(t, []StreamID{{1, 0}, {3, 0}}, []StreamID{entries[0].id, entries[1].id})
+	assert.Equal(t, int64(2), entries[0].deliveryCount)
+
+	consumers, _ := xredis.XInfoConsumers("events", "workers")
+	assert.Equal(t, []string{"alice", "bob"}, []string{consumers[0].name, consumers[1].name})
+	assert.Equal(t, []int{1, 2}, []int{consumers[0].pending, consumers[1].pending})
+}
+
+func TestXAutoClaim(t *testing.T) {
+	xredis := newStreamWithGroup(t)
+	xredis.XReadGroup("workers", "alice", []StreamRead{{"events", StreamID{}, true}}, 0, false, false)
+	xredis.XDel("events", []StreamID{{2, 0}})
+
+	result, _ := xredis.XAutoClaim("events", "workers", "bob", 0, STREAM_MIN_ID, 2, false)
+	assert.Equal(t, StreamID{3, 0}, result.next)
+	assert.Equal(t, []StreamEntry{{StreamID{1, 0}, []string{"a", "1"}}}, result.claimed)
+	assert.Equal(t, []StreamID{{2, 0}}, result.deleted)
+
+	result, _ = xredis.XAutoClaim("events", "workers", "bob", 0, result.next, 2, true)
+	assert.Equal(t, STREAM_MIN_ID, result.next)
+	assert.Len(t, result.claimed, 1)
+
+	summary, _ := xredis.XPendingSummary("events", "workers")
+	assert.Equal(t, []ConsumerPendingCount{{"bob", 2}}, summary.consumers)
+}
+
+func TestXGroupDelConsumer(t *testing.T) {
+	xredis := newStreamWithGroup(t)
+	created, _ := xredis.XGroupCreateConsumer("events", "workers", "alice")
+	assert.True(t, created)
+	created, _ = xredis.XGroupCreateConsumer("events", "workers", "alice")
+	assert.False(t, created)
+	xredis.XReadGroup("workers", "alice", []StreamRead{{"events", StreamID{}, true}}, 0, false, false)
+
+	pending, _ := xredis.XGroupDelConsumer("events", "workers", "alice")
+	assert.Equal(t, 3, pending)
+	summary, _ := xredis.XPendingSummary("events", "workers")
+	assert.Equal(t, 0, summary.count)
+}
+
+func TestXInfoStreamAndGroups(t *testing.T) {
+	xredis := newStreamWithGroup(t)
+	xredis.XReadGroup("workers", "alice", []StreamRead{{"events", StreamID{}, true}}, 1, false, false)
+
+	info, _ := xredis.XInfoStream("events")
+	assert.Equal(t, 3, info.length)
+	assert.Equal(t, 1, info.groups)
+	assert.Equal(t, StreamID{1, 0}, info.firstEntry.ID)
+	assert.Equal(t, StreamID{3, 0}, info.lastEntry.ID)
+
+	assert.Nil(t, xredis.XGroupSetID("events", "workers", StreamID{2, 0}, false))
+	groups, _ := xredis.XInfoGroups("events")
+	assert.Equal(t, []ConsumerGroupInfo{{"workers", 1, 1, StreamID{2, 0}, 2, 1}}, groups)
+
+	_, err := xredis.XInfoStream("other")
+	assert.Equal(t, REQUEST_ERROR_NO_SUCH_KEY, err.Error())
+}
+
+func TestSaveAndLoadStreamGroups(t *testing.T) {
+	xredis1 := newStreamWithGroup(t)
+	xredis1.XReadGroup("workers", "alice", []StreamRead{{"events", StreamID{}, true}}, 1, false, false)
+	xredis1.Copy("events", "copy", DEFAULT_DATABASE, false)
+	xredis1.XAck("events", "workers", []StreamID{{1, 0}})
+	data := xredis1.Serialize()
+
+	xredis2 := NewXRedis()
+	assert.Nil(t, xredis2.Load(data))
+	summary, _ := xredis2.XPendingSummary("events", "workers")
+	assert.Equal(t, 0, summary.count)
+	summary, _ = xredis2.XPendingSummary("copy", "workers")
+	assert.Equal(t, []ConsumerPendingCount{{"alice", 1}}, summary.consumers)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXAddWithExplicitIDs(t *testing.T) {
+	xredis := NewXRedis()
+
+	id, added, err := xredis.XAdd("events", "1-1", []string{"a", "1"}, false, nil)
+	assert.Nil(t, err)
+	assert.True(t, added)
+	assert.Equal(t, StreamID{1, 1}, id)
+
+	id, _, err = xredis.XAdd("events", "1-*", []string{"a", "2"}, false, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamID{1, 2}, id)
+
+	id, _, err = xredis.XAdd("events", "5-*", []string{"a", "3"}, false, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamID{5, 0}, id)
+
+	_, _, err = xredis.XAdd("events", "5-0", []string{"a", "4"}, false, nil)
+	assert.Equal(t, REQUEST_ERROR_STREAM_ID_TOO_SMALL, err.Error())
+
+	_, _, err = xredis.XAdd("other", "0-0", []string{"a", "4"}, false, nil)
+	assert.Equal(t, REQUEST_ERROR_STREAM_ID_ZERO, err.Error())
+	assert.False(t, xredis.Exists("other"))
+
+	length, _ := xredis.XLen("events")
+	assert.Equal(t, 3, length)
+}
+
+func TestXAddWithAutoID(t *testing.T) {
+	xredis := NewXRedis()
+
+	id1, _, _ := xredis.XAdd("events", STREAM_ID_AUTO, []string{"a", "1"}, false, nil)
+	id2, _, _ := xredis.XAdd("events", STREAM_ID_AUTO, []string{"a", "2"}, false, nil)
+	assert.Equal(t, 1, id2.compare(id1))
+	assert.Equal(t, TYPE_STREAM, xredis.Type("events"))
+}
+
+func TestXAddNoMkStream(t *testing.T) {
+	xredis := NewXRedis()
+
+	_, added, err := xredis.XAdd("events", STREAM_ID_AUTO, []string{"a", "1"}, true, nil)
+	assert.Nil(t, err)
+	assert.False(t, added)
+	assert.False(t, xredis.Exists("events"))
+}
+
+func TestXAddOnWrongType(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.Set("bla", RespString{"1"})
+
+	_, _, err := xredis.XAdd("bla", STREAM_ID_AUTO, []string{"a", "1"}, false, nil)
+	assert.Equal(t, REQUEST_ERROR_WRONG_TYPE, err.Error())
+}
+
+func TestXRange(t *testing.T) {
+	xredis := NewXRedis()
+	for _, id := range []string{"1-0", "2-0", "2-1", "3-0"} {
+		xredis.XAdd("events", id, []string{"id", id}, false, nil)
+	}
+
+	entries, err := xredis.XRange("events", StreamID{2, 0}, STREAM_MAX_ID, 0, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []StreamEntry{{StreamID{2, 0}, []string{"id", "2-0"}}, {StreamID{2, 1}, []string{"id", "2-1"}}, {StreamID{3, 0}, []string{"id", "3-0"}}}, entries)
+
+	entries, _ = xredis.XRange("events", STREAM_MIN_ID, StreamID{2, 0}, 0, true)
+	assert.Equal(t, []StreamEntry{{StreamID{2, 0}, []string{"id", "2-0"}}, {StreamID{1, 0}, []string{"id", "1-0"}}}, entries)
+
+	entries, _ = xredis.XRange("events", STREAM_MIN_ID, STREAM_MAX_ID, 1, true)
+	assert.Equal(t, []StreamEntry{{StreamID{3, 0}, []string{"id", "3-0"}}}, entries)
+
+	entries, _ = xredis.XRange("missing", STREAM_MIN_ID, STREAM_MAX_ID, 0, false)
+	assert.Empty(t, entries)
+}
+
+func TestXDelAndXTrim(t *testing.T) {
+	xredis := NewXRedis()
+	for _, id := range []string{"1-0", "2-0", "3-0", "4-0", "5-0"} {
+		xredis.XAdd("events", id, []string{"id", id}, false, nil)
+	}
+
+	deleted, err := xredis.XDel("events", []StreamID{{2, 0}, {9, 0}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+
+	trimmed, err := xredis.XTrim("events", StreamTrim{maxLen: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, trimmed)
+
+	trimmed, _ = xredis.XTrim("events", StreamTrim{byMinID: true, minID: StreamID{5, 0}})
+	assert.Equal(t, 1, trimmed)
+
+	entries, _ := xredis.XRange("events", STREAM_MIN_ID, STREAM_MAX_ID, 0, false)
+	assert.Equal(t, []StreamEntry{{StreamID{5, 0}, []string{"id", "5-0"}}}, entries)
+
+	// IDs keep growing from the last one ever added
+	_, _, err = xredis.XAdd("events", "5-0", []string{"a", "1"}, false, nil)
+	assert.NotNil(t, err)
+}
+
+func TestXAddWithTrimLimit(t *testing.T) {
+	xredis := NewXRedis()
+	for _, id := range []string{"1-0", "2-0", "3-0", "4-0"} {
+		xredis.XAdd("events", id, []string{"id", id}, false, &StreamTrim{maxLen: 1, limit: 2})
+	}
+
+	length, _ := xredis.XLen("events")
+	assert.Equal(t, 1, length)
+}
+
+func TestXReadFromLastIDBlocksUntilXAdd(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.XAdd("events", "1-0", []string{"a", "1"}, false, nil)
+
+	results, waiter, err := xredis.XRead([]StreamRead{{"events", StreamID{}, true}}, 0, true)
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+	assert.Equal(t, []StreamRead{{"events", StreamID{1, 0}, false}}, waiter.reads)
+
+	xredis.XAdd("events", "2-0", []string{"a", "2"}, false, nil)
+	<-waiter.ready
+
+	results, waiter, _ = xredis.XRead(waiter.reads, 0, true)
+	assert.Nil(t, waiter)
+	assert.Equal(t, []StreamReadResult{{"events", []StreamEntry{{StreamID{2, 0}, []string{"a", "2"}}}}}, results)
+}
+
+func TestCopiedStreamIsIndependent(t *testing.T) {
+	xredis := NewXRedis()
+	xredis.XAdd("events", "1-0", []string{"a", "1"}, false, nil)
+
+	xredis.Copy("events", "copy", DEFAULT_DATABASE, false)
+	xredis.XAdd("events", "2-0", []string{"a", "2"}, false, nil)
+
+	length, _ := xredis.XLen("copy")
+	assert.Equal(t, 1, length)
+}
+
+func TestSaveAndLoadStream(t *testing.T) {
+	xredis1 := NewXRedis()
+	xredis1.XAdd("events", "1-0", []string{"a", "1"}, false, nil)
+	xredis1.XAdd("events", "2-0", []string{"a", "2"}, false, nil)
+	xredis1.XDel("events", []StreamID{{2, 0}})
+	data := xredis1.Serialize()
+
+	xredis2 := NewXRedis()
+	assert.Nil(t, xredis2.Load(data))
+	entries, _ := xredis2.XRange("events", STREAM_MIN_ID, STREAM_MAX_ID, 0, false)
+	assert.Equal(t, []StreamEntry{{StreamID{1, 0}, []string{"a", "1"}}}, entries)
+	_, _, err := xredis2.XAdd("events", "2-0", []string{"a", "2"}, false, nil)
+	assert.NotNil(t, err)
+}
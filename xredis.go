@@ -5,118 +5,532 @@ import (
 	"encoding/gob"
 	"errors"
 	"log"
+	"maps"
 	"math"
+	"math/rand/v2"
+	"slices"
 	"strconv"
 	"time"
 )
 
 const NON_EXPIRATION_TIME = -1
+const DEFAULT_DATABASE = 0
+const DEFAULT_DATABASES_NUMBER = 16
+
+const ENCODING_INT = "int"
+const ENCODING_EMBSTR = "embstr"
+const ENCODING_RAW = "raw"
+const ENCODING_LISTPACK = "listpack"
+const ENCODING_QUICKLIST = "quicklist"
+
+const TYPE_STRING = "string"
+const TYPE_LIST = "list"
+const TYPE_HASH = "hash"
+const TYPE_SET = "set"
+const TYPE_ZSET = "zset"
+const TYPE_NONE = "none"
+
+// Same thresholds used by Redis to pick the string and list encodings
+const EMBSTR_MAX_LENGTH = 44
+const LISTPACK_MAX_ENTRIES = 128
 
 type XRedisValue struct {
 	Element                   RespDataType
 	ExpirationTimestampMillis int64
 }
 
+// XRedis is a handle to the server state owned by the commands goroutine.
+// Each handle targets its own selected database, so handles are created
+// as shallow copies of each other and the shared fields must therefore
+// never be reassigned after construction.
 type XRedis struct {
-	cache    map[string]XRedisValue
-	commands chan Command
+	databases     []map[string]XRedisValue
+	keyVersions   []map[string]*KeyVersion                // Versions of the watched keys of each database
+	streamWaiters []map[string]map[*StreamWaiter]struct{} // Clients blocked reading the keys of each database
+	keyIndexes    []*scanIndex                            // Built by the first SCAN of each database
+	pubsub        *PubSub
+	config        *Config
+	aof           *AppendOnlyFile
+	snapshots     *Snapshots
+	commands      chan Command
+	db            int
+}
+
+// KeyVersion tracks the modifications of a watched key. The version is
+// bumped by every write to the key, including its expiration, for as long
+// as there is someone watching it.
+type KeyVersion struct {
+	version  uint64
+	watchers int
+}
+
+// WatchedKey is the version of a key when it started being watched
+type WatchedKey struct {
+	db      int
+	key     string
+	version uint64
 }
 
 func NewXRedis() *XRedis {
-	xredis := XRedis{make(map[string]XRedisValue), make(chan Command)}
+	return NewXRedisWithDatabases(DEFAULT_DATABASES_NUMBER)
+}
+
+func NewXRedisWithDatabases(databasesNumber int) *XRedis {
+	databases := make([]map[string]XRedisValue, databasesNumber)
+	keyVersions := make([]map[string]*KeyVersion, databasesNumber)
+	streamWaiters := make([]map[string]map[*StreamWaiter]struct{}, databasesNumber)
+	for i := range databases {
+		databases[i] = make(map[string]XRedisValue)
+		keyVersions[i] = make(map[string]*KeyVersion)
+		streamWaiters[i] = make(map[string]map[*StreamWaiter]struct{})
+	}
+	xredis := XRedis{databases, keyVersions, streamWaiters, make([]*scanIndex, databasesNumber), NewPubSub(), NewConfig(), NewAppendOnlyFile(), NewSnapshots(), make(chan Command), DEFAULT_DATABASE}
 	xredis.registerRequiredTypesForSerialization()
 	go func() {
-		for command := range xredis.commands {
-			switch cmd := command.(type) {
-			case SetCommand:
-				xredis.handleSetCommand(cmd)
-			case GetCommand:
-				xredis.handleGetCommand(cmd)
-			case ExistsCommand:
-				xredis.handleExistsCommand(cmd)
-			case DeleteCommand:
-				xredis.handleDeleteCommand(cmd)
-			case IncrementCommand:
-				xredis.handleIncrementCommand(cmd)
-			case DecrementCommand:
-				xredis.handleDecrementCommand(cmd)
-			case LPushCommand:
-				xredis.handleLPushCommand(cmd)
-			case RPushCommand:
-				xredis.handleRPushCommand(cmd)
-			case SaveCommand:
-				xredis.handleSaveCommand(cmd)
-			case LoadCommand:
-				xredis.handleLoadCommand(cmd)
+		for {
+			command, ok := xredis.nextCommand()
+			if !ok {
+				return
 			}
+			xredis.process(command)
 		}
 	}()
+	go xredis.checkSavePointsEverySecond(xredis.snapshots.stopSavePoints)
 	return &xredis
 }
 
+// nextCommand waits for the next command to process. The captures of the
+// databases in progress copy a few keys before every command, and keep
+// going while none is waiting.
+func (xredis *XRedis) nextCommand() (Command, bool) {
+	for len(xredis.snapshots.captures) > 0 {
+		xredis.stepCaptures(CAPTURE_STEP_KEYS)
+		select {
+		case command, ok := <-xredis.commands:
+			return command, ok
+		default:
+		}
+	}
+	command, ok := <-xredis.commands
+	return command, ok
+}
+
+func (xredis *XRedis) process(command Command) {
+	switch cmd := command.(type) {
+	case SetCommand:
+		xredis.handleSetCommand(cmd)
+	case GetCommand:
+		xredis.handleGetCommand(cmd)
+	case ExistsCommand:
+		xredis.handleExistsCommand(cmd)
+	case DeleteCommand:
+		xredis.handleDeleteCommand(cmd)
+	case IncrementCommand:
+		xredis.handleIncrementCommand(cmd)
+	case DecrementCommand:
+		xredis.handleDecrementCommand(cmd)
+	case LPushCommand:
+		xredis.handleLPushCommand(cmd)
+	case RPushCommand:
+		xredis.handleRPushCommand(cmd)
+	case SaveCommand:
+		xredis.handleSaveCommand(cmd)
+	case LoadCommand:
+		xredis.handleLoadCommand(cmd)
+	case LoadingStartCommand:
+		xredis.handleLoadingStartCommand(cmd)
+	case LoadingProgressCommand:
+		xredis.handleLoadingProgressCommand(cmd)
+	case LoadingDoneCommand:
+		xredis.handleLoadingDoneCommand(cmd)
+	case SetEncryptionKeyCommand:
+		xredis.handleSetEncryptionKeyCommand(cmd)
+	case AllowUnencryptedLoadCommand:
+		xredis.handleAllowUnencryptedLoadCommand(cmd)
+	case FileDecryptionCommand:
+		xredis.handleFileDecryptionCommand(cmd)
+	case EncodingCommand:
+		xredis.handleEncodingCommand(cmd)
+	case KeysCommand:
+		xredis.handleKeysCommand(cmd)
+	case ScanCommand:
+		xredis.handleScanCommand(cmd)
+	case TypeCommand:
+		xredis.handleTypeCommand(cmd)
+	case RandomKeyCommand:
+		xredis.handleRandomKeyCommand(cmd)
+	case DBSizeCommand:
+		xredis.handleDBSizeCommand(cmd)
+	case CollectionScanCommand:
+		xredis.handleCollectionScanCommand(cmd)
+	case RenameCommand:
+		xredis.handleRenameCommand(cmd)
+	case CopyCommand:
+		xredis.handleCopyCommand(cmd)
+	case MoveCommand:
+		xredis.handleMoveCommand(cmd)
+	case FlushCommand:
+		xredis.handleFlushCommand(cmd)
+	case SwapDBCommand:
+		xredis.handleSwapDBCommand(cmd)
+	case TransactionCommand:
+		xredis.handleTransactionCommand(cmd)
+	case WatchCommand:
+		xredis.handleWatchCommand(cmd)
+	case UnwatchCommand:
+		xredis.handleUnwatchCommand(cmd)
+	case WatchedKeysChangedCommand:
+		xredis.handleWatchedKeysChangedCommand(cmd)
+	case SubscribeCommand:
+		xredis.handleSubscribeCommand(cmd)
+	case UnsubscribeCommand:
+		xredis.handleUnsubscribeCommand(cmd)
+	case PublishCommand:
+		xredis.handlePublishCommand(cmd)
+	case PubSubChannelsCommand:
+		xredis.handlePubSubChannelsCommand(cmd)
+	case PubSubNumSubCommand:
+		xredis.handlePubSubNumSubCommand(cmd)
+	case PubSubNumPatCommand:
+		xredis.handlePubSubNumPatCommand(cmd)
+	case ShardSubscribeCommand:
+		xredis.handleShardSubscribeCommand(cmd)
+	case ShardUnsubscribeCommand:
+		xredis.handleShardUnsubscribeCommand(cmd)
+	case ShardPublishCommand:
+		xredis.handleShardPublishCommand(cmd)
+	case PubSubShardChannelsCommand:
+		xredis.handlePubSubShardChannelsCommand(cmd)
+	case PubSubShardNumSubCommand:
+		xredis.handlePubSubShardNumSubCommand(cmd)
+	case XAddCommand:
+		xredis.handleXAddCommand(cmd)
+	case XRangeCommand:
+		xredis.handleXRangeCommand(cmd)
+	case XReadCommand:
+		xredis.handleXReadCommand(cmd)
+	case CancelStreamWaitCommand:
+		xredis.handleCancelStreamWaitCommand(cmd)
+	case XLenCommand:
+		xredis.handleXLenCommand(cmd)
+	case XDelCommand:
+		xredis.handleXDelCommand(cmd)
+	case XTrimCommand:
+		xredis.handleXTrimCommand(cmd)
+	case XGroupCreateCommand:
+		xredis.handleXGroupCreateCommand(cmd)
+	case XGroupSetIDCommand:
+		xredis.handleXGroupSetIDCommand(cmd)
+	case XGroupDestroyCommand:
+		xredis.handleXGroupDestroyCommand(cmd)
+	case XGroupCreateConsumerCommand:
+		xredis.handleXGroupCreateConsumerCommand(cmd)
+	case XGroupDelConsumerCommand:
+		xredis.handleXGroupDelConsumerCommand(cmd)
+	case XReadGroupCommand:
+		xredis.handleXReadGroupCommand(cmd)
+	case XAckCommand:
+		xredis.handleXAckCommand(cmd)
+	case XPendingSummaryCommand:
+		xredis.handleXPendingSummaryCommand(cmd)
+	case XPendingCommand:
+		xredis.handleXPendingCommand(cmd)
+	case XClaimCommand:
+		xredis.handleXClaimCommand(cmd)
+	case XAutoClaimCommand:
+		xredis.handleXAutoClaimCommand(cmd)
+	case XClaimStateCommand:
+		xredis.handleXClaimStateCommand(cmd)
+	case XInfoStreamCommand:
+		xredis.handleXInfoStreamCommand(cmd)
+	case XInfoGroupsCommand:
+		xredis.handleXInfoGroupsCommand(cmd)
+	case XInfoConsumersCommand:
+		xredis.handleXInfoConsumersCommand(cmd)
+	case SetBitCommand:
+		xredis.handleSetBitCommand(cmd)
+	case GetBitCommand:
+		xredis.handleGetBitCommand(cmd)
+	case BitCountCommand:
+		xredis.handleBitCountCommand(cmd)
+	case BitPosCommand:
+		xredis.handleBitPosCommand(cmd)
+	case BitOpCommand:
+		xredis.handleBitOpCommand(cmd)
+	case BitFieldCommand:
+		xredis.handleBitFieldCommand(cmd)
+	case PFAddCommand:
+		xredis.handlePFAddCommand(cmd)
+	case PFCountCommand:
+		xredis.handlePFCountCommand(cmd)
+	case PFMergeCommand:
+		xredis.handlePFMergeCommand(cmd)
+	case GeoAddCommand:
+		xredis.handleGeoAddCommand(cmd)
+	case HSetCommand:
+		xredis.handleHSetCommand(cmd)
+	case HDelCommand:
+		xredis.handleHDelCommand(cmd)
+	case SAddCommand:
+		xredis.handleSAddCommand(cmd)
+	case SRemCommand:
+		xredis.handleSRemCommand(cmd)
+	case GeoPosCommand:
+		xredis.handleGeoPosCommand(cmd)
+	case GeoDistCommand:
+		xredis.handleGeoDistCommand(cmd)
+	case GeoSearchCommand:
+		xredis.handleGeoSearchCommand(cmd)
+	case GeoSearchStoreCommand:
+		xredis.handleGeoSearchStoreCommand(cmd)
+	case ConfigGetCommand:
+		xredis.handleConfigGetCommand(cmd)
+	case ConfigSetCommand:
+		xredis.handleConfigSetCommand(cmd)
+	case SetDirCommand:
+		xredis.handleSetDirCommand(cmd)
+	case OpenAppendOnlyFileCommand:
+		xredis.handleOpenAppendOnlyFileCommand(cmd)
+	case CloseAppendOnlyFileCommand:
+		xredis.handleCloseAppendOnlyFileCommand(cmd)
+	case AppendToLogCommand:
+		xredis.handleAppendToLogCommand(cmd)
+	case AppendOnlyFsyncCommand:
+		xredis.handleAppendOnlyFsyncCommand(cmd)
+	case RewriteAppendOnlyFileCommand:
+		xredis.handleRewriteAppendOnlyFileCommand(cmd)
+	case AppendOnlyRewriteInProgressCommand:
+		xredis.handleAppendOnlyRewriteInProgressCommand(cmd)
+	case AppendOnlyRewriteDoneCommand:
+		xredis.handleAppendOnlyRewriteDoneCommand(cmd)
+	case SaveDumpCommand:
+		xredis.handleSaveDumpCommand(cmd)
+	case BackgroundSaveCommand:
+		xredis.handleBackgroundSaveCommand(cmd)
+	case BackgroundSaveDoneCommand:
+		xredis.handleBackgroundSaveDoneCommand(cmd)
+	case SavePointsCheckCommand:
+		xredis.handleSavePointsCheckCommand(cmd)
+	case ShutdownCommand:
+		xredis.handleShutdownCommand(cmd)
+	case LastSaveCommand:
+		xredis.handleLastSaveCommand(cmd)
+	case PersistenceInfoCommand:
+		xredis.handlePersistenceInfoCommand(cmd)
+	case DumpCommand:
+		xredis.handleDumpCommand(cmd)
+	case RestoreCommand:
+		xredis.handleRestoreCommand(cmd)
+	}
+}
+
 func (xredis *XRedis) registerRequiredTypesForSerialization() {
 	gob.Register(XRedisValue{})
 	gob.Register(RespString{})
+	gob.Register(RespInt{})
 	gob.Register(RespArray{})
+	gob.Register(&Stream{})
+	gob.Register(&HyperLogLog{})
+	gob.Register(&SortedSet{})
+}
+
+// Select returns a new handle whose commands target the database at index
+// db, leaving the current handle untouched.
+func (xredis *XRedis) Select(db int) (*XRedis, error) {
+	if db < 0 || db >= len(xredis.databases) {
+		return nil, errors.New(REQUEST_ERROR_DB_INDEX_OUT_OF_RANGE)
+	}
+	handle := *xredis
+	handle.db = db
+	return &handle, nil
 }
 
 func (xredis *XRedis) Set(key string, value RespDataType) {
 	doneChan := make(chan struct{})
-	xredis.commands <- SetCommand{key, value, NON_EXPIRATION_TIME, doneChan}
+	xredis.commands <- SetCommand{xredis.db, key, value, NON_EXPIRATION_TIME, doneChan}
 	<-doneChan // Wait for completion
 }
 
 func (xredis *XRedis) SetWithExpiration(key string, value RespDataType, expirationTime time.Time) {
 	expirationTimeTimestamp := expirationTime.UnixMilli()
 	doneChan := make(chan struct{})
-	xredis.commands <- SetCommand{key, value, expirationTimeTimestamp, doneChan}
+	xredis.commands <- SetCommand{xredis.db, key, value, expirationTimeTimestamp, doneChan}
 	<-doneChan // Wait for completion
 }
 
 func (xredis *XRedis) Get(key string) RespDataType {
 	rspChan := make(chan RespDataType)
-	xredis.commands <- GetCommand{key, rspChan}
+	xredis.commands <- GetCommand{xredis.db, key, rspChan}
 	return <-rspChan
 }
 
 func (xredis *XRedis) Exists(key string) bool {
 	rspChan := make(chan bool)
-	xredis.commands <- ExistsCommand{key, rspChan}
+	xredis.commands <- ExistsCommand{xredis.db, key, rspChan}
 	return <-rspChan
 }
 
 func (xredis *XRedis) Delete(key string) bool {
 	rspChan := make(chan bool)
-	xredis.commands <- DeleteCommand{key, rspChan}
+	xredis.commands <- DeleteCommand{xredis.db, key, rspChan}
 	return <-rspChan
 }
 
 func (xredis *XRedis) Increment(key string) (RespString, error) {
 	rspChan := make(chan RespString)
 	errorChan := make(chan error)
-	xredis.commands <- IncrementCommand{key, rspChan, errorChan}
+	xredis.commands <- IncrementCommand{xredis.db, key, rspChan, errorChan}
 	return <-rspChan, <-errorChan
 }
 
 func (xredis *XRedis) Decrement(key string) (RespString, error) {
 	rspChan := make(chan RespString)
 	errorChan := make(chan error)
-	xredis.commands <- DecrementCommand{key, rspChan, errorChan}
+	xredis.commands <- DecrementCommand{xredis.db, key, rspChan, errorChan}
 	return <-rspChan, <-errorChan
 }
 
 func (xredis *XRedis) LPush(key string, value RespDataType) error {
 	errorChan := make(chan error)
-	xredis.commands <- LPushCommand{key, value, errorChan}
+	xredis.commands <- LPushCommand{xredis.db, key, value, errorChan}
 	return <-errorChan
 }
 
 func (xredis *XRedis) RPush(key string, value RespDataType) error {
 	errorChan := make(chan error)
-	xredis.commands <- RPushCommand{key, value, errorChan}
+	xredis.commands <- RPushCommand{xredis.db, key, value, errorChan}
+	return <-errorChan
+}
+
+func (xredis *XRedis) Encoding(key string) (string, bool) {
+	rspChan := make(chan string)
+	existsChan := make(chan bool)
+	xredis.commands <- EncodingCommand{xredis.db, key, rspChan, existsChan}
+	return <-rspChan, <-existsChan
+}
+
+func (xredis *XRedis) Keys(pattern string) []string {
+	rspChan := make(chan []string)
+	xredis.commands <- KeysCommand{xredis.db, pattern, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) Scan(cursor uint64, pattern string, count int, typeName string) ([]string, uint64) {
+	rspChan := make(chan []string)
+	cursorChan := make(chan uint64)
+	xredis.commands <- ScanCommand{xredis.db, cursor, pattern, count, typeName, rspChan, cursorChan}
+	return <-rspChan, <-cursorChan
+}
+
+func (xredis *XRedis) Type(key string) string {
+	rspChan := make(chan string)
+	xredis.commands <- TypeCommand{xredis.db, key, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) RandomKey() (string, bool) {
+	rspChan := make(chan string)
+	existsChan := make(chan bool)
+	xredis.commands <- RandomKeyCommand{xredis.db, rspChan, existsChan}
+	return <-rspChan, <-existsChan
+}
+
+func (xredis *XRedis) DBSize() int {
+	rspChan := make(chan int)
+	xredis.commands <- DBSizeCommand{xredis.db, rspChan}
+	return <-rspChan
+}
+
+// ScanCollection incrementally iterates the collection stored at key,
+// which must be of type typeName. The reply holds the flattened entries
+// of each visited member, e.g. field and value pairs for hashes.
+func (xredis *XRedis) ScanCollection(key string, typeName string, cursor uint64, pattern string, count int) ([]RespDataType, uint64, error) {
+	rspChan := make(chan []RespDataType)
+	cursorChan := make(chan uint64)
+	errorChan := make(chan error)
+	xredis.commands <- CollectionScanCommand{xredis.db, key, typeName, cursor, pattern, count, rspChan, cursorChan, errorChan}
+	return <-rspChan, <-cursorChan, <-errorChan
+}
+
+// Rename moves the value and expiration time of srcKey to dstKey. When
+// onlyIfMissing is set nothing is done if dstKey already exists, and the
+// returned bool tells whether the rename happened.
+func (xredis *XRedis) Rename(srcKey string, dstKey string, onlyIfMissing bool) (bool, error) {
+	rspChan := make(chan bool)
+	errorChan := make(chan error)
+	xredis.commands <- RenameCommand{xredis.db, srcKey, dstKey, onlyIfMissing, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) Copy(srcKey string, dstKey string, dstDB int, replace bool) (bool, error) {
+	rspChan := make(chan bool)
+	errorChan := make(chan error)
+	xredis.commands <- CopyCommand{xredis.db, srcKey, dstKey, dstDB, replace, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+func (xredis *XRedis) Move(key string, dstDB int) (bool, error) {
+	rspChan := make(chan bool)
+	errorChan := make(chan error)
+	xredis.commands <- MoveCommand{xredis.db, key, dstDB, rspChan, errorChan}
+	return <-rspChan, <-errorChan
+}
+
+// FlushDB removes every key of the selected database. With async the
+// old key space is just dropped and left for the garbage collector
+// instead of being emptied on the commands goroutine.
+func (xredis *XRedis) FlushDB(async bool) {
+	doneChan := make(chan struct{})
+	xredis.commands <- FlushCommand{xredis.db, false, async, doneChan}
+	<-doneChan // Wait for completion
+}
+
+func (xredis *XRedis) FlushAll(async bool) {
+	doneChan := make(chan struct{})
+	xredis.commands <- FlushCommand{xredis.db, true, async, doneChan}
+	<-doneChan // Wait for completion
+}
+
+func (xredis *XRedis) SwapDB(db1 int, db2 int) error {
+	errorChan := make(chan error)
+	xredis.commands <- SwapDBCommand{db1, db2, errorChan}
 	return <-errorChan
 }
 
+// Atomically runs fn with a handle whose commands are processed as a
+// single unit: no other command is processed until fn returns.
+func (xredis *XRedis) Atomically(fn func(tx *XRedis)) {
+	txCommands := make(chan Command)
+	xredis.commands <- TransactionCommand{txCommands}
+	defer close(txCommands)
+
+	tx := *xredis
+	tx.commands = txCommands
+	fn(&tx)
+}
+
+// Watch starts watching the keys of the selected database, returning the
+// versions to be later checked by WatchedKeysChanged.
+func (xredis *XRedis) Watch(keys []string) []WatchedKey {
+	rspChan := make(chan []WatchedKey)
+	xredis.commands <- WatchCommand{xredis.db, keys, rspChan}
+	return <-rspChan
+}
+
+func (xredis *XRedis) Unwatch(watchedKeys []WatchedKey) {
+	doneChan := make(chan struct{})
+	xredis.commands <- UnwatchCommand{watchedKeys, doneChan}
+	<-doneChan // Wait for completion
+}
+
+// WatchedKeysChanged tells whether any of the keys was modified since it
+// started being watched.
+func (xredis *XRedis) WatchedKeysChanged(watchedKeys []WatchedKey) bool {
+	rspChan := make(chan bool)
+	xredis.commands <- WatchedKeysChangedCommand{watchedKeys, rspChan}
+	return <-rspChan
+}
+
 func (xredis *XRedis) Serialize() []byte {
 	rspChan := make(chan []byte)
 	xredis.commands <- SaveCommand{rspChan}
@@ -129,40 +543,57 @@ func (xredis *XRedis) Load(data []byte) error {
 	return <-errorChan
 }
 
+func (xredis *XRedis) handleTransactionCommand(cmd TransactionCommand) {
+	// Only the commands of the transaction are processed until it is
+	// closed, so that they are executed as a single unit.
+	for command := range cmd.commands {
+		xredis.process(command)
+	}
+}
+
 func (xredis *XRedis) handleSetCommand(cmd SetCommand) {
-	xredis.cache[cmd.key] = XRedisValue{cmd.value, cmd.expirationTimestamp}
+	xredis.setValue(cmd.db, cmd.key, XRedisValue{encodeStringValue(cmd.value), cmd.expirationTimestamp})
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STRING, KEYSPACE_EVENT_SET, cmd.db, cmd.key)
+	if cmd.expirationTimestamp != NON_EXPIRATION_TIME {
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_EXPIRE, cmd.db, cmd.key)
+	}
 	close(cmd.done)
 }
 
 func (xredis *XRedis) handleGetCommand(cmd GetCommand) {
 	var rsp RespDataType = RespNil{}
-	value, exists := xredis.getAndInvalidateIfExpired(cmd.key)
+	value, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
 	if exists {
-		rsp = value.Element
+		rsp = decodeStringValue(value.Element)
 	}
 	cmd.rspChannel <- rsp
 	close(cmd.rspChannel)
 }
 
 func (xredis *XRedis) handleExistsCommand(cmd ExistsCommand) {
-	_, exists := xredis.getAndInvalidateIfExpired(cmd.key)
+	_, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
 	cmd.rspChannel <- exists
 	close(cmd.rspChannel)
 }
 
 func (xredis *XRedis) handleDeleteCommand(cmd DeleteCommand) {
-	_, existed := xredis.getAndInvalidateIfExpired(cmd.key)
-	delete(xredis.cache, cmd.key)
+	_, existed := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
+	xredis.deleteValue(cmd.db, cmd.key)
+	if existed {
+		xredis.touchKey(cmd.db, cmd.key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_DEL, cmd.db, cmd.key)
+	}
 	cmd.rspChannel <- existed
 	close(cmd.rspChannel)
 }
 
 func (xredis *XRedis) handleIncrementCommand(cmd IncrementCommand) {
-	_, exists := xredis.getAndInvalidateIfExpired(cmd.key)
+	_, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
 	if !exists {
-		xredis.cache[cmd.key] = XRedisValue{RespString{"0"}, NON_EXPIRATION_TIME}
+		xredis.setValue(cmd.db, cmd.key, XRedisValue{RespInt{0}, NON_EXPIRATION_TIME})
 	}
-	respInt, ok := xredis.tryGetAsRespInt(cmd.key)
+	respInt, ok := xredis.tryGetAsRespInt(cmd.db, cmd.key)
 	if !ok || respInt.Value == math.MaxInt64 {
 		cmd.rspChannel <- RespString{}
 		cmd.errorChannel <- errors.New(REQUEST_ERROR_VALUE_NOT_NUMERIC_OR_MAX_REACHED)
@@ -171,20 +602,22 @@ func (xredis *XRedis) handleIncrementCommand(cmd IncrementCommand) {
 		return
 	}
 
-	newValue := RespString{strconv.FormatInt(respInt.Value+1, 10)}
-	xredis.cache[cmd.key] = XRedisValue{newValue, xredis.cache[cmd.key].ExpirationTimestampMillis}
-	cmd.rspChannel <- newValue
+	newValue := RespInt{respInt.Value + 1}
+	xredis.setValue(cmd.db, cmd.key, XRedisValue{newValue, xredis.databases[cmd.db][cmd.key].ExpirationTimestampMillis})
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STRING, KEYSPACE_EVENT_INCRBY, cmd.db, cmd.key)
+	cmd.rspChannel <- decodeStringValue(newValue).(RespString)
 	cmd.errorChannel <- nil
 	close(cmd.rspChannel)
 	close(cmd.errorChannel)
 }
 
 func (xredis *XRedis) handleDecrementCommand(cmd DecrementCommand) {
-	_, exists := xredis.getAndInvalidateIfExpired(cmd.key)
+	_, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
 	if !exists {
-		xredis.cache[cmd.key] = XRedisValue{RespString{"0"}, NON_EXPIRATION_TIME}
+		xredis.setValue(cmd.db, cmd.key, XRedisValue{RespInt{0}, NON_EXPIRATION_TIME})
 	}
-	respInt, ok := xredis.tryGetAsRespInt(cmd.key)
+	respInt, ok := xredis.tryGetAsRespInt(cmd.db, cmd.key)
 	if !ok || respInt.Value == math.MinInt64 {
 		cmd.rspChannel <- RespString{}
 		cmd.errorChannel <- errors.New(REQUEST_ERROR_VALUE_NOT_NUMERIC_OR_MAX_REACHED)
@@ -193,91 +626,529 @@ func (xredis *XRedis) handleDecrementCommand(cmd DecrementCommand) {
 		return
 	}
 
-	newValue := RespString{strconv.FormatInt(respInt.Value-1, 10)}
-	xredis.cache[cmd.key] = XRedisValue{newValue, xredis.cache[cmd.key].ExpirationTimestampMillis}
-	cmd.rspChannel <- newValue
+	newValue := RespInt{respInt.Value - 1}
+	xredis.setValue(cmd.db, cmd.key, XRedisValue{newValue, xredis.databases[cmd.db][cmd.key].ExpirationTimestampMillis})
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_STRING, KEYSPACE_EVENT_DECRBY, cmd.db, cmd.key)
+	cmd.rspChannel <- decodeStringValue(newValue).(RespString)
 	cmd.errorChannel <- nil
 	close(cmd.rspChannel)
 	close(cmd.errorChannel)
 }
 
 func (xredis *XRedis) handleLPushCommand(cmd LPushCommand) {
-	_, exists := xredis.getAndInvalidateIfExpired(cmd.key)
+	_, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
 	if !exists {
-		xredis.cache[cmd.key] = XRedisValue{RespArray{make([]RespDataType, 0)}, NON_EXPIRATION_TIME}
+		xredis.setValue(cmd.db, cmd.key, XRedisValue{RespArray{make([]RespDataType, 0)}, NON_EXPIRATION_TIME})
 	}
-	respArray, ok := xredis.cache[cmd.key].Element.(RespArray)
+	respArray, ok := xredis.databases[cmd.db][cmd.key].Element.(RespArray)
 	if !ok {
 		cmd.errorChannel <- errors.New(REQUEST_ERROR_VALUE_NOT_A_LIST)
 		close(cmd.errorChannel)
 		return
 	}
 
-	xredis.cache[cmd.key] = XRedisValue{RespArray{append([]RespDataType{cmd.value}, respArray.Elements...)}, xredis.cache[cmd.key].ExpirationTimestampMillis}
+	xredis.setValue(cmd.db, cmd.key, XRedisValue{RespArray{append([]RespDataType{cmd.value}, respArray.Elements...)}, xredis.databases[cmd.db][cmd.key].ExpirationTimestampMillis})
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_LIST, KEYSPACE_EVENT_LPUSH, cmd.db, cmd.key)
 	cmd.errorChannel <- nil
 	close(cmd.errorChannel)
 }
 
 func (xredis *XRedis) handleRPushCommand(cmd RPushCommand) {
-	_, exists := xredis.getAndInvalidateIfExpired(cmd.key)
+	_, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
 	if !exists {
-		xredis.cache[cmd.key] = XRedisValue{RespArray{make([]RespDataType, 0)}, NON_EXPIRATION_TIME}
+		xredis.setValue(cmd.db, cmd.key, XRedisValue{RespArray{make([]RespDataType, 0)}, NON_EXPIRATION_TIME})
 	}
-	respArray, ok := xredis.cache[cmd.key].Element.(RespArray)
+	respArray, ok := xredis.databases[cmd.db][cmd.key].Element.(RespArray)
 	if !ok {
 		cmd.errorChannel <- errors.New(REQUEST_ERROR_VALUE_NOT_A_LIST)
 		close(cmd.errorChannel)
 		return
 	}
 
-	xredis.cache[cmd.key] = XRedisValue{RespArray{append(respArray.Elements, cmd.value)}, xredis.cache[cmd.key].ExpirationTimestampMillis}
+	xredis.setValue(cmd.db, cmd.key, XRedisValue{RespArray{append(respArray.Elements, cmd.value)}, xredis.databases[cmd.db][cmd.key].ExpirationTimestampMillis})
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_LIST, KEYSPACE_EVENT_RPUSH, cmd.db, cmd.key)
 	cmd.errorChannel <- nil
 	close(cmd.errorChannel)
 }
 
 func (xredis *XRedis) handleSaveCommand(cmd SaveCommand) {
 	defer close(cmd.rspChannel)
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	err := enc.Encode(xredis.cache)
+	data, err := encodeSnapshot(xredis.databases)
 	if err != nil {
 		log.Println("Failed to serializing cache data: ", err)
 		cmd.rspChannel <- nil
 		return
 	}
 
-	cmd.rspChannel <- buf.Bytes()
+	cmd.rspChannel <- data
 }
 
 func (xredis *XRedis) handleLoadCommand(cmd LoadCommand) {
 	defer close(cmd.errorChannel)
 
 	if cmd.data != nil {
-		buffer := bytes.NewBuffer(cmd.data)
-		dec := gob.NewDecoder(buffer)
-		if err := dec.Decode(&xredis.cache); err != nil {
-			log.Fatalf("failed to deserialize DB dump file: %v", err)
-			cmd.errorChannel <- errors.New(REQUEST_RESULT_FAIL)
+		data, err := unsealDump(cmd.data, xredis.config.fileDecryption())
+		var databases []map[string]XRedisValue
+		if err == nil {
+			databases, err = decodeSnapshot(data)
+		}
+		if err != nil {
+			cmd.errorChannel <- err
+			return
+		}
+		xredis.loadDatabases(databases)
+	}
+
+	cmd.errorChannel <- nil
+}
+
+// loadDatabases replaces the databases by the decoded ones, dropping those
+// that aren't configured.
+func (xredis *XRedis) loadDatabases(databases []map[string]XRedisValue) {
+	xredis.completeCaptures()
+	for db, database := range databases {
+		if !xredis.isValidDatabase(db) {
+			if len(database) > 0 {
+				log.Printf("Dropping %d keys of database %d, which isn't configured", len(database), db)
+			}
+			continue
+		}
+		if database == nil {
+			database = make(map[string]XRedisValue)
+		}
+		for key, value := range database {
+			database[key] = XRedisValue{encodeStringValue(value.Element), value.ExpirationTimestampMillis}
+		}
+		xredis.touchExistingWatchedKeys(db)
+		xredis.databases[db] = database
+		xredis.keyIndexes[db] = nil
+		xredis.touchExistingWatchedKeys(db)
+		xredis.signalAllStreamWaiters(db)
+	}
+}
+
+func (xredis *XRedis) handleEncodingCommand(cmd EncodingCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.existsChannel)
+
+	value, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
+	if !exists {
+		cmd.rspChannel <- ""
+		cmd.existsChannel <- false
+		return
+	}
+
+	var encoding string
+	switch element := value.Element.(type) {
+	case RespInt:
+		encoding = ENCODING_INT
+	case RespString:
+		encoding = ENCODING_RAW
+		if len(element.Str) <= EMBSTR_MAX_LENGTH {
+			encoding = ENCODING_EMBSTR
+		}
+	case RespArray:
+		encoding = ENCODING_QUICKLIST
+		if len(element.Elements) <= LISTPACK_MAX_ENTRIES {
+			encoding = ENCODING_LISTPACK
+		}
+	case *Stream:
+		encoding = ENCODING_STREAM
+	case *HyperLogLog:
+		encoding = element.encoding()
+	case *SortedSet:
+		encoding = element.encoding()
+	case *Hash:
+		encoding = element.encoding()
+	case *Set:
+		encoding = element.encoding()
+	}
+	cmd.rspChannel <- encoding
+	cmd.existsChannel <- true
+}
+
+func (xredis *XRedis) handleKeysCommand(cmd KeysCommand) {
+	defer close(cmd.rspChannel)
+
+	keys := make([]string, 0)
+	for _, key := range xredis.liveKeys(cmd.db) {
+		if globMatch(cmd.pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	cmd.rspChannel <- keys
+}
+
+func (xredis *XRedis) handleScanCommand(cmd ScanCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.cursorChannel)
+
+	if xredis.keyIndexes[cmd.db] == nil {
+		xredis.keyIndexes[cmd.db] = newScanIndex(slices.Collect(maps.Keys(xredis.databases[cmd.db])))
+	}
+	batch, nextCursor := xredis.keyIndexes[cmd.db].scan(cmd.cursor, cmd.count)
+
+	// Filters are applied after picking the batch, as in Redis, so that
+	// COUNT bounds the number of keys examined, and so the reply size,
+	// rather than the number of keys matching.
+	matches := make([]string, 0, len(batch))
+	for _, key := range batch {
+		value, exists := xredis.getAndInvalidateIfExpired(cmd.db, key)
+		if !exists {
+			continue
+		}
+		if cmd.pattern != "" && !globMatch(cmd.pattern, key) {
+			continue
+		}
+		if cmd.typeName != "" && valueTypeName(value.Element) != cmd.typeName {
+			continue
+		}
+		matches = append(matches, key)
+	}
+	cmd.rspChannel <- matches
+	cmd.cursorChannel <- nextCursor
+}
+
+func (xredis *XRedis) handleTypeCommand(cmd TypeCommand) {
+	defer close(cmd.rspChannel)
+
+	value, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
+	if !exists {
+		cmd.rspChannel <- TYPE_NONE
+		return
+	}
+	cmd.rspChannel <- valueTypeName(value.Element)
+}
+
+func (xredis *XRedis) handleRandomKeyCommand(cmd RandomKeyCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.existsChannel)
+
+	keys := xredis.liveKeys(cmd.db)
+	if len(keys) == 0 {
+		cmd.rspChannel <- ""
+		cmd.existsChannel <- false
+		return
+	}
+	cmd.rspChannel <- keys[rand.IntN(len(keys))]
+	cmd.existsChannel <- true
+}
+
+func (xredis *XRedis) handleDBSizeCommand(cmd DBSizeCommand) {
+	defer close(cmd.rspChannel)
+	cmd.rspChannel <- len(xredis.liveKeys(cmd.db))
+}
+
+func (xredis *XRedis) handleCollectionScanCommand(cmd CollectionScanCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.cursorChannel)
+	defer close(cmd.errorChannel)
+
+	value, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
+	if !exists {
+		cmd.rspChannel <- []RespDataType{}
+		cmd.cursorChannel <- 0
+		cmd.errorChannel <- nil
+		return
+	}
+	collection, ok := value.Element.(ScannableCollection)
+	if !ok || valueTypeName(value.Element) != cmd.typeName {
+		cmd.rspChannel <- nil
+		cmd.cursorChannel <- 0
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_WRONG_TYPE)
+		return
+	}
+
+	batch, nextCursor := collection.scan(cmd.cursor, cmd.count)
+	entries := make([]RespDataType, 0, len(batch))
+	for _, member := range batch {
+		if cmd.pattern != "" && !globMatch(cmd.pattern, member) {
+			continue
+		}
+		entries = append(entries, collection.scanEntry(member)...)
+	}
+	cmd.rspChannel <- entries
+	cmd.cursorChannel <- nextCursor
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleRenameCommand(cmd RenameCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	value, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.srcKey)
+	if !exists {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_NO_SUCH_KEY)
+		return
+	}
+	_, dstExists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.dstKey)
+	if cmd.onlyIfMissing && dstExists {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- nil
+		return
+	}
+
+	xredis.deleteValue(cmd.db, cmd.srcKey)
+	xredis.setValue(cmd.db, cmd.dstKey, value)
+	xredis.touchKey(cmd.db, cmd.srcKey)
+	xredis.touchKey(cmd.db, cmd.dstKey)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_RENAME_FROM, cmd.db, cmd.srcKey)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_RENAME_TO, cmd.db, cmd.dstKey)
+	cmd.rspChannel <- true
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleCopyCommand(cmd CopyCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	if !xredis.isValidDatabase(cmd.dstDB) {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_DB_INDEX_OUT_OF_RANGE)
+		return
+	}
+	if cmd.srcKey == cmd.dstKey && cmd.db == cmd.dstDB {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_SAME_SOURCE_AND_DESTINATION)
+		return
+	}
+
+	value, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.srcKey)
+	if !exists {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- nil
+		return
+	}
+	if _, dstExists := xredis.getAndInvalidateIfExpired(cmd.dstDB, cmd.dstKey); dstExists && !cmd.replace {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- nil
+		return
+	}
+
+	xredis.setValue(cmd.dstDB, cmd.dstKey, XRedisValue{cloneValue(value.Element), value.ExpirationTimestampMillis})
+	xredis.touchKey(cmd.dstDB, cmd.dstKey)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_COPY_TO, cmd.dstDB, cmd.dstKey)
+	cmd.rspChannel <- true
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleMoveCommand(cmd MoveCommand) {
+	defer close(cmd.rspChannel)
+	defer close(cmd.errorChannel)
+
+	if !xredis.isValidDatabase(cmd.dstDB) {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_DB_INDEX_OUT_OF_RANGE)
+		return
+	}
+	if cmd.db == cmd.dstDB {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_SAME_SOURCE_AND_DESTINATION)
+		return
+	}
+
+	value, exists := xredis.getAndInvalidateIfExpired(cmd.db, cmd.key)
+	if !exists {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- nil
+		return
+	}
+	if _, dstExists := xredis.getAndInvalidateIfExpired(cmd.dstDB, cmd.key); dstExists {
+		cmd.rspChannel <- false
+		cmd.errorChannel <- nil
+		return
+	}
+
+	xredis.deleteValue(cmd.db, cmd.key)
+	xredis.setValue(cmd.dstDB, cmd.key, value)
+	xredis.touchKey(cmd.db, cmd.key)
+	xredis.touchKey(cmd.dstDB, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_MOVE_FROM, cmd.db, cmd.key)
+	xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_GENERIC, KEYSPACE_EVENT_MOVE_TO, cmd.dstDB, cmd.key)
+	cmd.rspChannel <- true
+	cmd.errorChannel <- nil
+}
+
+func (xredis *XRedis) handleFlushCommand(cmd FlushCommand) {
+	defer close(cmd.done)
+
+	flushed := []int{cmd.db}
+	if cmd.allDatabases {
+		flushed = make([]int, len(xredis.databases))
+		for i := range flushed {
+			flushed[i] = i
 		}
 	}
+	xredis.completeCaptures()
+	for _, db := range flushed {
+		xredis.touchExistingWatchedKeys(db)
+		xredis.snapshots.dirty += int64(len(xredis.databases[db]))
+		if cmd.async {
+			xredis.databases[db] = make(map[string]XRedisValue)
+		} else {
+			clear(xredis.databases[db])
+		}
+		xredis.keyIndexes[db] = nil
+	}
+}
+
+func (xredis *XRedis) handleSwapDBCommand(cmd SwapDBCommand) {
+	defer close(cmd.errorChannel)
 
+	if !xredis.isValidDatabase(cmd.db1) || !xredis.isValidDatabase(cmd.db2) {
+		cmd.errorChannel <- errors.New(REQUEST_ERROR_DB_INDEX_OUT_OF_RANGE)
+		return
+	}
+	xredis.completeCaptures()
+	xredis.touchExistingWatchedKeys(cmd.db1)
+	xredis.touchExistingWatchedKeys(cmd.db2)
+	xredis.databases[cmd.db1], xredis.databases[cmd.db2] = xredis.databases[cmd.db2], xredis.databases[cmd.db1]
+	xredis.keyIndexes[cmd.db1], xredis.keyIndexes[cmd.db2] = xredis.keyIndexes[cmd.db2], xredis.keyIndexes[cmd.db1]
+	xredis.snapshots.dirty++
+	xredis.touchExistingWatchedKeys(cmd.db1)
+	xredis.touchExistingWatchedKeys(cmd.db2)
+	xredis.signalAllStreamWaiters(cmd.db1)
+	xredis.signalAllStreamWaiters(cmd.db2)
 	cmd.errorChannel <- nil
 }
 
-func (xredis *XRedis) getAndInvalidateIfExpired(key string) (XRedisValue, bool) {
-	value, exists := xredis.cache[key]
+func (xredis *XRedis) handleWatchCommand(cmd WatchCommand) {
+	defer close(cmd.rspChannel)
+
+	watchedKeys := make([]WatchedKey, 0, len(cmd.keys))
+	for _, key := range cmd.keys {
+		// Expire the key first so that its expiration isn't seen as a
+		// modification made after it started being watched
+		xredis.getAndInvalidateIfExpired(cmd.db, key)
+		keyVersion, ok := xredis.keyVersions[cmd.db][key]
+		if !ok {
+			keyVersion = &KeyVersion{}
+			xredis.keyVersions[cmd.db][key] = keyVersion
+		}
+		keyVersion.watchers++
+		watchedKeys = append(watchedKeys, WatchedKey{cmd.db, key, keyVersion.version})
+	}
+	cmd.rspChannel <- watchedKeys
+}
+
+func (xredis *XRedis) handleUnwatchCommand(cmd UnwatchCommand) {
+	defer close(cmd.done)
+
+	for _, watchedKey := range cmd.watchedKeys {
+		keyVersion, ok := xredis.keyVersions[watchedKey.db][watchedKey.key]
+		if !ok {
+			continue
+		}
+		keyVersion.watchers--
+		if keyVersion.watchers == 0 {
+			delete(xredis.keyVersions[watchedKey.db], watchedKey.key)
+		}
+	}
+}
+
+func (xredis *XRedis) handleWatchedKeysChangedCommand(cmd WatchedKeysChangedCommand) {
+	defer close(cmd.rspChannel)
+
+	for _, watchedKey := range cmd.watchedKeys {
+		xredis.getAndInvalidateIfExpired(watchedKey.db, watchedKey.key)
+		keyVersion, ok := xredis.keyVersions[watchedKey.db][watchedKey.key]
+		if !ok || keyVersion.version != watchedKey.version {
+			cmd.rspChannel <- true
+			return
+		}
+	}
+	cmd.rspChannel <- false
+}
+
+// touchKey signals that the key was modified
+func (xredis *XRedis) touchKey(db int, key string) {
+	xredis.snapshots.dirty++
+	if keyVersion, ok := xredis.keyVersions[db][key]; ok {
+		keyVersion.version++
+	}
+	xredis.signalStreamWaiters(db, key)
+}
+
+// touchExistingWatchedKeys signals that every watched key of the database
+// that currently exists was modified.
+func (xredis *XRedis) touchExistingWatchedKeys(db int) {
+	for key, keyVersion := range xredis.keyVersions[db] {
+		if _, exists := xredis.databases[db][key]; exists {
+			keyVersion.version++
+		}
+	}
+}
+
+// liveKeys returns every key of the database that hasn't expired yet,
+// removing the expired ones found along the way.
+func (xredis *XRedis) liveKeys(db int) []string {
+	keys := make([]string, 0, len(xredis.databases[db]))
+	for key := range xredis.databases[db] {
+		if _, exists := xredis.getAndInvalidateIfExpired(db, key); exists {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (xredis *XRedis) isValidDatabase(db int) bool {
+	return db >= 0 && db < len(xredis.databases)
+}
+
+// getAndInvalidateIfExpired returns the value stored at key unless it has
+// expired, in which case it is deleted. The value may be modified in place
+// by the caller, so the captures in progress copy it first. As in Redis,
+// nothing expires while loading: the requests replayed from the append
+// only file must find the keys they found when they were applied, the
+// expirations that happened then being logged as DEL.
+func (xredis *XRedis) getAndInvalidateIfExpired(db int, key string) (XRedisValue, bool) {
+	xredis.captureKey(db, key)
+	value, exists := xredis.databases[db][key]
 	if !exists {
 		return XRedisValue{}, false
 	}
-	if value.ExpirationTimestampMillis != NON_EXPIRATION_TIME && time.Now().UnixMilli() > value.ExpirationTimestampMillis {
-		delete(xredis.cache, key)
+	if value.ExpirationTimestampMillis != NON_EXPIRATION_TIME && time.Now().UnixMilli() > value.ExpirationTimestampMillis && !xredis.snapshots.loading.Load() {
+		delete(xredis.databases[db], key)
+		xredis.unindexKey(db, key)
+		xredis.logExpiredKey(db, key)
+		xredis.touchKey(db, key)
+		xredis.notifyKeyspaceEvent(KEYSPACE_EVENTS_EXPIRED, KEYSPACE_EVENT_EXPIRED, db, key)
 		return XRedisValue{}, false
 	}
 	return value, true
 }
 
-func (xredis *XRedis) tryGetAsRespInt(key string) (RespInt, bool) {
-	value, exists := xredis.cache[key]
+// setValue stores the value at key, once the captures in progress copied
+// the previous one.
+func (xredis *XRedis) setValue(db int, key string, value XRedisValue) {
+	xredis.captureKey(db, key)
+	if _, exists := xredis.databases[db][key]; !exists && xredis.keyIndexes[db] != nil {
+		xredis.keyIndexes[db].add(key)
+	}
+	xredis.databases[db][key] = value
+}
+
+// deleteValue deletes the key, once the captures in progress copied it.
+func (xredis *XRedis) deleteValue(db int, key string) {
+	xredis.captureKey(db, key)
+	delete(xredis.databases[db], key)
+	xredis.unindexKey(db, key)
+}
+
+// unindexKey removes the deleted key from the scan index of its database
+func (xredis *XRedis) unindexKey(db int, key string) {
+	if xredis.keyIndexes[db] != nil {
+		xredis.keyIndexes[db].remove(key)
+	}
+}
+
+func (xredis *XRedis) tryGetAsRespInt(db int, key string) (RespInt, bool) {
+	value, exists := xredis.databases[db][key]
 	if !exists {
 		return RespInt{}, false
 	}
@@ -298,10 +1169,109 @@ func (xredis *XRedis) tryGetAsRespInt(key string) (RespInt, bool) {
 	return RespInt{}, false
 }
 
+// decodeDatabases decodes the databases of a gob encoded dump, as written
+// before the snapshot format existed, falling back to the single key space
+// format written before multiple databases existed.
+func decodeDatabases(data []byte) ([]map[string]XRedisValue, error) {
+	var databases []map[string]XRedisValue
+	err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(&databases)
+	if err == nil {
+		return databases, nil
+	}
+
+	var legacyDatabase map[string]XRedisValue
+	if legacyErr := gob.NewDecoder(bytes.NewBuffer(data)).Decode(&legacyDatabase); legacyErr != nil {
+		return nil, err
+	}
+	return []map[string]XRedisValue{legacyDatabase}, nil
+}
+
+// encodeStringValue stores strings that hold a canonical 64 bit integer
+// as a RespInt so that counters don't need to be parsed on every access.
+// Strings such as "007" or "+1" are kept as-is to preserve their content.
+func encodeStringValue(value RespDataType) RespDataType {
+	respStr, ok := value.(RespString)
+	if !ok {
+		return value
+	}
+	intValue, err := strconv.ParseInt(respStr.Str, 10, 64)
+	if err != nil || strconv.FormatInt(intValue, 10) != respStr.Str {
+		return value
+	}
+	return RespInt{intValue}
+}
+
+// decodeStringValue reverts encodeStringValue so that integer encoded
+// values are handed back to clients as the string they were set with.
+func decodeStringValue(value RespDataType) RespDataType {
+	respInt, ok := value.(RespInt)
+	if !ok {
+		return value
+	}
+	return RespString{strconv.FormatInt(respInt.Value, 10)}
+}
+
+func valueTypeName(element RespDataType) string {
+	switch element := element.(type) {
+	case RespString, RespInt:
+		return TYPE_STRING
+	case RespArray:
+		return TYPE_LIST
+	case *Stream:
+		return TYPE_STREAM
+	case *HyperLogLog:
+		// As in Redis, where HyperLogLogs are stored as strings
+		return TYPE_STRING
+	case ScannableCollection:
+		return element.collectionType()
+	default:
+		return TYPE_NONE
+	}
+}
+
+// ScannableCollection is implemented by the collection values that can be
+// walked incrementally by HSCAN, SSCAN and ZSCAN.
+type ScannableCollection interface {
+	// collectionType returns the type name reported by TYPE.
+	collectionType() string
+	// scan returns the members (or hash fields) of the count distinct scan
+	// hashes starting at cursor, and the cursor to resume from.
+	scan(cursor uint64, count int) ([]string, uint64)
+	// scanEntry returns the reply entries of a member: the member itself
+	// followed by its value or score when the collection has one.
+	scanEntry(member string) []RespDataType
+}
+
+// cloneValue deep copies the element so that the copy can be modified
+// without affecting the original value.
+func cloneValue(element RespDataType) RespDataType {
+	switch element := element.(type) {
+	case RespArray:
+		elements := make([]RespDataType, len(element.Elements))
+		for i, subElement := range element.Elements {
+			elements[i] = cloneValue(subElement)
+		}
+		return RespArray{elements}
+	case *Stream:
+		return element.clone()
+	case *HyperLogLog:
+		return element.clone()
+	case *SortedSet:
+		return element.clone()
+	case *Hash:
+		return element.clone()
+	case *Set:
+		return element.clone()
+	default:
+		return element
+	}
+}
+
 type Command interface {
 }
 
 type SetCommand struct {
+	db                  int
 	key                 string
 	value               RespDataType
 	expirationTimestamp int64
@@ -309,39 +1279,46 @@ type SetCommand struct {
 }
 
 type GetCommand struct {
+	db         int
 	key        string
 	rspChannel chan RespDataType
 }
 
 type ExistsCommand struct {
+	db         int
 	key        string
 	rspChannel chan bool
 }
 
 type DeleteCommand struct {
+	db         int
 	key        string
 	rspChannel chan bool
 }
 
 type IncrementCommand struct {
+	db           int
 	key          string
 	rspChannel   chan RespString
 	errorChannel chan error
 }
 
 type DecrementCommand struct {
+	db           int
 	key          string
 	rspChannel   chan RespString
 	errorChannel chan error
 }
 
 type LPushCommand struct {
+	db           int
 	key          string
 	value        RespDataType
 	errorChannel chan error
 }
 
 type RPushCommand struct {
+	db           int
 	key          string
 	value        RespDataType
 	errorChannel chan error
@@ -355,3 +1332,115 @@ type LoadCommand struct {
 	data         []byte
 	errorChannel chan error
 }
+
+type EncodingCommand struct {
+	db            int
+	key           string
+	rspChannel    chan string
+	existsChannel chan bool
+}
+
+type KeysCommand struct {
+	db         int
+	pattern    string
+	rspChannel chan []string
+}
+
+type ScanCommand struct {
+	db            int
+	cursor        uint64
+	pattern       string
+	count         int
+	typeName      string
+	rspChannel    chan []string
+	cursorChannel chan uint64
+}
+
+type TypeCommand struct {
+	db         int
+	key        string
+	rspChannel chan string
+}
+
+type RandomKeyCommand struct {
+	db            int
+	rspChannel    chan string
+	existsChannel chan bool
+}
+
+type DBSizeCommand struct {
+	db         int
+	rspChannel chan int
+}
+
+type CollectionScanCommand struct {
+	db            int
+	key           string
+	typeName      string
+	cursor        uint64
+	pattern       string
+	count         int
+	rspChannel    chan []RespDataType
+	cursorChannel chan uint64
+	errorChannel  chan error
+}
+
+type RenameCommand struct {
+	db            int
+	srcKey        string
+	dstKey        string
+	onlyIfMissing bool
+	rspChannel    chan bool
+	errorChannel  chan error
+}
+
+type CopyCommand struct {
+	db           int
+	srcKey       string
+	dstKey       string
+	dstDB        int
+	replace      bool
+	rspChannel   chan bool
+	errorChannel chan error
+}
+
+type MoveCommand struct {
+	db           int
+	key          string
+	dstDB        int
+	rspChannel   chan bool
+	errorChannel chan error
+}
+
+type FlushCommand struct {
+	db           int
+	allDatabases bool
+	async        bool
+	done         chan struct{}
+}
+
+type SwapDBCommand struct {
+	db1          int
+	db2          int
+	errorChannel chan error
+}
+
+type TransactionCommand struct {
+	commands chan Command
+}
+
+type WatchCommand struct {
+	db         int
+	keys       []string
+	rspChannel chan []WatchedKey
+}
+
+type UnwatchCommand struct {
+	watchedKeys []WatchedKey
+	done        chan struct{}
+}
+
+type WatchedKeysChangedCommand struct {
+	watchedKeys []WatchedKey
+	rspChannel  chan bool
+}
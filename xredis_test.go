@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"encoding/gob"
+	"strconv"
 	"testing"
 	"time"
 
@@ -165,3 +168,362 @@ func TestSaveAndLoad(t *testing.T) {
 	assert.Equal(t, RespString{"1"}, xredis2.Get("key2"))
 	assert.Equal(t, RespArray{[]RespDataType{RespString{"xxxx"}, RespString{"2"}}}, xredis2.Get("key3"))
 }
+
+func TestNumericStringsAreIntEncoded(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("counter", RespString{"10"})
+	encoding, exists := xredis.Encoding("counter")
+	assert.True(t, exists)
+	assert.Equal(t, ENCODING_INT, encoding)
+	assert.Equal(t, RespString{"10"}, xredis.Get("counter"))
+
+	xredis.Set("padded", RespString{"010"})
+	encoding, _ = xredis.Encoding("padded")
+	assert.Equal(t, ENCODING_EMBSTR, encoding)
+	assert.Equal(t, RespString{"010"}, xredis.Get("padded"))
+}
+
+func TestIncrementKeepsIntEncoding(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Increment("counter")
+	encoding, _ := xredis.Encoding("counter")
+	assert.Equal(t, ENCODING_INT, encoding)
+	assert.Equal(t, RespString{"1"}, xredis.Get("counter"))
+}
+
+func TestEncodingOfMissingKey(t *testing.T) {
+	xredis := NewXRedis()
+
+	_, exists := xredis.Encoding("bla")
+	assert.False(t, exists)
+}
+
+func TestKeysCommand(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("user:1", RespString{"a"})
+	xredis.Set("user:2", RespString{"b"})
+	xredis.Set("order:1", RespString{"c"})
+	assert.Equal(t, []string{"user:1", "user:2"}, xredis.Keys("user:*"))
+	assert.Equal(t, []string{"order:1", "user:1", "user:2"}, xredis.Keys("*"))
+}
+
+func TestScanCommandWithTypeFilter(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("string", RespString{"a"})
+	xredis.RPush("list", RespString{"b"})
+
+	keys, cursor := xredis.Scan(0, "", 10, TYPE_LIST)
+	assert.Equal(t, []string{"list"}, keys)
+	assert.Equal(t, uint64(0), cursor)
+}
+
+func TestScanCommandFollowsTheKeyspace(t *testing.T) {
+	xredis := NewXRedis()
+
+	for i := range 100 {
+		xredis.Set("stable-"+strconv.Itoa(i), RespString{"a"})
+		xredis.Set("deleted-"+strconv.Itoa(i), RespString{"b"})
+	}
+	var scanned []string
+	cursor := uint64(0)
+	for iteration := 0; ; iteration++ {
+		keys, nextCursor := xredis.Scan(cursor, "", 10, "")
+		scanned = append(scanned, keys...)
+		xredis.Delete("deleted-" + strconv.Itoa(iteration))
+		xredis.Set("added-"+strconv.Itoa(iteration), RespString{"c"})
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := range 100 {
+		assert.Contains(t, scanned, "stable-"+strconv.Itoa(i))
+	}
+
+	xredis.FlushDB(false)
+	xredis.Set("fresh", RespString{"d"})
+	keys, cursor := xredis.Scan(0, "", 10, "")
+	assert.Equal(t, []string{"fresh"}, keys)
+	assert.Equal(t, uint64(0), cursor)
+}
+
+func TestKeyspaceCommandsSkipExpiredKeys(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("alive", RespString{"a"})
+	xredis.SetWithExpiration("expired", RespString{"b"}, time.Now().Add(-time.Second))
+
+	assert.Equal(t, []string{"alive"}, xredis.Keys("*"))
+	assert.Equal(t, 1, xredis.DBSize())
+	assert.Equal(t, TYPE_NONE, xredis.Type("expired"))
+	key, exists := xredis.RandomKey()
+	assert.True(t, exists)
+	assert.Equal(t, "alive", key)
+}
+
+func TestRandomKeyOnEmptyDatabase(t *testing.T) {
+	xredis := NewXRedis()
+
+	_, exists := xredis.RandomKey()
+	assert.False(t, exists)
+}
+
+func TestScanCollectionCommand(t *testing.T) {
+	xredis := NewXRedis()
+
+	var members []string
+	for i := range 30 {
+		members = append(members, "member-"+strconv.Itoa(i))
+	}
+	xredis.SAdd("set", members)
+
+	var scanned []string
+	cursor := uint64(0)
+	for {
+		entries, nextCursor, err := xredis.ScanCollection("set", TYPE_SET, cursor, "member-1*", 4)
+		assert.Nil(t, err)
+		for _, entry := range entries {
+			scanned = append(scanned, entry.(RespString).Str)
+		}
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.ElementsMatch(t, []string{"member-1", "member-10", "member-11", "member-12", "member-13",
+		"member-14", "member-15", "member-16", "member-17", "member-18", "member-19"}, scanned)
+}
+
+func TestScanCollectionOnWrongType(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("string", RespString{"a"})
+	_, _, err := xredis.ScanCollection("string", TYPE_HASH, 0, "", 10)
+	assert.NotNil(t, err)
+	xredis.SAdd("set", []string{"a"})
+	_, _, err = xredis.ScanCollection("set", TYPE_HASH, 0, "", 10)
+	assert.NotNil(t, err)
+
+	entries, cursor, err := xredis.ScanCollection("missing", TYPE_HASH, 0, "", 10)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+	assert.Equal(t, uint64(0), cursor)
+}
+
+func TestRenamePreservesExpiration(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.SetWithExpiration("old", RespString{"bli"}, time.Now().Add(100*time.Millisecond))
+	renamed, err := xredis.Rename("old", "new", false)
+	assert.Nil(t, err)
+	assert.True(t, renamed)
+	assert.False(t, xredis.Exists("old"))
+	assert.Equal(t, RespString{"bli"}, xredis.Get("new"))
+
+	time.Sleep(105 * time.Millisecond)
+	assert.False(t, xredis.Exists("new"))
+}
+
+func TestRenameMissingKey(t *testing.T) {
+	xredis := NewXRedis()
+
+	_, err := xredis.Rename("old", "new", false)
+	assert.NotNil(t, err)
+}
+
+func TestRenameOnlyIfMissing(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("old", RespString{"a"})
+	xredis.Set("new", RespString{"b"})
+	renamed, err := xredis.Rename("old", "new", true)
+	assert.Nil(t, err)
+	assert.False(t, renamed)
+	assert.Equal(t, RespString{"b"}, xredis.Get("new"))
+}
+
+func TestCopyIsIndependentOfSource(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.RPush("src", RespString{"a"})
+	copied, err := xredis.Copy("src", "dst", DEFAULT_DATABASE, false)
+	assert.Nil(t, err)
+	assert.True(t, copied)
+
+	xredis.RPush("dst", RespString{"b"})
+	assert.Equal(t, RespArray{[]RespDataType{RespString{"a"}}}, xredis.Get("src"))
+	assert.Equal(t, RespArray{[]RespDataType{RespString{"a"}, RespString{"b"}}}, xredis.Get("dst"))
+}
+
+func TestCopyReplace(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("src", RespString{"a"})
+	xredis.Set("dst", RespString{"b"})
+	copied, _ := xredis.Copy("src", "dst", DEFAULT_DATABASE, false)
+	assert.False(t, copied)
+	copied, _ = xredis.Copy("src", "dst", DEFAULT_DATABASE, true)
+	assert.True(t, copied)
+	assert.Equal(t, RespString{"a"}, xredis.Get("dst"))
+}
+
+func TestSelectIsolatesDatabases(t *testing.T) {
+	xredis := NewXRedis()
+
+	db1, err := xredis.Select(1)
+	assert.Nil(t, err)
+	db1.Set("bla", RespString{"bli"})
+	assert.False(t, xredis.Exists("bla"))
+	assert.True(t, db1.Exists("bla"))
+
+	_, err = xredis.Select(DEFAULT_DATABASES_NUMBER)
+	assert.NotNil(t, err)
+}
+
+func TestMoveAcrossDatabases(t *testing.T) {
+	xredis := NewXRedis()
+	db1, _ := xredis.Select(1)
+
+	xredis.Set("bla", RespString{"bli"})
+	moved, err := xredis.Move("bla", 1)
+	assert.Nil(t, err)
+	assert.True(t, moved)
+	assert.False(t, xredis.Exists("bla"))
+	assert.Equal(t, RespString{"bli"}, db1.Get("bla"))
+
+	_, err = db1.Move("bla", 1)
+	assert.NotNil(t, err)
+}
+
+func TestCopyToAnotherDatabase(t *testing.T) {
+	xredis := NewXRedis()
+	db1, _ := xredis.Select(1)
+
+	xredis.Set("bla", RespString{"bli"})
+	copied, err := xredis.Copy("bla", "bla", 1, false)
+	assert.Nil(t, err)
+	assert.True(t, copied)
+	assert.Equal(t, RespString{"bli"}, xredis.Get("bla"))
+	assert.Equal(t, RespString{"bli"}, db1.Get("bla"))
+}
+
+func TestFlushDBAndFlushAll(t *testing.T) {
+	xredis := NewXRedis()
+	db1, _ := xredis.Select(1)
+
+	xredis.Set("bla", RespString{"bli"})
+	db1.Set("bla", RespString{"bli"})
+	xredis.FlushDB(false)
+	assert.False(t, xredis.Exists("bla"))
+	assert.True(t, db1.Exists("bla"))
+
+	xredis.Set("bla", RespString{"bli"})
+	xredis.FlushAll(true)
+	assert.False(t, xredis.Exists("bla"))
+	assert.False(t, db1.Exists("bla"))
+}
+
+func TestSwapDB(t *testing.T) {
+	xredis := NewXRedis()
+	db1, _ := xredis.Select(1)
+
+	xredis.Set("bla", RespString{"bli"})
+	err := xredis.SwapDB(0, 1)
+	assert.Nil(t, err)
+	assert.False(t, xredis.Exists("bla"))
+	assert.True(t, db1.Exists("bla"))
+
+	err = xredis.SwapDB(0, DEFAULT_DATABASES_NUMBER)
+	assert.NotNil(t, err)
+}
+
+func TestSaveAndLoadAllDatabases(t *testing.T) {
+	xredis1 := NewXRedis()
+	db1, _ := xredis1.Select(1)
+	xredis1.Set("key1", RespString{"xxxx"})
+	db1.Set("key2", RespString{"yyyy"})
+	data := xredis1.Serialize()
+
+	xredis2 := NewXRedis()
+	xredis2.Load(data)
+	db1, _ = xredis2.Select(1)
+	assert.Equal(t, RespString{"xxxx"}, xredis2.Get("key1"))
+	assert.Equal(t, RespString{"yyyy"}, db1.Get("key2"))
+	assert.False(t, xredis2.Exists("key2"))
+}
+
+func TestLoadSingleDatabaseDump(t *testing.T) {
+	var buf bytes.Buffer
+	legacyDatabase := map[string]XRedisValue{"key1": {RespString{"xxxx"}, NON_EXPIRATION_TIME}}
+	xredis := NewXRedis()
+	gob.NewEncoder(&buf).Encode(legacyDatabase)
+
+	xredis.Load(buf.Bytes())
+	assert.Equal(t, RespString{"xxxx"}, xredis.Get("key1"))
+}
+
+func TestAtomicallyBlocksOtherCommands(t *testing.T) {
+	xredis := NewXRedis()
+
+	setDone := make(chan struct{})
+	xredis.Atomically(func(tx *XRedis) {
+		tx.Set("bla", RespString{"1"})
+		go func() {
+			xredis.Set("bla", RespString{"2"})
+			close(setDone)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, RespString{"1"}, tx.Get("bla"))
+	})
+	<-setDone
+	assert.Equal(t, RespString{"2"}, xredis.Get("bla"))
+}
+
+func TestWatchedKeysChanged(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("bla", RespString{"bli"})
+	watchedKeys := xredis.Watch([]string{"bla", "missing"})
+	assert.False(t, xredis.WatchedKeysChanged(watchedKeys))
+
+	xredis.Set("bla", RespString{"blo"})
+	assert.True(t, xredis.WatchedKeysChanged(watchedKeys))
+	xredis.Unwatch(watchedKeys)
+}
+
+func TestWatchedKeysChangedOnExpiration(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.SetWithExpiration("bla", RespString{"bli"}, time.Now().Add(10*time.Millisecond))
+	watchedKeys := xredis.Watch([]string{"bla"})
+	time.Sleep(15 * time.Millisecond)
+	assert.True(t, xredis.WatchedKeysChanged(watchedKeys))
+}
+
+func TestWatchedKeysChangedOnFlush(t *testing.T) {
+	xredis := NewXRedis()
+
+	xredis.Set("bla", RespString{"bli"})
+	watchedKeys := xredis.Watch([]string{"bla"})
+	xredis.FlushAll(false)
+	assert.True(t, xredis.WatchedKeysChanged(watchedKeys))
+}
+
+func TestUnwatchReleasesKeyVersions(t *testing.T) {
+	xredis := NewXRedis()
+
+	watchedKeys1 := xredis.Watch([]string{"bla"})
+	watchedKeys2 := xredis.Watch([]string{"bla"})
+	xredis.Unwatch(watchedKeys1)
+	xredis.Set("bla", RespString{"bli"})
+	assert.True(t, xredis.WatchedKeysChanged(watchedKeys2))
+
+	xredis.Unwatch(watchedKeys2)
+	xredis.Atomically(func(tx *XRedis) {
+		assert.Empty(t, xredis.keyVersions[DEFAULT_DATABASE])
+	})
+}